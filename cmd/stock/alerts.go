@@ -0,0 +1,770 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	g "maragu.dev/gomponents"
+	// Importing this as '.' is intentional for cleaner HTML like code.
+	. "maragu.dev/gomponents/html"
+)
+
+// Alerts are rules over the metric cards, written the way you'd say them:
+//
+//	AAPL price < 150
+//	MSFT P/E crosses 30
+//	any of NVDA, AMD, INTC turns red on Current Ratio
+//
+// Each user's rules are kept with what they last saw and the alerts they
+// raised in alerts.json in their userDataDir; with auth.login: none everyone
+// shares alerts.json in the data dir. A scheduler fetches every symbol any
+// rule watches and scores it with buildMetricsList, so a rule sees exactly
+// what the stock page shows.
+
+// Conditions a rule can watch for.
+const (
+	alertBelow   = "<"
+	alertAbove   = ">"
+	alertCrosses = "crosses"
+	alertTurns   = "turns"
+)
+
+const (
+	maxAlertSymbols = 20
+	// Per alerts file, so one user can't make the scheduler fetch without
+	// bound.
+	maxAlertRules   = 50
+	maxAlertWatched = 100
+	// Fired alerts kept in the history, newest first.
+	alertHistoryLimit = 500
+)
+
+// Set from alerts.interval and alerts.cooldown. An interval of 0 turns
+// checking off.
+var (
+	alertInterval = 15 * time.Minute
+	alertCooldown = 24 * time.Hour
+)
+
+// Serializes reads and writes of the alerts file.
+var alertMu sync.Mutex
+
+type alertRule struct {
+	ID        string          `json:"id"`
+	Symbols   []common.Symbol `json:"symbols"`
+	Metric    string          `json:"metric"`
+	Condition string          `json:"condition"`
+	// The threshold for <, > and crosses.
+	Value float64 `json:"value,omitempty"`
+	// The color for turns.
+	Color    string        `json:"color,omitempty"`
+	Cooldown time.Duration `json:"cooldown"`
+	Created  time.Time     `json:"created"`
+	// What the rule saw at its last check, by symbol.
+	State map[common.Symbol]*alertState `json:"state,omitempty"`
+}
+
+type alertState struct {
+	Value   float64   `json:"value"`
+	Color   string    `json:"color,omitempty"`
+	Checked time.Time `json:"checked"`
+	// Whether a < or > condition held, so it fires once when it starts to
+	// hold rather than at every check while it does.
+	Met   bool       `json:"met,omitempty"`
+	Fired *time.Time `json:"fired,omitempty"`
+}
+
+type alertEvent struct {
+	Time    time.Time     `json:"time"`
+	RuleID  string        `json:"rule_id"`
+	Symbol  common.Symbol `json:"symbol"`
+	Message string        `json:"message"`
+	Value   float64       `json:"value"`
+	Color   string        `json:"color,omitempty"`
+}
+
+type alertFile struct {
+	Rules []*alertRule `json:"rules"`
+	// Newest first.
+	History []alertEvent `json:"history"`
+}
+
+const alertsFile = "alerts.json"
+
+// alertsPath is u's alerts file, or the shared one when nobody is logged in.
+func alertsPath(u *User) string {
+	if u == nil {
+		return filepath.Join(g_dataDir, alertsFile)
+	}
+	return filepath.Join(userDataDir(u), alertsFile)
+}
+
+// allAlertsPaths lists every alerts file for the scheduler.
+func allAlertsPaths() []string {
+	paths, _ := filepath.Glob(filepath.Join(g_dataDir, "users", "*", alertsFile))
+	return append([]string{alertsPath(nil)}, paths...)
+}
+
+// loadAlerts reads an alerts file. A missing file has no rules.
+func loadAlerts(path string) (*alertFile, error) {
+	f := &alertFile{}
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return f, nil
+}
+
+func saveAlerts(path string, f *alertFile) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writePrivateFile(path, data)
+}
+
+// alertMetricName finds the metric card name meant by name, ignoring case and
+// a missing " Ratio", so "price" is Price and "P/E" is P/E Ratio.
+func alertMetricName(name string) (string, bool) {
+	names := append([]string{}, infoMetrics...)
+	for n := range metricRules {
+		names = append(names, n)
+	}
+	for _, suffix := range []string{"", " Ratio"} {
+		for _, n := range names {
+			if strings.EqualFold(n, name+suffix) {
+				return n, true
+			}
+		}
+	}
+	return "", false
+}
+
+const alertSymbolList = `((?:[A-Za-z0-9.^=&/-]+\s*,\s*)*[A-Za-z0-9.^=&/-]+)`
+
+var (
+	alertTurnsRe   = regexp.MustCompile(`(?i)^(?:any\s+of\s+)?` + alertSymbolList + `\s+turns\s+(green|yellow|red)\s+on\s+(.+)$`)
+	alertCompareRe = regexp.MustCompile(`(?i)^(?:any\s+of\s+)?` + alertSymbolList + `\s+(.+?)\s*(<|>|\bcrosses\b)\s*(-?[0-9]*\.?[0-9]+)(%?)$`)
+)
+
+// parseAlertRule reads a rule as written in the UI or posted to the API.
+// Percentages may be written as 15% or as the fraction 0.15. An empty
+// cooldown uses alerts.cooldown.
+func parseAlertRule(text, cooldown string) (*alertRule, error) {
+	text = strings.TrimSpace(text)
+	r := &alertRule{Cooldown: alertCooldown}
+	if cooldown = strings.TrimSpace(cooldown); cooldown != "" {
+		d, err := time.ParseDuration(cooldown)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("cooldown %q is not a duration like 4h or 30m", cooldown)
+		}
+		r.Cooldown = d
+	}
+	var symbols, metric string
+	if m := alertTurnsRe.FindStringSubmatch(text); m != nil {
+		symbols, r.Condition, r.Color, metric = m[1], alertTurns, strings.ToLower(m[2]), m[3]
+	} else if m := alertCompareRe.FindStringSubmatch(text); m != nil {
+		symbols, metric, r.Condition = m[1], m[2], strings.ToLower(m[3])
+		v, err := strconv.ParseFloat(m[4], 64)
+		if err != nil {
+			return nil, err
+		}
+		if m[5] == "%" {
+			v /= 100
+		}
+		r.Value = v
+	} else {
+		return nil, fmt.Errorf("%q is not a rule like \"AAPL price < 150\", \"MSFT P/E crosses 30\" or \"any of NVDA, AMD turns red on Current Ratio\"", text)
+	}
+
+	name, ok := alertMetricName(strings.TrimSpace(metric))
+	if !ok {
+		return nil, fmt.Errorf("unknown metric %q", strings.TrimSpace(metric))
+	}
+	if _, scored := metricRules[name]; r.Condition == alertTurns && !scored {
+		return nil, fmt.Errorf("%s has no colors to turn", name)
+	}
+	r.Metric = name
+
+	seen := map[common.Symbol]bool{}
+	for _, s := range strings.Split(symbols, ",") {
+		symbol, err := common.ParseSymbol(s)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[symbol] {
+			seen[symbol] = true
+			r.Symbols = append(r.Symbols, symbol)
+		}
+	}
+	if len(r.Symbols) > maxAlertSymbols {
+		return nil, fmt.Errorf("at most %d symbols per rule", maxAlertSymbols)
+	}
+	return r, nil
+}
+
+// String writes the rule the way parseAlertRule reads it.
+func (r *alertRule) String() string {
+	var symbols []string
+	for _, s := range r.Symbols {
+		symbols = append(symbols, s.String())
+	}
+	who := strings.Join(symbols, ", ")
+	if len(symbols) > 1 {
+		who = "any of " + who
+	}
+	if r.Condition == alertTurns {
+		return fmt.Sprintf("%s turns %s on %s", who, r.Color, r.Metric)
+	}
+	return fmt.Sprintf("%s %s %s %s", who, r.Metric, r.Condition, strconv.FormatFloat(r.Value, 'f', -1, 64))
+}
+
+// check records what the rule sees for symbol now and returns the alert if
+// it fires. A rule that fired for symbol stays quiet for its cooldown.
+func (r *alertRule) check(symbol common.Symbol, m Metric, now time.Time) *alertEvent {
+	if r.State == nil {
+		r.State = map[common.Symbol]*alertState{}
+	}
+	prev, seen := r.State[symbol]
+	next := &alertState{Value: m.Raw, Color: m.Color, Checked: now}
+	if seen {
+		next.Fired = prev.Fired
+	}
+	r.State[symbol] = next
+
+	threshold := strconv.FormatFloat(r.Value, 'f', -1, 64)
+	var fire bool
+	var message string
+	switch r.Condition {
+	case alertBelow, alertAbove:
+		next.Met = (r.Condition == alertBelow && m.Raw < r.Value) || (r.Condition == alertAbove && m.Raw > r.Value)
+		fire = next.Met && !(seen && prev.Met)
+		message = fmt.Sprintf("%s %s is %s, %s %s", symbol, r.Metric, m.Value, map[string]string{alertBelow: "below", alertAbove: "above"}[r.Condition], threshold)
+	case alertCrosses:
+		// Crossing and turning need a previous check to compare with.
+		fire = seen && (prev.Value < r.Value) != (m.Raw < r.Value)
+		direction := "above"
+		if m.Raw < r.Value {
+			direction = "below"
+		}
+		message = fmt.Sprintf("%s %s crossed %s %s, now %s", symbol, r.Metric, direction, threshold, m.Value)
+	case alertTurns:
+		fire = seen && prev.Color != r.Color && m.Color == r.Color
+		if seen {
+			message = fmt.Sprintf("%s %s turned %s from %s, now %s", symbol, r.Metric, r.Color, firstNonEmpty(prev.Color, "unscored"), m.Value)
+		}
+	}
+	if !fire || (next.Fired != nil && now.Sub(*next.Fired) < r.Cooldown) {
+		return nil
+	}
+	next.Fired = &now
+	return &alertEvent{Time: now, RuleID: r.ID, Symbol: symbol, Message: message, Value: m.Raw, Color: m.Color}
+}
+
+// alertMetric finds name among the symbol's metric cards.
+func alertMetric(metrics []Metric, name string) (Metric, bool) {
+	for _, m := range metrics {
+		// A NaN can't be compared, e.g. a PEG ratio without earnings growth.
+		if m.Name == name && !math.IsNaN(m.Raw) {
+			return m, true
+		}
+	}
+	return Metric{}, false
+}
+
+// evaluateAlerts checks every rule against freshly scored metrics and
+// returns the alerts that fired. Symbols that can't be fetched are skipped
+// until the next run.
+func evaluateAlerts(ctx context.Context, now time.Time) ([]alertEvent, error) {
+	paths := allAlertsPaths()
+	var symbols []common.Symbol
+	alertMu.Lock()
+	for _, path := range paths {
+		f, err := loadAlerts(path)
+		if err != nil {
+			slog.WarnContext(ctx, "Could not load alerts", "err", err)
+			continue
+		}
+		for _, r := range f.Rules {
+			symbols = append(symbols, r.Symbols...)
+		}
+	}
+	alertMu.Unlock()
+
+	// Fetched once for all users, without holding the lock, so the UI stays
+	// responsive.
+	metrics := map[common.Symbol][]Metric{}
+	for _, symbol := range symbols {
+		if _, done := metrics[symbol]; done {
+			continue
+		}
+		metrics[symbol] = nil
+		result, err := fetchStockMetrics(ctx, symbol)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			slog.WarnContext(ctx, "Could not fetch symbol for alerts", "ticker", symbol, "err", err)
+			continue
+		}
+		metrics[symbol] = buildMetricsList(result)
+	}
+
+	var fired []alertEvent
+	var errs []error
+	for _, path := range paths {
+		events, err := checkAlerts(ctx, path, metrics, now)
+		fired = append(fired, events...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return fired, errors.Join(errs...)
+}
+
+// checkAlerts checks the rules of one alerts file against metrics and saves
+// what they saw.
+func checkAlerts(ctx context.Context, path string, metrics map[common.Symbol][]Metric, now time.Time) ([]alertEvent, error) {
+	alertMu.Lock()
+	defer alertMu.Unlock()
+	// Rules may have been added or deleted while fetching.
+	f, err := loadAlerts(path)
+	if err != nil || len(f.Rules) == 0 {
+		return nil, err
+	}
+	var fired []alertEvent
+	for _, r := range f.Rules {
+		for _, symbol := range r.Symbols {
+			m, ok := alertMetric(metrics[symbol], r.Metric)
+			if !ok {
+				continue
+			}
+			if e := r.check(symbol, m, now); e != nil {
+				slog.InfoContext(ctx, "Alert fired", "rule", r.String(), "ticker", symbol, "message", e.Message)
+				fired = append(fired, *e)
+			}
+		}
+	}
+	history := make([]alertEvent, 0, len(fired)+len(f.History))
+	for i := len(fired) - 1; i >= 0; i-- {
+		history = append(history, fired[i])
+	}
+	f.History = append(history, f.History...)
+	f.History = f.History[:min(len(f.History), alertHistoryLimit)]
+	return fired, saveAlerts(path, f)
+}
+
+// runAlerts evaluates the rules every interval until ctx is done.
+func runAlerts(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := evaluateAlerts(ctx, now); err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "Could not evaluate alerts", "err", err)
+			}
+		}
+	}
+}
+
+var (
+	errAlertNotFound  = errors.New("no alert with that id")
+	errTooManyAlerts  = fmt.Errorf("at most %d alerts each", maxAlertRules)
+	errTooManyWatched = fmt.Errorf("alerts can watch at most %d symbols in all", maxAlertWatched)
+	// With a login configured, anonymous callers have no alerts of their own.
+	errAlertsNeedLogin = errors.New("log in, or send your username and password, to manage alerts")
+)
+
+// alertsUser is whose alerts a request manages.
+func alertsUser(r *http.Request) (*User, error) {
+	u := currentUser(r.Context())
+	if u == nil && loginMode != "none" {
+		return nil, errAlertsNeedLogin
+	}
+	return u, nil
+}
+
+// addAlert saves a new rule to u's alerts.
+func addAlert(u *User, r *alertRule, now time.Time) error {
+	id, err := randomHex(4)
+	if err != nil {
+		return err
+	}
+	r.ID, r.Created = id, now
+	alertMu.Lock()
+	defer alertMu.Unlock()
+	f, err := loadAlerts(alertsPath(u))
+	if err != nil {
+		return err
+	}
+	if len(f.Rules) >= maxAlertRules {
+		return errTooManyAlerts
+	}
+	watched := map[common.Symbol]bool{}
+	for _, rule := range append(f.Rules, r) {
+		for _, s := range rule.Symbols {
+			watched[s] = true
+		}
+	}
+	if len(watched) > maxAlertWatched {
+		return errTooManyWatched
+	}
+	f.Rules = append(f.Rules, r)
+	return saveAlerts(alertsPath(u), f)
+}
+
+// deleteAlert removes one of u's rules. Its history is kept.
+func deleteAlert(u *User, id string) error {
+	alertMu.Lock()
+	defer alertMu.Unlock()
+	f, err := loadAlerts(alertsPath(u))
+	if err != nil {
+		return err
+	}
+	for i, r := range f.Rules {
+		if r.ID == id {
+			f.Rules = append(f.Rules[:i], f.Rules[i+1:]...)
+			return saveAlerts(alertsPath(u), f)
+		}
+	}
+	return errAlertNotFound
+}
+
+// listAlerts returns u's rules, oldest first, and history.
+func listAlerts(u *User) (*alertFile, error) {
+	alertMu.Lock()
+	defer alertMu.Unlock()
+	f, err := loadAlerts(alertsPath(u))
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(f.Rules, func(i, j int) bool { return f.Rules[i].Created.Before(f.Rules[j].Created) })
+	return f, nil
+}
+
+type APIAlertRequest struct {
+	Rule     string `json:"rule" doc:"e.g. AAPL price < 150, MSFT P/E crosses 30 or any of NVDA, AMD, INTC turns red on Current Ratio. Percentages may be written 15% or 0.15."`
+	Cooldown string `json:"cooldown,omitempty" doc:"How long the rule stays quiet for a symbol after firing, e.g. 4h. Defaults to the server's alerts.cooldown."`
+}
+
+type APIAlert struct {
+	ID        string          `json:"id"`
+	Rule      string          `json:"rule" doc:"The rule in canonical form."`
+	Symbols   []string        `json:"symbols"`
+	Metric    string          `json:"metric" doc:"Metric card name, e.g. P/E Ratio."`
+	Condition string          `json:"condition" enum:"<,>,crosses,turns"`
+	Value     float64         `json:"value" doc:"Threshold for <, > and crosses. Percentages are fractions."`
+	Color     string          `json:"color,omitempty" enum:"green,yellow,red" doc:"Only for turns."`
+	Cooldown  string          `json:"cooldown" doc:"Go duration, e.g. 24h0m0s."`
+	Created   string          `json:"created" doc:"RFC 3339."`
+	State     []APIAlertState `json:"state" doc:"What the rule saw for each symbol at its last check. Symbols not yet checked are left out."`
+}
+
+type APIAlertState struct {
+	Symbol  string  `json:"symbol"`
+	Value   float64 `json:"value"`
+	Color   string  `json:"color,omitempty" enum:"green,yellow,red"`
+	Checked string  `json:"checked" doc:"RFC 3339."`
+	Fired   string  `json:"fired,omitempty" doc:"When it last fired for this symbol, RFC 3339."`
+}
+
+type APIAlerts struct {
+	Alerts []APIAlert `json:"alerts" doc:"Oldest first."`
+}
+
+type APIAlertEvent struct {
+	Time    string  `json:"time" doc:"RFC 3339."`
+	RuleID  string  `json:"rule_id"`
+	Symbol  string  `json:"symbol"`
+	Message string  `json:"message"`
+	Value   float64 `json:"value"`
+	Color   string  `json:"color,omitempty" enum:"green,yellow,red"`
+}
+
+type APIAlertHistory struct {
+	Events []APIAlertEvent `json:"events" doc:"Newest first."`
+}
+
+func toAPIAlerts(f *alertFile) APIAlerts {
+	out := APIAlerts{Alerts: []APIAlert{}}
+	for _, r := range f.Rules {
+		a := APIAlert{
+			ID:        r.ID,
+			Rule:      r.String(),
+			Metric:    r.Metric,
+			Condition: r.Condition,
+			Value:     r.Value,
+			Color:     r.Color,
+			Cooldown:  r.Cooldown.String(),
+			Created:   r.Created.Format(time.RFC3339),
+			State:     []APIAlertState{},
+		}
+		for _, s := range r.Symbols {
+			a.Symbols = append(a.Symbols, s.String())
+			st, ok := r.State[s]
+			if !ok {
+				continue
+			}
+			state := APIAlertState{Symbol: s.String(), Value: st.Value, Color: st.Color, Checked: st.Checked.Format(time.RFC3339)}
+			if st.Fired != nil {
+				state.Fired = st.Fired.Format(time.RFC3339)
+			}
+			a.State = append(a.State, state)
+		}
+		out.Alerts = append(out.Alerts, a)
+	}
+	return out
+}
+
+func toAPIAlertEvents(events []alertEvent, ruleID string) []APIAlertEvent {
+	out := []APIAlertEvent{}
+	for _, e := range events {
+		if ruleID != "" && e.RuleID != ruleID {
+			continue
+		}
+		out = append(out, APIAlertEvent{e.Time.Format(time.RFC3339), e.RuleID, e.Symbol.String(), e.Message, e.Value, e.Color})
+	}
+	return out
+}
+
+func apiV1AlertsHandler(w http.ResponseWriter, r *http.Request) {
+	u, err := alertsUser(r)
+	if err != nil {
+		writeAPIError(w, http.StatusUnauthorized, errCodeUnauthorized, err.Error())
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req APIAlertRequest
+		if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<16)).Decode(&req); err != nil {
+			writeAPIError(w, http.StatusBadRequest, errCodeInvalidAlert, fmt.Sprintf("invalid JSON body: %v", err))
+			return
+		}
+		rule, err := parseAlertRule(req.Rule, req.Cooldown)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, errCodeInvalidAlert, err.Error())
+			return
+		}
+		err = addAlert(u, rule, time.Now())
+		if errors.Is(err, errTooManyAlerts) || errors.Is(err, errTooManyWatched) {
+			writeAPIError(w, http.StatusBadRequest, errCodeTooManyAlerts, err.Error())
+			return
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+			return
+		}
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			writeAPIError(w, http.StatusBadRequest, errCodeMissingID, "id parameter required")
+			return
+		}
+		err := deleteAlert(u, id)
+		if errors.Is(err, errAlertNotFound) {
+			writeAPIError(w, http.StatusNotFound, errCodeNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+			return
+		}
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "only GET, POST and DELETE are supported")
+		return
+	}
+	f, err := listAlerts(u)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, toAPIAlerts(f))
+}
+
+func apiV1AlertHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "only GET is supported")
+		return
+	}
+	u, err := alertsUser(r)
+	if err != nil {
+		writeAPIError(w, http.StatusUnauthorized, errCodeUnauthorized, err.Error())
+		return
+	}
+	f, err := listAlerts(u)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, APIAlertHistory{Events: toAPIAlertEvents(f.History, r.URL.Query().Get("id"))})
+}
+
+// How many fired alerts the alerts page lists.
+const alertPageHistory = 50
+
+// alertsHandler is the page for managing the rules. Its forms post back to
+// it to add or delete one.
+func alertsHandler(w http.ResponseWriter, r *http.Request) {
+	// requireLogin has already sent anonymous visitors to /login.
+	u := currentUser(r.Context())
+	var message string
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var err error
+		if r.FormValue("action") == "delete" {
+			err = deleteAlert(u, r.FormValue("id"))
+		} else {
+			var rule *alertRule
+			if rule, err = parseAlertRule(r.FormValue("rule"), r.FormValue("cooldown")); err == nil {
+				err = addAlert(u, rule, time.Now())
+			}
+		}
+		if err == nil {
+			http.Redirect(w, r, "/alerts", http.StatusSeeOther)
+			return
+		}
+		message = err.Error()
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	f, err := listAlerts(u)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	if message != "" {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	alertsPage(f, r.FormValue("rule"), r.FormValue("cooldown"), message, u).Render(w)
+}
+
+var alertColorClass = map[string]string{"green": "text-green-300", "yellow": "text-yellow-300", "red": "text-red-300"}
+
+func alertTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04 UTC")
+}
+
+func alertRulesTable(rules []*alertRule) g.Node {
+	if len(rules) == 0 {
+		return P(Class("text-sm text-gray-500"), g.Text("No alerts yet."))
+	}
+	var rows []g.Node
+	for _, r := range rules {
+		var states []g.Node
+		for _, s := range r.Symbols {
+			st, ok := r.State[s]
+			if !ok {
+				states = append(states, Span(Class("mr-3 text-gray-500"), g.Textf("%s not checked yet", s)))
+				continue
+			}
+			fired := ""
+			if st.Fired != nil {
+				fired = ", fired " + alertTime(*st.Fired)
+			}
+			states = append(states, Span(Class("mr-3"), TitleAttr("Checked "+alertTime(st.Checked)),
+				g.Textf("%s ", s),
+				Span(Class(firstNonEmpty(alertColorClass[st.Color], "text-gray-300")), g.Text(common.FormatLargeNumber(st.Value))),
+				g.Text(fired),
+			))
+		}
+		rows = append(rows, Tr(Class("border-t border-gray-700 align-top"),
+			Td(Class("py-2 pr-2"),
+				Div(Class("text-gray-100"), g.Text(r.String())),
+				Div(Class("text-xs text-gray-500"), g.Textf("Quiet for %s after firing", r.Cooldown)),
+			),
+			Td(Class("py-2 pr-2 text-xs text-gray-400"), g.Group(states)),
+			Td(Class("py-2 text-right"),
+				FormEl(Method("post"), Action("/alerts"),
+					Input(Type("hidden"), Name("action"), Value("delete")),
+					Input(Type("hidden"), Name("id"), Value(r.ID)),
+					Button(Type("submit"), Class("text-xs text-red-400 hover:underline"), g.Text("Delete")),
+				),
+			),
+		))
+	}
+	return Table(Class("w-full text-sm"), TBody(g.Group(rows)))
+}
+
+func alertHistoryTable(events []alertEvent) g.Node {
+	if len(events) == 0 {
+		return P(Class("text-sm text-gray-500"), g.Text("No alerts have fired."))
+	}
+	var rows []g.Node
+	for _, e := range events[:min(len(events), alertPageHistory)] {
+		rows = append(rows, Tr(Class("border-t border-gray-700"),
+			Td(Class("py-1 pr-2 text-gray-400 whitespace-nowrap"), g.Text(alertTime(e.Time))),
+			Td(Class("py-1 "+firstNonEmpty(alertColorClass[e.Color], "text-gray-300")), g.Text(e.Message)),
+		))
+	}
+	return Table(Class("w-full text-sm"), TBody(g.Group(rows)))
+}
+
+func alertsPage(f *alertFile, rule, cooldown, message string, user *User) g.Node {
+	schedule := fmt.Sprintf("Rules are checked every %s with the same scoring as the stock page.", alertInterval)
+	if alertInterval == 0 {
+		schedule = "Checking is turned off (alerts.interval is 0)."
+	}
+	return HTML(
+		Head(
+			Meta(Charset("UTF-8")),
+			Meta(Name("viewport"), Content("width=device-width, initial-scale=1.0")),
+			TitleEl(g.Text("Alerts - Stock Metrics Analyzer")),
+			Script(Src("https://cdn.tailwindcss.com")),
+		),
+		Body(Class("bg-gray-900 text-gray-200 min-h-screen"),
+			Div(Class("container mx-auto px-4 py-8 max-w-4xl space-y-6"),
+				userBadge(user),
+				Div(Class("flex items-center justify-between"),
+					H1(Class("text-3xl font-bold text-white"), g.Text("Alerts")),
+					A(Href("/"), Class("text-blue-400 hover:underline text-sm"), g.Text("← New Search")),
+				),
+				fundSection("New Alert",
+					FormEl(Method("post"), Action("/alerts"), Class("space-y-3"),
+						Input(Type("hidden"), Name("action"), Value("create")),
+						Div(Class("flex gap-2"),
+							Input(Type("text"), Name("rule"), Value(rule), Required(), AutoComplete("off"),
+								Placeholder("e.g. AAPL price < 150, MSFT P/E crosses 30, any of NVDA, AMD turns red on Current Ratio"),
+								Class("flex-1 px-3 py-2 rounded bg-gray-700 text-gray-100 focus:outline-none")),
+							Input(Type("text"), Name("cooldown"), Value(cooldown), Placeholder(alertCooldown.String()), TitleAttr("Cooldown after firing"),
+								Class("w-24 px-3 py-2 rounded bg-gray-700 text-gray-100 focus:outline-none")),
+							Button(Type("submit"), Class("px-4 py-2 rounded bg-blue-600 hover:bg-blue-500"), g.Text("Add")),
+						),
+						g.If(message != "", P(Class("text-sm text-red-400"), g.Text(message))),
+						P(Class("text-xs text-gray-500"), g.Text(schedule+" < and > fire when the condition starts to hold, crosses when the value moves to the other side, and turns when the card changes to that color.")),
+					),
+				),
+				fundSection("Rules", alertRulesTable(f.Rules)),
+				fundSection("History", alertHistoryTable(f.History)),
+			),
+		),
+	)
+}
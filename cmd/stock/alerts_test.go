@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAlertRule(t *testing.T) {
+	for text, want := range map[string]string{
+		"AAPL price < 150":    "AAPL Price < 150",
+		"msft P/E crosses 30": "MSFT P/E Ratio crosses 30",
+		"any of NVDA, AMD, INTC turns red on Current Ratio": "any of NVDA, AMD, INTC turns red on Current Ratio",
+		"brk.b, BRK-B roe>15%":                              "BRK-B ROE > 0.15",
+	} {
+		r, err := parseAlertRule(text, "")
+		if err != nil {
+			t.Errorf("%q: %v", text, err)
+			continue
+		}
+		if r.String() != want || r.Cooldown != alertCooldown {
+			t.Errorf("%q = %q, cooldown %s; want %q", text, r, r.Cooldown, want)
+		}
+	}
+	if r, err := parseAlertRule("AAPL price > 200", "4h"); err != nil || r.Cooldown != 4*time.Hour {
+		t.Errorf("cooldown = %v, %v", r, err)
+	}
+
+	for text, cooldown := range map[string]string{
+		"AAPL price is low":            "",
+		"AAPL sparkle < 1":             "",
+		"AAPL turns red on Market Cap": "",
+		"$$ price < 1":                 "",
+		"AAPL price < 150 ":            "soon",
+	} {
+		if _, err := parseAlertRule(text, cooldown); err == nil {
+			t.Errorf("%q with cooldown %q parsed", text, cooldown)
+		}
+	}
+}
+
+func TestAlertRuleCheck(t *testing.T) {
+	now := time.Date(2025, 10, 15, 12, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return now.Add(time.Duration(hours) * time.Hour) }
+
+	below, _ := parseAlertRule("AAPL price < 150", "24h")
+	// Fires when it drops below, not again while it stays there, and not on
+	// a second drop within the cooldown.
+	for i, c := range []struct {
+		price float64
+		fire  bool
+	}{{160, false}, {140, true}, {130, false}, {155, false}, {145, false}} {
+		e := below.check("AAPL", Metric{Raw: c.price, Value: "x"}, at(i))
+		if (e != nil) != c.fire {
+			t.Errorf("price %v: fired = %v, want %v", c.price, e != nil, c.fire)
+		}
+	}
+	if e := below.check("AAPL", Metric{Raw: 155}, at(30)); e != nil {
+		t.Error("fired above the threshold")
+	}
+	if e := below.check("AAPL", Metric{Raw: 140, Value: "140.00"}, at(31)); e == nil || e.Message != "AAPL Price is 140.00, below 150" {
+		t.Errorf("after the cooldown = %+v", e)
+	}
+
+	crosses, _ := parseAlertRule("MSFT P/E crosses 30", "0s")
+	for i, c := range []struct {
+		pe   float64
+		fire bool
+	}{{28, false}, {29, false}, {31, true}, {32, false}, {30, false}, {29, true}} {
+		if e := crosses.check("MSFT", Metric{Raw: c.pe}, at(i)); (e != nil) != c.fire {
+			t.Errorf("P/E %v: fired = %v, want %v", c.pe, e != nil, c.fire)
+		}
+	}
+
+	turns, _ := parseAlertRule("any of NVDA, AMD turns red on Current Ratio", "")
+	turns.check("NVDA", Metric{Raw: 1.2, Color: "yellow"}, at(0))
+	if e := turns.check("AMD", Metric{Raw: 0.8, Color: "red"}, at(0)); e != nil {
+		t.Error("fired on the first check")
+	}
+	if e := turns.check("NVDA", Metric{Raw: 0.9, Value: "0.90", Color: "red"}, at(1)); e == nil || e.Message != "NVDA Current Ratio turned red from yellow, now 0.90" {
+		t.Errorf("turned red = %+v", e)
+	}
+	if e := turns.check("AMD", Metric{Raw: 0.7, Color: "red"}, at(1)); e != nil {
+		t.Error("fired while staying red")
+	}
+}
+
+// withAlerts points the data dir at a temp dir and adds shared rules.
+func withAlerts(t *testing.T, rules ...string) {
+	orig := g_dataDir
+	g_dataDir = t.TempDir()
+	t.Cleanup(func() { g_dataDir = orig })
+	for i, text := range rules {
+		r, err := parseAlertRule(text, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := addAlert(nil, r, time.Date(2025, 10, 1, 0, i, 0, 0, time.UTC)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEvaluateAlerts(t *testing.T) {
+	withAlerts(t, "any of AAPL, MSFT, FAIL price < 150", "AAPL P/E crosses 25")
+	prices := map[string]float64{"AAPL": 190, "MSFT": 140}
+	var fetched []string
+	withFetcher(t, func(symbol string) (*Result, error) {
+		fetched = append(fetched, symbol)
+		if symbol == "FAIL" {
+			return nil, errors.New("upstream down")
+		}
+		r := testResult()
+		r.FinancialData.CurrentPrice = FmtRaw{Raw: prices[symbol]}
+		return r, nil
+	})
+
+	now := time.Date(2025, 10, 15, 12, 0, 0, 0, time.UTC)
+	fired, err := evaluateAlerts(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fired) != 1 || fired[0].Symbol != "MSFT" {
+		t.Errorf("first run fired %+v, want MSFT below 150", fired)
+	}
+	if strings.Join(fetched, " ") != "AAPL MSFT FAIL" {
+		t.Errorf("fetched %v, want each symbol once", fetched)
+	}
+
+	// P/E 30 to 20 crosses 25; MSFT is still below 150.
+	withFetcher(t, func(symbol string) (*Result, error) {
+		if symbol == "FAIL" {
+			return nil, errors.New("upstream down")
+		}
+		r := testResult()
+		r.SummaryDetail.TrailingPE = FmtRaw{Raw: 20}
+		r.FinancialData.CurrentPrice = FmtRaw{Raw: prices[symbol]}
+		return r, nil
+	})
+	if fired, _ = evaluateAlerts(context.Background(), now.Add(time.Hour)); len(fired) != 1 || fired[0].Message != "AAPL P/E Ratio crossed below 25, now 20.00" {
+		t.Errorf("second run fired %+v", fired)
+	}
+
+	f, err := listAlerts(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.History) != 2 || f.History[0].Symbol != "AAPL" || f.History[1].Symbol != "MSFT" {
+		t.Errorf("history = %+v, want newest first", f.History)
+	}
+	if st := f.Rules[0].State["MSFT"]; st == nil || !st.Met || st.Fired == nil || !st.Fired.Equal(now) {
+		t.Errorf("MSFT state = %+v", st)
+	}
+}
+
+func TestAPIV1Alerts(t *testing.T) {
+	withAlerts(t)
+	do := func(method, target, body string) (*httptest.ResponseRecorder, APIAlerts) {
+		rec := httptest.NewRecorder()
+		apiV1AlertsHandler(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		var got APIAlerts
+		json.Unmarshal(rec.Body.Bytes(), &got)
+		return rec, got
+	}
+
+	rec, got := do("POST", "/api/v1/alerts", `{"rule": "AAPL price < 150", "cooldown": "2h"}`)
+	if rec.Code != http.StatusOK || len(got.Alerts) != 1 {
+		t.Fatalf("create = %d %s", rec.Code, rec.Body.String())
+	}
+	a := got.Alerts[0]
+	if a.Rule != "AAPL Price < 150" || a.Condition != "<" || a.Value != 150 || a.Cooldown != "2h0m0s" || len(a.Symbols) != 1 || len(a.State) != 0 {
+		t.Errorf("alert = %+v", a)
+	}
+	if _, got = do("GET", "/api/v1/alerts", ""); len(got.Alerts) != 1 || got.Alerts[0].ID != a.ID {
+		t.Errorf("list = %+v", got)
+	}
+
+	for _, c := range []struct{ method, target, body, code string }{
+		{"POST", "/api/v1/alerts", `{"rule": "AAPL price is low"}`, errCodeInvalidAlert},
+		{"POST", "/api/v1/alerts", `not json`, errCodeInvalidAlert},
+		{"DELETE", "/api/v1/alerts", "", errCodeMissingID},
+		{"DELETE", "/api/v1/alerts?id=nope", "", errCodeNotFound},
+		{"PUT", "/api/v1/alerts", "", errCodeMethodNotAllowed},
+	} {
+		if rec, _ := do(c.method, c.target, c.body); rec.Code < 400 || !strings.Contains(rec.Body.String(), c.code) {
+			t.Errorf("%s %s %s: %d %s", c.method, c.target, c.body, rec.Code, rec.Body.String())
+		}
+	}
+
+	if rec, got = do("DELETE", "/api/v1/alerts?id="+a.ID, ""); rec.Code != http.StatusOK || len(got.Alerts) != 0 {
+		t.Errorf("delete = %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAPIV1AlertHistory(t *testing.T) {
+	withAlerts(t)
+	now := time.Date(2025, 10, 15, 12, 0, 0, 0, time.UTC)
+	data, _ := json.Marshal(alertFile{History: []alertEvent{
+		{Time: now, RuleID: "a", Symbol: "AAPL", Message: "AAPL Price is 140.00, below 150", Value: 140},
+		{Time: now.Add(-time.Hour), RuleID: "b", Symbol: "MSFT", Message: "MSFT P/E Ratio crossed above 30, now 31.00", Value: 31, Color: "red"},
+	}})
+	if err := writePrivateFile(alertsPath(nil), data); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	apiV1AlertHistoryHandler(rec, httptest.NewRequest("GET", "/api/v1/alerts/history?id=b", nil))
+	var got APIAlertHistory
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("history = %d %s", rec.Code, rec.Body.String())
+	}
+	if len(got.Events) != 1 || got.Events[0] != (APIAlertEvent{"2025-10-15T11:00:00Z", "b", "MSFT", "MSFT P/E Ratio crossed above 30, now 31.00", 31, "red"}) {
+		t.Errorf("history = %+v", got)
+	}
+}
+
+func TestAlertsPage(t *testing.T) {
+	withAlerts(t)
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/alerts", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		alertsHandler(rec, req)
+		return rec
+	}
+
+	if rec := post(url.Values{"action": {"create"}, "rule": {"any of NVDA, AMD turns red on Current Ratio"}}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("create = %d %s", rec.Code, rec.Body.String())
+	}
+	rec := post(url.Values{"action": {"create"}, "rule": {"NVDA sparkle < 1"}})
+	if html := rec.Body.String(); rec.Code != http.StatusBadRequest || !strings.Contains(html, "unknown metric") || !strings.Contains(html, `value="NVDA sparkle &lt; 1"`) {
+		t.Errorf("invalid rule = %d %s", rec.Code, html)
+	}
+
+	rec = httptest.NewRecorder()
+	alertsHandler(rec, httptest.NewRequest("GET", "/alerts", nil))
+	html := rec.Body.String()
+	for _, want := range []string{"any of NVDA, AMD turns red on Current Ratio", "NVDA not checked yet", "No alerts have fired."} {
+		if !strings.Contains(html, want) {
+			t.Errorf("alerts page lacks %q", want)
+		}
+	}
+
+	f, _ := listAlerts(nil)
+	if rec := post(url.Values{"action": {"delete"}, "id": {f.Rules[0].ID}}); rec.Code != http.StatusSeeOther {
+		t.Errorf("delete = %d", rec.Code)
+	}
+	if f, _ = listAlerts(nil); len(f.Rules) != 0 {
+		t.Errorf("rules after delete = %+v", f.Rules)
+	}
+}
+
+func TestAlertsPerUser(t *testing.T) {
+	withAlerts(t, "AAPL price < 150")
+	withLogin(t, "local", OIDCConfig{})
+	alice, bob := &User{ID: "local:alice"}, &User{ID: "local:bob"}
+	as := func(u *User, method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if u != nil {
+			req = req.WithContext(withUser(req.Context(), u))
+		}
+		rec := httptest.NewRecorder()
+		apiV1AlertsHandler(rec, req)
+		return rec
+	}
+
+	if rec := as(alice, "POST", "/api/v1/alerts", `{"rule": "MSFT price < 300"}`); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "AAPL") {
+		t.Errorf("alice's alerts = %d %s", rec.Code, rec.Body.String())
+	}
+	alices, _ := listAlerts(alice)
+	if rec := as(bob, "DELETE", "/api/v1/alerts?id="+alices.Rules[0].ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("bob deleting alice's alert = %d", rec.Code)
+	}
+	if rec := as(bob, "GET", "/api/v1/alerts", ""); strings.Contains(rec.Body.String(), "MSFT") {
+		t.Errorf("bob sees alice's alert: %s", rec.Body.String())
+	}
+	if rec := as(nil, "POST", "/api/v1/alerts", `{"rule": "NVDA price < 100"}`); rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), errCodeUnauthorized) {
+		t.Errorf("anonymous create = %d %s", rec.Code, rec.Body.String())
+	}
+
+	// The scheduler checks the shared rules and every user's.
+	var fetched []string
+	withFetcher(t, func(symbol string) (*Result, error) {
+		fetched = append(fetched, symbol)
+		return testResult(), nil
+	})
+	if _, err := evaluateAlerts(context.Background(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(fetched, " ") != "AAPL MSFT" {
+		t.Errorf("fetched %v", fetched)
+	}
+	if alices, _ = listAlerts(alice); alices.Rules[0].State["MSFT"] == nil {
+		t.Error("alice's rule wasn't checked")
+	}
+}
+
+func TestAlertLimits(t *testing.T) {
+	withAlerts(t)
+	add := func(text string) error {
+		r, err := parseAlertRule(text, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return addAlert(nil, r, time.Now())
+	}
+	// Five rules of 20 symbols each reach the watched limit.
+	for i := 0; i < maxAlertWatched/maxAlertSymbols; i++ {
+		var symbols []string
+		for j := 0; j < maxAlertSymbols; j++ {
+			symbols = append(symbols, fmt.Sprintf("S%d", i*maxAlertSymbols+j))
+		}
+		if err := add("any of " + strings.Join(symbols, ", ") + " price < 1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := add("NEW price < 1"); !errors.Is(err, errTooManyWatched) {
+		t.Errorf("one symbol too many = %v", err)
+	}
+	// Symbols already watched don't count again.
+	for i := maxAlertWatched / maxAlertSymbols; i < maxAlertRules; i++ {
+		if err := add("S0 price < 1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := add("S0 price < 2"); !errors.Is(err, errTooManyAlerts) {
+		t.Errorf("one rule too many = %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	common "app/internal/common"
+	"fmt"
+	"math"
+	"time"
+
+	g "maragu.dev/gomponents"
+	// Importing this as '.' is intentional for cleaner HTML like code.
+	. "maragu.dev/gomponents/html"
+)
+
+// Analyst price targets and the mean recommendation come from financialData,
+// the monthly rating counts from recommendationTrend and the rating changes
+// from upgradeDowngradeHistory.
+
+type APIAnalysts struct {
+	Count              int                      `json:"count" doc:"Analysts with a price target."`
+	Recommendation     string                   `json:"recommendation" doc:"Yahoo's consensus, e.g. buy or strong_buy."`
+	RecommendationMean float64                  `json:"recommendation_mean" doc:"1 is strong buy, 5 strong sell."`
+	Currency           string                   `json:"currency" doc:"Currency of the price and targets (ISO 4217)."`
+	Price              float64                  `json:"price"`
+	TargetLow          float64                  `json:"target_low"`
+	TargetMean         float64                  `json:"target_mean"`
+	TargetMedian       float64                  `json:"target_median"`
+	TargetHigh         float64                  `json:"target_high"`
+	Upside             float64                  `json:"upside" doc:"Mean target over the price, less 1. 0 without a target."`
+	Trend              []APIRecommendationMonth `json:"trend" doc:"This month first."`
+	Changes            []APIRatingChange        `json:"changes" doc:"Newest first, at most ten."`
+}
+
+type APIRecommendationMonth struct {
+	Period     string `json:"period" doc:"0m is this month, -1m the one before."`
+	StrongBuy  int    `json:"strong_buy"`
+	Buy        int    `json:"buy"`
+	Hold       int    `json:"hold"`
+	Sell       int    `json:"sell"`
+	StrongSell int    `json:"strong_sell"`
+}
+
+type APIRatingChange struct {
+	Date        string  `json:"date" doc:"YYYY-MM-DD."`
+	Firm        string  `json:"firm"`
+	Action      string  `json:"action" enum:"up,down,init,main,reit"`
+	FromGrade   string  `json:"from_grade"`
+	ToGrade     string  `json:"to_grade"`
+	PriceTarget float64 `json:"price_target" doc:"0 when the firm gave none."`
+}
+
+// recentRatingChanges is how many rating changes are shown.
+const recentRatingChanges = 10
+
+// toAPIAnalysts returns nil for instruments no analyst covers.
+func toAPIAnalysts(result *Result) *APIAnalysts {
+	fd := result.FinancialData
+	trend, history := result.RecommendationTrend.Trend, result.UpgradeDowngradeHistory.History
+	if fd.NumberOfAnalystOpinions.Raw == 0 && len(trend) == 0 && len(history) == 0 {
+		return nil
+	}
+	currency, scale := result.tradingCurrency()
+
+	a := &APIAnalysts{
+		Count:              int(fd.NumberOfAnalystOpinions.Raw),
+		Recommendation:     fd.RecommendationKey,
+		RecommendationMean: fd.RecommendationMean.Raw,
+		Currency:           currency,
+		Price:              fd.CurrentPrice.Raw * scale,
+		TargetLow:          fd.TargetLowPrice.Raw * scale,
+		TargetMean:         fd.TargetMeanPrice.Raw * scale,
+		TargetMedian:       fd.TargetMedianPrice.Raw * scale,
+		TargetHigh:         fd.TargetHighPrice.Raw * scale,
+		Trend:              []APIRecommendationMonth{},
+		Changes:            []APIRatingChange{},
+	}
+	if a.Price > 0 && a.TargetMean > 0 {
+		a.Upside = a.TargetMean/a.Price - 1
+	}
+	for _, t := range trend {
+		a.Trend = append(a.Trend, APIRecommendationMonth{t.Period, t.StrongBuy, t.Buy, t.Hold, t.Sell, t.StrongSell})
+	}
+	for _, h := range history[:min(len(history), recentRatingChanges)] {
+		a.Changes = append(a.Changes, APIRatingChange{
+			Date:        time.Unix(h.EpochGradeDate, 0).UTC().Format("2006-01-02"),
+			Firm:        h.Firm,
+			Action:      h.Action,
+			FromGrade:   h.FromGrade,
+			ToGrade:     h.ToGrade,
+			PriceTarget: h.CurrentPriceTarget * scale,
+		})
+	}
+	return a
+}
+
+var ratingActions = map[string]string{
+	"up":   "Upgrade",
+	"down": "Downgrade",
+	"init": "Initiated",
+	"main": "Maintained",
+	"reit": "Reiterated",
+}
+
+// Yahoo's recommendationKey values.
+var recommendationNames = map[string]string{
+	"strong_buy":   "Strong Buy",
+	"buy":          "Buy",
+	"hold":         "Hold",
+	"underperform": "Underperform",
+	"sell":         "Sell",
+}
+
+func monthName(period string) string {
+	switch period {
+	case "0m":
+		return "This month"
+	case "-1m":
+		return "1 month ago"
+	}
+	var n int
+	if _, err := fmt.Sscanf(period, "-%dm", &n); err == nil {
+		return fmt.Sprintf("%d months ago", n)
+	}
+	return period
+}
+
+// targetRange places the low, mean and high targets and the current price on
+// one line, so it's clear at a glance where the price sits.
+func targetRange(a *APIAnalysts) g.Node {
+	if a.TargetLow == 0 || a.TargetHigh == 0 {
+		return P(Class("text-sm text-gray-500"), g.Text("Yahoo Finance has no price targets."))
+	}
+	lo, hi := math.Min(a.TargetLow, a.Price), math.Max(a.TargetHigh, a.Price)
+	if hi == lo {
+		hi = lo + 1
+	}
+	pos := func(v float64) string {
+		return fmt.Sprintf("left: %.1f%%", (v-lo)/(hi-lo)*100)
+	}
+	marker := func(v float64, class, label string) g.Node {
+		return Div(Class("absolute top-0 -translate-x-1/2 flex flex-col items-center"), g.Attr("style", pos(v)),
+			Div(Class("w-1 h-6 "+class)),
+			Span(Class("text-xs text-gray-400 whitespace-nowrap mt-1"), g.Text(label)),
+			Span(Class("text-xs text-gray-300 whitespace-nowrap"), g.Text(common.FormatCurrency(v, a.Currency))),
+		)
+	}
+	return Div(Class("relative h-20 mx-8"),
+		Div(Class("absolute top-2 h-2 bg-gray-600 rounded"), g.Attr("style", fmt.Sprintf("%s; width: %.1f%%", pos(a.TargetLow), (a.TargetHigh-a.TargetLow)/(hi-lo)*100))),
+		marker(a.TargetLow, "bg-red-400", "Low"),
+		marker(a.TargetMean, "bg-blue-400", "Mean"),
+		marker(a.TargetHigh, "bg-green-400", "High"),
+		marker(a.Price, "bg-white", "Price"),
+	)
+}
+
+// recommendationBars draws each month's ratings as one bar split by rating.
+func recommendationBars(trend []APIRecommendationMonth) g.Node {
+	if len(trend) == 0 {
+		return P(Class("text-sm text-gray-500"), g.Text("Yahoo Finance has no recommendation history."))
+	}
+	var rows []g.Node
+	for _, t := range trend {
+		total := t.StrongBuy + t.Buy + t.Hold + t.Sell + t.StrongSell
+		var segments []g.Node
+		for _, s := range []struct {
+			n     int
+			class string
+			name  string
+		}{
+			{t.StrongBuy, "bg-green-600", "Strong Buy"},
+			{t.Buy, "bg-green-400", "Buy"},
+			{t.Hold, "bg-yellow-400", "Hold"},
+			{t.Sell, "bg-red-400", "Sell"},
+			{t.StrongSell, "bg-red-600", "Strong Sell"},
+		} {
+			if s.n == 0 {
+				continue
+			}
+			segments = append(segments, Div(Class(s.class+" h-full text-xs text-gray-900 text-center"),
+				g.Attr("style", fmt.Sprintf("width: %.1f%%", float64(s.n)/float64(total)*100)),
+				TitleAttr(fmt.Sprintf("%s: %d", s.name, s.n)),
+				g.Text(fmt.Sprint(s.n)),
+			))
+		}
+		rows = append(rows, Div(Class("flex items-center gap-2 mb-2"),
+			Span(Class("w-28 text-xs text-gray-400"), g.Text(monthName(t.Period))),
+			Div(Class("flex flex-1 h-5 rounded overflow-hidden bg-gray-700"), g.Group(segments)),
+		))
+	}
+	return Div(
+		g.Group(rows),
+		P(Class("text-xs text-gray-500 mt-2"), g.Text("Strong buy, buy, hold, sell and strong sell, left to right.")),
+	)
+}
+
+func ratingChangesTable(changes []APIRatingChange, currency string) g.Node {
+	if len(changes) == 0 {
+		return P(Class("text-sm text-gray-500"), g.Text("Yahoo Finance has no recent rating changes."))
+	}
+	var rows []g.Node
+	for _, c := range changes {
+		actionClass := "text-gray-300"
+		switch c.Action {
+		case "up":
+			actionClass = "text-green-300"
+		case "down":
+			actionClass = "text-red-300"
+		}
+		grade := c.ToGrade
+		if c.FromGrade != "" && c.FromGrade != c.ToGrade {
+			grade = c.FromGrade + " → " + c.ToGrade
+		}
+		target := ""
+		if c.PriceTarget > 0 {
+			target = common.FormatCurrency(c.PriceTarget, currency)
+		}
+		rows = append(rows, Tr(Class("border-t border-gray-700"),
+			Td(Class("py-1 pr-2 text-gray-400"), g.Text(c.Date)),
+			Td(Class("py-1 pr-2 text-gray-300"), g.Text(c.Firm)),
+			Td(Class("py-1 pr-2 "+actionClass), g.Text(firstNonEmpty(ratingActions[c.Action], c.Action))),
+			Td(Class("py-1 pr-2"), g.Text(grade)),
+			Td(Class("py-1 text-right"), g.Text(target)),
+		))
+	}
+	return Table(Class("w-full text-sm"), TBody(g.Group(rows)))
+}
+
+// analystSection is the stock page's analyst panel, or nil for instruments
+// without coverage.
+func analystSection(result *Result) g.Node {
+	a := toAPIAnalysts(result)
+	if a == nil {
+		return nil
+	}
+	summary := fmt.Sprintf("%d analysts", a.Count)
+	if name := recommendationNames[a.Recommendation]; name != "" {
+		summary += fmt.Sprintf(" · consensus %s (%.1f of 5)", name, a.RecommendationMean)
+	}
+	if a.Upside != 0 {
+		summary += fmt.Sprintf(" · mean target implies %+.1f%%", a.Upside*100)
+	}
+
+	return Div(Class("mt-8"),
+		H2(Class("text-2xl font-bold text-white mb-2"), g.Text("Analysts")),
+		P(Class("text-gray-400 mb-4"), g.Text(summary)),
+		Div(Class("grid grid-cols-1 lg:grid-cols-2 gap-6"),
+			fundSection("Price Targets", targetRange(a)),
+			fundSection("Recommendations", recommendationBars(a.Trend)),
+			Div(Class("lg:col-span-2"), fundSection("Recent Upgrades and Downgrades", ratingChangesTable(a.Changes, a.Currency))),
+		),
+	)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+// Trimmed down recommendationTrend and upgradeDowngradeHistory modules.
+const testAnalystModules = `{
+	"recommendationTrend":{"trend":[
+		{"period":"0m","strongBuy":6,"buy":20,"hold":12,"sell":1,"strongSell":1},
+		{"period":"-1m","strongBuy":7,"buy":21,"hold":11,"sell":1,"strongSell":0},
+		{"period":"-2m","strongBuy":0,"buy":0,"hold":0,"sell":0,"strongSell":0}]},
+	"upgradeDowngradeHistory":{"history":[
+		{"epochGradeDate":1760659200,"firm":"Loop Capital","toGrade":"Hold","fromGrade":"Buy","action":"down","priceTargetAction":"Lowers","currentPriceTarget":180,"priorPriceTarget":230},
+		{"epochGradeDate":1760400000,"firm":"Evercore ISI","toGrade":"Outperform","fromGrade":"Outperform","action":"main","priceTargetAction":"Raises","currentPriceTarget":250,"priorPriceTarget":240},
+		{"epochGradeDate":1760000000,"firm":"Jefferies","toGrade":"Buy","fromGrade":"","action":"init","currentPriceTarget":0}]}
+}`
+
+func testAnalystResult(t *testing.T) *Result {
+	r := testResult()
+	if err := json.Unmarshal([]byte(testAnalystModules), r); err != nil {
+		t.Fatal(err)
+	}
+	fd := &r.FinancialData
+	fd.NumberOfAnalystOpinions = FmtRaw{Raw: 40}
+	fd.RecommendationKey = "buy"
+	fd.RecommendationMean = FmtRaw{Raw: 2.1}
+	fd.TargetLowPrice = FmtRaw{Raw: 180}
+	fd.TargetMeanPrice = FmtRaw{Raw: 228}
+	fd.TargetMedianPrice = FmtRaw{Raw: 230}
+	fd.TargetHighPrice = FmtRaw{Raw: 300}
+	return r
+}
+
+func TestToAPIAnalysts(t *testing.T) {
+	a := toAPIAnalysts(testAnalystResult(t))
+	if a == nil {
+		t.Fatal("no analysts")
+	}
+	if a.Count != 40 || a.Recommendation != "buy" || a.Currency != "USD" || a.Price != 190 || a.TargetHigh != 300 {
+		t.Errorf("analysts = %+v", a)
+	}
+	if math.Abs(a.Upside-0.2) > 1e-9 {
+		t.Errorf("upside = %v, want 0.2", a.Upside)
+	}
+	if len(a.Trend) != 3 || a.Trend[0].Period != "0m" || a.Trend[0].Buy != 20 || a.Trend[1].StrongBuy != 7 {
+		t.Errorf("trend = %+v", a.Trend)
+	}
+	if len(a.Changes) != 3 || a.Changes[0].Date != "2025-10-17" || a.Changes[0].Action != "down" || a.Changes[0].PriceTarget != 180 {
+		t.Errorf("changes = %+v", a.Changes)
+	}
+
+	if toAPIAnalysts(testResult()) != nil {
+		t.Error("analysts without coverage")
+	}
+}
+
+func TestToAPIAnalystsPence(t *testing.T) {
+	r := testAnalystResult(t)
+	r.SummaryDetail.Currency = "GBp"
+	a := toAPIAnalysts(r)
+	if a.Currency != "GBP" || math.Abs(a.Price-1.9) > 1e-9 || math.Abs(a.TargetMean-2.28) > 1e-9 || a.Changes[0].PriceTarget != 1.8 {
+		t.Errorf("analysts = %+v", a)
+	}
+}
+
+func TestMonthName(t *testing.T) {
+	for period, want := range map[string]string{"0m": "This month", "-1m": "1 month ago", "-3m": "3 months ago", "+1y": "+1y"} {
+		if got := monthName(period); got != want {
+			t.Errorf("monthName(%q) = %q, want %q", period, got, want)
+		}
+	}
+}
+
+func TestAnalystSection(t *testing.T) {
+	var b strings.Builder
+	analystSection(testAnalystResult(t)).Render(&b)
+	html := b.String()
+	for _, want := range []string{
+		"40 analysts · consensus Buy (2.1 of 5) · mean target implies +20.0%",
+		"$228.00", "This month", "2 months ago", `title="Buy: 20"`,
+		"Loop Capital", "Downgrade", "Buy → Hold", "Initiated",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("analyst section lacks %q", want)
+		}
+	}
+	if analystSection(testResult()) != nil {
+		t.Error("analyst section without coverage")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// runAPIKeyCommand handles `stock apikey create|list|revoke`. Keys live in the
+// data dir from the config, so run it as the service user to keep the file
+// readable by the server.
+func runAPIKeyCommand(args []string, stdout, stderr io.Writer) int {
+	usage := "usage: stock apikey create -name NAME [-rate N] | list | revoke ID"
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, usage)
+		return 2
+	}
+	fs := flag.NewFlagSet("apikey "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	f := addConfigFlags(fs)
+	name := fs.String("name", "", "who or what the key is for (create)")
+	rate := fs.Int("rate", 0, "requests per minute for this key, 0 for the configured default (create)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	cfg, err := loadConfig(f)
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid config:\n%v\n", err)
+		return 1
+	}
+
+	store := newAPIKeyStore(apiKeyStorePath(cfg.DataDir))
+	if err := store.load(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	switch args[0] {
+	case "create":
+		if *name == "" || *rate < 0 {
+			fmt.Fprintln(stderr, usage)
+			return 2
+		}
+		k, plain, err := store.create(*name, *rate)
+		if err == nil {
+			err = store.save()
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "Created API key %s for %q. It won't be shown again:\n\n%s\n", k.ID, k.Name, plain)
+		return 0
+	case "list":
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tCREATED\tSTATUS\tRATE/MIN")
+		for _, k := range store.list() {
+			status := "active"
+			if k.Revoked != nil {
+				status = "revoked " + k.Revoked.Format(time.DateOnly)
+			}
+			rate := "default"
+			if k.RequestsPerMinute > 0 {
+				rate = fmt.Sprint(k.RequestsPerMinute)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, k.Created.Format(time.DateOnly), status, rate)
+		}
+		tw.Flush()
+		return 0
+	case "revoke":
+		if fs.NArg() != 1 {
+			fmt.Fprintln(stderr, usage)
+			return 2
+		}
+		err := store.revoke(fs.Arg(0))
+		if err == nil {
+			err = store.save()
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "Revoked API key %s\n", fs.Arg(0))
+		return 0
+	}
+	fmt.Fprintln(stderr, usage)
+	return 2
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// API keys look like sk_<id>_<secret>. Only a SHA-256 of the whole key is
+// stored; the id lets us find the record without trying every hash. The
+// secret is 128 random bits, so a fast hash is enough.
+const apiKeyPrefix = "sk_"
+
+// How often the server checks the key file for changes made by the CLI.
+const apiKeyReloadInterval = 5 * time.Second
+
+var (
+	errAPIKeyInvalid = errors.New("invalid API key")
+	errAPIKeyRevoked = errors.New("API key has been revoked")
+)
+
+type apiKey struct {
+	ID      string     `json:"id"`
+	Name    string     `json:"name"`
+	Hash    string     `json:"hash"`
+	Created time.Time  `json:"created"`
+	Revoked *time.Time `json:"revoked,omitempty"`
+	// 0 uses auth.key_requests_per_minute from the config.
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"`
+}
+
+// apiKeyStore is the key file in the data dir, reloaded when it changes on disk.
+type apiKeyStore struct {
+	path string
+
+	mu        sync.Mutex
+	keys      map[string]*apiKey
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func apiKeyStorePath(dataDir string) string {
+	return filepath.Join(dataDir, "apikeys.json")
+}
+
+func newAPIKeyStore(path string) *apiKeyStore {
+	return &apiKeyStore{path: path, keys: map[string]*apiKey{}}
+}
+
+// load reads the key file. A missing file is an empty store.
+func (s *apiKeyStore) load() error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.keys = map[string]*apiKey{}
+		s.modTime = time.Time{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var list []*apiKey
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("%s: %v", s.path, err)
+	}
+	keys := make(map[string]*apiKey, len(list))
+	for _, k := range list {
+		keys[k.ID] = k
+	}
+	s.keys, s.modTime = keys, info.ModTime()
+	return nil
+}
+
+// save writes the key file. Only the owner can read it.
+func (s *apiKeyStore) save() error {
+	data, err := json.MarshalIndent(s.list(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writePrivateFile(s.path, data)
+}
+
+// writePrivateFile replaces path via a temp file and rename, readable only by
+// the owner.
+func writePrivateFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// list returns the keys oldest first.
+func (s *apiKeyStore) list() []*apiKey {
+	list := make([]*apiKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		list = append(list, k)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+	return list
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// create adds a key and returns it with the plaintext key, which is never
+// stored and can't be shown again.
+func (s *apiKeyStore) create(name string, perMinute int) (*apiKey, string, error) {
+	id, err := randomHex(4)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(16)
+	if err != nil {
+		return nil, "", err
+	}
+	plain := apiKeyPrefix + id + "_" + secret
+	k := &apiKey{ID: id, Name: name, Hash: hashAPIKey(plain), Created: time.Now().UTC(), RequestsPerMinute: perMinute}
+	s.keys[id] = k
+	return k, plain, nil
+}
+
+func (s *apiKeyStore) revoke(id string) error {
+	k, ok := s.keys[id]
+	if !ok {
+		return fmt.Errorf("no API key with id %q", id)
+	}
+	if k.Revoked == nil {
+		now := time.Now().UTC()
+		k.Revoked = &now
+	}
+	return nil
+}
+
+// authenticate returns the key record for a plaintext key.
+func (s *apiKeyStore) authenticate(plain string) (*apiKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadIfChanged()
+
+	rest := strings.TrimPrefix(plain, apiKeyPrefix)
+	id, _, ok := strings.Cut(rest, "_")
+	if !ok || rest == plain {
+		return nil, errAPIKeyInvalid
+	}
+	k, ok := s.keys[id]
+	if !ok || subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hashAPIKey(plain))) != 1 {
+		return nil, errAPIKeyInvalid
+	}
+	if k.Revoked != nil {
+		return nil, errAPIKeyRevoked
+	}
+	return k, nil
+}
+
+// Picks up keys created or revoked by the CLI while the server runs.
+func (s *apiKeyStore) reloadIfChanged() {
+	if time.Since(s.lastCheck) < apiKeyReloadInterval {
+		return
+	}
+	s.lastCheck = time.Now()
+	info, err := os.Stat(s.path)
+	if err == nil && info.ModTime().Equal(s.modTime) {
+		return
+	}
+	if errors.Is(err, os.ErrNotExist) && s.modTime.IsZero() {
+		return
+	}
+	if err := s.load(); err != nil {
+		// Keep serving with the keys we have.
+		slog.Error("Could not reload API keys", "err", err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeyStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikeys.json")
+	s := newAPIKeyStore(path)
+	k, plain, err := s.create("ci", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), plain) || strings.Contains(string(data), strings.Split(plain, "_")[2]) {
+		t.Error("plaintext key written to disk")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	server := newAPIKeyStore(path)
+	got, err := server.authenticate(plain)
+	if err != nil || got.ID != k.ID || got.RequestsPerMinute != 10 {
+		t.Fatalf("authenticate = %+v, %v", got, err)
+	}
+	for _, bad := range []string{"", "sk_", "sk_" + k.ID + "_wrong", "nope", strings.TrimPrefix(plain, "sk_")} {
+		if _, err := server.authenticate(bad); err != errAPIKeyInvalid {
+			t.Errorf("authenticate(%q) = %v, want errAPIKeyInvalid", bad, err)
+		}
+	}
+
+	// The CLI revokes in another process; the server notices on its next check.
+	if err := s.revoke(k.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+	server.lastCheck = server.lastCheck.Add(-apiKeyReloadInterval)
+	server.modTime = server.modTime.Add(-1)
+	if _, err := server.authenticate(plain); err != errAPIKeyRevoked {
+		t.Errorf("revoked key: %v, want errAPIKeyRevoked", err)
+	}
+}
+
+func TestAPIKeyCommand(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.yaml")
+	os.WriteFile(config, []byte("data_dir: "+dir+"\n"), 0644)
+	run := func(args ...string) (int, string) {
+		var out, errOut strings.Builder
+		code := runAPIKeyCommand(append(args[:1:1], append([]string{"-config", config}, args[1:]...)...), &out, &errOut)
+		return code, out.String() + errOut.String()
+	}
+
+	code, out := run("create", "-name", "grafana")
+	if code != 0 || !strings.Contains(out, "sk_") {
+		t.Fatalf("create = %d %q", code, out)
+	}
+	id := strings.Fields(out)[3]
+
+	if code, out := run("list"); code != 0 || !strings.Contains(out, "grafana") || !strings.Contains(out, "active") {
+		t.Errorf("list = %d %q", code, out)
+	}
+	if code, out := run("revoke", id); code != 0 {
+		t.Fatalf("revoke = %d %q", code, out)
+	}
+	if _, out := run("list"); !strings.Contains(out, "revoked") {
+		t.Errorf("list after revoke = %q", out)
+	}
+	if code, _ := run("revoke", "missing"); code != 1 {
+		t.Errorf("revoking an unknown id = %d, want 1", code)
+	}
+	if code, _ := run("create"); code != 2 {
+		t.Errorf("create without a name = %d, want 2", code)
+	}
+}
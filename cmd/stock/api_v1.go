@@ -0,0 +1,459 @@
+package main
+
+import (
+	common "app/internal/common"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// All versioned API routes live under this prefix. The types below are our
+// own stable schema so clients don't couple to Yahoo's quoteSummary layout.
+const apiV1Prefix = "/api/v1/"
+
+// Error codes returned in APIError.Code.
+const (
+	errCodeMissingSymbol    = "missing_symbol"
+	errCodeInvalidSymbol    = "invalid_symbol"
+	errCodeInvalidBody      = "invalid_body"
+	errCodeTooManySymbols   = "too_many_symbols"
+	errCodeInvalidCurrency  = "invalid_currency"
+	errCodeInvalidDate      = "invalid_date"
+	errCodeMissingMetric    = "missing_metric"
+	errCodeUnknownMetric    = "unknown_metric"
+	errCodeMissingID        = "missing_id"
+	errCodeInvalidAlert     = "invalid_alert"
+	errCodeTooManyAlerts    = "too_many_alerts"
+	errCodeMissingQuery     = "missing_query"
+	errCodeSymbolNotFound   = "symbol_not_found"
+	errCodeUpstream         = "upstream_error"
+	errCodeNotFound         = "not_found"
+	errCodeMethodNotAllowed = "method_not_allowed"
+	errCodeRateLimited      = "rate_limited"
+	errCodeUnauthorized     = "unauthorized"
+	errCodeInternal         = "internal_error"
+)
+
+type APIQuote struct {
+	Symbol           string  `json:"symbol" doc:"Ticker symbol in canonical form, e.g. BRK-B for BRK.B."`
+	Currency         string  `json:"currency" doc:"Trading currency (ISO 4217)."`
+	Price            float64 `json:"price"`
+	PreviousClose    float64 `json:"previous_close"`
+	Open             float64 `json:"open"`
+	DayLow           float64 `json:"day_low"`
+	DayHigh          float64 `json:"day_high"`
+	FiftyTwoWeekLow  float64 `json:"fifty_two_week_low"`
+	FiftyTwoWeekHigh float64 `json:"fifty_two_week_high"`
+	Volume           float64 `json:"volume"`
+	AverageVolume    float64 `json:"average_volume"`
+	MarketCap        float64 `json:"market_cap"`
+}
+
+type APIFundamentals struct {
+	FinancialCurrency string  `json:"financial_currency" doc:"Currency the financial statements are reported in (ISO 4217)."`
+	TrailingPE        float64 `json:"trailing_pe"`
+	ForwardPE         float64 `json:"forward_pe"`
+	PriceToBook       float64 `json:"price_to_book"`
+	PriceToSales      float64 `json:"price_to_sales" doc:"Market cap over revenue, both in financial_currency."`
+	DebtToEquity      float64 `json:"debt_to_equity"`
+	CurrentRatio      float64 `json:"current_ratio"`
+	QuickRatio        float64 `json:"quick_ratio"`
+	ReturnOnEquity    float64 `json:"return_on_equity" doc:"Fraction, 0.25 means 25%."`
+	ReturnOnAssets    float64 `json:"return_on_assets" doc:"Fraction, 0.25 means 25%."`
+	GrossMargin       float64 `json:"gross_margin" doc:"Fraction, 0.25 means 25%."`
+	OperatingMargin   float64 `json:"operating_margin" doc:"Fraction, 0.25 means 25%."`
+	ProfitMargin      float64 `json:"profit_margin" doc:"Fraction, 0.25 means 25%."`
+	RevenueGrowth     float64 `json:"revenue_growth" doc:"Year over year, as a fraction."`
+	EarningsGrowth    float64 `json:"earnings_growth" doc:"Year over year, as a fraction."`
+	TotalRevenue      float64 `json:"total_revenue"`
+	Ebitda            float64 `json:"ebitda"`
+	FreeCashflow      float64 `json:"free_cashflow"`
+	TotalCash         float64 `json:"total_cash"`
+	TotalDebt         float64 `json:"total_debt"`
+	EnterpriseValue   float64 `json:"enterprise_value"`
+	SharesOutstanding float64 `json:"shares_outstanding"`
+	BookValue         float64 `json:"book_value" doc:"Book value per share."`
+	Beta              float64 `json:"beta"`
+	DividendYield     float64 `json:"dividend_yield" doc:"Fraction, 0.025 means 2.5%."`
+}
+
+// APIDerived holds metrics we compute ourselves rather than read from Yahoo.
+type APIDerived struct {
+	PEGRatio float64 `json:"peg_ratio" doc:"Trailing P/E divided by earnings growth."`
+	ROIC     float64 `json:"roic" doc:"Approximate return on invested capital, as a fraction."`
+	// Crypto and currency pairs only.
+	Volatility    float64  `json:"volatility,omitempty" doc:"Annualized 30 day volatility of daily closes, as a fraction."`
+	RangePosition *float64 `json:"range_position,omitempty" doc:"Where the price sits in its 52 week range, from 0 at the low to 1 at the high. Missing without a range."`
+}
+
+// APIMetric is a metric scored the same way as the cards on the stock page.
+type APIMetric struct {
+	Name       string           `json:"name"`
+	Value      float64          `json:"value" doc:"Raw value. Percentages are fractions, 0.25 means 25%."`
+	Formatted  string           `json:"formatted" doc:"Value as displayed on the stock page."`
+	Unit       string           `json:"unit" enum:"ratio,percent,currency,shares,days,coins,rate"`
+	Color      string           `json:"color" enum:"green,yellow,red"`
+	Reason     string           `json:"reason" doc:"Plain text explanation of the color."`
+	ReasonHTML string           `json:"reason_html" doc:"The explanation as rendered on the stock page."`
+	Threshold  *MetricThreshold `json:"threshold,omitempty" doc:"Omitted for informational metrics that are not scored."`
+	Currency   string           `json:"currency,omitempty" doc:"ISO 4217 code of a currency metric's value."`
+	Note       string           `json:"note,omitempty" doc:"Explains a currency conversion, if one was applied."`
+}
+
+type APIMetrics struct {
+	Symbol  string      `json:"symbol"`
+	Metrics []APIMetric `json:"metrics"`
+}
+
+type APIStock struct {
+	Symbol       string           `json:"symbol"`
+	QuoteType    string           `json:"quote_type" enum:"EQUITY,ETF,MUTUALFUND,CRYPTOCURRENCY,CURRENCY,INDEX,FUTURE"`
+	Quote        APIQuote         `json:"quote"`
+	Fundamentals APIFundamentals  `json:"fundamentals"`
+	Derived      APIDerived       `json:"derived"`
+	Metrics      []APIMetric      `json:"metrics" doc:"Fund, crypto or currency metrics instead of stock ones for those instruments."`
+	Fund         *APIFund         `json:"fund,omitempty" doc:"Only for ETFs and mutual funds."`
+	Crypto       *APICrypto       `json:"crypto,omitempty" doc:"Only for cryptocurrencies."`
+	Currency     *APICurrencyPair `json:"currency,omitempty" doc:"Only for currency pairs."`
+	Earnings     *APIEarnings     `json:"earnings,omitempty" doc:"Only for instruments that report earnings."`
+	Analysts     *APIAnalysts     `json:"analysts,omitempty" doc:"Only for instruments analysts cover."`
+	Ownership    *APIOwnership    `json:"ownership,omitempty" doc:"Only for stocks."`
+	Dividends    *APIDividends    `json:"dividends,omitempty" doc:"Only for stocks that pay a dividend."`
+	Options      *APIOptionsNext  `json:"options,omitempty" doc:"The nearest expiration, for instruments with listed options. The chain itself is at /api/v1/options."`
+}
+
+type APIError struct {
+	Error APIErrorBody `json:"error"`
+}
+
+type APIErrorBody struct {
+	Code    string `json:"code" enum:"missing_symbol,invalid_symbol,invalid_body,too_many_symbols,invalid_currency,invalid_date,missing_metric,unknown_metric,missing_id,invalid_alert,too_many_alerts,missing_query,symbol_not_found,upstream_error,not_found,method_not_allowed,rate_limited,unauthorized,internal_error"`
+	Message string `json:"message"`
+}
+
+// apiRoute describes one v1 endpoint. The same table registers the handlers
+// and generates the OpenAPI document, so the two can't drift apart.
+type apiRoute struct {
+	Path    string
+	Summary string
+	Params  []apiParam
+	// When set, the route also accepts POST with this JSON body.
+	RequestBody reflect.Type
+	// When set, the route also accepts DELETE with these parameters.
+	DeleteParams []apiParam
+	Response     reflect.Type
+	Handler      http.HandlerFunc
+}
+
+type apiParam struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+var symbolParam = apiParam{Name: "symbol", Description: "Ticker symbol, e.g. AAPL.", Required: true}
+
+var currencyParam = apiParam{Name: displayCurrencyParam, Description: "ISO 4217 code to show currency metrics in, e.g. EUR. Defaults to the currency the symbol trades in."}
+
+func apiV1Routes() []apiRoute {
+	return []apiRoute{
+		{
+			Path:     apiV1Prefix + "stock",
+			Summary:  "Quote, fundamentals, derived and scored metrics for a symbol.",
+			Params:   []apiParam{symbolParam, currencyParam},
+			Response: reflect.TypeOf(APIStock{}),
+			Handler:  apiV1StockHandler,
+		},
+		{
+			Path:     apiV1Prefix + "metrics",
+			Summary:  "Scored metrics exactly as shown on the stock page, with the thresholds used.",
+			Params:   []apiParam{symbolParam, currencyParam},
+			Response: reflect.TypeOf(APIMetrics{}),
+			Handler:  apiV1MetricsHandler,
+		},
+		{
+			Path:    apiV1Prefix + "batch",
+			Summary: "Stock data for many symbols. Per-symbol failures are reported in each result. Add stream=1 for NDJSON.",
+			Params: []apiParam{
+				{Name: "symbols", Description: "Comma separated ticker symbols, e.g. AAPL,MSFT. Use the POST body instead for long lists."},
+				{Name: "stream", Description: "Set to 1 to stream one JSON result per line as each symbol completes."},
+			},
+			RequestBody: reflect.TypeOf(APIBatchRequest{}),
+			Response:    reflect.TypeOf(APIBatch{}),
+			Handler:     apiV1BatchHandler,
+		},
+		{
+			Path:     apiV1Prefix + "quote",
+			Summary:  "Current quote for a symbol.",
+			Params:   []apiParam{symbolParam},
+			Response: reflect.TypeOf(APIQuote{}),
+			Handler:  apiV1QuoteHandler,
+		},
+		{
+			Path:     apiV1Prefix + "fundamentals",
+			Summary:  "Valuation, profitability and balance sheet fundamentals for a symbol.",
+			Params:   []apiParam{symbolParam},
+			Response: reflect.TypeOf(APIFundamentals{}),
+			Handler:  apiV1FundamentalsHandler,
+		},
+		{
+			Path:    apiV1Prefix + "options",
+			Summary: "Option chain for one expiration with implied volatility, Greeks, put/call ratios and max pain.",
+			Params: []apiParam{
+				symbolParam,
+				{Name: expirationDateParam, Description: "Expiration as YYYY-MM-DD, one of those listed in expirations. Defaults to the nearest."},
+			},
+			Response: reflect.TypeOf(APIOptions{}),
+			Handler:  apiV1OptionsHandler,
+		},
+		{
+			Path:    apiV1Prefix + "history",
+			Summary: "Daily history of one scored metric, recorded the first time each day the symbol is looked up.",
+			Params: []apiParam{
+				symbolParam,
+				{Name: "metric", Description: "Metric name as on the stock page, e.g. P/E Ratio.", Required: true},
+			},
+			Response: reflect.TypeOf(APIMetricHistory{}),
+			Handler:  apiV1HistoryHandler,
+		},
+		{
+			Path:         apiV1Prefix + "alerts",
+			Summary:      "Alert rules with what each last saw. POST a rule to add one and DELETE one by id; both return the rules afterwards.",
+			RequestBody:  reflect.TypeOf(APIAlertRequest{}),
+			DeleteParams: []apiParam{{Name: "id", Description: "The rule to delete.", Required: true}},
+			Response:     reflect.TypeOf(APIAlerts{}),
+			Handler:      apiV1AlertsHandler,
+		},
+		{
+			Path:    apiV1Prefix + "alerts/history",
+			Summary: "Alerts that fired, newest first.",
+			Params: []apiParam{
+				{Name: "id", Description: "Only the alerts of this rule."},
+			},
+			Response: reflect.TypeOf(APIAlertHistory{}),
+			Handler:  apiV1AlertHistoryHandler,
+		},
+		{
+			Path:    apiV1Prefix + "search",
+			Summary: "Symbols whose ticker or company name matches a query, for autocomplete.",
+			Params: []apiParam{
+				{Name: "q", Description: "Ticker or company name, e.g. appl or apple.", Required: true},
+				{Name: "limit", Description: "Maximum results, up to 10. Defaults to 8."},
+			},
+			Response: reflect.TypeOf(APISearch{}),
+			Handler:  searchHandler,
+		},
+		{
+			Path:     apiV1Prefix + "openapi.json",
+			Summary:  "This OpenAPI 3 document.",
+			Response: reflect.TypeOf(map[string]interface{}{}),
+			Handler:  openAPIHandler,
+		},
+	}
+}
+
+func toAPIStock(symbol string, result *Result) APIStock {
+	return APIStock{
+		Symbol:       symbol,
+		QuoteType:    result.quoteType(),
+		Quote:        toAPIQuote(symbol, result),
+		Fundamentals: toAPIFundamentals(result),
+		Derived:      toAPIDerived(result),
+		Metrics:      toAPIMetrics(buildMetricsList(result)),
+		Fund:         toAPIFund(result),
+		Crypto:       toAPICrypto(result),
+		Currency:     toAPICurrencyPair(symbol, result),
+		Earnings:     toAPIEarnings(result),
+		Analysts:     toAPIAnalysts(result),
+		Ownership:    toAPIOwnership(result, time.Now()),
+		Dividends:    toAPIDividends(result, time.Now()),
+		Options:      toAPIOptionsNext(result, time.Now()),
+	}
+}
+
+func toAPIQuote(symbol string, result *Result) APIQuote {
+	sd := result.SummaryDetail
+	return APIQuote{
+		Symbol:           symbol,
+		Currency:         sd.Currency,
+		Price:            result.price(),
+		PreviousClose:    sd.PreviousClose.Raw,
+		Open:             sd.Open.Raw,
+		DayLow:           sd.DayLow.Raw,
+		DayHigh:          sd.DayHigh.Raw,
+		FiftyTwoWeekLow:  sd.FiftyTwoWeekLow.Raw,
+		FiftyTwoWeekHigh: sd.FiftyTwoWeekHigh.Raw,
+		Volume:           sd.Volume.Raw,
+		AverageVolume:    sd.AverageVolume.Raw,
+		MarketCap:        sd.MarketCap.Raw,
+	}
+}
+
+func toAPIFundamentals(result *Result) APIFundamentals {
+	sd, fd, ks := result.SummaryDetail, result.FinancialData, result.DefaultKeyStatistics
+	return APIFundamentals{
+		FinancialCurrency: fd.FinancialCurrency,
+		TrailingPE:        sd.TrailingPE.Raw,
+		ForwardPE:         sd.ForwardPE.Raw,
+		PriceToBook:       result.priceToBook(),
+		PriceToSales:      result.priceToSales(),
+		DebtToEquity:      fd.DebtToEquity.Raw,
+		CurrentRatio:      fd.CurrentRatio.Raw,
+		QuickRatio:        fd.QuickRatio.Raw,
+		ReturnOnEquity:    fd.ReturnOnEquity.Raw,
+		ReturnOnAssets:    fd.ReturnOnAssets.Raw,
+		GrossMargin:       fd.GrossMargins.Raw,
+		OperatingMargin:   fd.OperatingMargins.Raw,
+		ProfitMargin:      ks.ProfitMargins.Raw,
+		RevenueGrowth:     fd.RevenueGrowth.Raw,
+		EarningsGrowth:    fd.EarningsGrowth.Raw,
+		TotalRevenue:      fd.TotalRevenue.Raw,
+		Ebitda:            fd.Ebitda.Raw,
+		FreeCashflow:      fd.FreeCashflow.Raw,
+		TotalCash:         fd.TotalCash.Raw,
+		TotalDebt:         fd.TotalDebt.Raw,
+		EnterpriseValue:   ks.EnterpriseValue.Raw,
+		SharesOutstanding: ks.SharesOutstanding.Raw,
+		BookValue:         ks.BookValue.Raw,
+		Beta:              sd.Beta.Raw,
+		DividendYield:     sd.DividendYield.Raw,
+	}
+}
+
+func toAPIDerived(result *Result) APIDerived {
+	var peg float64
+	if result.FinancialData.EarningsGrowth.Raw != 0 {
+		peg = result.SummaryDetail.TrailingPE.Raw / result.FinancialData.EarningsGrowth.Raw
+	}
+	d := APIDerived{
+		PEGRatio: peg,
+		ROIC:     CalculateROIC(result.FinancialData, result.DefaultKeyStatistics),
+	}
+	if result.isCrypto() || result.isCurrency() {
+		d.Volatility = volatility(result)
+		d.RangePosition = rangePosition(result)
+	}
+	return d
+}
+
+func toAPIMetrics(metrics []Metric) []APIMetric {
+	out := make([]APIMetric, 0, len(metrics))
+	for _, m := range metrics {
+		out = append(out, APIMetric{
+			Name:       m.Name,
+			Value:      m.Raw,
+			Formatted:  m.Value,
+			Unit:       m.Unit,
+			Color:      m.Color,
+			Reason:     plainReason(m.Reason),
+			ReasonHTML: m.Reason,
+			Threshold:  m.Threshold,
+			Currency:   m.Currency,
+			Note:       m.Note,
+		})
+	}
+	return out
+}
+
+var htmlTagRe = regexp.MustCompile(`<[^>]*>`)
+
+// Reasons are written for the HTML cards, so turn line breaks into newlines
+// and drop any other markup.
+func plainReason(reason string) string {
+	reason = strings.ReplaceAll(reason, "<br>", "\n")
+	return strings.TrimSpace(htmlTagRe.ReplaceAllString(reason, ""))
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	writeAPIJSON(w, status, APIError{Error: APIErrorBody{Code: code, Message: message}})
+}
+
+// Reads the symbol parameter and fetches it, writing the error response
+// itself when that fails. Returns a nil result if the caller should stop.
+func fetchAPIResult(w http.ResponseWriter, r *http.Request) (string, *Result) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "only GET is supported")
+		return "", nil
+	}
+	raw := r.URL.Query().Get("symbol")
+	if strings.TrimSpace(raw) == "" {
+		writeAPIError(w, http.StatusBadRequest, errCodeMissingSymbol, "symbol parameter required")
+		return "", nil
+	}
+	symbol, err := common.ParseSymbol(raw)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidSymbol, err.Error())
+		return "", nil
+	}
+	var display string
+	if raw := r.URL.Query().Get(displayCurrencyParam); raw != "" {
+		if display, err = common.ParseCurrency(raw); err != nil {
+			writeAPIError(w, http.StatusBadRequest, errCodeInvalidCurrency, err.Error())
+			return "", nil
+		}
+	}
+	result, err := fetchStockMetrics(r.Context(), symbol)
+	if errors.Is(err, ErrSymbolNotFound) {
+		writeAPIError(w, http.StatusNotFound, errCodeSymbolNotFound, err.Error())
+		return "", nil
+	}
+	var busy *upstreamBusyError
+	if errors.As(err, &busy) {
+		writeRateLimited(w, r, busy.retryAfter)
+		return "", nil
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, errCodeUpstream, err.Error())
+		return "", nil
+	}
+	return symbol.String(), withCurrencies(r.Context(), result, display)
+}
+
+func apiV1StockHandler(w http.ResponseWriter, r *http.Request) {
+	symbol, result := fetchAPIResult(w, r)
+	if result == nil {
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, toAPIStock(symbol, result))
+}
+
+func apiV1MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	symbol, result := fetchAPIResult(w, r)
+	if result == nil {
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, APIMetrics{
+		Symbol:  symbol,
+		Metrics: toAPIMetrics(buildMetricsList(result)),
+	})
+}
+
+func apiV1QuoteHandler(w http.ResponseWriter, r *http.Request) {
+	symbol, result := fetchAPIResult(w, r)
+	if result == nil {
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, toAPIQuote(symbol, result))
+}
+
+func apiV1FundamentalsHandler(w http.ResponseWriter, r *http.Request) {
+	_, result := fetchAPIResult(w, r)
+	if result == nil {
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, toAPIFundamentals(result))
+}
+
+func apiV1NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeAPIError(w, http.StatusNotFound, errCodeNotFound, "no such endpoint: "+r.URL.Path)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// How many symbols of a batch are fetched from upstream at once.
+	batchConcurrency = 4
+	maxBatchSymbols  = 500
+)
+
+var (
+	errBatchEmpty       = errors.New("symbols parameter required")
+	errBatchInvalidBody = errors.New("invalid JSON body")
+	errBatchTooMany     = fmt.Errorf("at most %d symbols per batch", maxBatchSymbols)
+)
+
+type APIBatchRequest struct {
+	Symbols []string `json:"symbols"`
+}
+
+// APIBatchItem is the outcome for one symbol. Exactly one of Stock and Error
+// is set.
+type APIBatchItem struct {
+	Symbol string        `json:"symbol"`
+	Stock  *APIStock     `json:"stock,omitempty"`
+	Error  *APIErrorBody `json:"error,omitempty"`
+}
+
+type APIBatch struct {
+	Results []APIBatchItem `json:"results" doc:"One entry per requested symbol, in request order."`
+}
+
+// batchSymbol is one requested symbol. Err is set when Input doesn't parse;
+// such an entry is reported as invalid_symbol without being fetched.
+type batchSymbol struct {
+	Input  string
+	Symbol common.Symbol
+	Err    error
+}
+
+// parseSymbolList parses each non-blank entry of raw. Duplicates are dropped,
+// keeping the first occurrence.
+func parseSymbolList(raw []string) []batchSymbol {
+	seen := map[string]bool{}
+	var symbols []batchSymbol
+	for _, s := range raw {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		symbol, err := common.ParseSymbol(s)
+		key := string(symbol)
+		if err != nil {
+			key = s
+		}
+		if !seen[key] {
+			seen[key] = true
+			symbols = append(symbols, batchSymbol{Input: s, Symbol: symbol, Err: err})
+		}
+	}
+	return symbols
+}
+
+// Reads symbols from ?symbols=A,B,C or a JSON body on POST. An invalid symbol
+// only fails its own entry; the batch fails when it is empty or too large.
+func parseBatchSymbols(r *http.Request) ([]batchSymbol, error) {
+	var raw []string
+	switch r.Method {
+	case http.MethodGet:
+		raw = strings.Split(r.URL.Query().Get("symbols"), ",")
+	case http.MethodPost:
+		var req APIBatchRequest
+		if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20)).Decode(&req); err != nil {
+			return nil, fmt.Errorf("%w: %v", errBatchInvalidBody, err)
+		}
+		raw = req.Symbols
+	}
+
+	symbols := parseSymbolList(raw)
+	if len(symbols) == 0 {
+		return nil, errBatchEmpty
+	}
+	if len(symbols) > maxBatchSymbols {
+		return nil, errBatchTooMany
+	}
+	return symbols, nil
+}
+
+func fetchBatchItem(ctx context.Context, ticker common.Symbol) APIBatchItem {
+	symbol := ticker.String()
+	result, err := fetchStockMetrics(ctx, ticker)
+	if errors.Is(err, ErrSymbolNotFound) {
+		return APIBatchItem{Symbol: symbol, Error: &APIErrorBody{Code: errCodeSymbolNotFound, Message: err.Error()}}
+	}
+	var busy *upstreamBusyError
+	if errors.As(err, &busy) {
+		return APIBatchItem{Symbol: symbol, Error: &APIErrorBody{Code: errCodeRateLimited, Message: err.Error()}}
+	}
+	if err != nil {
+		return APIBatchItem{Symbol: symbol, Error: &APIErrorBody{Code: errCodeUpstream, Message: err.Error()}}
+	}
+	stock := toAPIStock(symbol, result)
+	return APIBatchItem{Symbol: symbol, Stock: &stock}
+}
+
+// fetchBatch fetches symbols with at most batchConcurrency in flight and
+// calls emit for each as it completes, along with its index in symbols.
+// Invalid symbols are emitted straight away. emit is never called
+// concurrently.
+func fetchBatch(ctx context.Context, symbols []batchSymbol, emit func(int, APIBatchItem)) {
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		sem = make(chan struct{}, batchConcurrency)
+	)
+	for i, s := range symbols {
+		if s.Err != nil {
+			mu.Lock()
+			emit(i, APIBatchItem{Symbol: s.Input, Error: &APIErrorBody{Code: errCodeInvalidSymbol, Message: s.Err.Error()}})
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, symbol common.Symbol) {
+			defer wg.Done()
+			item := fetchBatchItem(ctx, symbol)
+			<-sem
+			mu.Lock()
+			emit(i, item)
+			mu.Unlock()
+		}(i, s.Symbol)
+	}
+	wg.Wait()
+}
+
+func wantsNDJSON(r *http.Request) bool {
+	return r.URL.Query().Get("stream") == "1" || strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
+}
+
+// apiV1BatchHandler fetches many symbols in one call. A failed symbol is
+// reported in its own entry and doesn't fail the batch. With ?stream=1 or
+// Accept: application/x-ndjson, each APIBatchItem is written as its own line
+// as soon as it is ready, in completion order.
+func apiV1BatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "only GET and POST are supported")
+		return
+	}
+	symbols, err := parseBatchSymbols(r)
+	if err != nil {
+		code := errCodeMissingSymbol
+		switch {
+		case errors.Is(err, errBatchInvalidBody):
+			code = errCodeInvalidBody
+		case errors.Is(err, errBatchTooMany):
+			code = errCodeTooManySymbols
+		}
+		writeAPIError(w, http.StatusBadRequest, code, err.Error())
+		return
+	}
+
+	if wantsNDJSON(r) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		flusher, _ := w.(http.Flusher)
+		enc := json.NewEncoder(w)
+		fetchBatch(r.Context(), symbols, func(_ int, item APIBatchItem) {
+			enc.Encode(item)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		})
+		return
+	}
+
+	results := make([]APIBatchItem, len(symbols))
+	fetchBatch(r.Context(), symbols, func(i int, item APIBatchItem) {
+		results[i] = item
+	})
+	writeAPIJSON(w, http.StatusOK, APIBatch{Results: results})
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	common "app/internal/common"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAPIV1Batch(t *testing.T) {
+	withFetcher(t, func(symbol string) (*Result, error) {
+		if symbol == "BAD" {
+			return nil, fmt.Errorf("no data: %w", ErrSymbolNotFound)
+		}
+		return testResult(), nil
+	})
+
+	rec := httptest.NewRecorder()
+	apiV1BatchHandler(rec, httptest.NewRequest("GET", "/api/v1/batch?symbols=msft,BAD,aapl,MSFT", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var got APIBatch
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	var symbols []string
+	for _, item := range got.Results {
+		symbols = append(symbols, item.Symbol)
+	}
+	if strings.Join(symbols, ",") != "MSFT,BAD,AAPL" {
+		t.Errorf("symbols = %v, want request order without duplicates", symbols)
+	}
+	if got.Results[1].Error == nil || got.Results[1].Error.Code != errCodeSymbolNotFound || got.Results[1].Stock != nil {
+		t.Errorf("BAD result = %+v, want symbol_not_found error", got.Results[1])
+	}
+	if got.Results[2].Stock == nil || got.Results[2].Stock.Quote.Price != 190 {
+		t.Errorf("AAPL result = %+v", got.Results[2])
+	}
+}
+
+func TestAPIV1BatchPOST(t *testing.T) {
+	withFetcher(t, func(string) (*Result, error) { return testResult(), nil })
+
+	body := strings.NewReader(`{"symbols": ["AAPL", "GOOG"]}`)
+	rec := httptest.NewRecorder()
+	apiV1BatchHandler(rec, httptest.NewRequest("POST", "/api/v1/batch", body))
+
+	var got APIBatch
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Results) != 2 || got.Results[1].Symbol != "GOOG" {
+		t.Errorf("results = %+v", got.Results)
+	}
+}
+
+func TestAPIV1BatchCanonicalizesSymbols(t *testing.T) {
+	withFetcher(t, func(string) (*Result, error) { return testResult(), nil })
+
+	rec := httptest.NewRecorder()
+	apiV1BatchHandler(rec, httptest.NewRequest("POST", "/api/v1/batch", strings.NewReader(`{"symbols": ["brk.b", "BRK-B", "BRK/B"]}`)))
+	var got APIBatch
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Results) != 1 || got.Results[0].Symbol != "BRK-B" {
+		t.Errorf("results = %+v, want one BRK-B", got.Results)
+	}
+
+	rec = httptest.NewRecorder()
+	apiV1BatchHandler(rec, httptest.NewRequest("GET", "/api/v1/batch?symbols=AAPL,../etc", nil))
+	got = APIBatch{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || len(got.Results) != 2 {
+		t.Fatalf("invalid symbol = %d %s", rec.Code, rec.Body.String())
+	}
+	if got.Results[0].Stock == nil {
+		t.Errorf("AAPL = %+v, want fetched", got.Results[0])
+	}
+	if bad := got.Results[1]; bad.Symbol != "../etc" || bad.Error == nil || bad.Error.Code != errCodeInvalidSymbol {
+		t.Errorf("../etc = %+v, want invalid_symbol", bad)
+	}
+}
+
+func TestAPIV1BatchNDJSON(t *testing.T) {
+	withFetcher(t, func(symbol string) (*Result, error) {
+		if symbol == "DOWN" {
+			return nil, errors.New("timeout")
+		}
+		return testResult(), nil
+	})
+
+	rec := httptest.NewRecorder()
+	apiV1BatchHandler(rec, httptest.NewRequest("GET", "/api/v1/batch?symbols=AAPL,DOWN,MSFT&stream=1", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	seen := map[string]APIBatchItem{}
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var item APIBatchItem
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		seen[item.Symbol] = item
+	}
+	if len(seen) != 3 {
+		t.Fatalf("got %d lines, want 3", len(seen))
+	}
+	if seen["DOWN"].Error == nil || seen["DOWN"].Error.Code != errCodeUpstream {
+		t.Errorf("DOWN = %+v, want upstream_error", seen["DOWN"])
+	}
+}
+
+func TestAPIV1BatchErrors(t *testing.T) {
+	var tooMany []string
+	for i := 0; i <= maxBatchSymbols; i++ {
+		tooMany = append(tooMany, fmt.Sprintf("S%d", i))
+	}
+	for _, tc := range []struct {
+		name, method, url, body, code string
+	}{
+		{"empty", "GET", "/api/v1/batch?symbols=,,", "", errCodeMissingSymbol},
+		{"bad body", "POST", "/api/v1/batch", "not json", errCodeInvalidBody},
+		{"too many", "GET", "/api/v1/batch?symbols=" + strings.Join(tooMany, ","), "", errCodeTooManySymbols},
+	} {
+		rec := httptest.NewRecorder()
+		apiV1BatchHandler(rec, httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body)))
+		var body APIError
+		json.NewDecoder(rec.Body).Decode(&body)
+		if rec.Code != http.StatusBadRequest || body.Error.Code != tc.code {
+			t.Errorf("%s: status %d, code %q, want 400 %s", tc.name, rec.Code, body.Error.Code, tc.code)
+		}
+	}
+}
+
+func TestFetchBatchBoundsConcurrency(t *testing.T) {
+	var inFlight, peak int32
+	withFetcher(t, func(string) (*Result, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return testResult(), nil
+	})
+
+	var symbols []batchSymbol
+	for i := 0; i < 20; i++ {
+		symbols = append(symbols, batchSymbol{Symbol: common.Symbol(fmt.Sprintf("S%d", i))})
+	}
+	count := 0
+	fetchBatch(context.Background(), symbols, func(int, APIBatchItem) { count++ })
+
+	if count != len(symbols) {
+		t.Errorf("emitted %d items, want %d", count, len(symbols))
+	}
+	if peak > batchConcurrency {
+		t.Errorf("peak concurrency = %d, want <= %d", peak, batchConcurrency)
+	}
+}
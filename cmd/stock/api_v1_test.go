@@ -0,0 +1,151 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testResult() *Result {
+	var r Result
+	r.SummaryDetail.Currency = "USD"
+	r.SummaryDetail.TrailingPE = FmtRaw{Raw: 30}
+	r.SummaryDetail.MarketCap = FmtRaw{Raw: 3_000_000_000_000}
+	r.SummaryDetail.FiftyTwoWeekHigh = FmtRaw{Raw: 200}
+	r.FinancialData.CurrentPrice = FmtRaw{Raw: 190}
+	r.FinancialData.EarningsGrowth = FmtRaw{Raw: 0.1}
+	r.FinancialData.FinancialCurrency = "USD"
+	return &r
+}
+
+// withFetcher swaps fetchStockMetrics for the duration of a test.
+func withFetcher(t *testing.T, f func(string) (*Result, error)) {
+	orig := fetchStockMetrics
+	fetchStockMetrics = func(_ context.Context, symbol common.Symbol) (*Result, error) { return f(symbol.String()) }
+	t.Cleanup(func() { fetchStockMetrics = orig })
+}
+
+func TestAPIV1Stock(t *testing.T) {
+	withFetcher(t, func(symbol string) (*Result, error) {
+		if symbol != "AAPL" {
+			t.Errorf("fetched %q, want AAPL", symbol)
+		}
+		return testResult(), nil
+	})
+
+	rec := httptest.NewRecorder()
+	apiV1StockHandler(rec, httptest.NewRequest("GET", "/api/v1/stock?symbol=aapl", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var got APIStock
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Symbol != "AAPL" || got.Quote.Price != 190 || got.Quote.Currency != "USD" {
+		t.Errorf("unexpected quote: %+v", got.Quote)
+	}
+	if got.Fundamentals.TrailingPE != 30 {
+		t.Errorf("TrailingPE = %v, want 30", got.Fundamentals.TrailingPE)
+	}
+	if got.Derived.PEGRatio != 300 {
+		t.Errorf("PEGRatio = %v, want 300", got.Derived.PEGRatio)
+	}
+	for _, m := range got.Metrics {
+		if m.Name == "P/E Ratio" {
+			if m.Value != 30 || m.Color != "red" || m.Formatted != "30.00" {
+				t.Errorf("unexpected P/E metric: %+v", m)
+			}
+			return
+		}
+	}
+	t.Error("P/E Ratio metric missing")
+}
+
+func TestAPIV1Metrics(t *testing.T) {
+	withFetcher(t, func(string) (*Result, error) { return testResult(), nil })
+
+	rec := httptest.NewRecorder()
+	apiV1MetricsHandler(rec, httptest.NewRequest("GET", "/api/v1/metrics?symbol=AAPL", nil))
+
+	var got APIMetrics
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	byName := map[string]APIMetric{}
+	for _, m := range got.Metrics {
+		byName[m.Name] = m
+	}
+
+	peg := byName["PEG Ratio"]
+	if peg.Threshold == nil || peg.Threshold.Green != 15 || peg.Unit != unitRatio {
+		t.Errorf("unexpected PEG metric: %+v", peg)
+	}
+	if peg.ReasonHTML == peg.Reason || !strings.Contains(peg.ReasonHTML, "<br>") {
+		t.Errorf("expected HTML and plain reasons to differ, got %q and %q", peg.ReasonHTML, peg.Reason)
+	}
+
+	growth := byName["Earnings Growth"]
+	if growth.Unit != unitPercent || growth.Formatted != "10.00%" || growth.Color != "yellow" {
+		t.Errorf("unexpected Earnings Growth metric: %+v", growth)
+	}
+
+	if price := byName["Price"]; price.Threshold != nil || price.Unit != unitCurrency {
+		t.Errorf("unexpected Price metric: %+v", price)
+	}
+}
+
+func TestAPIV1Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		fetchErr   error
+		wantStatus int
+		wantCode   string
+	}{
+		{"missing symbol", "GET", "/api/v1/quote", nil, http.StatusBadRequest, errCodeMissingSymbol},
+		{"path traversal", "GET", "/api/v1/quote?symbol=../../etc", nil, http.StatusBadRequest, errCodeInvalidSymbol},
+		{"space", "GET", "/api/v1/quote?symbol=A%20B", nil, http.StatusBadRequest, errCodeInvalidSymbol},
+		{"not found", "GET", "/api/v1/quote?symbol=ZZZZ", fmt.Errorf("no data: %w", ErrSymbolNotFound), http.StatusNotFound, errCodeSymbolNotFound},
+		{"upstream", "GET", "/api/v1/quote?symbol=AAPL", errors.New("boom"), http.StatusBadGateway, errCodeUpstream},
+		{"method", "POST", "/api/v1/quote?symbol=AAPL", nil, http.StatusMethodNotAllowed, errCodeMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withFetcher(t, func(string) (*Result, error) {
+				if tt.fetchErr != nil {
+					return nil, tt.fetchErr
+				}
+				return testResult(), nil
+			})
+			rec := httptest.NewRecorder()
+			apiV1QuoteHandler(rec, httptest.NewRequest(tt.method, tt.url, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var got APIError
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("error body is not JSON: %v", err)
+			}
+			if got.Error.Code != tt.wantCode || got.Error.Message == "" {
+				t.Errorf("error = %+v, want code %q", got.Error, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestPlainReason(t *testing.T) {
+	got := plainReason("High P/E.<br><br>PEG = <b>P/E</b> / growth")
+	want := "High P/E.\n\nPEG = P/E / growth"
+	if got != want {
+		t.Errorf("plainReason() = %q, want %q", got, want)
+	}
+}
@@ -156,11 +156,13 @@ cat > "${BIN_DIR}/${NAME}" << EOF
 VIP=\$(ip -o -4 addr show | awk '{print \$4}' | grep -oE '10\.100\.[0-9]+\.[0-9]+' | head -n 1)
 EIP=\$(ip route get 8.8.8.8 | awk '/src/ {print \$7}')
 
+export STOCK_CONFIG=\${STOCK_CONFIG:-/etc/${NAME}/config.yaml}
+
 ARCH=\$(uname -m)
 if [[ "\$ARCH" == "x86_64" ]]; then
-    exec /opt/${NAME}/bin/${NAME}-amd64 --port=$PORT --data=/opt/${NAME}/data
+    exec /opt/${NAME}/bin/${NAME}-amd64 "\$@"
 elif [[ "\$ARCH" == "aarch64" ]]; then
-    exec /opt/${NAME}/bin/${NAME}-arm64 --port=$PORT --data=/opt/${NAME}/data
+    exec /opt/${NAME}/bin/${NAME}-arm64 "\$@"
 else
     echo "Unsupported architecture: \$ARCH"
     exit 1
@@ -168,6 +170,92 @@ fi
 EOF
 chmod +x "${BIN_DIR}/${NAME}"
 
+# Create the config file. It's a conffile, so dpkg keeps local edits on upgrade.
+# Check changes with: /opt/${NAME}/bin/${NAME} config validate
+mkdir -p "${BUILD_DIR}/etc/${NAME}"
+cat > "${BUILD_DIR}/etc/${NAME}/config.yaml" << EOF
+# Settings for ${NAME}. Each can also be set with a STOCK_* environment
+# variable named after its path, e.g. STOCK_LISTEN_PORT=8082, which wins over
+# this file. Command-line flags win over both.
+listen:
+  ip: ""
+  port: ${PORT}
+tls:
+  # Serve HTTPS on listen.port. Without cert_file a self-signed certificate is
+  # generated in the data dir; point these at e.g. certbot's files instead:
+  #   cert_file: /etc/letsencrypt/live/example.com/fullchain.pem
+  #   key_file: /etc/letsencrypt/live/example.com/privkey.pem
+  # They're reloaded when renewed. The service user must be able to read them.
+  enabled: false
+  cert_file: ""
+  key_file: ""
+  # Plain HTTP port redirecting to HTTPS, 0 for none. Open it in ufw too.
+  redirect_port: 0
+  # Not sent with the self-signed certificate.
+  hsts_max_age: 8760h
+health:
+  # 0 means listen.port + 1.
+  port: 0
+  # Serve the health port over HTTPS too.
+  tls: false
+data_dir: /opt/${NAME}/data
+provider: yahoo
+cache:
+  quote_ttl: 1h
+# Per minute; 0 means unlimited. Over the limit, clients get a 429 with
+# Retry-After.
+rate_limit:
+  # Per client IP on the HTML and API routes.
+  requests_per_minute: 120
+  burst: 30
+  # Shared by all requests to Yahoo, including Chrome session launches.
+  # Fetches queue for up to upstream_max_wait before giving up.
+  upstream_per_minute: 60
+  upstream_max_wait: 10s
+  # Proxies whose X-Forwarded-For is trusted to name the client.
+  trusted_proxies: ["127.0.0.1", "::1"]
+auth:
+  # none, or api_key to require a key on /api/. Manage keys as the service user:
+  #   sudo -u ${USER} /opt/${NAME}/bin/${NAME} apikey create -name NAME
+  mode: none
+  # Also require a key on the HTML pages.
+  require_for_html: false
+  # Per key, unless the key was created with its own -rate. 0 means unlimited.
+  key_requests_per_minute: 300
+  # Who may use the HTML pages: none, local or oidc. Add local users with:
+  #   sudo -u ${USER} /opt/${NAME}/bin/${NAME} user add USERNAME
+  login: none
+  session_ttl: 168h
+  oidc:
+    issuer: ""
+    client_id: ""
+    # Or keep it out of this file with STOCK_AUTH_OIDC_CLIENT_SECRET.
+    client_secret: ""
+    # This server's callback as the identity provider sees it.
+    redirect_url: https://example.com/auth/oidc/callback
+log:
+  # text or json
+  format: text
+  # debug, info, warn or error. Change at runtime with:
+  #   curl -X PUT 'localhost:$((PORT + 1))/log/level?level=debug'
+  level: info
+metrics:
+  tax_rate: 0.21
+  # Annual risk-free rate for option Greeks and implied volatility.
+  risk_free_rate: 0.04
+  # Override scoring thresholds by metric name. Percentages are fractions.
+  # thresholds:
+  #   "P/E Ratio":
+  #     green: 15
+  #     yellow: 25
+alerts:
+  # How often alert rules are checked; 0 turns checking off.
+  interval: 15m
+  # How long a rule stays quiet for a symbol after firing.
+  cooldown: 24h
+EOF
+echo "/etc/${NAME}/config.yaml" > "${DEBIAN_DIR}/conffiles"
+
 # Create systemd service
 cat > "${SERVICE_DIR}/${NAME}.service" << EOF
 [Unit]
@@ -262,10 +350,23 @@ TIMEOUT=30
 INTERVAL=1
 elapsed=0
 
-PORT=$PORT
+# The health port and scheme come from the effective config, so an edited
+# conffile is honored. With health.tls it serves HTTPS, usually self-signed.
+EFFECTIVE_CONFIG=\$(/opt/${NAME}/bin/${NAME} config show --effective 2>/dev/null)
+health_setting() {
+    echo "\$EFFECTIVE_CONFIG" | awk -v key="\$1:" '/^health:/ {h=1; next} /^[^ ]/ {h=0} h && \$1 == key {print \$2}'
+}
+HEALTH_PORT=\$(health_setting port)
+HEALTH_PORT=\${HEALTH_PORT:-$((PORT + 1))}
+SCHEME=http
+CURL_OPTS="-sf"
+if [ "\$(health_setting tls)" = true ]; then
+    SCHEME=https
+    CURL_OPTS="-skf"
+fi
 check_healthy() {
-    echo "Checking curl -sf --connect-timeout 2 --max-time 5 http://localhost:$((PORT + 1))/health >/dev/null"
-    curl -sf --connect-timeout 2 --max-time 5 http://localhost:$((PORT + 1))/health >/dev/null
+    echo "Checking curl \$CURL_OPTS --connect-timeout 2 --max-time 5 \$SCHEME://localhost:\$HEALTH_PORT/health/ready >/dev/null"
+    curl \$CURL_OPTS --connect-timeout 2 --max-time 5 \$SCHEME://localhost:\$HEALTH_PORT/health/ready >/dev/null
 }
 
 echo "Waiting up to \${TIMEOUT} seconds for ${NAME} service to send watchdog ping..."
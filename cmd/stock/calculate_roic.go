@@ -1,9 +1,12 @@
 package main
 
+// Corporate tax rate used to approximate NOPAT. Set from the config file.
+var roicTaxRate = 0.21
+
 func CalculateROIC(financialData FinancialData, keyStats DefaultKeyStatistics) float64 {
 	// Approximate NOPAT
 	ebitda := financialData.Ebitda.Raw
-	taxRate := 0.21 // Use actual if available
+	taxRate := roicTaxRate // Use actual if available
 	nopat := ebitda * (1 - taxRate)
 
 	// Calculate Equity = Book Value × Shares Outstanding
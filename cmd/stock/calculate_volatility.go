@@ -0,0 +1,47 @@
+package main
+
+import "math"
+
+// How many daily closes the volatility is measured over.
+const volatilityWindow = 30
+
+// CalculateVolatility returns the annualized standard deviation of daily log
+// returns over the last volatilityWindow closes. tradingDays is how many
+// closes make a year: 365 for crypto, which never closes, 252 for FX. It
+// returns 0 when there are too few closes to say.
+func CalculateVolatility(closes []float64, tradingDays float64) float64 {
+	if len(closes) > volatilityWindow+1 {
+		closes = closes[len(closes)-volatilityWindow-1:]
+	}
+	var returns []float64
+	for i := 1; i < len(closes); i++ {
+		if closes[i-1] <= 0 || closes[i] <= 0 {
+			continue
+		}
+		returns = append(returns, math.Log(closes[i]/closes[i-1]))
+	}
+	if len(returns) < 2 {
+		return 0
+	}
+
+	var mean float64
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	variance /= float64(len(returns) - 1)
+	return math.Sqrt(variance * tradingDays)
+}
+
+// RangePosition is where price sits between low and high: 0 at the low, 1 at
+// the high. ok is false when the range is empty.
+func RangePosition(price, low, high float64) (position float64, ok bool) {
+	if high <= low {
+		return 0, false
+	}
+	return math.Max(0, math.Min(1, (price-low)/(high-low))), true
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalculateVolatility(t *testing.T) {
+	// Alternating +1%/-1% log returns: a daily stddev of about 1%.
+	closes := []float64{100}
+	for i := 0; i < 40; i++ {
+		closes = append(closes, closes[len(closes)-1]*math.Exp(0.01*float64(1-2*(i%2))))
+	}
+	daily := CalculateVolatility(closes, 1)
+	if math.Abs(daily-0.01) > 0.0005 {
+		t.Errorf("daily volatility = %v, want about 0.01", daily)
+	}
+	if got := CalculateVolatility(closes, 365); math.Abs(got-daily*math.Sqrt(365)) > 1e-9 {
+		t.Errorf("annualized volatility = %v", got)
+	}
+
+	// Only the last volatilityWindow returns count.
+	calm := append([]float64{1, 1000, 1}, closes[len(closes)-volatilityWindow-1:]...)
+	if got := CalculateVolatility(calm, 1); got != daily {
+		t.Errorf("volatility with old spike = %v, want %v", got, daily)
+	}
+
+	for _, few := range [][]float64{nil, {100}, {100, 101}, {100, 0, 0}} {
+		if got := CalculateVolatility(few, 365); got != 0 {
+			t.Errorf("CalculateVolatility(%v) = %v, want 0", few, got)
+		}
+	}
+}
+
+func TestRangePosition(t *testing.T) {
+	for _, tc := range []struct {
+		price, low, high, want float64
+		ok                     bool
+	}{
+		{50, 0, 100, 0.5, true},
+		{90, 80, 100, 0.5, true},
+		{120, 80, 100, 1, true},
+		{70, 80, 100, 0, true},
+		{80, 80, 100, 0, true},
+		{90, 100, 100, 0, false},
+		{90, 0, 0, 0, false},
+	} {
+		if got, ok := RangePosition(tc.price, tc.low, tc.high); got != tc.want || ok != tc.ok {
+			t.Errorf("RangePosition(%v, %v, %v) = %v, %v, want %v, %v", tc.price, tc.low, tc.high, got, ok, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	common "app/internal/common"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Settings are layered, each overriding the one before:
+//
+//  1. defaults below
+//  2. the config file (/etc/stock/config.yaml, or -config / STOCK_CONFIG)
+//  3. STOCK_* environment variables, named after the YAML path, e.g.
+//     STOCK_LISTEN_PORT or STOCK_CACHE_QUOTE_TTL
+//  4. command-line flags
+
+const defaultConfigPath = "/etc/stock/config.yaml"
+
+const envPrefix = "STOCK_"
+
+type Config struct {
+	Listen    ListenConfig    `yaml:"listen"`
+	TLS       TLSConfig       `yaml:"tls"`
+	Health    HealthConfig    `yaml:"health"`
+	DataDir   string          `yaml:"data_dir"`
+	Provider  string          `yaml:"provider"`
+	Cache     CacheConfig     `yaml:"cache"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+	Alerts    AlertsConfig    `yaml:"alerts"`
+	Log       LogConfig       `yaml:"log"`
+}
+
+type ListenConfig struct {
+	// Empty listens on all interfaces.
+	IP   string `yaml:"ip"`
+	Port int    `yaml:"port"`
+}
+
+type TLSConfig struct {
+	// Serve HTTPS on listen.port instead of HTTP.
+	Enabled bool `yaml:"enabled"`
+	// PEM files, reloaded when they change. Empty uses a self-signed
+	// certificate generated in the data dir on first run.
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// A plain HTTP port that redirects to HTTPS; 0 for none.
+	RedirectPort int `yaml:"redirect_port"`
+	// Strict-Transport-Security max-age; 0 to not send it. Never sent with the
+	// self-signed certificate, so browsers aren't locked out by it.
+	HSTSMaxAge time.Duration `yaml:"hsts_max_age"`
+}
+
+type HealthConfig struct {
+	// 0 means the listen port + 1.
+	Port int `yaml:"port"`
+	// Serve the health port over HTTPS too, with the main certificate.
+	TLS bool `yaml:"tls"`
+}
+
+type CacheConfig struct {
+	// How long a fetched quote is served before fetching again.
+	QuoteTTL time.Duration `yaml:"quote_ttl"`
+}
+
+// Limits are per minute; 0 means unlimited.
+type RateLimitConfig struct {
+	// Per client IP, on the HTML and API routes.
+	RequestsPerMinute int `yaml:"requests_per_minute"`
+	Burst             int `yaml:"burst"`
+	// Shared by every request to Yahoo, including Chrome session launches.
+	UpstreamPerMinute int `yaml:"upstream_per_minute"`
+	// How long a fetch may queue for the upstream budget before giving up
+	// with a 429.
+	UpstreamMaxWait time.Duration `yaml:"upstream_max_wait"`
+	// Proxies whose X-Forwarded-For is believed, as addresses or CIDRs.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+type AuthConfig struct {
+	// none, or api_key to require a key on /api/.
+	Mode string `yaml:"mode"`
+	// Also require a key for the HTML pages.
+	RequireForHTML bool `yaml:"require_for_html"`
+	// Per key, for keys created without their own rate; 0 means unlimited.
+	KeyRequestsPerMinute int `yaml:"key_requests_per_minute"`
+	// Who may use the HTML pages: none for anyone, local for users added
+	// with `stock user add`, or oidc for an OpenID Connect provider.
+	Login string `yaml:"login"`
+	// How long a login lasts.
+	SessionTTL time.Duration `yaml:"session_ttl"`
+	OIDC       OIDCConfig    `yaml:"oidc"`
+}
+
+type OIDCConfig struct {
+	Issuer       string `yaml:"issuer"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// This server's /auth/oidc/callback as the IdP sees it.
+	RedirectURL string `yaml:"redirect_url"`
+	// Defaults to openid, email and profile.
+	Scopes []string `yaml:"scopes,omitempty"`
+}
+
+type LogConfig struct {
+	// text or json.
+	Format string `yaml:"format"`
+	// debug, info, warn or error. Can be changed at runtime via /log/level on
+	// the health port.
+	Level string `yaml:"level"`
+}
+
+type MetricsConfig struct {
+	// Used to approximate NOPAT for ROIC.
+	TaxRate float64 `yaml:"tax_rate"`
+	// Annual risk-free rate for option Greeks and implied volatility.
+	RiskFreeRate float64 `yaml:"risk_free_rate"`
+	// Overrides for the scoring thresholds, keyed by metric name.
+	Thresholds map[string]ThresholdConfig `yaml:"thresholds,omitempty"`
+}
+
+type AlertsConfig struct {
+	// How often the alert rules are checked; 0 turns checking off.
+	Interval time.Duration `yaml:"interval"`
+	// How long a rule stays quiet for a symbol after firing, unless the rule
+	// sets its own.
+	Cooldown time.Duration `yaml:"cooldown"`
+}
+
+type ThresholdConfig struct {
+	Green  float64  `yaml:"green"`
+	Yellow *float64 `yaml:"yellow,omitempty"`
+}
+
+var validProviders = []string{"yahoo"}
+
+var validAuthModes = []string{"none", "api_key"}
+
+var validLoginModes = []string{"none", "local", "oidc"}
+
+var validLogFormats = []string{"text", "json"}
+
+var validLogLevels = []string{"debug", "info", "warn", "error"}
+
+func defaultConfig() Config {
+	return Config{
+		Listen:   ListenConfig{Port: 8080},
+		TLS:      TLSConfig{HSTSMaxAge: 365 * 24 * time.Hour},
+		Provider: "yahoo",
+		Cache: CacheConfig{
+			QuoteTTL: time.Hour,
+		},
+		RateLimit: RateLimitConfig{
+			RequestsPerMinute: 120,
+			Burst:             30,
+			UpstreamPerMinute: 60,
+			UpstreamMaxWait:   10 * time.Second,
+			TrustedProxies:    []string{"127.0.0.1", "::1"},
+		},
+		Auth:    AuthConfig{Mode: "none", KeyRequestsPerMinute: 300, Login: "none", SessionTTL: 7 * 24 * time.Hour},
+		Metrics: MetricsConfig{TaxRate: 0.21, RiskFreeRate: 0.04},
+		Alerts:  AlertsConfig{Interval: 15 * time.Minute, Cooldown: 24 * time.Hour},
+		Log:     LogConfig{Format: "text", Level: "info"},
+	}
+}
+
+// configFlags are the flags that can override the config file.
+type configFlags struct {
+	path    *string
+	port    *int
+	ip      *string
+	dataDir *string
+	fs      *flag.FlagSet
+}
+
+func addConfigFlags(fs *flag.FlagSet) *configFlags {
+	return &configFlags{
+		path:    fs.String("config", "", "config file (default $STOCK_CONFIG or "+defaultConfigPath+")"),
+		port:    fs.Int("port", 0, "port to listen on"),
+		ip:      fs.String("ip", "", "ip to listen on"),
+		dataDir: fs.String("data", "", "directory to store data"),
+		fs:      fs,
+	}
+}
+
+// configPath picks the file to load. Only the default path may be missing.
+func (f *configFlags) configPath() (path string, required bool) {
+	if *f.path != "" {
+		return *f.path, true
+	}
+	if env := os.Getenv(envPrefix + "CONFIG"); env != "" {
+		return env, true
+	}
+	return defaultConfigPath, false
+}
+
+// loadConfig builds the effective config from every layer and validates it.
+func loadConfig(f *configFlags) (Config, error) {
+	cfg := defaultConfig()
+
+	path, required := f.configPath()
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := decodeConfig(bytes.NewReader(data), &cfg); err != nil {
+			return cfg, fmt.Errorf("%s: %v", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && !required:
+	default:
+		return cfg, err
+	}
+
+	if err := applyEnv(&cfg, os.LookupEnv); err != nil {
+		return cfg, err
+	}
+
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "port":
+			cfg.Listen.Port = *f.port
+		case "ip":
+			cfg.Listen.IP = *f.ip
+		case "data":
+			cfg.DataDir = *f.dataDir
+		}
+	})
+
+	if cfg.Health.Port == 0 {
+		cfg.Health.Port = cfg.Listen.Port + 1
+	}
+	return cfg, cfg.Validate()
+}
+
+// Unknown keys are errors so a typo doesn't silently fall back to a default.
+func decodeConfig(r io.Reader, cfg *Config) error {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// applyEnv sets every scalar field that has a matching STOCK_* variable.
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	return walkEnv(reflect.ValueOf(cfg).Elem(), envPrefix, lookup)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func walkEnv(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		name := prefix + strings.ToUpper(tag)
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			if err := walkEnv(fv, name+"_", lookup); err != nil {
+				return err
+			}
+			continue
+		}
+		raw, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setFromString(fv, raw); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func setFromString(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("cannot be set from the environment")
+		}
+		// Comma separated.
+		var list []string
+		for _, s := range strings.Split(raw, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("cannot be set from the environment")
+	}
+	return nil
+}
+
+// Validate reports every problem at once rather than just the first.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	check(c.Listen.Port > 0 && c.Listen.Port < 65536, "listen.port %d out of range", c.Listen.Port)
+	check(c.Health.Port > 0 && c.Health.Port < 65536, "health.port %d out of range", c.Health.Port)
+	check(c.Health.Port != c.Listen.Port, "health.port must differ from listen.port")
+	check((c.TLS.CertFile == "") == (c.TLS.KeyFile == ""), "tls.cert_file and tls.key_file must be set together")
+	check(c.TLS.RedirectPort >= 0 && c.TLS.RedirectPort < 65536, "tls.redirect_port %d out of range", c.TLS.RedirectPort)
+	check(c.TLS.RedirectPort == 0 || (c.TLS.RedirectPort != c.Listen.Port && c.TLS.RedirectPort != c.Health.Port),
+		"tls.redirect_port must differ from listen.port and health.port")
+	check(c.TLS.RedirectPort == 0 || c.TLS.Enabled, "tls.redirect_port needs tls.enabled")
+	check(c.TLS.HSTSMaxAge >= 0, "tls.hsts_max_age must not be negative")
+	check(!c.Health.TLS || c.TLS.Enabled, "health.tls needs tls.enabled")
+	check(contains(validProviders, c.Provider), "provider %q is not one of %v", c.Provider, validProviders)
+	check(c.Cache.QuoteTTL > 0, "cache.quote_ttl must be positive")
+	check(c.RateLimit.RequestsPerMinute >= 0, "rate_limit.requests_per_minute must not be negative")
+	check(c.RateLimit.Burst >= 0, "rate_limit.burst must not be negative")
+	check(c.RateLimit.UpstreamPerMinute >= 0, "rate_limit.upstream_per_minute must not be negative")
+	check(c.RateLimit.UpstreamMaxWait >= 0, "rate_limit.upstream_max_wait must not be negative")
+	if _, err := common.ParseCIDRs(c.RateLimit.TrustedProxies); err != nil {
+		errs = append(errs, fmt.Errorf("rate_limit.trusted_proxies: %v", err))
+	}
+	check(contains(validAuthModes, c.Auth.Mode), "auth.mode %q is not one of %v", c.Auth.Mode, validAuthModes)
+	check(c.Auth.KeyRequestsPerMinute >= 0, "auth.key_requests_per_minute must not be negative")
+	check(!c.Auth.RequireForHTML || c.Auth.Mode != "none", "auth.require_for_html needs an auth.mode other than none")
+	check(contains(validLoginModes, c.Auth.Login), "auth.login %q is not one of %v", c.Auth.Login, validLoginModes)
+	check(!c.Auth.RequireForHTML || c.Auth.Login == "none", "auth.require_for_html and auth.login both protect the HTML pages; use one")
+	check(c.Auth.SessionTTL > 0, "auth.session_ttl must be positive")
+	if c.Auth.Login == "oidc" {
+		o := c.Auth.OIDC
+		check(strings.HasPrefix(o.Issuer, "https://") || strings.HasPrefix(o.Issuer, "http://"), "auth.oidc.issuer must be an http(s) URL")
+		check(o.ClientID != "", "auth.oidc.client_id is required")
+		check(strings.HasSuffix(o.RedirectURL, "/auth/oidc/callback"), "auth.oidc.redirect_url must end in /auth/oidc/callback")
+	}
+	check(contains(validLogFormats, c.Log.Format), "log.format %q is not one of %v", c.Log.Format, validLogFormats)
+	check(contains(validLogLevels, strings.ToLower(c.Log.Level)), "log.level %q is not one of %v", c.Log.Level, validLogLevels)
+	check(c.Metrics.TaxRate >= 0 && c.Metrics.TaxRate < 1, "metrics.tax_rate %g must be in [0, 1)", c.Metrics.TaxRate)
+	check(c.Metrics.RiskFreeRate >= 0 && c.Metrics.RiskFreeRate < 1, "metrics.risk_free_rate %g must be in [0, 1)", c.Metrics.RiskFreeRate)
+	for name, t := range c.Metrics.Thresholds {
+		if err := checkMetricThreshold(name, t.Green, t.Yellow); err != nil {
+			errs = append(errs, fmt.Errorf("metrics.thresholds: %v", err))
+		}
+	}
+	check(c.Alerts.Interval == 0 || c.Alerts.Interval >= time.Minute, "alerts.interval must be 0 or at least 1m")
+	check(c.Alerts.Cooldown >= 0, "alerts.cooldown must not be negative")
+	return errors.Join(errs...)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// applyConfig pushes the settings that live in package state.
+func applyConfig(cfg Config) {
+	g_dataDir = cfg.DataDir
+	quoteCacheTTL = cfg.Cache.QuoteTTL
+	roicTaxRate = cfg.Metrics.TaxRate
+	optionsRiskFreeRate = cfg.Metrics.RiskFreeRate
+	clientLimiter = common.NewRateLimiter(cfg.RateLimit.RequestsPerMinute, cfg.RateLimit.Burst)
+	upstreamBudget = common.NewRateLimiter(cfg.RateLimit.UpstreamPerMinute, 0)
+	upstreamMaxWait = cfg.RateLimit.UpstreamMaxWait
+	trustedProxies, _ = common.ParseCIDRs(cfg.RateLimit.TrustedProxies)
+	apiKeys = nil
+	if cfg.Auth.Mode == "api_key" {
+		apiKeys = newAPIKeyStore(apiKeyStorePath(cfg.DataDir))
+	}
+	requireAuthForHTML = cfg.Auth.RequireForHTML
+	defaultKeyPerMinute = cfg.Auth.KeyRequestsPerMinute
+	alertInterval = cfg.Alerts.Interval
+	alertCooldown = cfg.Alerts.Cooldown
+	for name, t := range cfg.Metrics.Thresholds {
+		// Already validated.
+		overrideMetricThreshold(name, t.Green, t.Yellow)
+	}
+}
+
+func (c Config) scheme() string {
+	if c.TLS.Enabled {
+		return "https"
+	}
+	return "http"
+}
+
+func (c Config) listenAddr() string {
+	return fmt.Sprintf("%s:%d", c.Listen.IP, c.Listen.Port)
+}
+
+func (c Config) healthAddr() string {
+	return fmt.Sprintf("%s:%d", c.Listen.IP, c.Health.Port)
+}
+
+func (c Config) redirectAddr() string {
+	return fmt.Sprintf("%s:%d", c.Listen.IP, c.TLS.RedirectPort)
+}
+
+// runConfigCommand handles `stock config validate` and
+// `stock config show [--effective]`.
+func runConfigCommand(args []string, stdout, stderr io.Writer) int {
+	usage := "usage: stock config validate|show [--effective] [-config path]"
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, usage)
+		return 2
+	}
+	fs := flag.NewFlagSet("config "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	f := addConfigFlags(fs)
+	effective := false
+	if args[0] == "show" {
+		fs.BoolVar(&effective, "effective", false, "show the merged result of defaults, file, environment and flags")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	switch args[0] {
+	case "validate":
+		path, _ := f.configPath()
+		if _, err := loadConfig(f); err != nil {
+			fmt.Fprintf(stderr, "invalid config:\n%v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "%s: OK\n", path)
+		return 0
+	case "show":
+		if !effective {
+			path, _ := f.configPath()
+			data, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return 1
+			}
+			stdout.Write(data)
+			return 0
+		}
+		cfg, err := loadConfig(f)
+		if err != nil {
+			fmt.Fprintf(stderr, "invalid config:\n%v\n", err)
+			return 1
+		}
+		// Operators paste this when troubleshooting; keep the secret out.
+		if cfg.Auth.OIDC.ClientSecret != "" {
+			cfg.Auth.OIDC.ClientSecret = "***"
+		}
+		enc := yaml.NewEncoder(stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(cfg); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintln(stderr, usage)
+	return 2
+}
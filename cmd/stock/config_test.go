@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseConfigFlags writes body to a config file and parses args against it.
+func parseConfigFlags(t *testing.T, body string, args ...string) *configFlags {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := addConfigFlags(fs)
+	if err := fs.Parse(append([]string{"-config", path}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	f := parseConfigFlags(t, `
+listen:
+  port: 9000
+  ip: 10.0.0.1
+data_dir: /from/file
+cache:
+  quote_ttl: 2h
+`, "-data", "/from/flag")
+	t.Setenv("STOCK_LISTEN_PORT", "9100")
+	t.Setenv("STOCK_CACHE_QUOTE_TTL", "3h")
+	t.Setenv("STOCK_DATA_DIR", "/from/env")
+
+	cfg, err := loadConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Listen.IP != "10.0.0.1" {
+		t.Errorf("listen.ip = %q, want the file's value", cfg.Listen.IP)
+	}
+	if cfg.Listen.Port != 9100 || cfg.Health.Port != 9101 {
+		t.Errorf("ports = %d/%d, want env 9100 and health 9101", cfg.Listen.Port, cfg.Health.Port)
+	}
+	if cfg.DataDir != "/from/flag" {
+		t.Errorf("data_dir = %q, want the flag to win", cfg.DataDir)
+	}
+	if cfg.Cache.QuoteTTL != 3*time.Hour {
+		t.Errorf("cache = %+v", cfg.Cache)
+	}
+	if cfg.Provider != "yahoo" || cfg.Metrics.TaxRate != 0.21 {
+		t.Errorf("defaults not kept: %+v", cfg)
+	}
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := addConfigFlags(fs)
+	*f.path = ""
+	t.Setenv("STOCK_CONFIG", "")
+	if _, required := f.configPath(); required {
+		t.Fatal("default path should be optional")
+	}
+
+	t.Setenv("STOCK_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+	if _, err := loadConfig(f); err == nil {
+		t.Error("expected an error for a missing STOCK_CONFIG file")
+	}
+}
+
+func TestLoadConfigRejectsBadValues(t *testing.T) {
+	for name, body := range map[string]string{
+		"unknown key":     "listen:\n  prot: 80\n",
+		"bad port":        "listen:\n  port: 70000\n",
+		"same ports":      "listen:\n  port: 9000\nhealth:\n  port: 9000\n",
+		"bad provider":    "provider: bloomberg\n",
+		"bad duration":    "cache:\n  quote_ttl: soon\n",
+		"unknown metric":  "metrics:\n  thresholds:\n    Vibes:\n      green: 1\n",
+		"inverted":        "metrics:\n  thresholds:\n    P/E Ratio:\n      green: 25\n      yellow: 15\n",
+		"bad login":       "auth:\n  login: ldap\n",
+		"oidc no client":  "auth:\n  login: oidc\n  oidc:\n    issuer: https://idp.test\n    redirect_url: https://app.test/auth/oidc/callback\n",
+		"cert no key":     "tls:\n  enabled: true\n  cert_file: /etc/ssl/a.pem\n",
+		"redirect no tls": "tls:\n  redirect_port: 80\n",
+		"health tls only": "health:\n  tls: true\n",
+		"two html auths":  "auth:\n  mode: api_key\n  require_for_html: true\n  login: local\n",
+		"alerts interval": "alerts:\n  interval: 10s\n",
+	} {
+		if _, err := loadConfig(parseConfigFlags(t, body)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	t.Setenv("STOCK_LISTEN_PORT", "eighty")
+	if _, err := loadConfig(parseConfigFlags(t, "")); err == nil || !strings.Contains(err.Error(), "STOCK_LISTEN_PORT") {
+		t.Errorf("bad env value error = %v", err)
+	}
+}
+
+func TestConfigShowEffective(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("listen:\n  port: 9000\n"), 0644)
+	t.Setenv("STOCK_PROVIDER", "yahoo")
+
+	var stdout, stderr bytes.Buffer
+	if code := runConfigCommand([]string{"show", "--effective", "-config", path, "-ip", "127.0.0.1"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	for _, want := range []string{"port: 9000", "ip: 127.0.0.1", "port: 9001", "quote_ttl: 1h0m0s", "tax_rate: 0.21"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("effective config missing %q:\n%s", want, stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	if code := runConfigCommand([]string{"validate", "-config", path}, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "OK") {
+		t.Errorf("validate = %d, %q", code, stdout.String())
+	}
+}
+
+func TestConfigShowEffectiveMasksSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("auth:\n  oidc:\n    client_secret: hunter2\n"), 0644)
+
+	var stdout, stderr bytes.Buffer
+	if code := runConfigCommand([]string{"show", "--effective", "-config", path}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	if strings.Contains(stdout.String(), "hunter2") || !strings.Contains(stdout.String(), `client_secret: '***'`) {
+		t.Errorf("client secret not masked:\n%s", stdout.String())
+	}
+}
+
+func TestApplyConfigOverridesThresholds(t *testing.T) {
+	saved := metricRules["P/E Ratio"]
+	savedTTL, savedTax, savedDir := quoteCacheTTL, roicTaxRate, g_dataDir
+	t.Cleanup(func() {
+		metricRules["P/E Ratio"] = saved
+		quoteCacheTTL, roicTaxRate, g_dataDir = savedTTL, savedTax, savedDir
+	})
+
+	yellow := 40.0
+	cfg := defaultConfig()
+	cfg.Metrics.TaxRate = 0.25
+	cfg.Metrics.Thresholds = map[string]ThresholdConfig{"P/E Ratio": {Green: 30, Yellow: &yellow}}
+	applyConfig(cfg)
+
+	if color, _ := getColorAndReasonForMetric("P/E Ratio", 28); color != "green" {
+		t.Errorf("P/E 28 with green=30 scored %s", color)
+	}
+	if roicTaxRate != 0.25 {
+		t.Errorf("roicTaxRate = %g", roicTaxRate)
+	}
+}
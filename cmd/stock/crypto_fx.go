@@ -0,0 +1,186 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Crypto and currency pairs have no earnings or balance sheet, so they are
+// scored on liquidity, supply, volatility and where they sit in their range.
+
+// Closes per year when annualizing volatility. Crypto trades every day.
+const (
+	cryptoTradingDays = 365
+	fxTradingDays     = 252
+)
+
+// APICrypto holds the supply and trading figures for a cryptocurrency.
+type APICrypto struct {
+	FromCurrency        string  `json:"from_currency" doc:"The coin, e.g. BTC."`
+	ToCurrency          string  `json:"to_currency" doc:"The currency it is priced in, e.g. USD."`
+	CirculatingSupply   float64 `json:"circulating_supply" doc:"Coins in circulation."`
+	MaxSupply           float64 `json:"max_supply" doc:"0 when the supply is unlimited or unknown."`
+	Volume24h           float64 `json:"volume_24h" doc:"Traded in the last 24 hours, in to_currency."`
+	VolumeAllCurrencies float64 `json:"volume_all_currencies" doc:"Traded in the last 24 hours across all pairs, in to_currency."`
+	Algorithm           string  `json:"algorithm,omitempty"`
+	StartDate           string  `json:"start_date,omitempty" doc:"When the coin started trading, YYYY-MM-DD."`
+	CoinMarketCapLink   string  `json:"coin_market_cap_link,omitempty"`
+}
+
+// APICurrencyPair names the two sides of an FX rate: one Base costs Rate Quote.
+type APICurrencyPair struct {
+	Base  string  `json:"base" doc:"e.g. EUR for EURUSD=X."`
+	Quote string  `json:"quote" doc:"e.g. USD for EURUSD=X."`
+	Rate  float64 `json:"rate"`
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func toAPICrypto(result *Result) *APICrypto {
+	if !result.isCrypto() {
+		return nil
+	}
+	sd := result.SummaryDetail
+	c := &APICrypto{
+		FromCurrency:        stringValue(sd.FromCurrency),
+		ToCurrency:          stringValue(sd.ToCurrency),
+		CirculatingSupply:   sd.CirculatingSupply.Raw,
+		MaxSupply:           sd.MaxSupply.Raw,
+		Volume24h:           sd.Volume24Hr.Raw,
+		VolumeAllCurrencies: sd.VolumeAllCurrencies.Raw,
+		Algorithm:           stringValue(sd.Algorithm),
+		CoinMarketCapLink:   stringValue(sd.CoinMarketCapLink),
+	}
+	if sd.StartDate.Raw > 0 {
+		c.StartDate = time.Unix(int64(sd.StartDate.Raw), 0).UTC().Format("2006-01-02")
+	}
+	return c
+}
+
+// currencyPair splits an FX symbol. Yahoo writes EURUSD=X for EUR/USD, and
+// JPY=X, with the base left out, for USD/JPY.
+func currencyPair(symbol string) (base, quote string) {
+	code := strings.TrimSuffix(symbol, "=X")
+	switch len(code) {
+	case 6:
+		return code[:3], code[3:]
+	case 3:
+		return "USD", code
+	}
+	return "", ""
+}
+
+func toAPICurrencyPair(symbol string, result *Result) *APICurrencyPair {
+	if !result.isCurrency() {
+		return nil
+	}
+	base, quote := currencyPair(symbol)
+	if quote == "" {
+		quote = result.SummaryDetail.Currency
+	}
+	return &APICurrencyPair{Base: base, Quote: quote, Rate: result.price()}
+}
+
+// rangePosition is where the price sits in its 52 week range, or nil if
+// there's no range to speak of.
+func rangePosition(result *Result) *float64 {
+	sd := result.SummaryDetail
+	if position, ok := RangePosition(result.price(), sd.FiftyTwoWeekLow.Raw, sd.FiftyTwoWeekHigh.Raw); ok {
+		return &position
+	}
+	return nil
+}
+
+// volatility is the annualized 30 day volatility, or 0 if the daily closes
+// couldn't be fetched.
+func volatility(result *Result) float64 {
+	switch {
+	case result.isCrypto():
+		return CalculateVolatility(result.DailyCloses, cryptoTradingDays)
+	case result.isCurrency():
+		return CalculateVolatility(result.DailyCloses, fxTradingDays)
+	}
+	return 0
+}
+
+// buildCryptoMetricsList is buildMetricsList for cryptocurrencies.
+func buildCryptoMetricsList(result *Result) []Metric {
+	sd := result.SummaryDetail
+	volume := firstNonZero(sd.Volume24Hr.Raw, sd.VolumeAllCurrencies.Raw)
+	var turnover, issued *float64
+	if sd.MarketCap.Raw > 0 && volume > 0 {
+		turnover = nonZero(volume / sd.MarketCap.Raw)
+	}
+	if sd.MaxSupply.Raw > 0 && sd.CirculatingSupply.Raw > 0 {
+		issued = nonZero(sd.CirculatingSupply.Raw / sd.MaxSupply.Raw)
+	}
+	return availableMetrics([]metricConfig{
+		{"Crypto Volatility", nonZero(volatility(result)), unitPercent},
+		{"Volume/Market Cap", turnover, unitPercent},
+		{"Supply Issued", issued, unitPercent},
+		{"52W Range Position", rangePosition(result), unitPercent},
+		{"Price", nonZero(result.price()), unitCurrency},
+		{"Market Cap", nonZero(sd.MarketCap.Raw), unitCurrency},
+		{"24h Volume", nonZero(volume), unitCurrency},
+		{"Circulating Supply", nonZero(sd.CirculatingSupply.Raw), unitCoins},
+		{"Max Supply", nonZero(sd.MaxSupply.Raw), unitCoins},
+	})
+}
+
+// buildCurrencyMetricsList is buildMetricsList for currency pairs.
+func buildCurrencyMetricsList(result *Result) []Metric {
+	sd := result.SummaryDetail
+	var dayRange, trend *float64
+	if sd.PreviousClose.Raw > 0 && sd.DayHigh.Raw > 0 {
+		r := (sd.DayHigh.Raw - sd.DayLow.Raw) / sd.PreviousClose.Raw
+		dayRange = &r
+	}
+	if sd.TwoHundredDayAverage.Raw > 0 && sd.FiftyDayAverage.Raw > 0 {
+		t := sd.FiftyDayAverage.Raw/sd.TwoHundredDayAverage.Raw - 1
+		trend = &t
+	}
+	return availableMetrics([]metricConfig{
+		{"FX Volatility", nonZero(volatility(result)), unitPercent},
+		{"52W Range Position", rangePosition(result), unitPercent},
+		{"Rate", nonZero(result.price()), unitRate},
+		{"Day Range", dayRange, unitPercent},
+		{"50/200 Day Trend", trend, unitPercent},
+		{"52W Low", nonZero(sd.FiftyTwoWeekLow.Raw), unitRate},
+		{"52W High", nonZero(sd.FiftyTwoWeekHigh.Raw), unitRate},
+	})
+}
+
+// withDailyCloses adds the price history volatility is measured from to
+// crypto and currency results. Without it volatility is just left out. Callers
+// of a shared fetch get the same result, so it returns a copy.
+func withDailyCloses(ctx context.Context, ticker common.Symbol, result *Result) *Result {
+	if !result.isCrypto() && !result.isCurrency() {
+		return result
+	}
+	closes, err := getDailyCloses(ctx, ticker)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not fetch daily closes", "ticker", ticker, "err", err)
+		return result
+	}
+	withCloses := *result
+	withCloses.DailyCloses = closes
+	return &withCloses
+}
+
+// currencyPairText reads an FX rate out, e.g. "1 EUR = 1.0845 USD".
+func currencyPairText(symbol string, result *Result) string {
+	pair := toAPICurrencyPair(symbol, result)
+	if pair == nil || pair.Base == "" {
+		return ""
+	}
+	return fmt.Sprintf("1 %s = %.4f %s", pair.Base, pair.Rate, pair.Quote)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Trimmed down quoteSummaries for BTC-USD and EURUSD=X.
+const (
+	testCryptoSummary = `{"quoteSummary":{"result":[{
+	"price":{"quoteType":"CRYPTOCURRENCY","regularMarketPrice":{"raw":60000},"currency":"USD","shortName":"Bitcoin USD"},
+	"summaryDetail":{"previousClose":{"raw":59000},"marketCap":{"raw":1.2e12},"fiftyTwoWeekLow":{"raw":40000},"fiftyTwoWeekHigh":{"raw":70000},
+		"volume24Hr":{"raw":3.6e10},"circulatingSupply":{"raw":1.98e7},"maxSupply":{"raw":2.1e7},"startDate":{"raw":1367107200},
+		"fromCurrency":"BTC","toCurrency":"USD=X","algorithm":"SHA256","currency":"USD"}
+}],"error":null}}`
+	testCurrencySummary = `{"quoteSummary":{"result":[{
+	"quoteType":{"quoteType":"CURRENCY","symbol":"EURUSD=X","shortName":"EUR/USD"},
+	"price":{"quoteType":"CURRENCY","regularMarketPrice":{"raw":1.0845},"currency":"USD"},
+	"summaryDetail":{"previousClose":{"raw":1.08},"dayLow":{"raw":1.079},"dayHigh":{"raw":1.089},
+		"fiftyTwoWeekLow":{"raw":1.04},"fiftyTwoWeekHigh":{"raw":1.12},"fiftyDayAverage":{"raw":1.09},"twoHundredDayAverage":{"raw":1.08},"currency":"USD"}
+}],"error":null}}`
+)
+
+func metricsByName(result *Result) map[string]Metric {
+	metrics := map[string]Metric{}
+	for _, m := range buildMetricsList(result) {
+		metrics[m.Name] = m
+	}
+	return metrics
+}
+
+func TestCryptoMetrics(t *testing.T) {
+	result, err := parseQuoteSummary("BTC-USD", []byte(testCryptoSummary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.isCrypto() || result.instrumentLabel() != "Crypto" {
+		t.Fatalf("quoteType = %q", result.quoteType())
+	}
+	result.DailyCloses = []float64{100, 110, 100, 110, 100}
+
+	metrics := metricsByName(result)
+	for _, stockOnly := range []string{"P/E Ratio", "ROE", "Quick Ratio"} {
+		if _, ok := metrics[stockOnly]; ok {
+			t.Errorf("crypto has stock metric %q", stockOnly)
+		}
+	}
+	if m := metrics["Supply Issued"]; m.Value != "94.29%" || m.Color != "green" {
+		t.Errorf("Supply Issued = %+v", m)
+	}
+	if m := metrics["Volume/Market Cap"]; m.Value != "3.00%" || m.Color != "yellow" {
+		t.Errorf("Volume/Market Cap = %+v", m)
+	}
+	if m := metrics["52W Range Position"]; m.Color != "yellow" {
+		t.Errorf("52W Range Position = %+v", m)
+	}
+	if m := metrics["Crypto Volatility"]; m.Color != "red" {
+		t.Errorf("Crypto Volatility = %+v", m)
+	}
+	if m := metrics["Circulating Supply"]; m.Value != "19.80M" || m.Unit != unitCoins {
+		t.Errorf("Circulating Supply = %+v", m)
+	}
+
+	c := toAPICrypto(result)
+	if c.FromCurrency != "BTC" || c.StartDate != "2013-04-28" || c.Algorithm != "SHA256" {
+		t.Errorf("crypto = %+v", c)
+	}
+	if toAPICrypto(testResult()) != nil {
+		t.Error("stock has crypto details")
+	}
+}
+
+func TestCurrencyMetrics(t *testing.T) {
+	result, err := parseQuoteSummary("EURUSD=X", []byte(testCurrencySummary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := metricsByName(result)
+	if m := metrics["Rate"]; m.Value != "1.0845" {
+		t.Errorf("Rate = %+v", m)
+	}
+	if _, ok := metrics["FX Volatility"]; ok {
+		t.Error("FX Volatility scored without daily closes")
+	}
+	if m := metrics["52W Range Position"]; m.Raw < 0.556 || m.Raw > 0.557 {
+		t.Errorf("52W Range Position = %+v", m)
+	}
+
+	for _, tc := range []struct{ symbol, base, quote string }{
+		{"EURUSD=X", "EUR", "USD"},
+		{"JPY=X", "USD", "JPY"},
+		{"BTC-USD", "", ""},
+	} {
+		if base, quote := currencyPair(tc.symbol); base != tc.base || quote != tc.quote {
+			t.Errorf("currencyPair(%q) = %q, %q", tc.symbol, base, quote)
+		}
+	}
+	if got := currencyPairText("EURUSD=X", result); got != "1 EUR = 1.0845 USD" {
+		t.Errorf("currencyPairText = %q", got)
+	}
+
+	// At the 52 week low with flat averages: both are data, not gaps.
+	result.Price.RegularMarketPrice.Raw = 1.04
+	result.SummaryDetail.FiftyDayAverage.Raw = 1.08
+	metrics = metricsByName(result)
+	if m, ok := metrics["52W Range Position"]; !ok || m.Raw != 0 || m.Value != "0.00%" {
+		t.Errorf("52W Range Position at the low = %+v", m)
+	}
+	if m, ok := metrics["50/200 Day Trend"]; !ok || m.Raw != 0 {
+		t.Errorf("flat 50/200 Day Trend = %+v", m)
+	}
+}
+
+func TestCryptoFetchesDailyCloses(t *testing.T) {
+	var charts int
+	withFakeYahoo(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v8/finance/chart/") {
+			charts++
+			w.Write([]byte(`{"chart":{"result":[{"indicators":{"quote":[{"close":[100,null,110,100,110]}]}}]}}`))
+			return
+		}
+		w.Write([]byte(testCryptoSummary))
+	})
+	t.Cleanup(func() { chartCache = map[common.Symbol]cachedCloses{} })
+
+	for i := 0; i < 2; i++ {
+		result, err := getStockMetrics(context.Background(), "BTC-USD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.DailyCloses) != 4 {
+			t.Errorf("closes = %v, want nulls skipped", result.DailyCloses)
+		}
+	}
+	if charts != 1 {
+		t.Errorf("chart fetched %d times, want 1", charts)
+	}
+	// Stocks don't need it.
+	withFakeYahoo(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v8/finance/chart/") {
+			t.Error("chart fetched for a stock")
+		}
+		w.Write([]byte(testQuoteSummary))
+	})
+	if _, err := getStockMetrics(context.Background(), "AAPL"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCryptoPage(t *testing.T) {
+	withFetcher(t, func(string) (*Result, error) { return parseQuoteSummary("BTC-USD", []byte(testCryptoSummary)) })
+
+	rec := httptest.NewRecorder()
+	stockHandler(rec, httptest.NewRequest("GET", "/stock?symbol=BTC-USD", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"Bitcoin USD", "Real-time Crypto Metrics", "Supply Issued", "Circulating Supply"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("crypto page lacks %q", want)
+		}
+	}
+	if strings.Contains(body, "Quick Ratio") {
+		t.Error("crypto page shows stock metrics")
+	}
+
+	rec = httptest.NewRecorder()
+	apiV1StockHandler(rec, httptest.NewRequest("GET", "/api/v1/stock?symbol=BTC-USD", nil))
+	var got APIStock
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("stock = %d %s", rec.Code, rec.Body.String())
+	}
+	if got.QuoteType != "CRYPTOCURRENCY" || got.Crypto == nil || got.Currency != nil || got.Quote.Price != 60000 || got.Derived.RangePosition == nil || *got.Derived.RangePosition != 2.0/3 {
+		t.Errorf("API stock = %+v", got)
+	}
+}
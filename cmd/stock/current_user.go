@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+
+	g "maragu.dev/gomponents"
+	. "maragu.dev/gomponents/html"
+)
+
+// User is whoever is logged in to the web UI.
+type User struct {
+	// Stable across logins: local:<username> or oidc:<subject>.
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Email    string `json:"email,omitempty"`
+	Provider string `json:"provider"`
+}
+
+type userContextKey struct{}
+
+func withUser(ctx context.Context, u *User) context.Context {
+	return context.WithValue(ctx, userContextKey{}, u)
+}
+
+// currentUser returns the logged in user, or nil when the request is
+// anonymous, which it always is with auth.login: none.
+func currentUser(ctx context.Context) *User {
+	u, _ := ctx.Value(userContextKey{}).(*User)
+	return u
+}
+
+// userDataDir is where things saved by u are kept. OIDC subjects can be any
+// string, so the directory is named after a hash of the ID.
+func userDataDir(u *User) string {
+	sum := sha256.Sum256([]byte(u.ID))
+	return filepath.Join(g_dataDir, "users", hex.EncodeToString(sum[:10]))
+}
+
+// userBadge shows who is logged in, with a logout button. Nothing when
+// anonymous.
+func userBadge(u *User) g.Node {
+	if u == nil {
+		return nil
+	}
+	return FormEl(Method("post"), Action("/logout"), Class("flex items-center justify-end gap-2 text-xs text-gray-500 mb-2"),
+		Span(g.Text("Signed in as "), Strong(g.Text(u.Name))),
+		Button(Type("submit"), Class("text-blue-500 hover:underline"), g.Text("Log out")),
+	)
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	g "maragu.dev/gomponents"
+	// Importing this as '.' is intentional for cleaner HTML like code.
+	. "maragu.dev/gomponents/html"
+)
+
+// quoteSummary only has the current dividend, so the history behind growth
+// rates and streaks comes from the chart endpoint's dividend events, which go
+// back to the first payment. Like daily closes they're kept in memory for
+// quoteCacheTTL.
+
+type Dividend struct {
+	Date   time.Time
+	Amount float64
+}
+
+type cachedDividends struct {
+	dividends []Dividend
+	at        time.Time
+}
+
+var (
+	dividendMu    sync.Mutex
+	dividendCache = map[common.Symbol]cachedDividends{}
+)
+
+// getDividends returns every dividend ticker has paid, oldest first.
+func getDividends(ctx context.Context, ticker common.Symbol) ([]Dividend, error) {
+	dividendMu.Lock()
+	c, ok := dividendCache[ticker]
+	dividendMu.Unlock()
+	if ok && time.Since(c.at) < quoteCacheTTL {
+		return c.dividends, nil
+	}
+
+	var body struct {
+		Chart struct {
+			Result []struct {
+				Events struct {
+					// Keyed by the payment's unix time.
+					Dividends map[string]struct {
+						Amount float64 `json:"amount"`
+						Date   int64   `json:"date"`
+					} `json:"dividends"`
+				} `json:"events"`
+			} `json:"result"`
+		} `json:"chart"`
+	}
+	// Monthly bars keep the response small; the events are the same.
+	if err := requestChart(ctx, ticker, "range=max&interval=1mo&events=div", &body); err != nil {
+		return nil, err
+	}
+	if len(body.Chart.Result) == 0 {
+		upstreamErrors.Inc("chart", upstreamErrNotFound)
+		return nil, fmt.Errorf("no chart data for %s", ticker)
+	}
+	var dividends []Dividend
+	for _, d := range body.Chart.Result[0].Events.Dividends {
+		dividends = append(dividends, Dividend{Date: time.Unix(d.Date, 0).UTC(), Amount: d.Amount})
+	}
+	sort.Slice(dividends, func(i, j int) bool { return dividends[i].Date.Before(dividends[j].Date) })
+
+	dividendMu.Lock()
+	dividendCache[ticker] = cachedDividends{dividends: dividends, at: time.Now()}
+	dividendMu.Unlock()
+	return dividends, nil
+}
+
+// dividendRate is the last twelve months' dividends per share, or the
+// forward rate if Yahoo has no trailing one.
+func (r *Result) dividendRate() float64 {
+	if rate := r.SummaryDetail.TrailingAnnualDividendRate.Raw; rate > 0 {
+		return rate
+	}
+	return r.SummaryDetail.DividendRate.Raw
+}
+
+// paysDividends reports whether result is a stock that pays a dividend.
+func (r *Result) paysDividends() bool {
+	return r.quoteType() == quoteTypeEquity && (r.dividendRate() > 0 || r.SummaryDetail.DividendYield.Raw > 0)
+}
+
+// withDividends adds the dividend history to stocks that pay one. Like
+// withDailyCloses it returns a copy, and leaves the history out if it can't
+// be fetched.
+func withDividends(ctx context.Context, ticker common.Symbol, result *Result) *Result {
+	if !result.paysDividends() {
+		return result
+	}
+	dividends, err := getDividends(ctx, ticker)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not fetch dividend history", "ticker", ticker, "err", err)
+		return result
+	}
+	withHistory := *result
+	withHistory.Dividends = dividends
+	return &withHistory
+}
+
+type APIDividends struct {
+	Currency       string   `json:"currency" doc:"Currency of the per share amounts (ISO 4217)."`
+	Rate           float64  `json:"rate" doc:"Dividends per share over the last twelve months."`
+	Yield          float64  `json:"yield" doc:"Fraction, 0.03 means 3%."`
+	PayoutRatio    float64  `json:"payout_ratio" doc:"Dividends over earnings, as a fraction."`
+	FCFPayoutRatio *float64 `json:"fcf_payout_ratio,omitempty" doc:"Dividends paid over free cash flow. Missing without positive free cash flow or an FX rate between the trading and statement currencies."`
+	ExDate         string   `json:"ex_date,omitempty" doc:"Latest ex-dividend date, YYYY-MM-DD."`
+	PayDate        string   `json:"pay_date,omitempty" doc:"Latest payment date, YYYY-MM-DD."`
+	// Missing when the history doesn't go back far enough.
+	Growth1Y             *float64          `json:"growth_1y,omitempty" doc:"Growth in the last full year's dividends over the year before."`
+	Growth5Y             *float64          `json:"growth_5y,omitempty" doc:"Compound annual growth over five full years."`
+	Growth10Y            *float64          `json:"growth_10y,omitempty" doc:"Compound annual growth over ten full years."`
+	ConsecutiveIncreases int               `json:"consecutive_increases" doc:"Full years in a row the yearly total rose."`
+	SafetyScore          float64           `json:"safety_score" doc:"0 to 100, from the payout ratios, the streak and 5 year growth."`
+	History              []APIDividendYear `json:"history" doc:"Total per full calendar year, oldest first."`
+}
+
+type APIDividendYear struct {
+	Year   int     `json:"year"`
+	Amount float64 `json:"amount"`
+}
+
+// dividendYears totals the dividends in each calendar year before now's,
+// which isn't over yet. Years without a payment are left out.
+func dividendYears(dividends []Dividend, scale float64, now time.Time) []APIDividendYear {
+	years := []APIDividendYear{}
+	for _, d := range dividends {
+		year := d.Date.Year()
+		if year >= now.Year() {
+			break
+		}
+		if n := len(years); n > 0 && years[n-1].Year == year {
+			years[n-1].Amount += d.Amount * scale
+			continue
+		}
+		years = append(years, APIDividendYear{year, d.Amount * scale})
+	}
+	return years
+}
+
+// dividendGrowth is the compound annual growth of the last year's total over
+// the one n years earlier, or nil if either is missing.
+func dividendGrowth(years []APIDividendYear, n int) *float64 {
+	if len(years) == 0 {
+		return nil
+	}
+	last := years[len(years)-1]
+	for _, y := range years {
+		if y.Year == last.Year-n && y.Amount > 0 {
+			growth := math.Pow(last.Amount/y.Amount, 1/float64(n)) - 1
+			return &growth
+		}
+	}
+	return nil
+}
+
+// consecutiveIncreases counts back from the last year while each year's total
+// beat the year before's. A year without dividends ends the streak.
+func consecutiveIncreases(years []APIDividendYear) int {
+	var n int
+	for i := len(years) - 1; i > 0; i-- {
+		if years[i].Year != years[i-1].Year+1 || years[i].Amount <= years[i-1].Amount {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// fcfPayoutRatio is the last year's dividends over free cash flow. Dividends
+// are in the trading currency and cash flow in the statement one.
+func fcfPayoutRatio(result *Result) *float64 {
+	fcf, shares := result.FinancialData.FreeCashflow.Raw, result.DefaultKeyStatistics.SharesOutstanding.Raw
+	if fcf <= 0 || shares <= 0 {
+		return nil
+	}
+	trading, scale := result.tradingCurrency()
+	financial, _ := common.NormalizeCurrency(result.FinancialData.FinancialCurrency)
+	if financial != "" && financial != trading {
+		if scale = result.Currencies.tradingToFinancial(); scale == 0 {
+			return nil
+		}
+	}
+	ratio := result.dividendRate() * scale * shares / fcf
+	return &ratio
+}
+
+// payoutPoints scores a payout ratio out of 25: most of the profits left
+// over is safe, paying out more than is earned is not.
+func payoutPoints(ratio float64) float64 {
+	switch {
+	case ratio <= 0.6:
+		return 25
+	case ratio <= 0.8:
+		return 15
+	case ratio <= 1:
+		return 5
+	}
+	return 0
+}
+
+// dividendSafety scores the dividend from 0 to 100 on four things worth 25
+// each: the payout ratio against earnings and against free cash flow, the
+// streak of increases and 5 year growth. Those Yahoo has no data for are left
+// out and the rest scaled up to 100.
+func dividendSafety(result *Result, d *APIDividends) float64 {
+	var points, parts float64
+	if d.PayoutRatio > 0 {
+		points += payoutPoints(d.PayoutRatio)
+		parts++
+	}
+	if d.FCFPayoutRatio != nil {
+		points += payoutPoints(*d.FCFPayoutRatio)
+		parts++
+	} else if result.FinancialData.FreeCashflow.Raw < 0 {
+		// Paying out with no free cash flow to pay from.
+		parts++
+	}
+	if len(d.History) >= 2 {
+		switch {
+		case d.ConsecutiveIncreases >= 10:
+			points += 25
+		case d.ConsecutiveIncreases >= 5:
+			points += 15
+		case d.ConsecutiveIncreases >= 1:
+			points += 5
+		}
+		parts++
+	}
+	if d.Growth5Y != nil {
+		switch {
+		case *d.Growth5Y > 0.05:
+			points += 25
+		case *d.Growth5Y > 0:
+			points += 15
+		}
+		parts++
+	}
+	if parts == 0 {
+		return 0
+	}
+	return points / (parts * 25) * 100
+}
+
+func dateText(unix float64) string {
+	if unix <= 0 {
+		return ""
+	}
+	return time.Unix(int64(unix), 0).UTC().Format("2006-01-02")
+}
+
+// toAPIDividends returns nil unless result is a stock that pays a dividend.
+// now decides which years are complete.
+func toAPIDividends(result *Result, now time.Time) *APIDividends {
+	if !result.paysDividends() {
+		return nil
+	}
+	currency, scale := result.tradingCurrency()
+	sd, events := result.SummaryDetail, result.CalendarEvents
+	d := &APIDividends{
+		Currency:       currency,
+		Rate:           result.dividendRate() * scale,
+		Yield:          sd.DividendYield.Raw,
+		PayoutRatio:    sd.PayoutRatio.Raw,
+		FCFPayoutRatio: fcfPayoutRatio(result),
+		ExDate:         dateText(math.Max(events.ExDividendDate.Raw, sd.ExDividendDate.Raw)),
+		PayDate:        dateText(events.DividendDate.Raw),
+		History:        dividendYears(result.Dividends, scale, now),
+	}
+	if d.Yield == 0 {
+		d.Yield = sd.TrailingAnnualDividendYield.Raw
+	}
+	d.Growth1Y = dividendGrowth(d.History, 1)
+	d.Growth5Y = dividendGrowth(d.History, 5)
+	d.Growth10Y = dividendGrowth(d.History, 10)
+	d.ConsecutiveIncreases = consecutiveIncreases(d.History)
+	d.SafetyScore = dividendSafety(result, d)
+	return d
+}
+
+// dividendMetrics are the dividend cards, only for stocks that pay one:
+// a growth stock without a dividend isn't worse for it.
+func dividendMetrics(result *Result, now time.Time) []Metric {
+	d := toAPIDividends(result, now)
+	if d == nil {
+		return nil
+	}
+	// Yahoo reports 0 without positive earnings, which isn't a safe payout.
+	payout := &d.PayoutRatio
+	if d.PayoutRatio <= 0 {
+		payout = nil
+	}
+	cards := []struct {
+		name  string
+		value *float64
+	}{
+		{"Dividend Yield", &d.Yield},
+		{"Payout Ratio", payout},
+		{"FCF Payout Ratio", d.FCFPayoutRatio},
+		{"Dividend Growth (5Y)", d.Growth5Y},
+	}
+	var metrics []Metric
+	for _, c := range cards {
+		if m := buildMetricCardInformation(c.name, c.value, unitPercent); m != nil {
+			metrics = append(metrics, *m)
+		}
+	}
+	return append(metrics, *buildMetricCardInformation("Dividend Safety", &d.SafetyScore, unitRatio))
+}
+
+// dividendChart draws the yearly totals, at most the last twenty years.
+func dividendChart(years []APIDividendYear, currency string) g.Node {
+	if len(years) == 0 {
+		return P(Class("text-sm text-gray-500"), g.Text("Yahoo Finance has no dividend history."))
+	}
+	years = years[max(0, len(years)-20):]
+	var top float64
+	for _, y := range years {
+		top = math.Max(top, y.Amount)
+	}
+	plotH := float64(chartHeight - chartLabelH)
+	slot := float64(chartWidth) / float64(len(years))
+	var nodes []g.Node
+	for i, y := range years {
+		h := math.Max(y.Amount/top*plotH, 1)
+		x := float64(i)*slot + slot*0.15
+		nodes = append(nodes, g.El("rect",
+			g.Attr("x", num(x)), g.Attr("y", num(plotH-h)),
+			g.Attr("width", num(slot*0.7)), g.Attr("height", num(h)),
+			g.Attr("fill", "#34d399"),
+			g.El("title", g.Text(fmt.Sprintf("%d: %s", y.Year, common.FormatCurrency(y.Amount, currency)))),
+		))
+		// Every other label when they'd crowd each other.
+		if len(years) <= 10 || i%2 == len(years)%2 {
+			nodes = append(nodes, g.El("text",
+				g.Attr("x", num(x+slot*0.35)), g.Attr("y", num(chartHeight-4)),
+				g.Attr("text-anchor", "middle"), g.Attr("font-size", "11"), g.Attr("fill", "#9ca3af"),
+				g.Text(strconv.Itoa(y.Year)),
+			))
+		}
+	}
+	return SVG(g.Attr("viewBox", fmt.Sprintf("0 0 %d %d", chartWidth, chartHeight)), Class("w-full h-auto"), g.Attr("role", "img"),
+		g.Attr("aria-label", "Dividends per share by year"),
+		g.Group(nodes),
+	)
+}
+
+func optionalPercent(v *float64) string {
+	if v == nil {
+		return "–"
+	}
+	return fmt.Sprintf("%+.1f%%", *v*100)
+}
+
+func dividendStat(label, value string) g.Node {
+	return Div(Class("flex justify-between border-t border-gray-700 py-1 text-sm"),
+		Span(Class("text-gray-400"), g.Text(label)),
+		Span(Class("text-gray-100"), g.Text(value)),
+	)
+}
+
+// dividendSection is the stock page's dividend panel, or nil for stocks that
+// don't pay one.
+func dividendSection(result *Result, now time.Time) g.Node {
+	d := toAPIDividends(result, now)
+	if d == nil {
+		return nil
+	}
+	summary := fmt.Sprintf("Pays %s a share a year, a %s yield.", common.FormatCurrency(d.Rate, d.Currency), percentText(d.Yield))
+	if d.ExDate != "" {
+		summary += " Ex-dividend " + d.ExDate
+		if d.PayDate != "" {
+			summary += ", paid " + d.PayDate
+		}
+		summary += "."
+	}
+	payout, fcfPayout := "–", "–"
+	if d.PayoutRatio > 0 {
+		payout = percentText(d.PayoutRatio)
+	}
+	if d.FCFPayoutRatio != nil {
+		fcfPayout = percentText(*d.FCFPayoutRatio)
+	}
+
+	return Div(Class("mt-8"),
+		H2(Class("text-2xl font-bold text-white mb-2"), g.Text("Dividends")),
+		P(Class("text-gray-400 mb-4"), g.Text(summary)),
+		Div(Class("grid grid-cols-1 lg:grid-cols-2 gap-6"),
+			fundSection("Dividends per Share", dividendChart(d.History, d.Currency)),
+			fundSection("Growth and Safety",
+				dividendStat("Growth, last year", optionalPercent(d.Growth1Y)),
+				dividendStat("Growth, 5 years a year", optionalPercent(d.Growth5Y)),
+				dividendStat("Growth, 10 years a year", optionalPercent(d.Growth10Y)),
+				dividendStat("Years of increases", strconv.Itoa(d.ConsecutiveIncreases)),
+				dividendStat("Payout ratio (earnings)", payout),
+				dividendStat("Payout ratio (free cash flow)", fcfPayout),
+				dividendStat("Safety score", fmt.Sprintf("%.0f / 100", d.SafetyScore)),
+			),
+		),
+	)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A trimmed down chart response with quarterly dividends from 2023 to 2025.
+const testDividendChart = `{"chart":{"result":[{"meta":{"currency":"USD"},"events":{"dividends":{
+	"1676592000":{"amount":0.23,"date":1676592000},
+	"1684108800":{"amount":0.24,"date":1684108800},
+	"1691625600":{"amount":0.24,"date":1691625600},
+	"1699574400":{"amount":0.24,"date":1699574400},
+	"1707436800":{"amount":0.24,"date":1707436800},
+	"1715299200":{"amount":0.25,"date":1715299200},
+	"1723161600":{"amount":0.25,"date":1723161600},
+	"1731024000":{"amount":0.25,"date":1731024000},
+	"1739404800":{"amount":0.25,"date":1739404800}
+}}}],"error":null}}`
+
+func dividendYearsOf(amounts map[int]float64) []Dividend {
+	var dividends []Dividend
+	for year := 2000; year < 2030; year++ {
+		if a, ok := amounts[year]; ok {
+			dividends = append(dividends, Dividend{Date: time.Date(year, 6, 1, 0, 0, 0, 0, time.UTC), Amount: a})
+		}
+	}
+	return dividends
+}
+
+func testDividendResult() *Result {
+	r := testResult()
+	r.QuoteType.QuoteType = quoteTypeEquity
+	r.SummaryDetail.DividendYield = FmtRaw{Raw: 0.005}
+	r.SummaryDetail.TrailingAnnualDividendRate = FmtRaw{Raw: 1}
+	r.SummaryDetail.PayoutRatio = FmtRaw{Raw: 0.15}
+	r.SummaryDetail.ExDividendDate = FmtRaw{Raw: 1739145600}
+	r.CalendarEvents.DividendDate = FmtRaw{Raw: 1739404800}
+	r.FinancialData.FreeCashflow = FmtRaw{Raw: 100e9}
+	r.DefaultKeyStatistics.SharesOutstanding = FmtRaw{Raw: 15e9}
+	// Rising every year from 2014 to 2024.
+	amounts := map[int]float64{}
+	for year := 2014; year <= 2024; year++ {
+		amounts[year] = 0.5 + float64(year-2014)*0.05
+	}
+	amounts[2025] = 0.25
+	r.Dividends = dividendYearsOf(amounts)
+	return r
+}
+
+var testDividendNow = time.Date(2025, 10, 15, 0, 0, 0, 0, time.UTC)
+
+func TestGetDividends(t *testing.T) {
+	var requests []string
+	withFakeYahoo(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path+"?"+r.URL.RawQuery)
+		w.Write([]byte(testDividendChart))
+	})
+	t.Cleanup(func() { dividendCache = map[common.Symbol]cachedDividends{} })
+
+	for i := 0; i < 2; i++ {
+		dividends, err := getDividends(context.Background(), "AAPL")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dividends) != 9 || dividends[0].Amount != 0.23 || dividends[8].Date.Format("2006-01-02") != "2025-02-13" {
+			t.Errorf("dividends = %+v", dividends)
+		}
+	}
+	if strings.Join(requests, " ") != "/v8/finance/chart/AAPL?range=max&interval=1mo&events=div" {
+		t.Errorf("requests = %v, want one, cached after", requests)
+	}
+}
+
+func TestDividendHistory(t *testing.T) {
+	years := dividendYears(dividendYearsOf(map[int]float64{2019: 1, 2020: 1.1, 2021: 1, 2023: 1.2, 2024: 1.3, 2025: 0.7}), 1, testDividendNow)
+	if len(years) != 5 || years[4] != (APIDividendYear{2024, 1.3}) {
+		t.Errorf("years = %+v, want 2019 to 2024 without 2022 or the unfinished 2025", years)
+	}
+	// 2022 paid nothing, so the streak starts in 2023.
+	if n := consecutiveIncreases(years); n != 1 {
+		t.Errorf("streak = %d, want 1", n)
+	}
+	if g := dividendGrowth(years, 1); g == nil || math.Abs(*g-(1.3/1.2-1)) > 1e-9 {
+		t.Errorf("1Y growth = %v", g)
+	}
+	if g := dividendGrowth(years, 5); g == nil || math.Abs(*g-(math.Pow(1.3, 0.2)-1)) > 1e-9 {
+		t.Errorf("5Y growth = %v", g)
+	}
+	if g := dividendGrowth(years, 10); g != nil {
+		t.Errorf("10Y growth = %v without ten years of history", *g)
+	}
+}
+
+func TestToAPIDividends(t *testing.T) {
+	d := toAPIDividends(testDividendResult(), testDividendNow)
+	if d == nil {
+		t.Fatal("no dividends")
+	}
+	if d.Rate != 1 || d.Currency != "USD" || d.ExDate != "2025-02-10" || d.PayDate != "2025-02-13" || len(d.History) != 11 {
+		t.Errorf("dividends = %+v", d)
+	}
+	if d.ConsecutiveIncreases != 10 || d.Growth10Y == nil || math.Abs(*d.Growth10Y-(math.Pow(2, 0.1)-1)) > 1e-9 {
+		t.Errorf("streak %d, 10Y growth %v", d.ConsecutiveIncreases, d.Growth10Y)
+	}
+	// 15 billion shares at $1 over $100 billion.
+	if d.FCFPayoutRatio == nil || math.Abs(*d.FCFPayoutRatio-0.15) > 1e-9 {
+		t.Errorf("FCF payout = %v", d.FCFPayoutRatio)
+	}
+	// Every part scores full marks.
+	if d.SafetyScore != 100 {
+		t.Errorf("safety = %v", d.SafetyScore)
+	}
+
+	r := testDividendResult()
+	r.SummaryDetail.TrailingAnnualDividendRate = FmtRaw{}
+	r.SummaryDetail.DividendYield = FmtRaw{}
+	if toAPIDividends(r, testDividendNow) != nil || dividendMetrics(r, testDividendNow) != nil {
+		t.Error("dividends for a stock that pays none")
+	}
+}
+
+func TestDividendSafety(t *testing.T) {
+	r := testDividendResult()
+	// Paying out more than it earns with negative free cash flow, and the
+	// dividend was cut five years ago.
+	r.SummaryDetail.PayoutRatio = FmtRaw{Raw: 1.2}
+	r.FinancialData.FreeCashflow = FmtRaw{Raw: -1e9}
+	r.Dividends = dividendYearsOf(map[int]float64{2019: 2, 2020: 1, 2021: 1, 2022: 1, 2023: 1, 2024: 1.05})
+	d := toAPIDividends(r, testDividendNow)
+	// 0 + 0 + 5 for one increase + 0, of 100.
+	if d.FCFPayoutRatio != nil || d.SafetyScore != 5 {
+		t.Errorf("FCF payout %v, safety %v", d.FCFPayoutRatio, d.SafetyScore)
+	}
+	// Only the payout ratio is known.
+	r.FinancialData.FreeCashflow = FmtRaw{}
+	r.Dividends = nil
+	r.SummaryDetail.PayoutRatio = FmtRaw{Raw: 0.7}
+	if d := toAPIDividends(r, testDividendNow); d.SafetyScore != 60 {
+		t.Errorf("safety = %v, want 15 of 25", d.SafetyScore)
+	}
+}
+
+func TestDividendMetrics(t *testing.T) {
+	metrics := map[string]Metric{}
+	for _, m := range dividendMetrics(testDividendResult(), testDividendNow) {
+		metrics[m.Name] = m
+	}
+	for name, color := range map[string]string{
+		"Dividend Yield":       "red",
+		"Payout Ratio":         "green",
+		"FCF Payout Ratio":     "green",
+		"Dividend Growth (5Y)": "green",
+		"Dividend Safety":      "green",
+	} {
+		if m, ok := metrics[name]; !ok || m.Color != color {
+			t.Errorf("%s = %+v, want %s", name, m, color)
+		}
+	}
+	// Yahoo's 0 for a loss-making payer isn't a safe payout.
+	r := testDividendResult()
+	r.SummaryDetail.PayoutRatio = FmtRaw{}
+	for _, m := range dividendMetrics(r, testDividendNow) {
+		if m.Name == "Payout Ratio" {
+			t.Errorf("Payout Ratio card without earnings: %+v", m)
+		}
+	}
+	// A stock without a dividend isn't marked down for it.
+	if _, ok := metricsByName(testResult())["Dividend Yield"]; ok {
+		t.Error("Dividend Yield card for a stock that pays none")
+	}
+}
+
+func TestDividendSection(t *testing.T) {
+	var b strings.Builder
+	dividendSection(testDividendResult(), testDividendNow).Render(&b)
+	html := b.String()
+	for _, want := range []string{
+		"Pays $1.00 a share a year, a 0.50% yield. Ex-dividend 2025-02-10, paid 2025-02-13.",
+		"2024: $1.00", "Years of increases", "100 / 100",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("dividend section lacks %q", want)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	common "app/internal/common"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	g "maragu.dev/gomponents"
+	// Importing this as '.' is intentional for cleaner HTML like code.
+	. "maragu.dev/gomponents/html"
+)
+
+// The earnings module has the last four quarters of EPS against the analyst
+// estimate, and four years and quarters of revenue and earnings.
+
+type APIEarningsQuarter struct {
+	Quarter  string   `json:"quarter" doc:"Calendar quarter, e.g. 3Q2024."`
+	Actual   float64  `json:"actual" doc:"Reported EPS."`
+	Estimate *float64 `json:"estimate,omitempty" doc:"Consensus EPS estimate. Missing when Yahoo has none."`
+	Surprise float64  `json:"surprise" doc:"(actual - estimate) / |estimate|, as a fraction. 0 without an estimate."`
+	Beat     bool     `json:"beat" doc:"Whether EPS met or beat the estimate. False without an estimate."`
+}
+
+type APIFinancialPeriod struct {
+	Period   string  `json:"period" doc:"A year, e.g. 2024, or a quarter, e.g. 3Q2024."`
+	Revenue  float64 `json:"revenue"`
+	Earnings float64 `json:"earnings"`
+}
+
+type APIEarnings struct {
+	Currency string `json:"currency" doc:"Currency of revenue and earnings (ISO 4217)."`
+	// Empty when Yahoo has no upcoming date.
+	NextDate           string               `json:"next_date,omitempty" doc:"YYYY-MM-DD."`
+	NextDateIsEstimate bool                 `json:"next_date_is_estimate"`
+	NextEstimate       float64              `json:"next_estimate" doc:"Consensus EPS estimate for the coming report."`
+	BeatRate           float64              `json:"beat_rate" doc:"Share of the quarters in history with an estimate that met or beat it."`
+	History            []APIEarningsQuarter `json:"history" doc:"Oldest first."`
+	Yearly             []APIFinancialPeriod `json:"yearly" doc:"Oldest first."`
+	Quarterly          []APIFinancialPeriod `json:"quarterly" doc:"Oldest first."`
+}
+
+// surprise is how far actual beat estimate by, relative to the estimate.
+func surprise(actual, estimate float64) float64 {
+	if estimate == 0 {
+		return 0
+	}
+	return (actual - estimate) / math.Abs(estimate)
+}
+
+// toAPIEarnings returns nil when Yahoo has no earnings, as for funds, crypto
+// and currencies.
+func toAPIEarnings(result *Result) *APIEarnings {
+	e := result.Earnings
+	chart, fin := e.EarningsChart, e.FinancialsChart
+	if len(chart.Quarterly) == 0 && len(fin.Yearly) == 0 && len(fin.Quarterly) == 0 {
+		return nil
+	}
+	out := &APIEarnings{
+		Currency:           firstNonEmpty(e.FinancialCurrency, result.FinancialData.FinancialCurrency),
+		NextDateIsEstimate: chart.IsEarningsDateEstimate,
+		NextEstimate:       chart.CurrentQuarterEstimate.Raw,
+		History:            []APIEarningsQuarter{},
+		Yearly:             []APIFinancialPeriod{},
+		Quarterly:          []APIFinancialPeriod{},
+	}
+	if len(chart.EarningsDate) > 0 && chart.EarningsDate[0].Raw > 0 {
+		out.NextDate = time.Unix(chart.EarningsDate[0].Raw, 0).UTC().Format("2006-01-02")
+	}
+
+	var beats, estimated int
+	for _, q := range chart.Quarterly {
+		quarter := APIEarningsQuarter{
+			Quarter: firstNonEmpty(q.CalendarQuarter, q.Date),
+			Actual:  q.Actual.Raw,
+		}
+		// Yahoo sends {} when no analyst covered the quarter.
+		if q.Estimate.Fmt != "" || q.Estimate.Raw != 0 {
+			estimate := q.Estimate.Raw
+			quarter.Estimate = &estimate
+			quarter.Surprise = surprise(q.Actual.Raw, estimate)
+			quarter.Beat = q.Actual.Raw >= estimate
+			estimated++
+			if quarter.Beat {
+				beats++
+			}
+		}
+		out.History = append(out.History, quarter)
+	}
+	if estimated > 0 {
+		out.BeatRate = float64(beats) / float64(estimated)
+	}
+
+	for _, y := range fin.Yearly {
+		out.Yearly = append(out.Yearly, APIFinancialPeriod{strconv.Itoa(y.Date), y.Revenue.Raw, y.Earnings.Raw})
+	}
+	for _, q := range fin.Quarterly {
+		out.Quarterly = append(out.Quarterly, APIFinancialPeriod{q.Date, q.Revenue.Raw, q.Earnings.Raw})
+	}
+	return out
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// beatRateMetric is the EPS Beat Rate card, or nil without an EPS history
+// that has estimates.
+func beatRateMetric(result *Result) *Metric {
+	e := toAPIEarnings(result)
+	if e == nil || !slices.ContainsFunc(e.History, func(q APIEarningsQuarter) bool { return q.Estimate != nil }) {
+		return nil
+	}
+	return buildMetricCardInformation("EPS Beat Rate", &e.BeatRate, unitPercent)
+}
+
+// daysUntil counts whole days from now until date, a YYYY-MM-DD in UTC.
+func daysUntil(date string, now time.Time) int {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return int(t.Sub(today).Hours() / 24)
+}
+
+func countdownText(days int) string {
+	switch {
+	case days < 0:
+		return "date passed, awaiting an update"
+	case days == 0:
+		return "today"
+	case days == 1:
+		return "tomorrow"
+	}
+	return fmt.Sprintf("in %d days", days)
+}
+
+// Size of a financials chart's SVG viewBox, and the room under the bars for
+// period labels.
+const (
+	chartWidth  = 480
+	chartHeight = 200
+	chartLabelH = 20
+)
+
+// financialsChart draws revenue and earnings side by side for each period,
+// scaled to the largest value. Losses hang below the zero line.
+func financialsChart(periods []APIFinancialPeriod, currency string) g.Node {
+	if len(periods) == 0 {
+		return P(Class("text-sm text-gray-500"), g.Text("Yahoo Finance has no figures for these periods."))
+	}
+	var top, bottom float64
+	for _, p := range periods {
+		top = math.Max(top, math.Max(p.Revenue, p.Earnings))
+		bottom = math.Min(bottom, math.Min(p.Revenue, p.Earnings))
+	}
+	if top-bottom == 0 {
+		top = 1
+	}
+	plotH := float64(chartHeight - chartLabelH)
+	scale := plotH / (top - bottom)
+	zero := top * scale
+
+	slot := float64(chartWidth) / float64(len(periods))
+	barW := slot * 0.35
+	var nodes []g.Node
+	nodes = append(nodes, g.El("line",
+		g.Attr("x1", "0"), g.Attr("x2", num(chartWidth)), g.Attr("y1", num(zero)), g.Attr("y2", num(zero)),
+		g.Attr("stroke", "#4b5563"),
+	))
+	for i, p := range periods {
+		x := float64(i)*slot + slot*0.15
+		for j, series := range []struct {
+			value float64
+			fill  string
+			name  string
+		}{{p.Revenue, "#60a5fa", "Revenue"}, {p.Earnings, "#34d399", "Earnings"}} {
+			y, h := zero-series.value*scale, series.value*scale
+			if series.value < 0 {
+				y, h = zero, -h
+			}
+			fill := series.fill
+			if series.value < 0 {
+				fill = "#f87171"
+			}
+			nodes = append(nodes, g.El("rect",
+				g.Attr("x", num(x+float64(j)*barW)), g.Attr("y", num(y)),
+				g.Attr("width", num(barW-2)), g.Attr("height", num(math.Max(h, 1))),
+				g.Attr("fill", fill),
+				g.El("title", g.Text(fmt.Sprintf("%s %s: %s", p.Period, series.name, common.FormatCurrency(series.value, currency)))),
+			))
+		}
+		nodes = append(nodes, g.El("text",
+			g.Attr("x", num(x+barW)), g.Attr("y", num(chartHeight-4)),
+			g.Attr("text-anchor", "middle"), g.Attr("font-size", "11"), g.Attr("fill", "#9ca3af"),
+			g.Text(p.Period),
+		))
+	}
+	return Div(
+		SVG(g.Attr("viewBox", fmt.Sprintf("0 0 %d %d", chartWidth, chartHeight)), Class("w-full h-auto"), g.Attr("role", "img"),
+			g.Attr("aria-label", "Revenue and earnings by period"),
+			g.Group(nodes),
+		),
+		Div(Class("flex gap-4 text-xs text-gray-400 mt-2"),
+			Span(Span(Class("inline-block w-3 h-3 mr-1 align-middle"), g.Attr("style", "background:#60a5fa")), g.Text("Revenue")),
+			Span(Span(Class("inline-block w-3 h-3 mr-1 align-middle"), g.Attr("style", "background:#34d399")), g.Text("Earnings")),
+			Span(g.Text("Hover a bar for its value")),
+		),
+	)
+}
+
+func num(v float64) string {
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
+
+func epsHistoryTable(history []APIEarningsQuarter) g.Node {
+	if len(history) == 0 {
+		return P(Class("text-sm text-gray-500"), g.Text("Yahoo Finance has no EPS history."))
+	}
+	var rows []g.Node
+	for _, q := range history {
+		estimate, badge, class := "–", "Beat", "bg-green-900 text-green-300"
+		if !q.Beat {
+			badge, class = "Miss", "bg-red-900 text-red-300"
+		}
+		if q.Estimate == nil {
+			badge, class = "No estimate", "bg-gray-700 text-gray-300"
+		} else {
+			estimate = fmt.Sprintf("%.2f", *q.Estimate)
+		}
+		rows = append(rows, Tr(Class("border-t border-gray-700"),
+			Td(Class("py-1 pr-2 text-gray-300"), g.Text(q.Quarter)),
+			Td(Class("py-1 pr-2 text-right"), g.Text(estimate)),
+			Td(Class("py-1 pr-2 text-right"), g.Text(fmt.Sprintf("%.2f", q.Actual))),
+			Td(Class("py-1 pr-2 text-right "+returnClass(q.Surprise)), g.Text(fmt.Sprintf("%+.1f%%", q.Surprise*100))),
+			Td(Class("py-1 text-right"), Span(Class("px-2 py-0.5 rounded text-xs "+class), g.Text(badge))),
+		))
+	}
+	return Table(Class("w-full text-sm"),
+		THead(Tr(Class("text-gray-400 text-xs"),
+			Th(Class("text-left font-medium"), g.Text("Quarter")),
+			Th(Class("text-right font-medium"), g.Text("Estimate")),
+			Th(Class("text-right font-medium"), g.Text("Actual")),
+			Th(Class("text-right font-medium"), g.Text("Surprise")),
+			Th(),
+		)),
+		TBody(g.Group(rows)),
+	)
+}
+
+// earningsSection is the stock page's earnings panel, or nil without
+// earnings data.
+func earningsSection(result *Result, now time.Time) g.Node {
+	e := toAPIEarnings(result)
+	if e == nil {
+		return nil
+	}
+	next := "Yahoo Finance has no upcoming earnings date."
+	if e.NextDate != "" {
+		next = fmt.Sprintf("Next earnings %s, %s", e.NextDate, countdownText(daysUntil(e.NextDate, now)))
+		if e.NextDateIsEstimate {
+			next += " (estimated)"
+		}
+		if e.NextEstimate != 0 {
+			next += fmt.Sprintf(" · EPS estimate %.2f", e.NextEstimate)
+		}
+	}
+
+	return Div(Class("mt-8"),
+		H2(Class("text-2xl font-bold text-white mb-2"), g.Text("Earnings")),
+		P(Class("text-gray-400 mb-4"), g.Text(next)),
+		Div(Class("grid grid-cols-1 lg:grid-cols-2 gap-6"),
+			Div(g.Attr("x-data", "{ period: 'yearly' }"), Class("bg-gray-800 rounded-lg border border-gray-700 p-4"),
+				Div(Class("flex justify-between items-center mb-3"),
+					H3(Class("text-lg font-semibold text-white"), g.Text("Revenue and Earnings")),
+					Div(Class("flex gap-2 text-xs"),
+						Button(g.Attr("@click", "period = 'yearly'"), g.Attr(":class", "period === 'yearly' ? 'bg-blue-600 text-white' : 'bg-gray-700 text-gray-300'"), Class("px-2 py-1 rounded"), g.Text("Yearly")),
+						Button(g.Attr("@click", "period = 'quarterly'"), g.Attr(":class", "period === 'quarterly' ? 'bg-blue-600 text-white' : 'bg-gray-700 text-gray-300'"), Class("px-2 py-1 rounded"), g.Text("Quarterly")),
+					),
+				),
+				Div(g.Attr("x-show", "period === 'yearly'"), financialsChart(e.Yearly, e.Currency)),
+				Div(g.Attr("x-show", "period === 'quarterly'"), financialsChart(e.Quarterly, e.Currency)),
+			),
+			fundSection("EPS vs Estimate", epsHistoryTable(e.History)),
+		),
+	)
+}
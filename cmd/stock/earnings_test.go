@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEarningsResult() *Result {
+	r := testResult()
+	e := &r.Earnings
+	e.FinancialCurrency = "USD"
+	e.EarningsChart.Quarterly = []QuarterlyEarning{
+		{Date: "4Q2024", CalendarQuarter: "4Q2024", Actual: FmtRaw{Raw: 2.40}, Estimate: FmtRaw{Raw: 2.35}},
+		{Date: "1Q2025", CalendarQuarter: "1Q2025", Actual: FmtRaw{Raw: 1.65}, Estimate: FmtRaw{Raw: 1.62}},
+		{Date: "2Q2025", CalendarQuarter: "2Q2025", Actual: FmtRaw{Raw: 1.40}, Estimate: FmtRaw{Raw: 1.50}},
+		{Date: "3Q2025", CalendarQuarter: "3Q2025", Actual: FmtRaw{Raw: 1.80}, Estimate: FmtRaw{Raw: 1.80}},
+	}
+	e.EarningsChart.CurrentQuarterEstimate = FmtRaw{Raw: 2.50}
+	// 2025-10-30.
+	e.EarningsChart.EarningsDate = []EarningsDate{{Raw: 1761782400}}
+	e.EarningsChart.IsEarningsDateEstimate = true
+	e.FinancialsChart.Yearly = []YearlyFinancial{
+		{Date: 2023, Revenue: FmtRaw{Raw: 383e9}, Earnings: FmtRaw{Raw: 97e9}},
+		{Date: 2024, Revenue: FmtRaw{Raw: 391e9}, Earnings: FmtRaw{Raw: -5e9}},
+	}
+	e.FinancialsChart.Quarterly = []QuarterlyFinancial{
+		{Date: "2Q2025", Revenue: FmtRaw{Raw: 94e9}, Earnings: FmtRaw{Raw: 23e9}},
+	}
+	return r
+}
+
+func TestToAPIEarnings(t *testing.T) {
+	e := toAPIEarnings(testEarningsResult())
+	if e == nil {
+		t.Fatal("no earnings")
+	}
+	if e.NextDate != "2025-10-30" || !e.NextDateIsEstimate || e.NextEstimate != 2.50 || e.Currency != "USD" {
+		t.Errorf("next = %+v", e)
+	}
+	// Meeting the estimate counts as a beat; 75% is only just short of green.
+	if e.BeatRate != 0.75 || !e.History[3].Beat || e.History[2].Beat {
+		t.Errorf("beat rate = %v, history = %+v", e.BeatRate, e.History)
+	}
+	if s := e.History[2].Surprise; s > -0.0666 || s < -0.0667 {
+		t.Errorf("2Q2025 surprise = %v, want -1/15", s)
+	}
+	if len(e.Yearly) != 2 || e.Yearly[0].Period != "2023" || e.Yearly[1].Earnings != -5e9 || len(e.Quarterly) != 1 {
+		t.Errorf("periods = %+v %+v", e.Yearly, e.Quarterly)
+	}
+
+	// A quarter without an estimate ({} from Yahoo) is neither a beat nor a
+	// miss.
+	r := testEarningsResult()
+	r.Earnings.EarningsChart.Quarterly[2].Estimate = FmtRaw{}
+	e = toAPIEarnings(r)
+	if e.BeatRate != 1 || e.History[2].Estimate != nil || e.History[2].Beat || e.History[2].Surprise != 0 {
+		t.Errorf("without an estimate: beat rate = %v, quarter = %+v", e.BeatRate, e.History[2])
+	}
+	for i := range r.Earnings.EarningsChart.Quarterly {
+		r.Earnings.EarningsChart.Quarterly[i].Estimate = FmtRaw{}
+	}
+	if m := beatRateMetric(r); m != nil {
+		t.Errorf("beat rate metric without estimates = %+v", m)
+	}
+
+	if toAPIEarnings(testResult()) != nil || beatRateMetric(testResult()) != nil {
+		t.Error("earnings without Yahoo data")
+	}
+	if m := beatRateMetric(testEarningsResult()); m == nil || m.Color != "yellow" {
+		t.Errorf("beat rate metric = %+v", m)
+	}
+}
+
+func TestSurprise(t *testing.T) {
+	for _, tt := range []struct{ actual, estimate, want float64 }{
+		{1.1, 1, 0.1},
+		// A smaller loss than expected is a positive surprise.
+		{-0.5, -1, 0.5},
+		{1, 0, 0},
+	} {
+		if got := surprise(tt.actual, tt.estimate); got < tt.want-1e-9 || got > tt.want+1e-9 {
+			t.Errorf("surprise(%v, %v) = %v, want %v", tt.actual, tt.estimate, got, tt.want)
+		}
+	}
+}
+
+func TestDaysUntil(t *testing.T) {
+	now := time.Date(2025, 10, 20, 23, 30, 0, 0, time.UTC)
+	for date, want := range map[string]int{"2025-10-20": 0, "2025-10-21": 1, "2025-10-30": 10, "2025-10-01": -19} {
+		if got := daysUntil(date, now); got != want {
+			t.Errorf("daysUntil(%s) = %d, want %d", date, got, want)
+		}
+	}
+	if countdownText(10) != "in 10 days" || countdownText(1) != "tomorrow" {
+		t.Error("countdown text")
+	}
+}
+
+func TestEarningsSection(t *testing.T) {
+	var b strings.Builder
+	earningsSection(testEarningsResult(), time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)).Render(&b)
+	html := b.String()
+	for _, want := range []string{
+		"Next earnings 2025-10-30, in 10 days (estimated)",
+		"EPS estimate 2.50",
+		"<svg", "2024 Earnings: -$5.00B", `fill="#f87171"`,
+		"+2.1%", "-6.7%", "Miss",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("earnings section lacks %q", want)
+		}
+	}
+	if earningsSection(testResult(), time.Now()) != nil {
+		t.Error("earnings section without Yahoo data")
+	}
+}
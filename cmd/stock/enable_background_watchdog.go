@@ -1,7 +1,7 @@
 package main
 
 import (
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/coreos/go-systemd/daemon"
@@ -25,7 +25,10 @@ func (s *SystemdDaemon) SdWatchdogEnabled(unsetEnv bool) (time.Duration, error)
 
 // When running under systemd, send a keep-alive ping to systemd every 15 seconds.
 // At 30 seconds without a ping, systemd will restart the process.
-func EnableBackgroundWatchdog(dmn DaemonNotifier) error {
+//
+// The ping is only sent while isAlive returns true, so a process that is
+// running but wedged still gets restarted.
+func EnableBackgroundWatchdog(dmn DaemonNotifier, isAlive func() bool) error {
 	// Tell systemd we're ready
 	sent, err := dmn.SdNotify(false, "READY=1")
 	if err != nil {
@@ -33,7 +36,7 @@ func EnableBackgroundWatchdog(dmn DaemonNotifier) error {
 	}
 	if !sent {
 		// Not fatal — just log
-		log.Println("Not running under systemd or watchdog not enabled")
+		slog.Info("Not running under systemd or watchdog not enabled")
 	}
 
 	watchdogInterval, err := dmn.SdWatchdogEnabled(false)
@@ -46,7 +49,9 @@ func EnableBackgroundWatchdog(dmn DaemonNotifier) error {
 
 		go func() {
 			for range ticker.C {
-				dmn.SdNotify(false, "WATCHDOG=1")
+				if isAlive() {
+					dmn.SdNotify(false, "WATCHDOG=1")
+				}
 			}
 		}()
 	}
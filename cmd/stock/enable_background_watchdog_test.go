@@ -23,13 +23,15 @@ func (m *MockDaemon) SdWatchdogEnabled(_ bool) (time.Duration, error) {
 	return m.WatchdogReturnValue, m.WatchdogReturnError
 }
 
+func alwaysAlive() bool { return true }
+
 func TestEnableBackgroundWatchdog(t *testing.T) {
 	mock := &MockDaemon{
 		NotifyReturnValue:   true,
 		WatchdogReturnValue: 100 * time.Millisecond,
 	}
 
-	EnableBackgroundWatchdog(mock)
+	EnableBackgroundWatchdog(mock, alwaysAlive)
 
 	// Check that "READY=1" notification was sent
 	foundReady := false
@@ -65,7 +67,7 @@ func TestEnableBackgroundWatchdog_NotRunningUnderSystemd(t *testing.T) {
 		WatchdogReturnValue: 0,     // watchdog disabled
 	}
 
-	EnableBackgroundWatchdog(mock)
+	EnableBackgroundWatchdog(mock, alwaysAlive)
 
 	// Should send READY=1 but return false, so check that NotifyCalls include it
 	foundReady := false
@@ -87,6 +89,24 @@ func TestEnableBackgroundWatchdog_NotRunningUnderSystemd(t *testing.T) {
 	}
 }
 
+func TestEnableBackgroundWatchdog_NotAlive(t *testing.T) {
+	mock := &MockDaemon{
+		NotifyReturnValue:   true,
+		WatchdogReturnValue: 100 * time.Millisecond,
+	}
+
+	EnableBackgroundWatchdog(mock, func() bool { return false })
+
+	// Wait long enough that several pings would have been sent if alive.
+	time.Sleep(350 * time.Millisecond)
+
+	for _, call := range mock.NotifyCalls {
+		if call == "WATCHDOG=1" {
+			t.Error("Did not expect WATCHDOG=1 notification while liveness is failing")
+		}
+	}
+}
+
 type testError struct{}
 
 func (e *testError) Error() string {
@@ -101,7 +121,7 @@ func TestEnableBackgroundWatchdog_ErrorOnNotify(t *testing.T) {
 		NotifyReturnError: errTest,
 	}
 
-	err := EnableBackgroundWatchdog(mock)
+	err := EnableBackgroundWatchdog(mock, alwaysAlive)
 	if err == nil {
 		t.Fatal("expected error but got nil")
 	}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	xlsx "app/internal/xlsx"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var exportHeader = []string{"Symbol", "Metric", "Value", "Formatted", "Unit", "Color", "Reason"}
+
+// Spreadsheet fills for each card color, matching Excel's conditional
+// formatting presets.
+var exportFills = map[string]string{
+	"green":  "C6EFCE",
+	"yellow": "FFEB9C",
+	"red":    "FFC7CE",
+}
+
+// Reads ?symbol= for a single stock or ?symbols=A,B,C for a list.
+func parseExportSymbols(r *http.Request) []batchSymbol {
+	raw := r.URL.Query().Get("symbols")
+	if raw == "" {
+		raw = r.URL.Query().Get("symbol")
+	}
+	return parseSymbolList(strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }))
+}
+
+// spreadsheetText stops spreadsheet apps from running a cell as a formula.
+// Invalid symbols are echoed back as typed, so a shared export link could
+// otherwise plant one.
+func spreadsheetText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// exportRows fetches symbols and returns their results in symbol order. The
+// writers emit one row per metric, or a single error row for a failed or
+// invalid symbol.
+func exportRows(ctx context.Context, symbols []batchSymbol) []APIBatchItem {
+	items := make([]APIBatchItem, len(symbols))
+	fetchBatch(ctx, symbols, func(i int, item APIBatchItem) {
+		items[i] = item
+	})
+	return items
+}
+
+func writeExportCSV(w http.ResponseWriter, items []APIBatchItem) error {
+	cw := csv.NewWriter(w)
+	cw.Write(exportHeader)
+	for _, item := range items {
+		if item.Error != nil {
+			cw.Write([]string{spreadsheetText(item.Symbol), "Error", "", "", "", "", spreadsheetText(item.Error.Message)})
+			continue
+		}
+		for _, m := range item.Stock.Metrics {
+			cw.Write([]string{
+				spreadsheetText(item.Symbol),
+				spreadsheetText(m.Name),
+				strconv.FormatFloat(m.Value, 'f', -1, 64),
+				spreadsheetText(m.Formatted),
+				spreadsheetText(m.Unit),
+				spreadsheetText(m.Color),
+				spreadsheetText(m.Reason),
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeExportXLSX(w http.ResponseWriter, items []APIBatchItem) error {
+	var header []xlsx.Cell
+	for _, h := range exportHeader {
+		header = append(header, xlsx.Cell{Value: h, Bold: true})
+	}
+	rows := [][]xlsx.Cell{header}
+	for _, item := range items {
+		if item.Error != nil {
+			rows = append(rows, []xlsx.Cell{
+				{Value: spreadsheetText(item.Symbol)}, {Value: "Error"}, {}, {}, {}, {}, {Value: spreadsheetText(item.Error.Message)},
+			})
+			continue
+		}
+		for _, m := range item.Stock.Metrics {
+			fill := exportFills[m.Color]
+			rows = append(rows, []xlsx.Cell{
+				{Value: spreadsheetText(item.Symbol)},
+				{Value: spreadsheetText(m.Name), Fill: fill},
+				{Value: m.Value, Fill: fill},
+				{Value: spreadsheetText(m.Formatted), Fill: fill},
+				{Value: spreadsheetText(m.Unit)},
+				{Value: spreadsheetText(m.Color), Fill: fill},
+				{Value: spreadsheetText(m.Reason)},
+			})
+		}
+	}
+	return xlsx.Write(w, xlsx.Sheet{
+		Name:         "Metrics",
+		Rows:         rows,
+		ColumnWidths: []float64{10, 24, 18, 14, 10, 8, 80},
+	})
+}
+
+// exportHandler downloads metrics for one or more symbols as CSV or XLSX.
+//
+//	/export?symbol=AAPL&format=csv
+//	/export?symbols=AAPL,MSFT&format=xlsx
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+	symbols := parseExportSymbols(r)
+	if len(symbols) == 0 {
+		http.Error(w, "symbol or symbols parameter required", http.StatusBadRequest)
+		return
+	}
+	if len(symbols) > maxBatchSymbols {
+		http.Error(w, fmt.Sprintf("at most %d symbols per export", maxBatchSymbols), http.StatusBadRequest)
+		return
+	}
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "xlsx" {
+		http.Error(w, "format must be csv or xlsx", http.StatusBadRequest)
+		return
+	}
+
+	name := "stocks"
+	if len(symbols) == 1 && symbols[0].Err == nil {
+		name = symbols[0].Symbol.CacheKey()
+	}
+	filename := fmt.Sprintf("%s-metrics-%s.%s", name, time.Now().Format("2006-01-02"), format)
+
+	items := exportRows(r.Context(), symbols)
+
+	var err error
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if format == "xlsx" {
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		err = writeExportXLSX(w, items)
+	} else {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		err = writeExportCSV(w, items)
+	}
+	if err != nil {
+		// Headers are already sent, so all we can do is log.
+		slog.ErrorContext(r.Context(), "Error writing export", "err", err)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestExportCSV(t *testing.T) {
+	withFetcher(t, func(symbol string) (*Result, error) {
+		if symbol == "BAD" {
+			return nil, errors.New("upstream down")
+		}
+		return testResult(), nil
+	})
+
+	rec := httptest.NewRecorder()
+	exportHandler(rec, httptest.NewRequest("GET", "/export?symbols=aapl,BAD,../etc&format=csv", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, `stocks-metrics-`) || !strings.HasSuffix(cd, `.csv"`) {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(records[0], ",") != strings.Join(exportHeader, ",") {
+		t.Errorf("header = %v", records[0])
+	}
+	var pe, errRow, invalidRow []string
+	for _, r := range records[1:] {
+		if r[0] == "../etc" {
+			invalidRow = r
+		}
+		if r[0] == "AAPL" && r[1] == "P/E Ratio" {
+			pe = r
+		}
+		if r[0] == "BAD" {
+			errRow = r
+		}
+	}
+	if pe == nil || pe[2] != "30" || pe[3] != "30.00" || pe[5] != "red" {
+		t.Errorf("P/E row = %v", pe)
+	}
+	if errRow == nil || errRow[1] != "Error" || errRow[6] != "upstream down" {
+		t.Errorf("error row = %v", errRow)
+	}
+	if invalidRow == nil || invalidRow[1] != "Error" {
+		t.Errorf("invalid symbol row = %v", invalidRow)
+	}
+}
+
+func TestExportXLSX(t *testing.T) {
+	withFetcher(t, func(string) (*Result, error) { return testResult(), nil })
+
+	rec := httptest.NewRecorder()
+	exportHandler(rec, httptest.NewRequest("GET", "/export?symbol=AAPL&format=xlsx", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, `AAPL-metrics-`) {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+
+	body := rec.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("not a zip: %v", err)
+	}
+	for _, f := range zr.File {
+		if f.Name != "xl/styles.xml" {
+			continue
+		}
+		rc, _ := f.Open()
+		styles, _ := io.ReadAll(rc)
+		rc.Close()
+		if !strings.Contains(string(styles), exportFills["red"]) {
+			t.Errorf("red fill missing from styles")
+		}
+		return
+	}
+	t.Error("xl/styles.xml missing")
+}
+
+func TestExportEscapesFormulas(t *testing.T) {
+	withFetcher(t, func(string) (*Result, error) { return testResult(), nil })
+	link := "/export?symbols=" + url.QueryEscape(`=HYPERLINK("http://x")`)
+
+	rec := httptest.NewRecorder()
+	exportHandler(rec, httptest.NewRequest("GET", link+"&format=csv", nil))
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1][0] != `'=HYPERLINK("http://x")` {
+		t.Errorf("csv rows = %v", records)
+	}
+
+	rec = httptest.NewRecorder()
+	exportHandler(rec, httptest.NewRequest("GET", link+"&format=xlsx", nil))
+	body := rec.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("not a zip: %v", err)
+	}
+	for _, f := range zr.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, _ := f.Open()
+		sheet, _ := io.ReadAll(rc)
+		rc.Close()
+		if !strings.Contains(string(sheet), ">&#39;=HYPERLINK(") {
+			t.Errorf("formula not escaped in sheet:\n%s", sheet)
+		}
+		return
+	}
+	t.Error("xl/worksheets/sheet1.xml missing")
+}
+
+func TestSpreadsheetText(t *testing.T) {
+	for in, want := range map[string]string{
+		"AAPL": "AAPL", "": "", "=1+1": "'=1+1", "+1": "'+1", "-5.00%": "'-5.00%", "@SUM(A1)": "'@SUM(A1)", "\tx": "'\tx", "\rx": "'\rx",
+	} {
+		if got := spreadsheetText(in); got != want {
+			t.Errorf("spreadsheetText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	for _, url := range []string{"/export", "/export?symbol=AAPL&format=pdf"} {
+		rec := httptest.NewRecorder()
+		exportHandler(rec, httptest.NewRequest("GET", url, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s status = %d, want 400", url, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	g "maragu.dev/gomponents"
+
+	// Importing this as '.' is intentional for cleaner HTML like code.
+	. "maragu.dev/gomponents/html"
+)
+
+// ETFs and mutual funds have no earnings or balance sheet of their own, so
+// stock cards like ROE and Quick Ratio are meaningless for them. They are
+// judged on cost, size, concentration and track record instead.
+
+// APIFund describes what an ETF or mutual fund costs and holds.
+type APIFund struct {
+	Family          string              `json:"family" doc:"Fund family, e.g. Vanguard."`
+	Category        string              `json:"category" doc:"Morningstar category, e.g. Large Blend."`
+	LegalType       string              `json:"legal_type" doc:"e.g. Exchange Traded Fund."`
+	ExpenseRatio    float64             `json:"expense_ratio" doc:"Fraction, 0.0009 means 0.09%."`
+	Yield           float64             `json:"yield" doc:"Fraction, 0.013 means 1.3%."`
+	TotalAssets     float64             `json:"total_assets"`
+	Turnover        float64             `json:"holdings_turnover" doc:"Fraction of holdings replaced per year."`
+	Allocation      APIAssetAllocation  `json:"allocation"`
+	Holdings        []APIHolding        `json:"holdings" doc:"Top holdings, largest first."`
+	SectorWeights   []APISectorWeight   `json:"sector_weights" doc:"Largest first; sectors without holdings are omitted."`
+	TrailingReturns []APITrailingReturn `json:"trailing_returns" doc:"Shortest period first; periods the fund is too young for are omitted."`
+}
+
+// APIAssetAllocation is how the fund's assets are split, as fractions.
+type APIAssetAllocation struct {
+	Stocks float64 `json:"stocks"`
+	Bonds  float64 `json:"bonds"`
+	Cash   float64 `json:"cash"`
+	Other  float64 `json:"other"`
+}
+
+type APIHolding struct {
+	Symbol string  `json:"symbol"`
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight" doc:"Fraction of the fund."`
+}
+
+type APISectorWeight struct {
+	Sector string  `json:"sector"`
+	Weight float64 `json:"weight" doc:"Fraction of the fund."`
+}
+
+type APITrailingReturn struct {
+	Period string  `json:"period" enum:"YTD,1M,3M,1Y,3Y,5Y,10Y"`
+	Return float64 `json:"return" doc:"Fraction; 3Y and longer are annualized."`
+}
+
+// Yahoo's sectorWeightings keys.
+var sectorNames = map[string]string{
+	"realestate":             "Real Estate",
+	"consumer_cyclical":      "Consumer Cyclical",
+	"basic_materials":        "Basic Materials",
+	"consumer_defensive":     "Consumer Defensive",
+	"technology":             "Technology",
+	"communication_services": "Communication Services",
+	"financial_services":     "Financial Services",
+	"utilities":              "Utilities",
+	"industrials":            "Industrials",
+	"energy":                 "Energy",
+	"healthcare":             "Healthcare",
+}
+
+// toAPIFund gathers the fund fields, which Yahoo spreads across several
+// modules with different units.
+func toAPIFund(result *Result) *APIFund {
+	if !result.isFund() {
+		return nil
+	}
+	sd, ks, th := result.SummaryDetail, result.DefaultKeyStatistics, result.TopHoldings
+	profile := result.FundProfile
+	fees := profile.FeesExpensesInvestment
+	f := &APIFund{
+		Family:       profile.Family,
+		Category:     profile.CategoryName,
+		LegalType:    profile.LegalType,
+		ExpenseRatio: firstNonZero(ks.AnnualReportExpenseRatio.Raw, fees.NetExpRatio.Raw/100, fees.AnnualReportExpenseRatio.Raw/100),
+		Yield:        firstNonZero(sd.Yield.Raw, ks.Yield.Raw),
+		TotalAssets:  firstNonZero(sd.TotalAssets.Raw, ks.TotalAssets.Raw, fees.TotalNetAssets.Raw),
+		Turnover:     firstNonZero(ks.AnnualHoldingsTurnover.Raw, fees.AnnualHoldingsTurnover.Raw/100),
+		Allocation: APIAssetAllocation{
+			Stocks: th.StockPosition.Raw,
+			Bonds:  th.BondPosition.Raw,
+			Cash:   th.CashPosition.Raw,
+			Other:  th.OtherPosition.Raw,
+		},
+	}
+	if f.Family == "" && ks.FundFamily != nil {
+		f.Family = *ks.FundFamily
+	}
+	if f.Category == "" && ks.Category != nil {
+		f.Category = *ks.Category
+	}
+	if f.LegalType == "" && ks.LegalType != nil {
+		f.LegalType = *ks.LegalType
+	}
+
+	for _, h := range th.Holdings {
+		f.Holdings = append(f.Holdings, APIHolding{Symbol: h.Symbol, Name: h.HoldingName, Weight: h.HoldingPercent.Raw})
+	}
+	sort.SliceStable(f.Holdings, func(i, j int) bool { return f.Holdings[i].Weight > f.Holdings[j].Weight })
+
+	for _, entry := range th.SectorWeightings {
+		for key, w := range entry {
+			if w.Raw == 0 {
+				continue
+			}
+			name, ok := sectorNames[key]
+			if !ok {
+				name = strings.ReplaceAll(key, "_", " ")
+			}
+			f.SectorWeights = append(f.SectorWeights, APISectorWeight{Sector: name, Weight: w.Raw})
+		}
+	}
+	sort.SliceStable(f.SectorWeights, func(i, j int) bool { return f.SectorWeights[i].Weight > f.SectorWeights[j].Weight })
+
+	tr := result.FundPerformance.TrailingReturns
+	for _, p := range []struct {
+		period string
+		value  FmtRaw
+	}{
+		{"YTD", tr.Ytd}, {"1M", tr.OneMonth}, {"3M", tr.ThreeMonth}, {"1Y", tr.OneYear},
+		{"3Y", tr.ThreeYear}, {"5Y", tr.FiveYear}, {"10Y", tr.TenYear},
+	} {
+		// Missing periods come back as {}.
+		if p.value.Fmt != "" || p.value.Raw != 0 {
+			f.TrailingReturns = append(f.TrailingReturns, APITrailingReturn{Period: p.period, Return: p.value.Raw})
+		}
+	}
+	return f
+}
+
+// topWeight is the share of the fund in its listed top holdings.
+func (f *APIFund) topWeight() float64 {
+	var sum float64
+	for _, h := range f.Holdings {
+		sum += h.Weight
+	}
+	return sum
+}
+
+// buildFundMetricsList is buildMetricsList for funds.
+func buildFundMetricsList(result *Result) []Metric {
+	f := toAPIFund(result)
+	ks := result.DefaultKeyStatistics
+	tr := result.FundPerformance.TrailingReturns
+	ytd := firstNonZero(tr.Ytd.Raw, ks.YtdReturn.Raw, result.SummaryDetail.YtdReturn.Raw)
+	threeYear := firstNonZero(ks.ThreeYearAverageReturn.Raw, tr.ThreeYear.Raw)
+	fiveYear := firstNonZero(ks.FiveYearAverageReturn.Raw, tr.FiveYear.Raw)
+	topWeight := f.topWeight()
+	price := result.price()
+
+	return availableMetrics([]metricConfig{
+		{"Expense Ratio", nonZero(f.ExpenseRatio), unitPercent},
+		{"Yield", nonZero(f.Yield), unitPercent},
+		{"Total Assets", nonZero(f.TotalAssets), unitCurrency},
+		{"YTD Return", nonZero(ytd), unitPercent},
+		{"3Y Avg Return", nonZero(threeYear), unitPercent},
+		{"5Y Avg Return", nonZero(fiveYear), unitPercent},
+		{"Beta (3Y)", nonZero(ks.Beta3Year.Raw), unitRatio},
+		{"Holdings Turnover", nonZero(f.Turnover), unitPercent},
+		{"Top 10 Weight", nonZero(topWeight), unitPercent},
+		{"Price", nonZero(price), unitCurrency},
+	})
+}
+
+func percentText(v float64) string {
+	return fmt.Sprintf("%.2f%%", v*100)
+}
+
+// Positive returns in green, negative in red.
+func returnClass(v float64) string {
+	if v < 0 {
+		return "text-red-300"
+	}
+	return "text-green-300"
+}
+
+func fundSection(title string, body ...g.Node) g.Node {
+	return Div(Class("bg-gray-800 rounded-lg border border-gray-700 p-4"),
+		H2(Class("text-lg font-semibold text-white mb-3"), g.Text(title)),
+		g.Group(body),
+	)
+}
+
+func fundHoldingsTable(holdings []APIHolding) g.Node {
+	if len(holdings) == 0 {
+		return P(Class("text-sm text-gray-500"), g.Text("Yahoo Finance has no holdings for this fund."))
+	}
+	var rows []g.Node
+	for _, h := range holdings {
+		rows = append(rows, Tr(Class("border-t border-gray-700"),
+			Td(Class("py-1 pr-2"),
+				g.If(h.Symbol != "", A(Href("/stock?symbol="+url.QueryEscape(h.Symbol)), Class("text-blue-400 hover:underline"), g.Text(h.Symbol))),
+			),
+			Td(Class("py-1 pr-2 text-gray-300"), g.Text(h.Name)),
+			Td(Class("py-1 text-right"), g.Text(percentText(h.Weight))),
+		))
+	}
+	return Table(Class("w-full text-sm"), TBody(g.Group(rows)))
+}
+
+func fundWeightBars(sectors []APISectorWeight, empty string) g.Node {
+	if len(sectors) == 0 {
+		return P(Class("text-sm text-gray-500"), g.Text(empty))
+	}
+	var bars []g.Node
+	for _, s := range sectors {
+		bars = append(bars, Div(Class("mb-2"),
+			Div(Class("flex justify-between text-sm"),
+				Span(Class("text-gray-300"), g.Text(s.Sector)),
+				Span(g.Text(percentText(s.Weight))),
+			),
+			Div(Class("w-full bg-gray-700 rounded h-2"),
+				Div(Class("bg-blue-500 h-2 rounded"), Style(fmt.Sprintf("width: %.1f%%", s.Weight*100))),
+			),
+		))
+	}
+	return g.Group(bars)
+}
+
+func fundReturnsTable(returns []APITrailingReturn) g.Node {
+	if len(returns) == 0 {
+		return P(Class("text-sm text-gray-500"), g.Text("No trailing returns for this fund."))
+	}
+	var heads, cells []g.Node
+	for _, r := range returns {
+		heads = append(heads, Th(Class("px-2 py-1 text-gray-400 font-normal"), g.Text(r.Period)))
+		cells = append(cells, Td(Class("px-2 py-1 text-center font-semibold "+returnClass(r.Return)), g.Text(percentText(r.Return))))
+	}
+	return Table(Class("w-full text-sm"),
+		THead(Tr(g.Group(heads))),
+		TBody(Tr(g.Group(cells))),
+	)
+}
+
+// fundPage is stockPage for ETFs and mutual funds: the fund metric cards,
+// then what the fund holds and how it has done.
+func fundPage(symbol string, result *Result, user *User) g.Node {
+	f := toAPIFund(result)
+	var metricCards []g.Node
+	for _, m := range buildMetricsList(result) {
+		metricCards = append(metricCards, renderMetricCard(m))
+	}
+	var subtitle []string
+	for _, s := range []string{f.LegalType, f.Family, f.Category} {
+		if s != "" {
+			subtitle = append(subtitle, s)
+		}
+	}
+
+	return HTML(
+		Head(
+			Meta(Charset("UTF-8")),
+			Meta(Name("viewport"), Content("width=device-width, initial-scale=1.0")),
+			TitleEl(g.Text(fmt.Sprintf("%s - Fund Analysis", symbol))),
+			Script(Src("https://cdn.tailwindcss.com")),
+			Script(g.Raw(`tailwind.config = { theme: { extend: { colors: { darkbg: '#1a1a1a' } } } }`)),
+		),
+		Body(Class("bg-darkbg text-gray-200 min-h-screen"),
+			Div(Class("container mx-auto px-4 py-8"),
+				userBadge(user),
+				Div(Class("mb-8 flex items-center justify-between"),
+					Div(
+						H1(Class("text-4xl font-bold text-white mb-2"), g.Text(symbol)),
+						P(Class("text-gray-300"), g.Text(instrumentName(symbol, result))),
+						g.If(len(subtitle) > 0, P(Class("text-sm text-gray-500"), g.Text(strings.Join(subtitle, " · ")))),
+					),
+					Div(Class("flex flex-col items-end gap-2"),
+						A(Href("/"), Class("text-blue-400 hover:underline text-sm"), g.Text("← New Search")),
+						Div(Class("flex gap-2"),
+							currencyPicker(symbol, result.Currencies),
+							g.If(result.hasOptions(), downloadButton("/options?symbol="+url.QueryEscape(symbol), "Options")),
+							downloadButton("/export?symbol="+url.QueryEscape(symbol)+"&format=csv", "Download CSV"),
+							downloadButton("/export?symbol="+url.QueryEscape(symbol)+"&format=xlsx", "Download Excel"),
+						),
+					),
+				),
+
+				Div(Class("mb-6 flex gap-4"),
+					colorKey("green", "Strong Buy Signal"),
+					colorKey("yellow", "Neutral"),
+					colorKey("red", "Caution"),
+				),
+				Div(Class("grid grid-cols-1 md:grid-cols-2 lg:grid-cols-3 gap-6 mb-8"), g.Group(metricCards)),
+
+				Div(Class("grid grid-cols-1 lg:grid-cols-2 gap-6"),
+					fundSection("Trailing Returns", fundReturnsTable(f.TrailingReturns)),
+					fundSection("Asset Allocation", fundWeightBars(allocationWeights(f.Allocation), "No asset allocation for this fund.")),
+					fundSection("Top Holdings", fundHoldingsTable(f.Holdings)),
+					fundSection("Sector Weights", fundWeightBars(f.SectorWeights, "No sector breakdown for this fund.")),
+				),
+			),
+		),
+	)
+}
+
+// allocationWeights lets the asset allocation share the sector weight bars.
+func allocationWeights(a APIAssetAllocation) []APISectorWeight {
+	var out []APISectorWeight
+	for _, w := range []APISectorWeight{{"Stocks", a.Stocks}, {"Bonds", a.Bonds}, {"Cash", a.Cash}, {"Other", a.Other}} {
+		if w.Weight > 0 {
+			out = append(out, w)
+		}
+	}
+	return out
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// A trimmed down quoteSummary for SPY.
+const testFundSummary = `{"quoteSummary":{"result":[{
+	"quoteType":{"exchange":"PCX","quoteType":"ETF","symbol":"SPY","shortName":"SPDR S&P 500","longName":"SPDR S&P 500 ETF Trust"},
+	"summaryDetail":{"navPrice":{"raw":512.3},"yield":{"raw":0.0123},"totalAssets":{"raw":5.1e11},"currency":"USD"},
+	"defaultKeyStatistics":{"fundFamily":"SPDR State Street Global Advisors","legalType":"Exchange Traded Fund","category":"Large Blend",
+		"annualReportExpenseRatio":{},"beta3Year":{"raw":1.0},"threeYearAverageReturn":{"raw":0.081},"fiveYearAverageReturn":{"raw":0.135},
+		"annualHoldingsTurnover":{"raw":0.02}},
+	"fundProfile":{"family":"SPDR State Street Global Advisors","categoryName":"Large Blend","legalType":"Exchange Traded Fund",
+		"feesExpensesInvestment":{"netExpRatio":{"raw":0.0945,"fmt":"0.09%"},"annualHoldingsTurnover":{"raw":2.0}}},
+	"topHoldings":{"stockPosition":{"raw":0.9995},"cashPosition":{"raw":0.0005},
+		"holdings":[{"symbol":"MSFT","holdingName":"Microsoft Corp","holdingPercent":{"raw":0.071}},
+			{"symbol":"AAPL","holdingName":"Apple Inc","holdingPercent":{"raw":0.072}},
+			{"symbol":"NVDA","holdingName":"NVIDIA Corp","holdingPercent":{"raw":0.06}}],
+		"sectorWeightings":[{"realestate":{"raw":0.022}},{"technology":{"raw":0.31}},{"energy":{"raw":0}},{"financial_services":{"raw":0.13}}]},
+	"fundPerformance":{"trailingReturns":{"ytd":{"raw":0.105,"fmt":"10.50%"},"oneYear":{"raw":0.27,"fmt":"27.00%"},
+		"threeYear":{"raw":0.081,"fmt":"8.10%"},"tenYear":{}}}
+}],"error":null}}`
+
+func testFundResult(t *testing.T) *Result {
+	result, err := parseQuoteSummary("SPY", []byte(testFundSummary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestFundDetection(t *testing.T) {
+	fund := testFundResult(t)
+	if !fund.isFund() || fund.quoteType() != "ETF" {
+		t.Errorf("SPY: isFund %v, quoteType %q", fund.isFund(), fund.quoteType())
+	}
+	if stock := testResult(); stock.isFund() || stock.quoteType() != "EQUITY" {
+		t.Errorf("stock: isFund %v, quoteType %q", stock.isFund(), stock.quoteType())
+	}
+
+	// Cached before quoteType was requested.
+	fund.QuoteType = QuoteType{}
+	if !fund.isFund() || fund.quoteType() != "ETF" {
+		t.Errorf("old cache: isFund %v, quoteType %q", fund.isFund(), fund.quoteType())
+	}
+}
+
+func TestFundMetrics(t *testing.T) {
+	metrics := map[string]Metric{}
+	for _, m := range buildMetricsList(testFundResult(t)) {
+		metrics[m.Name] = m
+	}
+	for _, stockOnly := range []string{"ROE", "Quick Ratio", "P/E Ratio"} {
+		if _, ok := metrics[stockOnly]; ok {
+			t.Errorf("fund has stock metric %q", stockOnly)
+		}
+	}
+
+	// Only fundProfile has the expense ratio here, in percent.
+	if m := metrics["Expense Ratio"]; m.Value != "0.09%" || m.Color != "green" {
+		t.Errorf("Expense Ratio = %+v", m)
+	}
+	if m := metrics["Holdings Turnover"]; m.Raw != 0.02 || m.Color != "green" {
+		t.Errorf("Holdings Turnover = %+v", m)
+	}
+	if m := metrics["Top 10 Weight"]; m.Color != "green" {
+		t.Errorf("Top 10 Weight = %+v", m)
+	}
+	if m := metrics["Price"]; m.Raw != 512.3 {
+		t.Errorf("Price = %+v, want the NAV", m)
+	}
+	if _, ok := metrics["Beta (3Y)"]; !ok {
+		t.Error("Beta (3Y) missing")
+	}
+}
+
+func TestAPIFund(t *testing.T) {
+	f := toAPIFund(testFundResult(t))
+	if f.Family != "SPDR State Street Global Advisors" || f.Category != "Large Blend" || f.TotalAssets != 5.1e11 {
+		t.Errorf("fund = %+v", f)
+	}
+	if len(f.Holdings) != 3 || f.Holdings[0].Symbol != "AAPL" {
+		t.Errorf("holdings = %+v, want largest first", f.Holdings)
+	}
+	if len(f.SectorWeights) != 3 || f.SectorWeights[0] != (APISectorWeight{"Technology", 0.31}) || f.SectorWeights[2].Sector != "Real Estate" {
+		t.Errorf("sectors = %+v", f.SectorWeights)
+	}
+	var periods []string
+	for _, r := range f.TrailingReturns {
+		periods = append(periods, r.Period)
+	}
+	if got := strings.Join(periods, ","); got != "YTD,1Y,3Y" {
+		t.Errorf("trailing return periods = %s", got)
+	}
+	if toAPIFund(testResult()) != nil {
+		t.Error("stock has fund details")
+	}
+}
+
+func TestFundPage(t *testing.T) {
+	withFetcher(t, func(string) (*Result, error) { return parseQuoteSummary("SPY", []byte(testFundSummary)) })
+
+	rec := httptest.NewRecorder()
+	stockHandler(rec, httptest.NewRequest("GET", "/stock?symbol=SPY", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"SPDR S&amp;P 500 ETF Trust", "Expense Ratio", "Top Holdings", "Sector Weights", "Trailing Returns", "Technology", `href="/stock?symbol=AAPL"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("fund page lacks %q", want)
+		}
+	}
+	if strings.Contains(body, "Quick Ratio") {
+		t.Error("fund page shows stock metrics")
+	}
+
+	rec = httptest.NewRecorder()
+	apiV1StockHandler(rec, httptest.NewRequest("GET", "/api/v1/stock?symbol=SPY", nil))
+	var got APIStock
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("stock = %d %s", rec.Code, rec.Body.String())
+	}
+	if got.QuoteType != "ETF" || got.Fund == nil || got.Quote.Price != 512.3 {
+		t.Errorf("API stock = %+v", got)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Yahoo reports prices in the currency a listing trades in but financial
+// statements in the currency the company reports in. The two differ for ADRs
+// and many foreign listings: TSM trades in USD and reports in TWD. Values are
+// converted with Yahoo's own FX quotes, which are cached like any other quote.
+
+// fetchFXQuote is a test seam. FX quotes skip the daily closes.
+var fetchFXQuote = getQuoteSummary
+
+// Offered on the stock page. The API takes any ISO code Yahoo has a rate for.
+var displayCurrencies = []string{"USD", "EUR", "GBP", "JPY", "CHF", "CAD", "AUD", "CNY", "HKD", "INR"}
+
+const (
+	displayCurrencyParam  = "currency"
+	displayCurrencyCookie = "stock_currency"
+)
+
+// currencies says which currency a result's values are in and how to show
+// them in the display currency. A zero rate means it couldn't be fetched.
+type currencies struct {
+	// Normalized ISO codes. Empty Display means no conversion was asked for.
+	Trading   string
+	Financial string
+	Display   string
+	// Converts Yahoo's minor units, e.g. 0.01 for prices in GBp.
+	tradingScale  float64
+	tradingRate   float64
+	financialRate float64
+}
+
+func getFXRate(ctx context.Context, from, to string) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	pair, err := common.ParseSymbol(from + to + "=X")
+	if err != nil {
+		return 0, err
+	}
+	result, err := fetchFXQuote(ctx, pair)
+	if err != nil {
+		return 0, err
+	}
+	rate := result.price()
+	if rate <= 0 {
+		return 0, fmt.Errorf("no rate for %s", pair)
+	}
+	return rate, nil
+}
+
+// tradingCurrency is the ISO code of the currency result trades in and the
+// factor that converts its prices into it.
+func (r *Result) tradingCurrency() (string, float64) {
+	raw := r.Price.Currency
+	if raw == "" {
+		raw = r.SummaryDetail.Currency
+	}
+	return common.NormalizeCurrency(raw)
+}
+
+// withCurrencies returns a copy of result set up to show its values in
+// display, or in the currency it trades in if display is empty.
+func withCurrencies(ctx context.Context, result *Result, display string) *Result {
+	trading, scale := result.tradingCurrency()
+	if trading == "" {
+		return result
+	}
+	financial, _ := common.NormalizeCurrency(result.FinancialData.FinancialCurrency)
+	if financial == "" {
+		financial = trading
+	}
+	if display == "" {
+		display = trading
+	}
+
+	c := currencies{Trading: trading, Financial: financial, Display: display, tradingScale: scale}
+	var err error
+	if c.tradingRate, err = getFXRate(ctx, trading, display); err != nil {
+		slog.WarnContext(ctx, "Could not fetch FX rate", "from", trading, "to", display, "err", err)
+	}
+	if c.financialRate, err = getFXRate(ctx, financial, display); err != nil {
+		slog.WarnContext(ctx, "Could not fetch FX rate", "from", financial, "to", display, "err", err)
+	}
+	converted := *result
+	converted.Currencies = c
+	return &converted
+}
+
+// mixed reports whether the price and the financial statements are in
+// different currencies, so ratios between them need converting.
+func (c currencies) mixed() bool {
+	return c.Trading != c.Financial
+}
+
+// tradingToFinancial converts an amount in the trading currency into the
+// financial one, or is 0 if either rate is missing.
+func (c currencies) tradingToFinancial() float64 {
+	if c.tradingRate == 0 || c.financialRate == 0 {
+		return 0
+	}
+	return c.tradingScale * c.tradingRate / c.financialRate
+}
+
+// Currency metrics taken from the financial statements. The rest are in the
+// trading currency.
+var statementMetrics = map[string]bool{
+	"Free Cash Flow": true,
+	"Book Value":     true,
+}
+
+// Ratios recomputed in the financial currency when it differs from the
+// trading one, with the trading-currency amount that gets converted.
+var recomputedRatios = map[string]string{
+	"P/S Ratio": "market cap",
+	"P/B Ratio": "price",
+}
+
+// localize shows currency metrics in the display currency, noting on the
+// card when a value was converted or couldn't be.
+func (c currencies) localize(metrics []Metric) []Metric {
+	if c.Display == "" {
+		return metrics
+	}
+	for i := range metrics {
+		m := &metrics[i]
+		if from, ok := recomputedRatios[m.Name]; ok && c.mixed() && c.tradingToFinancial() != 0 {
+			m.Note = fmt.Sprintf("Computed in %s, converting the %s %s at 1 %s = %.4f %s.", c.Financial, c.Trading, from, c.Trading, c.tradingToFinancial(), c.Financial)
+		}
+		if m.Unit != unitCurrency {
+			continue
+		}
+		from, rate, scale := c.Trading, c.tradingRate, c.tradingScale
+		if statementMetrics[m.Name] {
+			from, rate, scale = c.Financial, c.financialRate, 1
+		}
+		if rate == 0 {
+			m.Raw *= scale
+			m.Currency = from
+			m.Note = fmt.Sprintf("No %s/%s rate available, so shown in %s.", from, c.Display, from)
+		} else {
+			m.Raw *= scale * rate
+			m.Currency = c.Display
+			if from != c.Display {
+				m.Note = fmt.Sprintf("Converted from %s at 1 %s = %.4f %s.", from, from, rate, c.Display)
+			}
+		}
+		m.Value = common.FormatCurrency(m.Raw, m.Currency)
+	}
+	return metrics
+}
+
+// priceToSales is market cap over revenue. Yahoo's own ratio divides a
+// market cap in the trading currency by revenue in the financial one, so it
+// is recomputed when they differ.
+func (r *Result) priceToSales() float64 {
+	rate := r.Currencies.tradingToFinancial()
+	if !r.Currencies.mixed() || rate == 0 || r.FinancialData.TotalRevenue.Raw == 0 {
+		return r.SummaryDetail.PriceToSalesTrailing12Months.Raw
+	}
+	return r.SummaryDetail.MarketCap.Raw * rate / r.FinancialData.TotalRevenue.Raw
+}
+
+// priceToBook is price over book value per share, recomputed for the same
+// reason as priceToSales.
+func (r *Result) priceToBook() float64 {
+	rate := r.Currencies.tradingToFinancial()
+	if !r.Currencies.mixed() || rate == 0 || r.DefaultKeyStatistics.BookValue.Raw == 0 {
+		return r.DefaultKeyStatistics.PriceToBook.Raw
+	}
+	return r.FinancialData.CurrentPrice.Raw * rate / r.DefaultKeyStatistics.BookValue.Raw
+}
+
+// displayCurrency is the currency r wants values shown in: the currency
+// parameter, else the one remembered in a cookie, else "" for each
+// instrument's own. An empty parameter asks for the instrument's own.
+func displayCurrency(r *http.Request) (string, error) {
+	q := r.URL.Query()
+	if !q.Has(displayCurrencyParam) {
+		if c, err := r.Cookie(displayCurrencyCookie); err == nil {
+			if code, err := common.ParseCurrency(c.Value); err == nil {
+				return code, nil
+			}
+		}
+		return "", nil
+	}
+	if q.Get(displayCurrencyParam) == "" {
+		return "", nil
+	}
+	return common.ParseCurrency(q.Get(displayCurrencyParam))
+}
+
+// rememberDisplayCurrency keeps a currency picked on the stock page for the
+// next page, or forgets it when the instrument's own was picked.
+func rememberDisplayCurrency(w http.ResponseWriter, r *http.Request, code string) {
+	if !r.URL.Query().Has(displayCurrencyParam) {
+		return
+	}
+	c := &http.Cookie{
+		Name:     displayCurrencyCookie,
+		Value:    code,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   r.TLS != nil,
+		MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+	}
+	if code == "" {
+		c.MaxAge = -1
+	}
+	http.SetCookie(w, c)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// withFXRates serves FX quotes from rates, keyed like "TWDUSD". Any other
+// pair is not found.
+func withFXRates(t *testing.T, rates map[string]float64) {
+	orig := fetchFXQuote
+	fetchFXQuote = func(_ context.Context, pair common.Symbol) (*Result, error) {
+		rate, ok := rates[strings.TrimSuffix(pair.String(), "=X")]
+		if !ok {
+			return nil, ErrSymbolNotFound
+		}
+		result := &Result{}
+		result.Price.RegularMarketPrice.Raw = rate
+		return result, nil
+	}
+	t.Cleanup(func() { fetchFXQuote = orig })
+}
+
+var testFXRates = map[string]float64{"TWDUSD": 0.03125, "USDEUR": 0.9, "TWDEUR": 0.028125}
+
+// An ADR: trades in USD, reports in TWD.
+func testADRResult() *Result {
+	result := &Result{}
+	result.Price.Currency = "USD"
+	result.SummaryDetail.Currency = "USD"
+	result.SummaryDetail.MarketCap.Raw = 1e12
+	result.SummaryDetail.PriceToSalesTrailing12Months.Raw = 0.4
+	result.FinancialData.FinancialCurrency = "TWD"
+	result.FinancialData.CurrentPrice.Raw = 190
+	result.FinancialData.TotalRevenue.Raw = 3e12
+	result.FinancialData.FreeCashflow.Raw = 8e11
+	result.DefaultKeyStatistics.BookValue.Raw = 400
+	result.DefaultKeyStatistics.PriceToBook.Raw = 0.475
+	result.SummaryDetail.TrailingPE.Raw = 25
+	result.FinancialData.EarningsGrowth.Raw = 0.2
+	return result
+}
+
+func metricNamed(t *testing.T, metrics []Metric, name string) Metric {
+	t.Helper()
+	for _, m := range metrics {
+		if m.Name == name {
+			return m
+		}
+	}
+	t.Fatalf("no %s metric", name)
+	return Metric{}
+}
+
+func TestCurrencyConversion(t *testing.T) {
+	withFXRates(t, testFXRates)
+
+	// Native: statements are converted to the trading currency.
+	metrics := buildMetricsList(withCurrencies(context.Background(), testADRResult(), ""))
+	if m := metricNamed(t, metrics, "Free Cash Flow"); m.Value != "$25.00B" || m.Currency != "USD" || m.Note != "Converted from TWD at 1 TWD = 0.0312 USD." {
+		t.Errorf("Free Cash Flow = %+v", m)
+	}
+	if m := metricNamed(t, metrics, "Market Cap"); m.Value != "$1.00T" || m.Note != "" {
+		t.Errorf("Market Cap = %+v", m)
+	}
+	// 1T USD is 32T TWD, over 3T TWD of revenue, not Yahoo's 0.4.
+	if m := metricNamed(t, metrics, "P/S Ratio"); m.Raw < 10.66 || m.Raw > 10.67 || !strings.Contains(m.Note, "Computed in TWD") {
+		t.Errorf("P/S Ratio = %+v", m)
+	}
+	// $190 is 6080 TWD a share, over 400 TWD of book value.
+	if m := metricNamed(t, metrics, "P/B Ratio"); m.Raw != 15.2 || m.Note != "Computed in TWD, converting the USD price at 1 USD = 32.0000 TWD." {
+		t.Errorf("P/B Ratio = %+v", m)
+	}
+
+	metrics = buildMetricsList(withCurrencies(context.Background(), testADRResult(), "EUR"))
+	if m := metricNamed(t, metrics, "Price"); m.Value != "€171.00" || m.Currency != "EUR" {
+		t.Errorf("Price = %+v", m)
+	}
+	if m := metricNamed(t, metrics, "Free Cash Flow"); m.Value != "€22.50B" {
+		t.Errorf("Free Cash Flow = %+v", m)
+	}
+
+	// No rate: shown unconverted and said so.
+	metrics = buildMetricsList(withCurrencies(context.Background(), testADRResult(), "CHF"))
+	if m := metricNamed(t, metrics, "Price"); m.Value != "$190.00" || !strings.Contains(m.Note, "No USD/CHF rate") {
+		t.Errorf("Price = %+v", m)
+	}
+	if m := metricNamed(t, metrics, "P/S Ratio"); m.Raw != 0.4 || m.Note != "" {
+		t.Errorf("P/S Ratio without rates = %+v", m)
+	}
+	if m := metricNamed(t, metrics, "P/B Ratio"); m.Raw != 0.475 || m.Note != "" {
+		t.Errorf("P/B Ratio without rates = %+v", m)
+	}
+}
+
+func TestCurrencyMinorUnits(t *testing.T) {
+	withFXRates(t, map[string]float64{})
+	result := &Result{}
+	result.Price.Currency = "GBp"
+	result.FinancialData.FinancialCurrency = "GBP"
+	result.FinancialData.CurrentPrice.Raw = 250
+
+	result = withCurrencies(context.Background(), result, "")
+	if result.Currencies.mixed() {
+		t.Errorf("GBp and GBP are mixed: %+v", result.Currencies)
+	}
+	if m := metricNamed(t, buildMetricsList(result), "Price"); m.Value != "£2.50" || m.Note != "" {
+		t.Errorf("Price = %+v", m)
+	}
+}
+
+func TestDisplayCurrencyParam(t *testing.T) {
+	withFetcher(t, func(string) (*Result, error) { return testADRResult(), nil })
+	withFXRates(t, testFXRates)
+
+	rec := httptest.NewRecorder()
+	stockHandler(rec, httptest.NewRequest("GET", "/stock?symbol=TSM&currency=eur", nil))
+	if !strings.Contains(rec.Body.String(), "€171.00") {
+		t.Error("page not in EUR")
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != displayCurrencyCookie || cookies[0].Value != "EUR" {
+		t.Fatalf("cookies = %v", cookies)
+	}
+
+	// Remembered for the next page.
+	req := httptest.NewRequest("GET", "/stock?symbol=TSM", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	stockHandler(rec, req)
+	if !strings.Contains(rec.Body.String(), "€171.00") || len(rec.Result().Cookies()) != 0 {
+		t.Error("remembered currency not used")
+	}
+
+	// Picking native forgets it.
+	req = httptest.NewRequest("GET", "/stock?symbol=TSM&currency=", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	stockHandler(rec, req)
+	if !strings.Contains(rec.Body.String(), "$190.00") {
+		t.Error("page not in USD")
+	}
+	if c := rec.Result().Cookies(); len(c) != 1 || c[0].MaxAge >= 0 {
+		t.Errorf("cookie not cleared: %v", c)
+	}
+}
+
+func TestAPICurrency(t *testing.T) {
+	withFetcher(t, func(string) (*Result, error) { return testADRResult(), nil })
+	withFXRates(t, testFXRates)
+
+	rec := httptest.NewRecorder()
+	apiV1StockHandler(rec, httptest.NewRequest("GET", "/api/v1/stock?symbol=TSM&currency=EUR", nil))
+	var got APIStock
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("stock = %d %s", rec.Code, rec.Body.String())
+	}
+	if got.Fundamentals.PriceToSales < 10.66 || got.Fundamentals.PriceToSales > 10.67 {
+		t.Errorf("price_to_sales = %v", got.Fundamentals.PriceToSales)
+	}
+	for _, m := range got.Metrics {
+		if m.Name == "Price" && (m.Currency != "EUR" || m.Value != 171 || m.Note == "") {
+			t.Errorf("price metric = %+v", m)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	apiV1StockHandler(rec, httptest.NewRequest("GET", "/api/v1/stock?symbol=TSM&currency=euro", nil))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), errCodeInvalidCurrency) {
+		t.Errorf("invalid currency = %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -2,176 +2,373 @@ package main
 
 import "fmt"
 
-func getColorAndReasonForMetric(name string, value float64) (string, string) {
-	switch name {
-	case "Short Ratio":
-		if value < 2 {
-			return "green", "Low short interest ratio suggests the stock may be undervalued."
-		} else if value < 5 {
-			return "yellow", "Moderate short interest ratio — the stock is fairly valued, but depends on industry norms."
-		}
-		return "red", "High short interest ratio may indicate overvaluation, meaning you're paying a premium for short interest."
-
-	case "Short Percent of Float":
-		if value < .1 {
-			return "green", "Low short interest ratio suggests the stock may be undervalued."
-		} else if value < .5 {
-			return "yellow", "Moderate short interest ratio — the stock is fairly valued, but depends on industry norms."
-		}
-		return "red", "High short interest ratio may indicate overvaluation, meaning you're paying a premium for short interest."
-	case "P/E Ratio":
-		if value < 15 {
-			return "green", "Low P/E suggests the stock may be undervalued relative to earnings."
-		} else if value < 25 {
-			return "yellow", "Moderate P/E — the stock is fairly valued, but depends on industry norms."
-		}
-		return "red", "High P/E may indicate overvaluation, meaning you're paying a premium for earnings."
-	case "PEG Ratio":
-		desc := "<br><br>PEG= (P/E Ratio)/(Earnings Growth Rate) This tells you how much you're paying for each percentage point of expected growth."
-		if value < 15 {
-			return "green", "Low P/E ratio factoring in future earnings growth suggests the stock may be undervalued relative to earnings and growth." + desc
-		} else if value < 25 {
-			return "yellow", "Moderate P/E ratio factoring in future earnings growth — the stock is fairly valued, but depends on industry norms." + desc
-		}
-		return "red", "High P/E ratio factoring in future earnings growth may indicate overvaluation, meaning you're paying a premium for earnings and growth." + desc
-
-	case "Forward P/E":
-		if value < 15 {
-			return "green", "Low forward P/E suggests earnings are expected to grow, making it potentially undervalued."
-		} else if value < 25 {
-			return "yellow", "Moderate forward P/E — growth expectations are priced in."
-		}
-		return "red", "High forward P/E could mean over-optimistic growth assumptions or expensive valuation."
-
-	case "P/B Ratio":
-		if value < 1.5 {
-			return "green", "Low P/B may indicate the stock is trading below its book value — potentially a bargain."
-		} else if value < 3 {
-			return "yellow", "Moderate P/B — fairly valued compared to assets."
-		}
-		return "red", "High P/B may suggest overvaluation or overconfidence in asset efficiency."
-
-	case "P/S Ratio":
-		if value < 2 {
-			return "green", "Low P/S suggests the stock is reasonably priced relative to revenue."
-		} else if value < 5 {
-			return "yellow", "Moderate P/S — revenue valuation is acceptable but monitor margins."
-		}
-		return "red", "High P/S can indicate overvaluation, especially if profits are weak."
+// MetricThreshold is the boundary a metric's value is compared against when
+// scoring it. Thresholds are in the same units as the raw value, so percentages
+// are fractions (0.2 means 20%).
+type MetricThreshold struct {
+	// When true, values above Green score green. Otherwise values below Green do.
+	HigherIsBetter bool    `json:"higher_is_better"`
+	Green          float64 `json:"green"`
+	// Nil when the metric goes straight from green to red.
+	Yellow *float64 `json:"yellow,omitempty"`
+}
 
-	case "Debt/Equity":
-		if value < 0.5 {
-			return "green", "Low debt levels suggest financial stability and lower risk."
-		} else if value < 1.5 {
-			return "yellow", "Moderate debt — manageable, but watch interest costs."
-		}
-		return "red", "High debt increases financial risk, especially if cash flows are weak."
+type metricRule struct {
+	threshold MetricThreshold
+	green     string
+	yellow    string
+	red       string
+}
 
-	case "Current Ratio":
-		if value > 2 {
-			return "green", "Strong liquidity — the company can easily meet short-term liabilities."
-		} else if value > 1 {
-			return "yellow", "Adequate liquidity, but less buffer in case of financial stress."
-		}
-		return "red", "Poor liquidity — the company may struggle to cover short-term obligations."
+func lowerIsBetter(green, yellow float64) MetricThreshold {
+	return MetricThreshold{Green: green, Yellow: &yellow}
+}
 
-	case "Quick Ratio":
-		if value > 1.5 {
-			return "green", "Excellent liquidity — even excluding inventory, the company is financially healthy."
-		} else if value > 0.8 {
-			return "yellow", "Acceptable liquidity — but inventory reliance is higher."
-		}
-		return "red", "Poor quick ratio — short-term liabilities may not be well-covered."
+func higherIsBetter(green, yellow float64) MetricThreshold {
+	return MetricThreshold{HigherIsBetter: true, Green: green, Yellow: &yellow}
+}
 
-	case "ROE":
-		if value > 20 {
-			return "green", "Excellent ROE — the company is using equity efficiently to generate profits."
-		} else if value > 10 {
-			return "yellow", "Moderate ROE — reasonable returns on equity."
-		}
-		return "red", "Low ROE — inefficient capital use or declining profitability."
+const pegDesc = "<br><br>PEG= (P/E Ratio)/(Earnings Growth Rate) This tells you how much you're paying for each percentage point of expected growth."
 
-	case "ROA":
-		if value > 10 {
-			return "green", "High ROA — strong use of assets to generate earnings."
-		} else if value > 5 {
-			return "yellow", "Moderate ROA — reasonable asset efficiency."
-		}
-		return "red", "Low ROA — could signal inefficient asset management or low profitability."
+const roicDesc = "<br><br>ROIC is a measure of profitability relative to total assets. It is crucial to compare ROIC within the same sector, as industries like technology may achieve higher ROIC due to lower capital requirements, while capital-intensive industries like utilities typically have lower ratios."
 
-	case "Gross Margin":
-		if value > 40 {
-			return "green", "Strong gross margin — the company has pricing power or cost efficiency."
-		} else if value > 20 {
-			return "yellow", "Moderate margins — acceptable for many industries."
-		}
-		return "red", "Low margins — may struggle with profitability or face pricing pressure."
+var metricRules = map[string]metricRule{
+	"Short Ratio": {
+		lowerIsBetter(2, 5),
+		"Low short interest ratio suggests the stock may be undervalued.",
+		"Moderate short interest ratio — the stock is fairly valued, but depends on industry norms.",
+		"High short interest ratio may indicate overvaluation, meaning you're paying a premium for short interest.",
+	},
+	"Short Percent of Float": {
+		lowerIsBetter(.1, .5),
+		"Low short interest ratio suggests the stock may be undervalued.",
+		"Moderate short interest ratio — the stock is fairly valued, but depends on industry norms.",
+		"High short interest ratio may indicate overvaluation, meaning you're paying a premium for short interest.",
+	},
+	"Short Interest Change": {
+		lowerIsBetter(0, .10),
+		"Short interest fell on the prior month — fewer investors are betting against the stock.",
+		"Short interest rose a little on the prior month.",
+		"Short interest jumped on the prior month — more investors are betting on a decline.",
+	},
+	"Insider Ownership": {
+		higherIsBetter(.05, .01),
+		"Insiders own a meaningful stake — management's interests are aligned with shareholders'.",
+		"Insiders own a modest stake.",
+		"Insiders own little of the company — management has little of its own money at stake.",
+	},
+	"Institutional Ownership": {
+		higherIsBetter(.50, .20),
+		"Widely held by institutions — professional investors back it and it trades easily.",
+		"Moderate institutional ownership.",
+		"Few institutions hold it — it may be too small, illiquid or out of favor for professional investors.",
+	},
+	"Insider Net Buying (12M)": {
+		higherIsBetter(0, -.001),
+		"Insiders bought more than they sold over the past year — a vote of confidence from those who know the company best.",
+		"Insiders sold a little on balance, as is routine when pay comes in stock.",
+		"Insiders sold heavily over the past year.",
+	},
+	"P/E Ratio": {
+		lowerIsBetter(15, 25),
+		"Low P/E suggests the stock may be undervalued relative to earnings.",
+		"Moderate P/E — the stock is fairly valued, but depends on industry norms.",
+		"High P/E may indicate overvaluation, meaning you're paying a premium for earnings.",
+	},
+	"PEG Ratio": {
+		lowerIsBetter(15, 25),
+		"Low P/E ratio factoring in future earnings growth suggests the stock may be undervalued relative to earnings and growth." + pegDesc,
+		"Moderate P/E ratio factoring in future earnings growth — the stock is fairly valued, but depends on industry norms." + pegDesc,
+		"High P/E ratio factoring in future earnings growth may indicate overvaluation, meaning you're paying a premium for earnings and growth." + pegDesc,
+	},
+	"Forward P/E": {
+		lowerIsBetter(15, 25),
+		"Low forward P/E suggests earnings are expected to grow, making it potentially undervalued.",
+		"Moderate forward P/E — growth expectations are priced in.",
+		"High forward P/E could mean over-optimistic growth assumptions or expensive valuation.",
+	},
+	"P/B Ratio": {
+		lowerIsBetter(1.5, 3),
+		"Low P/B may indicate the stock is trading below its book value — potentially a bargain.",
+		"Moderate P/B — fairly valued compared to assets.",
+		"High P/B may suggest overvaluation or overconfidence in asset efficiency.",
+	},
+	"P/S Ratio": {
+		lowerIsBetter(2, 5),
+		"Low P/S suggests the stock is reasonably priced relative to revenue.",
+		"Moderate P/S — revenue valuation is acceptable but monitor margins.",
+		"High P/S can indicate overvaluation, especially if profits are weak.",
+	},
+	"Debt/Equity": {
+		lowerIsBetter(0.5, 1.5),
+		"Low debt levels suggest financial stability and lower risk.",
+		"Moderate debt — manageable, but watch interest costs.",
+		"High debt increases financial risk, especially if cash flows are weak.",
+	},
+	"Current Ratio": {
+		higherIsBetter(2, 1),
+		"Strong liquidity — the company can easily meet short-term liabilities.",
+		"Adequate liquidity, but less buffer in case of financial stress.",
+		"Poor liquidity — the company may struggle to cover short-term obligations.",
+	},
+	"Quick Ratio": {
+		higherIsBetter(1.5, 0.8),
+		"Excellent liquidity — even excluding inventory, the company is financially healthy.",
+		"Acceptable liquidity — but inventory reliance is higher.",
+		"Poor quick ratio — short-term liabilities may not be well-covered.",
+	},
+	"ROE": {
+		higherIsBetter(.20, .10),
+		"Excellent ROE — the company is using equity efficiently to generate profits.",
+		"Moderate ROE — reasonable returns on equity.",
+		"Low ROE — inefficient capital use or declining profitability.",
+	},
+	"ROA": {
+		higherIsBetter(.10, .05),
+		"High ROA — strong use of assets to generate earnings.",
+		"Moderate ROA — reasonable asset efficiency.",
+		"Low ROA — could signal inefficient asset management or low profitability.",
+	},
+	"Gross Margin": {
+		higherIsBetter(.40, .20),
+		"Strong gross margin — the company has pricing power or cost efficiency.",
+		"Moderate margins — acceptable for many industries.",
+		"Low margins — may struggle with profitability or face pricing pressure.",
+	},
+	"Operating Margin": {
+		higherIsBetter(.20, .10),
+		"Excellent operating efficiency and cost control.",
+		"Decent operating margin — the business model is sustainable.",
+		"Low operating margin — profitability may be under pressure.",
+	},
+	"Net Margin": {
+		higherIsBetter(.15, .05),
+		"Strong net margin — good bottom-line profitability.",
+		"Moderate net margin — acceptable for many industries.",
+		"Weak net margin — high costs or low pricing power.",
+	},
+	"Revenue Growth": {
+		higherIsBetter(.15, .05),
+		"Strong revenue growth — indicates expansion and market demand.",
+		"Moderate growth — stable but not rapid.",
+		"Weak revenue growth — may indicate stagnation or competitive pressure.",
+	},
+	"Earnings Growth": {
+		higherIsBetter(.15, .05),
+		"Strong earnings growth — profit is accelerating.",
+		"Moderate earnings growth — consistent but not spectacular.",
+		"Weak or negative earnings growth — could be a red flag for investors.",
+	},
+	"Free Cash Flow": {
+		MetricThreshold{HigherIsBetter: true, Green: 0},
+		"Positive FCF — the company generates more cash than it spends, allowing flexibility.",
+		"",
+		"Negative FCF — the company is spending more than it brings in, may need financing.",
+	},
+	"Beta": {
+		lowerIsBetter(0.8, 1.2),
+		"Low beta — the stock is less volatile than the market, suitable for risk-averse investors.",
+		"Average beta — price movement is roughly in line with the market.",
+		"High beta — more volatile, riskier in down markets.",
+	},
+	"Dividend Yield": {
+		higherIsBetter(.03, .01),
+		"High dividend yield — good income potential for investors.",
+		"Moderate dividend — some income, but not a focus.",
+		"Low dividend — little income for income-focused investors.",
+	},
+	"Payout Ratio": {
+		lowerIsBetter(.60, .80),
+		"Pays out well under its earnings — room to keep raising the dividend.",
+		"Pays out most of its earnings — little room for increases if profits dip.",
+		"Pays out about as much as it earns or more — the dividend may be cut.",
+	},
+	"FCF Payout Ratio": {
+		lowerIsBetter(.60, .80),
+		"The dividend is well covered by free cash flow.",
+		"The dividend takes most of the free cash flow.",
+		"The dividend costs about as much as the free cash flow or more — it is being paid from debt or savings.",
+	},
+	"Dividend Growth (5Y)": {
+		higherIsBetter(.05, 0),
+		"The dividend has grown steadily over five years, ahead of inflation.",
+		"The dividend has barely grown over five years.",
+		"The dividend is lower than five years ago.",
+	},
+	"Dividend Safety": {
+		higherIsBetter(70, 40),
+		"Well covered, with a record of increases — the dividend looks safe.",
+		"Some warning signs — check the payout ratios and growth record.",
+		"Stretched payouts or a record of cuts — the dividend may be at risk.",
+	},
+	"ROIC": {
+		higherIsBetter(.10, .05),
+		"High ROIC — " + roicDesc,
+		"Moderate ROIC — " + roicDesc,
+		"Low ROIC — " + roicDesc,
+	},
 
-	case "Operating Margin":
-		if value > 20 {
-			return "green", "Excellent operating efficiency and cost control."
-		} else if value > 10 {
-			return "yellow", "Decent operating margin — the business model is sustainable."
-		}
-		return "red", "Low operating margin — profitability may be under pressure."
+	// ETFs and mutual funds.
+	"Expense Ratio": {
+		lowerIsBetter(.002, .0075),
+		"Low expense ratio — little of the return goes to fees.",
+		"Moderate expense ratio — typical of actively managed funds; check the returns justify it.",
+		"High expense ratio — fees compound and take a large bite out of long-term returns.",
+	},
+	"Yield": {
+		higherIsBetter(.03, .01),
+		"High yield — good income potential for investors.",
+		"Moderate yield — some income, but not a focus.",
+		"Low or no yield — not ideal for income-focused investors.",
+	},
+	"Total Assets": {
+		higherIsBetter(1e9, 1e8),
+		"Large fund — typically tight trading spreads and little risk of closure.",
+		"Mid-sized fund — adequate, but check trading volume.",
+		"Small fund — wider spreads and a higher risk of being closed or merged.",
+	},
+	"3Y Avg Return": {
+		higherIsBetter(.10, .05),
+		"Strong three-year returns.",
+		"Moderate three-year returns.",
+		"Weak or negative three-year returns.",
+	},
+	"5Y Avg Return": {
+		higherIsBetter(.10, .05),
+		"Strong five-year returns across a market cycle.",
+		"Moderate five-year returns.",
+		"Weak or negative five-year returns.",
+	},
+	"Beta (3Y)": {
+		lowerIsBetter(0.8, 1.2),
+		"Low beta — the fund is less volatile than the market.",
+		"Average beta — moves roughly in line with the market.",
+		"High beta — more volatile, riskier in down markets.",
+	},
+	"Holdings Turnover": {
+		lowerIsBetter(.25, .75),
+		"Low turnover — few trading costs and little taxable distribution.",
+		"Moderate turnover — some hidden trading costs.",
+		"High turnover — trading costs and capital gains distributions eat into returns.",
+	},
+	"Top 10 Weight": {
+		lowerIsBetter(.30, .60),
+		"Well diversified — no small group of holdings dominates.",
+		"Fairly concentrated — the top holdings drive much of the return.",
+		"Highly concentrated — a few holdings decide the fund's fate.",
+	},
 
-	case "Net Margin":
-		if value > 15 {
-			return "green", "Strong net margin — good bottom-line profitability."
-		} else if value > 5 {
-			return "yellow", "Moderate net margin — acceptable for many industries."
-		}
-		return "red", "Weak net margin — high costs or low pricing power."
-
-	case "Revenue Growth":
-		if value > 15 {
-			return "green", "Strong revenue growth — indicates expansion and market demand."
-		} else if value > 5 {
-			return "yellow", "Moderate growth — stable but not rapid."
-		}
-		return "red", "Weak revenue growth — may indicate stagnation or competitive pressure."
-
-	case "Earnings Growth":
-		if value > 15 {
-			return "green", "Strong earnings growth — profit is accelerating."
-		} else if value > 5 {
-			return "yellow", "Moderate earnings growth — consistent but not spectacular."
-		}
-		return "red", "Weak or negative earnings growth — could be a red flag for investors."
+	// Crypto and currency pairs
+	"Crypto Volatility": {
+		lowerIsBetter(.50, .80),
+		"Calm for crypto — prices have moved comparatively little lately.",
+		"Typical crypto swings — expect large daily moves.",
+		"Extremely volatile — the price can halve or double within weeks.",
+	},
+	"FX Volatility": {
+		lowerIsBetter(.07, .12),
+		"Stable pair — the rate has moved little lately.",
+		"Moderate volatility for a currency pair.",
+		"Volatile pair — unusually large swings for a currency.",
+	},
+	"Volume/Market Cap": {
+		higherIsBetter(.05, .01),
+		"Liquid — a good share of the market cap trades every day.",
+		"Moderately liquid — large orders may move the price.",
+		"Thinly traded for its size — it may be hard to sell at the quoted price.",
+	},
+	"Supply Issued": {
+		higherIsBetter(.90, .60),
+		"Most of the supply is already in circulation — little dilution ahead.",
+		"A fair share of the supply is still to be issued.",
+		"Much of the supply is yet to come — new coins may weigh on the price.",
+	},
+	"52W Range Position": {
+		lowerIsBetter(.30, .80),
+		"Near its 52 week low — cheap relative to the past year.",
+		"Mid range — neither stretched nor depressed.",
+		"Near its 52 week high — much of the past year's gain is already priced in.",
+	},
+	"EPS Beat Rate": {
+		higherIsBetter(.75, .50),
+		"Consistently beats estimates — management guides conservatively and delivers.",
+		"Beats about as often as it misses.",
+		"Usually misses estimates — expect surprises to the downside.",
+	},
+}
 
-	case "Free Cash Flow":
-		if value > 0 {
-			return "green", "Positive FCF — the company generates more cash than it spends, allowing flexibility."
+// Score compares value against the threshold and returns green, yellow or red.
+func (t MetricThreshold) Score(value float64) string {
+	beats := func(limit float64) bool {
+		if t.HigherIsBetter {
+			return value > limit
 		}
-		return "red", "Negative FCF — the company is spending more than it brings in, may need financing."
+		return value < limit
+	}
+	if beats(t.Green) {
+		return "green"
+	}
+	if t.Yellow != nil && beats(*t.Yellow) {
+		return "yellow"
+	}
+	return "red"
+}
 
-	case "Beta":
-		if value < 0.8 {
-			return "green", "Low beta — the stock is less volatile than the market, suitable for risk-averse investors."
-		} else if value < 1.2 {
-			return "yellow", "Average beta — price movement is roughly in line with the market."
-		}
-		return "red", "High beta — more volatile, riskier in down markets."
+// metricThreshold returns the threshold used to score name, or nil if the
+// metric is informational only.
+func metricThreshold(name string) *MetricThreshold {
+	rule, ok := metricRules[name]
+	if !ok {
+		return nil
+	}
+	return &rule.threshold
+}
 
-	case "Dividend Yield":
-		if value > 3 {
-			return "green", "High dividend yield — good income potential for investors."
-		} else if value > 1 {
-			return "yellow", "Moderate dividend — some income, but not a focus."
-		}
-		return "red", "Low or no dividend — not ideal for income-focused investors."
-	case "ROIC":
-		desc := "<br><br>ROIC is a measure of profitability relative to total assets. It is crucial to compare ROIC within the same sector, as industries like technology may achieve higher ROIC due to lower capital requirements, while capital-intensive industries like utilities typically have lower ratios."
-		if value > 10 {
-			return "green", fmt.Sprintf("High ROIC — %s", desc)
-		} else if value > 5 {
-			return "yellow", fmt.Sprintf("Moderate ROIC — %s", desc)
-		} else {
-			return "red", fmt.Sprintf("Low ROIC — %s", desc)
-		}
+// overrideMetricThreshold replaces the boundaries of a scored metric, keeping
+// its direction. A nil yellow goes straight from green to red.
+func overrideMetricThreshold(name string, green float64, yellow *float64) error {
+	if err := checkMetricThreshold(name, green, yellow); err != nil {
+		return err
+	}
+	rule := metricRules[name]
+	rule.threshold.Green = green
+	rule.threshold.Yellow = yellow
+	metricRules[name] = rule
+	return nil
+}
 
+// checkMetricThreshold reports whether an override for name makes sense: the
+// metric must be scored and yellow must sit on the worse side of green.
+func checkMetricThreshold(name string, green float64, yellow *float64) error {
+	rule, ok := metricRules[name]
+	if !ok {
+		return fmt.Errorf("unknown scored metric %q", name)
+	}
+	if yellow == nil {
+		return nil
+	}
+	if rule.threshold.HigherIsBetter && *yellow > green {
+		return fmt.Errorf("%s: yellow (%g) must not be above green (%g) when higher is better", name, *yellow, green)
 	}
+	if !rule.threshold.HigherIsBetter && *yellow < green {
+		return fmt.Errorf("%s: yellow (%g) must not be below green (%g) when lower is better", name, *yellow, green)
+	}
+	return nil
+}
 
-	// Default case
-	return "yellow", "No specific evaluation available for this metric."
+// Returns the card color and an HTML reason for the metric's value.
+func getColorAndReasonForMetric(name string, value float64) (string, string) {
+	rule, ok := metricRules[name]
+	if !ok {
+		// Default case
+		return "yellow", "No specific evaluation available for this metric."
+	}
+	switch color := rule.threshold.Score(value); color {
+	case "green":
+		return color, rule.green
+	case "yellow":
+		return color, rule.yellow
+	default:
+		return color, rule.red
+	}
 }
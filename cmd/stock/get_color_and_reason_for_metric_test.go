@@ -18,6 +18,19 @@ func TestGetColorAndReasonForMetric(t *testing.T) {
 		{"P/E Ratio", 20, "yellow"},
 		{"P/E Ratio", 30, "red"},
 
+		{"Current Ratio", 2.5, "green"},
+		{"Current Ratio", 1.5, "yellow"},
+		{"Current Ratio", 0.5, "red"},
+
+		// Percentages are scored as fractions, the same as Yahoo reports them.
+		{"ROE", 0.25, "green"},
+		{"ROE", 0.15, "yellow"},
+		{"ROE", 0.05, "red"},
+		{"Dividend Yield", 0.04, "green"},
+
+		{"Free Cash Flow", 1_000_000, "green"},
+		{"Free Cash Flow", -1_000_000, "red"},
+
 		{"Unknown Metric", 0, "yellow"},
 	}
 
@@ -33,3 +46,19 @@ func TestGetColorAndReasonForMetric(t *testing.T) {
 		}
 	}
 }
+
+func TestMetricThreshold(t *testing.T) {
+	if got := metricThreshold("Price"); got != nil {
+		t.Errorf("metricThreshold(Price) = %+v, want nil for unscored metric", got)
+	}
+
+	pe := metricThreshold("P/E Ratio")
+	if pe == nil || pe.HigherIsBetter || pe.Green != 15 || pe.Yellow == nil || *pe.Yellow != 25 {
+		t.Errorf("metricThreshold(P/E Ratio) = %+v", pe)
+	}
+
+	fcf := metricThreshold("Free Cash Flow")
+	if fcf == nil || !fcf.HigherIsBetter || fcf.Yellow != nil {
+		t.Errorf("metricThreshold(Free Cash Flow) = %+v", fcf)
+	}
+}
@@ -1,160 +1,224 @@
 package main
 
 import (
+	common "app/internal/common"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
-	"strings"
+	"sync"
 	"time"
+)
+
+// ErrSymbolNotFound is returned when Yahoo has no quote data for a ticker.
+var ErrSymbolNotFound = errors.New("symbol not found")
+
+// fetchStockMetrics is the fetcher used by the HTTP handlers. Tests replace it
+// to avoid launching Chrome.
+var fetchStockMetrics = getStockMetrics
+
+// Base URL for Yahoo's JSON endpoints. Tests point this at a local server.
+var yahooQueryBaseURL = "https://query1.finance.yahoo.com"
+
+// Tracks tickers currently being fetched so concurrent requests for the same
+// ticker share one upstream request.
+type inflightFetch struct {
+	done   chan struct{}
+	result *Result
+	err    error
+}
 
-	"github.com/chromedp/cdproto/network"
-	"github.com/chromedp/chromedp"
+var (
+	inflightMu      sync.Mutex
+	inflightFetches = map[common.Symbol]*inflightFetch{}
 )
 
-func getStockMetrics(ticker string) (*Result, error) {
-	// Ensure cache dir exists. This is relative to the CWD, or
-	// WorkingDirectory=/opt/stock when launched via systemd.
-	cacheDir := filepath.Join(g_dataDir, "./stockdata")
+// getStockMetrics returns quote data for ticker, with the daily closes crypto
+// and currency pairs are scored on and the nearest option chain.
+func getStockMetrics(ctx context.Context, ticker common.Symbol) (*Result, error) {
+	result, err := getQuoteSummary(ctx, ticker)
+	if err != nil {
+		return nil, err
+	}
+	result = withOptions(ctx, ticker, withDividends(ctx, ticker, withDailyCloses(ctx, ticker, result)))
+	recordSnapshot(ctx, ticker, result, time.Now())
+	return result, nil
+}
+
+// getQuoteSummary returns quote data for ticker from the cache or Yahoo. ctx
+// tags log lines with the request; the upstream fetch itself is shared by
+// every caller waiting on the ticker, so it runs under upstreamCtx instead.
+func getQuoteSummary(ctx context.Context, ticker common.Symbol) (*Result, error) {
+	// Ensure cache dir exists.
+	cacheDir := stockCacheDir()
 	if err := os.MkdirAll(cacheDir, os.ModePerm); err != nil {
 		return nil, fmt.Errorf("could not create cache dir: %v", err)
 	}
 
-	// Format current time for cache filename (rounded to hour)
-	now := time.Now().UTC().Truncate(time.Hour)
-	filename := fmt.Sprintf("%s-%s.json", ticker, now.Format("2006-01-02-15"))
-	cachePath := filepath.Join(cacheDir, filename)
+	// If a fresh enough file exists, read and return
+	if cachedData, path, ok := loadFreshCache(cacheDir, ticker); ok {
+		slog.DebugContext(ctx, "Loaded from cache", "ticker", ticker, "path", path)
+		cacheLookups.Inc("hit")
+		return parseQuoteSummary(ticker, cachedData)
+	}
+	if files, _ := quoteCacheFiles(cacheDir, ticker); len(files) > 0 {
+		// Cached before, but older than quoteCacheTTL.
+		cacheLookups.Inc("stale")
+	} else {
+		cacheLookups.Inc("miss")
+	}
 
-	var qs Response
+	cachePath := quoteCachePath(cacheDir, ticker, time.Now())
 
-	// If file exists, read and return
-	if _, err := os.Stat(cachePath); err == nil {
-		cachedData, err := ioutil.ReadFile(cachePath)
-		if err != nil {
-			return nil, fmt.Errorf("could not read cached file: %v", err)
-		}
-		err = json.Unmarshal(cachedData, &qs)
-		if err != nil {
-			return nil, fmt.Errorf("error unmarshalling cached JSON: %v", err)
-		}
-		fmt.Println("Loaded from cache:", cachePath)
-		return &qs.QuoteSummary.Result[0], nil
-	}
-
-	// Create temp directories for Chrome data.
-	// Required for the chrome/chromium headless request to work.
-	userDataDir := filepath.Join(g_dataDir, fmt.Sprintf("chrome-user-data"))
-	//userDataDir := filepath.Join(g_dataDir, fmt.Sprintf("chrome-user-data-%d", time.Now().UnixNano()))
-	if err := os.MkdirAll(userDataDir, 0755); err != nil {
-		log.Fatal(err)
-	}
-
-	log.Printf("Running headless Chrome for %s with user data dir: %s\n", ticker, userDataDir)
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.Flag("headless", true),
-		chromedp.Flag("disable-gpu", true),
-		chromedp.Flag("user-data-dir", userDataDir),
-		chromedp.UserDataDir(userDataDir),
-		//chromedp.Flag("no-sandbox", true),
-		//chromedp.Flag("disable-setuid-sandbox", true),
-	)
+	inflightMu.Lock()
+	if f, ok := inflightFetches[ticker]; ok {
+		inflightMu.Unlock()
+		<-f.done
+		return f.result, f.err
+	}
+	f := &inflightFetch{done: make(chan struct{})}
+	inflightFetches[ticker] = f
+	inflightMu.Unlock()
 
-	allocCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
-	defer cancel()
+	f.result, f.err = fetchAndCacheQuoteSummary(ctx, ticker, cachePath)
 
-	ctx, cancel := chromedp.NewContext(allocCtx)
-	defer cancel()
+	inflightMu.Lock()
+	delete(inflightFetches, ticker)
+	inflightMu.Unlock()
+	close(f.done)
 
-	if err := chromedp.Run(ctx, network.Enable()); err != nil {
-		log.Fatal(err)
+	return f.result, f.err
+}
+
+func fetchAndCacheQuoteSummary(ctx context.Context, ticker common.Symbol, cachePath string) (*Result, error) {
+	body, status, err := requestWithCrumb(ctx, "quoteSummary", func(session *yahooSession) ([]byte, int, error) {
+		return requestQuoteSummary(ctx, ticker, session)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// All this is chrome/chromium fiasco is to get a crumb token so we can
-	// read the ticker data.
-	var crumb string
-	if err := chromedp.Run(ctx,
-		chromedp.Navigate(fmt.Sprintf("https://finance.yahoo.com/quote/%s", ticker)),
-		chromedp.Sleep(3*time.Second),
-		chromedp.Evaluate(`window.YAHOO && window.YAHOO.context && window.YAHOO.context.user && window.YAHOO.context.user.crumb || ""`, &crumb),
-	); err != nil {
-		log.Fatal(err)
+	result, err := parseQuoteSummary(ticker, body)
+	if errors.Is(err, ErrSymbolNotFound) {
+		upstreamErrors.Inc("quoteSummary", upstreamErrNotFound)
+		return nil, err
+	}
+	if err != nil {
+		if status != http.StatusOK {
+			upstreamErrors.Inc("quoteSummary", upstreamErrStatus)
+			return nil, fmt.Errorf("quoteSummary returned status %d: %v", status, err)
+		}
+		upstreamErrors.Inc("quoteSummary", upstreamErrDecode)
+		return nil, err
 	}
 
-	// Without a crumb token, we can't read the ticker data
-	if crumb == "" {
-		// Hmm, maybe Yahoo changed the page format?
-		log.Fatal("crumb token not found")
+	// Only cache good responses, so a transient error isn't served for the hour.
+	if status == http.StatusOK {
+		// Write to JSON blob ticker data to our cache
+		if err := writeQuoteCache(cachePath, body); err != nil {
+			return nil, fmt.Errorf("could not write cache file: %v", err)
+		}
+		slog.InfoContext(ctx, "Fetched and cached", "ticker", ticker, "path", cachePath)
 	}
-	log.Printf("Crumb token: %s\n", crumb)
+	return result, nil
+}
 
-	// Unfortunately, the crumb is not enough to fetch the data,
-	// we need the cookies to pass as well.
-	//
-	// Fetch cookies properly inside chromedp.Run and ActionFunc
-	var cookies []*network.Cookie
-	if err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
-		var err error
-		cookies, err = network.GetCookies().WithURLs([]string{"https://finance.yahoo.com"}).Do(ctx)
-		return err
-	})); err != nil {
-		log.Fatal(err)
+// requestWithCrumb makes a request that needs the Yahoo session, retrying
+// once with a new session if Yahoo rejects the crumb. endpoint labels the
+// upstream metrics.
+func requestWithCrumb(ctx context.Context, endpoint string, request func(*yahooSession) ([]byte, int, error)) ([]byte, int, error) {
+	session, err := getYahooSession(ctx)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	var cookiePairs []string
-	for _, c := range cookies {
-		cookiePairs = append(cookiePairs, fmt.Sprintf("%s=%s", c.Name, c.Value))
+	body, status, err := request(session)
+	if err != nil {
+		return nil, 0, err
 	}
-	cookieHeader := strings.Join(cookiePairs, "; ")
-	log.Printf("Cookie header: %s\n", cookieHeader)
+	if status == http.StatusUnauthorized || status == http.StatusForbidden {
+		// The crumb expired early. Get a new one and retry once.
+		slog.WarnContext(ctx, "Yahoo rejected crumb, refreshing session", "status", status)
+		upstreamErrors.Inc(endpoint, upstreamErrRejected)
+		invalidateYahooSession(session)
+		if session, err = getYahooSession(ctx); err != nil {
+			return nil, 0, err
+		}
+		return request(session)
+	}
+	return body, status, nil
+}
 
-	// Lets make the request to get all our ticker data.
-	url := fmt.Sprintf(
-		"https://query1.finance.yahoo.com/v10/finance/quoteSummary/%s?modules=summaryDetail,financialData,defaultKeyStatistics,earnings&crumb=%s",
-		ticker,
-		crumb,
+// Lets make the request to get all our ticker data.
+func requestQuoteSummary(ctx context.Context, ticker common.Symbol, session *yahooSession) ([]byte, int, error) {
+	quoteURL := fmt.Sprintf(
+		"%s/v10/finance/quoteSummary/%s?modules=summaryDetail,financialData,defaultKeyStatistics,earnings,price,quoteType,topHoldings,fundPerformance,fundProfile,recommendationTrend,upgradeDowngradeHistory,institutionOwnership,fundOwnership,insiderTransactions,calendarEvents&crumb=%s",
+		yahooQueryBaseURL,
+		ticker.PathEscape(),
+		session.Crumb,
 	)
+	return requestYahoo(ctx, "quoteSummary", ticker, quoteURL, session)
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+// requestYahoo GETs a Yahoo API URL with the session's cookies. endpoint
+// labels the upstream metrics and logs.
+func requestYahoo(ctx context.Context, endpoint string, ticker common.Symbol, rawURL string, session *yahooSession) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(upstreamCtx, "GET", rawURL, nil)
 	if err != nil {
-		log.Fatal(err)
+		return nil, 0, err
 	}
-	req.Header.Set("Cookie", cookieHeader)
+	req.Header.Set("Cookie", session.CookieHeader)
 	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; chromedp)")
 
-	client := &http.Client{}
+	if err := waitForUpstream(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	start := time.Now()
+	defer func() {
+		upstreamDuration.Observe(time.Since(start).Seconds(), endpoint)
+	}()
+	// The URL carries the crumb, so log the ticker instead.
+	slog.DebugContext(ctx, "Requesting "+endpoint, "ticker", ticker)
+
+	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Fatal(err)
+		// Drop the URL from the error; its query has the crumb.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		upstreamErrors.Inc(endpoint, upstreamErrNetwork)
+		slog.ErrorContext(ctx, endpoint+" request failed", "ticker", ticker, "err", err)
+		return nil, 0, fmt.Errorf("%s request failed: %v", endpoint, err)
 	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatal(err)
+		upstreamErrors.Inc(endpoint, upstreamErrNetwork)
+		return nil, 0, fmt.Errorf("could not read %s response: %v", endpoint, err)
 	}
 
-	fmt.Println("Response status:", resp.Status)
-	// Uncomment this for debugging.
-	// fmt.Println("Response body:", string(body))
-
-	// Write to JSON blob ticker data to our cache
-	if err := ioutil.WriteFile(cachePath, body, 0644); err != nil {
-		return nil, fmt.Errorf("could not write cache file: %v", err)
-	}
-	fmt.Println("Fetched and cached:", cachePath)
+	slog.InfoContext(ctx, endpoint+" response", "ticker", ticker, "status", resp.StatusCode, "duration", time.Since(start))
+	slog.DebugContext(ctx, endpoint+" body", "ticker", ticker, "body", string(body))
+	return body, resp.StatusCode, nil
+}
 
-	// Unmarshal JSON into the struct
-	err = json.Unmarshal(body, &qs)
-	if err != nil {
-		log.Fatalf("Error unmarshalling JSON: %v", err)
+func parseQuoteSummary(ticker common.Symbol, body []byte) (*Result, error) {
+	var qs Response
+	if err := json.Unmarshal(body, &qs); err != nil {
+		return nil, fmt.Errorf("error unmarshalling JSON: %v", err)
 	}
 
 	if len(qs.QuoteSummary.Result) == 0 {
-		return nil, fmt.Errorf("no data returned for ticker %s", ticker)
+		return nil, fmt.Errorf("no data returned for ticker %s: %w", ticker, ErrSymbolNotFound)
 	}
 
 	data := qs.QuoteSummary.Result[0]
@@ -1,15 +1,21 @@
 package main
 
 import (
+	common "app/internal/common"
+	"context"
 	"log"
+	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestGetStockMetrics_Integration(t *testing.T) {
 	log.Printf("Running integration test for getStockMetrics() ... this is a slower test!")
-	ticker := "AAPL" // Use a reliable, real ticker
+	ticker := common.Symbol("AAPL") // Use a reliable, real ticker
 
-	result, err := getStockMetrics(ticker)
+	result, err := getStockMetrics(context.Background(), ticker)
 	if err != nil {
 		t.Fatalf("getStockMetrics(%q) returned error: %v", ticker, err)
 	}
@@ -31,3 +37,44 @@ func TestGetStockMetrics_Integration(t *testing.T) {
 
 	t.Logf("Successfully fetched data for %s", ticker)
 }
+
+func TestGetStockMetricsCountsExpiredCacheAsStale(t *testing.T) {
+	withFakeYahoo(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("<html>try later</html>"))
+	})
+
+	cacheDir := filepath.Join(g_dataDir, "stockdata")
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().UTC().Add(-3 * time.Hour).Format("2006-01-02-15")
+	oldPath := filepath.Join(cacheDir, "MSFT-"+old+".json")
+	if err := os.WriteFile(oldPath, []byte(testQuoteSummary), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldTime := time.Now().Add(-3 * time.Hour)
+	if err := os.Chtimes(oldPath, oldTime, oldTime); err != nil {
+		t.Fatal(err)
+	}
+
+	// Past the TTL, so it's a stale lookup and upstream's error comes through.
+	staleBefore, missBefore := cacheLookups.Value("stale"), cacheLookups.Value("miss")
+	if _, err := getStockMetrics(context.Background(), "MSFT"); err == nil {
+		t.Error("expected an error instead of expired data")
+	}
+	if cacheLookups.Value("stale") != staleBefore+1 || cacheLookups.Value("miss") != missBefore {
+		t.Error("expired entry not counted as stale")
+	}
+
+	// Nothing cached for this one.
+	if _, err := getStockMetrics(context.Background(), "GOOG"); err == nil {
+		t.Error("expected an error without any cached data")
+	}
+	if cacheLookups.Value("miss") != missBefore+1 {
+		t.Error("miss not counted")
+	}
+	if upstreamErrors.Value("quoteSummary", upstreamErrStatus) == 0 {
+		t.Error("upstream status error not counted")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// How long in-flight requests get to finish after SIGTERM. systemd's default
+// TimeoutStopSec is 90s; ours is 30s, so leave room for the cleanup after.
+const shutdownTimeout = 20 * time.Second
+
+var (
+	// Every upstream fetch and Chrome launch derives from upstreamCtx, so a
+	// shutdown can abandon them all at once.
+	upstreamCtx, cancelUpstream = context.WithCancel(context.Background())
+
+	// Chrome runs currently in progress.
+	chromeProcesses sync.WaitGroup
+)
+
+// gracefulShutdown stops srv in order: tell systemd we're stopping, fail
+// readiness, stop accepting connections and drain in-flight requests until
+// timeout, cancel whatever upstream work remains, wait for Chrome to exit,
+// then close the cache, the health server and the HTTPS redirect.
+func gracefulShutdown(dmn DaemonNotifier, srv *http.Server, timeout time.Duration) error {
+	if _, err := dmn.SdNotify(false, "STOPPING=1"); err != nil {
+		slog.Error("Error notifying systemd of shutdown", "err", err)
+	}
+	common.SetDraining(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	slog.Info("Draining in-flight requests", "timeout", timeout)
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		slog.Warn("Drain incomplete, closing remaining connections", "err", err)
+		srv.Close()
+	}
+
+	cancelUpstream()
+	chromeProcesses.Wait()
+	closeQuoteCache()
+
+	// The health and redirect servers only answer probes and redirects, so
+	// there's nothing to drain.
+	healthCtx, healthCancel := context.WithTimeout(context.Background(), time.Second)
+	defer healthCancel()
+	common.ShutdownHealthServer(healthCtx)
+	if redirectServer != nil {
+		redirectServer.Close()
+	}
+
+	slog.Info("Shutdown complete")
+	return err
+}
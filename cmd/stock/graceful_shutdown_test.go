@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// withFreshShutdownState restores the process-wide state a shutdown tears down.
+func withFreshShutdownState(t *testing.T) {
+	t.Cleanup(func() {
+		upstreamCtx, cancelUpstream = context.WithCancel(context.Background())
+		quoteCacheMu.Lock()
+		quoteCacheClosed = false
+		quoteCacheMu.Unlock()
+	})
+}
+
+func TestGracefulShutdownDrainsInFlightRequests(t *testing.T) {
+	withFreshShutdownState(t)
+
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+
+	type response struct {
+		body string
+		err  error
+	}
+	got := make(chan response, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			got <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		got <- response{string(body), err}
+	}()
+	<-started
+
+	mock := &MockDaemon{NotifyReturnValue: true}
+	if err := gracefulShutdown(mock, srv, 5*time.Second); err != nil {
+		t.Fatalf("gracefulShutdown: %v", err)
+	}
+
+	if r := <-got; r.err != nil || r.body != "done" {
+		t.Errorf("in-flight request = %q, %v; want it to complete", r.body, r.err)
+	}
+	if len(mock.NotifyCalls) == 0 || mock.NotifyCalls[0] != "STOPPING=1" {
+		t.Errorf("notify calls = %v, want STOPPING=1 first", mock.NotifyCalls)
+	}
+	if upstreamCtx.Err() == nil {
+		t.Error("upstream context not cancelled")
+	}
+	if err := writeQuoteCache(t.TempDir()+"/x.json", []byte("{}")); err != errCacheClosed {
+		t.Errorf("cache write after shutdown = %v, want errCacheClosed", err)
+	}
+	if _, err := http.Get("http://" + ln.Addr().String() + "/slow"); err == nil {
+		t.Error("server still accepting connections after shutdown")
+	}
+}
+
+func TestGracefulShutdownGivesUpAfterTimeout(t *testing.T) {
+	withFreshShutdownState(t)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stuck", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+	defer close(release)
+
+	go http.Get("http://" + ln.Addr().String() + "/stuck")
+	<-started
+
+	start := time.Now()
+	err = gracefulShutdown(&MockDaemon{}, srv, 50*time.Millisecond)
+	if err == nil {
+		t.Error("expected a deadline error for a stuck request")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("shutdown took %s, want it bounded by the timeout", elapsed)
+	}
+}
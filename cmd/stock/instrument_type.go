@@ -0,0 +1,132 @@
+package main
+
+// Yahoo's quote types. Each kind of instrument gets metrics that make sense
+// for it: stocks are scored on their fundamentals, funds on cost and track
+// record, crypto and currency pairs on liquidity and volatility.
+const (
+	quoteTypeEquity     = "EQUITY"
+	quoteTypeETF        = "ETF"
+	quoteTypeMutualFund = "MUTUALFUND"
+	quoteTypeCrypto     = "CRYPTOCURRENCY"
+	quoteTypeCurrency   = "CURRENCY"
+	quoteTypeIndex      = "INDEX"
+	quoteTypeFuture     = "FUTURE"
+)
+
+// quoteType returns Yahoo's quote type. Results cached before the quoteType
+// and price modules were requested are guessed from fields only some kinds of
+// instrument have.
+func (r *Result) quoteType() string {
+	if r.QuoteType.QuoteType != "" {
+		return r.QuoteType.QuoteType
+	}
+	if r.Price.QuoteType != "" {
+		return r.Price.QuoteType
+	}
+	ks, sd := r.DefaultKeyStatistics, r.SummaryDetail
+	switch {
+	case ks.LegalType != nil && *ks.LegalType == "Exchange Traded Fund":
+		return quoteTypeETF
+	case ks.LegalType != nil || ks.FundFamily != nil:
+		return quoteTypeMutualFund
+	case sd.CoinMarketCapLink != nil || sd.Algorithm != nil || sd.CirculatingSupply.Raw != 0:
+		return quoteTypeCrypto
+	}
+	return quoteTypeEquity
+}
+
+// isFund reports whether result is an ETF or mutual fund.
+func (r *Result) isFund() bool {
+	t := r.quoteType()
+	return t == quoteTypeETF || t == quoteTypeMutualFund
+}
+
+func (r *Result) isCrypto() bool {
+	return r.quoteType() == quoteTypeCrypto
+}
+
+func (r *Result) isCurrency() bool {
+	return r.quoteType() == quoteTypeCurrency
+}
+
+// hasOptions reports whether the instrument may have listed options. Only
+// stocks, ETFs and indices do; Yahoo has no chain for the others.
+func (r *Result) hasOptions() bool {
+	switch r.quoteType() {
+	case quoteTypeEquity, quoteTypeETF, quoteTypeIndex:
+		return true
+	}
+	return false
+}
+
+// instrumentLabel names the kind of instrument for page headings.
+func (r *Result) instrumentLabel() string {
+	switch r.quoteType() {
+	case quoteTypeETF, quoteTypeMutualFund:
+		return "Fund"
+	case quoteTypeCrypto:
+		return "Crypto"
+	case quoteTypeCurrency:
+		return "Currency"
+	case quoteTypeIndex:
+		return "Index"
+	case quoteTypeFuture:
+		return "Futures"
+	}
+	return "Stock"
+}
+
+// price returns the last price. Only stocks have financialData, so fall back
+// to the price module, then for results cached before it was requested to the
+// NAV and the previous close.
+func (r *Result) price() float64 {
+	return firstNonZero(r.FinancialData.CurrentPrice.Raw, r.Price.RegularMarketPrice.Raw, r.SummaryDetail.NavPrice.Raw, r.SummaryDetail.PreviousClose.Raw)
+}
+
+func firstNonZero(values ...float64) float64 {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
+
+// instrumentName is the instrument's full name, e.g. "Bitcoin USD", or the
+// symbol if Yahoo gave none.
+func instrumentName(symbol string, result *Result) string {
+	for _, name := range []string{result.QuoteType.LongName, result.Price.LongName, result.QuoteType.ShortName, result.Price.ShortName} {
+		if name != "" {
+			return name
+		}
+	}
+	return symbol
+}
+
+type metricConfig struct {
+	name string
+	// nil when there's no data.
+	value *float64
+	unit  string
+}
+
+// nonZero is v, or nil for the 0 Yahoo leaves a missing value at.
+func nonZero(v float64) *float64 {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
+
+// availableMetrics builds the cards for everything but stocks. Metrics with
+// no value are left out rather than scored as zero, since a zero expense
+// ratio or volatility would score misleadingly well.
+func availableMetrics(configs []metricConfig) []Metric {
+	var metricsList []Metric
+	for _, cfg := range configs {
+		if m := buildMetricCardInformation(cfg.name, cfg.value, cfg.unit); m != nil {
+			metricsList = append(metricsList, *m)
+		}
+	}
+	return metricsList
+}
@@ -0,0 +1,38 @@
+package main
+
+import common "app/internal/common"
+
+// Prometheus metrics for the upstream fetch path, served from /metrics on the
+// health port alongside the HTTP metrics from common.InstrumentHandler.
+var (
+	cacheLookups = common.NewCounterVec("stock_cache_lookups_total",
+		"Quote cache lookups, by result: hit, miss when nothing is cached, or stale when the cached entry has expired.", "result")
+	upstreamDuration = common.NewHistogramVec("stock_upstream_fetch_duration_seconds",
+		"Time taken by requests to Yahoo, by endpoint.", common.DefaultBuckets, "endpoint")
+	upstreamErrors = common.NewCounterVec("stock_upstream_errors_total",
+		"Failed requests to Yahoo, by endpoint and error type.", "endpoint", "type")
+	chromeLaunches = common.NewCounterVec("stock_chrome_launches_total",
+		"Headless Chrome launches to obtain a Yahoo session, by result.", "result")
+	crumbRefreshes = common.NewCounterVec("stock_crumb_refreshes_total",
+		"New Yahoo crumbs obtained, by reason: initial, expired or rejected.", "reason")
+	rateLimited = common.NewCounterVec("stock_rate_limited_total",
+		"Requests turned away with 429, by scope: client for the per-IP limit, upstream for the Yahoo budget.", "scope")
+	apiKeyRequests = common.NewCounterVec("stock_api_key_requests_total",
+		"Authenticated requests, by API key id.", "key_id")
+	apiAuthFailures = common.NewCounterVec("stock_api_auth_failures_total",
+		"Requests rejected for a missing, invalid or revoked API key.", "reason")
+	logins = common.NewCounterVec("stock_logins_total",
+		"Web UI login attempts, by method (local, basic or oidc) and result.", "method", "result")
+	upstreamQueueWait = common.NewHistogramVec("stock_upstream_queue_wait_seconds",
+		"Time spent waiting for the Yahoo request budget.", common.DefaultBuckets)
+)
+
+// Error types for upstreamErrors.
+const (
+	upstreamErrNetwork  = "network"
+	upstreamErrStatus   = "status"
+	upstreamErrRejected = "rejected"
+	upstreamErrDecode   = "decode"
+	upstreamErrNotFound = "not_found"
+	upstreamErrSession  = "session"
+)
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"app/internal/oidc"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	g "maragu.dev/gomponents"
+	. "maragu.dev/gomponents/html"
+)
+
+// Where the OIDC flow keeps its state between the redirect to the IdP and the
+// callback, and how long the user has to finish logging in there.
+const (
+	oidcFlowCookie = "stock_oidc"
+	oidcFlowPath   = "/auth/oidc/"
+	oidcFlowTTL    = 10 * time.Minute
+)
+
+var (
+	// none, local or oidc. Set from auth.login in the config.
+	loginMode = "none"
+	// Set when loginMode is local.
+	localUsers *userStore
+
+	oidcConfig oidc.Config
+	// Discovered on first use, so the server starts while the IdP is down.
+	oidcMu       sync.Mutex
+	oidcProvider *oidc.Provider
+)
+
+// oidcFlow is what the callback needs to finish a login the same browser
+// started.
+type oidcFlow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Next     string `json:"next"`
+}
+
+// setupLogin prepares auth.login. It needs the data dir for users and the
+// session key.
+func setupLogin(cfg Config) error {
+	loginMode = cfg.Auth.Login
+	sessionTTL = cfg.Auth.SessionTTL
+	localUsers = nil
+	oidcMu.Lock()
+	oidcProvider = nil
+	oidcMu.Unlock()
+	if loginMode == "none" {
+		return nil
+	}
+	key, err := loadSessionKey(sessionKeyPath(cfg.DataDir))
+	if err != nil {
+		return fmt.Errorf("session key: %v", err)
+	}
+	sessionKey = key
+	if err := loadSessionGenerations(sessionGenerationsPath(cfg.DataDir)); err != nil {
+		return fmt.Errorf("sessions: %v", err)
+	}
+	switch loginMode {
+	case "local":
+		localUsers = newUserStore(userStorePath(cfg.DataDir))
+		if err := localUsers.load(); err != nil {
+			return err
+		}
+	case "oidc":
+		o := cfg.Auth.OIDC
+		oidcConfig = oidc.Config{Issuer: o.Issuer, ClientID: o.ClientID, ClientSecret: o.ClientSecret, RedirectURL: o.RedirectURL, Scopes: o.Scopes}
+	}
+	return nil
+}
+
+func getOIDCProvider(r *http.Request) (*oidc.Provider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	if oidcProvider != nil {
+		return oidcProvider, nil
+	}
+	p, err := oidc.Discover(r.Context(), oidcConfig)
+	if err != nil {
+		return nil, err
+	}
+	oidcProvider = p
+	return p, nil
+}
+
+// Pages that must work before logging in.
+func loginExempt(path string) bool {
+	return path == "/login" || path == "/logout" || strings.HasPrefix(path, oidcFlowPath)
+}
+
+// requireLogin puts the logged in user in the request context and sends
+// anonymous visitors of HTML pages to /login. The API is left to API keys.
+func requireLogin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if loginMode == "none" {
+			h(w, r)
+			return
+		}
+		u := sessionUser(r)
+		// Scripts can send local credentials with each request instead.
+		if username, password, ok := r.BasicAuth(); u == nil && ok && loginMode == "local" {
+			lu, err := localUsers.authenticate(username, password)
+			if err != nil {
+				logins.Inc("basic", "failure")
+				w.Header().Set("WWW-Authenticate", `Basic realm="stock"`)
+				renderLoginPage(w, r, http.StatusUnauthorized, err.Error())
+				return
+			}
+			logins.Inc("basic", "success")
+			u = localIdentity(lu)
+		}
+		if u != nil {
+			h(w, r.WithContext(withUser(r.Context(), u)))
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/api/") || loginExempt(r.URL.Path) {
+			h(w, r)
+			return
+		}
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+	}
+}
+
+func localIdentity(lu *localUser) *User {
+	name := lu.Name
+	if name == "" {
+		name = lu.Username
+	}
+	return &User{ID: "local:" + lu.Username, Name: name, Provider: "local"}
+}
+
+// safeNext keeps the post-login redirect on this site.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// loginHandler shows the login page and checks local passwords posted to it.
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	next := safeNext(r.FormValue("next"))
+	if loginMode == "none" || currentUser(r.Context()) != nil {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+	if r.Method != http.MethodPost || loginMode != "local" {
+		renderLoginPage(w, r, http.StatusOK, "")
+		return
+	}
+
+	username := r.PostFormValue("username")
+	lu, err := localUsers.authenticate(username, r.PostFormValue("password"))
+	if err != nil {
+		logins.Inc("local", "failure")
+		slog.WarnContext(r.Context(), "Login failed", "username", normalizeUsername(username))
+		renderLoginPage(w, r, http.StatusUnauthorized, err.Error())
+		return
+	}
+	finishLogin(w, r, "local", localIdentity(lu), next)
+}
+
+func finishLogin(w http.ResponseWriter, r *http.Request, method string, u *User, next string) {
+	if err := startSession(w, r, u); err != nil {
+		logins.Inc(method, "failure")
+		slog.ErrorContext(r.Context(), "Could not start session", "err", err)
+		renderLoginPage(w, r, http.StatusInternalServerError, "Could not log you in. Try again.")
+		return
+	}
+	logins.Inc(method, "success")
+	slog.InfoContext(r.Context(), "User logged in", "user", u.ID, "method", method)
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// logoutHandler ends the user's sessions, in other browsers too. POST only, so
+// other sites can't log users out with a link.
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if u := currentUser(r.Context()); u != nil {
+		// The cookie is cleared either way, but a copy of it stays valid.
+		if err := endSessions(u); err != nil {
+			slog.ErrorContext(r.Context(), "Could not end sessions", "user", u.ID, "err", err)
+		}
+	}
+	clearCookie(w, sessionCookie, "/")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// oidcLoginHandler sends the browser to the IdP.
+func oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if loginMode != "oidc" {
+		http.NotFound(w, r)
+		return
+	}
+	p, err := getOIDCProvider(r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "OIDC discovery failed", "err", err)
+		renderLoginPage(w, r, http.StatusBadGateway, "The login provider is not reachable. Try again later.")
+		return
+	}
+	flow := oidcFlow{
+		State:    oidc.RandomString(),
+		Nonce:    oidc.RandomString(),
+		Verifier: oidc.RandomString(),
+		Next:     safeNext(r.FormValue("next")),
+	}
+	if err := setSignedCookie(w, r, oidcFlowCookie, oidcFlowPath, flow, oidcFlowTTL); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, p.AuthCodeURL(flow.State, flow.Nonce, flow.Verifier), http.StatusFound)
+}
+
+// oidcCallbackHandler is where the IdP sends the browser back with a code.
+func oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if loginMode != "oidc" {
+		http.NotFound(w, r)
+		return
+	}
+	fail := func(status int, message string, args ...any) {
+		logins.Inc("oidc", "failure")
+		slog.WarnContext(r.Context(), "OIDC login failed", args...)
+		renderLoginPage(w, r, status, message)
+	}
+
+	var flow oidcFlow
+	c, err := r.Cookie(oidcFlowCookie)
+	if err == nil {
+		err = readSignedValue(c.Value, &flow)
+	}
+	if err != nil {
+		fail(http.StatusBadRequest, "Your login took too long or was started in another browser. Try again.", "err", err)
+		return
+	}
+	clearCookie(w, oidcFlowCookie, oidcFlowPath)
+	q := r.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(flow.State)) != 1 {
+		fail(http.StatusBadRequest, "Login state did not match. Try again.", "err", "state mismatch")
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		fail(http.StatusUnauthorized, "The login provider refused the login.", "err", e, "description", q.Get("error_description"))
+		return
+	}
+	p, err := getOIDCProvider(r)
+	if err != nil {
+		fail(http.StatusBadGateway, "The login provider is not reachable. Try again later.", "err", err)
+		return
+	}
+	claims, err := p.Exchange(r.Context(), q.Get("code"), flow.Verifier, flow.Nonce)
+	if err != nil {
+		fail(http.StatusUnauthorized, "The login could not be verified. Try again.", "err", err)
+		return
+	}
+	finishLogin(w, r, "oidc", oidcIdentity(claims), flow.Next)
+}
+
+func oidcIdentity(c *oidc.Claims) *User {
+	name := c.Name
+	for _, alt := range []string{c.PreferredUsername, c.Email, c.Subject} {
+		if name == "" {
+			name = alt
+		}
+	}
+	return &User{ID: "oidc:" + c.Subject, Name: name, Email: c.Email, Provider: "oidc"}
+}
+
+func renderLoginPage(w http.ResponseWriter, r *http.Request, status int, message string) {
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(status)
+	loginPage(safeNext(r.FormValue("next")), message).Render(w)
+}
+
+// loginPage has a password form for local users or a button for the IdP.
+func loginPage(next, message string) g.Node {
+	var form g.Node
+	if loginMode == "oidc" {
+		form = A(Href(oidcFlowPath+"login?next="+url.QueryEscape(next)),
+			Class("block text-center px-4 py-2 rounded bg-blue-600 hover:bg-blue-500"),
+			g.Text("Sign in with single sign-on"))
+	} else {
+		form = FormEl(Method("post"), Action("/login"), Class("space-y-3"),
+			Input(Type("hidden"), Name("next"), Value(next)),
+			Input(Type("text"), Name("username"), Placeholder("Username"), Required(), AutoComplete("username"),
+				Class("w-full px-3 py-2 rounded bg-gray-700 text-gray-100 focus:outline-none")),
+			Input(Type("password"), Name("password"), Placeholder("Password"), Required(), AutoComplete("current-password"),
+				Class("w-full px-3 py-2 rounded bg-gray-700 text-gray-100 focus:outline-none")),
+			Button(Type("submit"), Class("w-full px-4 py-2 rounded bg-blue-600 hover:bg-blue-500"), g.Text("Log in")),
+		)
+	}
+	return HTML(
+		Head(
+			Meta(Charset("UTF-8")),
+			Meta(Name("viewport"), Content("width=device-width, initial-scale=1.0")),
+			TitleEl(g.Text("Log in - Stock Metrics Analyzer")),
+			Script(Src("https://cdn.tailwindcss.com")),
+		),
+		Body(Class("bg-gray-900 text-gray-100 min-h-screen flex items-center justify-center"),
+			Div(Class("container mx-auto px-4"),
+				Div(Class("max-w-md mx-auto bg-gray-800 rounded-lg shadow-lg p-8"),
+					H1(Class("text-2xl font-bold mb-4"), g.Text("Log in")),
+					g.If(message != "", P(Class("text-red-400 mb-4"), g.Text(message))),
+					form,
+				),
+			),
+		),
+	)
+}
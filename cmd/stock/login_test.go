@@ -0,0 +1,243 @@
+package main
+
+import (
+	"app/internal/oidc"
+	"app/internal/oidc/oidctest"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// withLogin turns on auth.login with a fresh data dir.
+func withLogin(t *testing.T, mode string, o OIDCConfig) {
+	withCheapBcrypt(t)
+	cfg := defaultConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Auth.Login = mode
+	cfg.Auth.OIDC = o
+	if err := setupLogin(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { setupLogin(defaultConfig()) })
+}
+
+// loginTestServer serves the home page and login routes behind requireLogin,
+// with a browser-like client that keeps cookies.
+func loginTestServer(t *testing.T) (*httptest.Server, *http.Client) {
+	mux := http.NewServeMux()
+	for path, h := range map[string]http.HandlerFunc{
+		"/":                       homeHandler,
+		"/login":                  loginHandler,
+		"/logout":                 logoutHandler,
+		oidcFlowPath + "login":    oidcLoginHandler,
+		oidcFlowPath + "callback": oidcCallbackHandler,
+		"/api/v1/quote":           func(w http.ResponseWriter, r *http.Request) {},
+	} {
+		mux.Handle(path, requireLogin(h))
+	}
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	jar, _ := cookiejar.New(nil)
+	return srv, &http.Client{Jar: jar}
+}
+
+func get(t *testing.T, c *http.Client, u string) (*http.Response, string) {
+	t.Helper()
+	resp, err := c.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp, string(body)
+}
+
+func TestLocalLogin(t *testing.T) {
+	srv, client := loginTestServer(t)
+	withLogin(t, "local", OIDCConfig{})
+	if err := localUsers.setPassword("alice", "Alice Liddell", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body := get(t, client, srv.URL+"/?x=1")
+	if resp.Request.URL.Path != "/login" || resp.Request.URL.Query().Get("next") != "/?x=1" || !strings.Contains(body, `name="password"`) {
+		t.Fatalf("anonymous visit ended at %s", resp.Request.URL)
+	}
+	if resp, _ := get(t, client, srv.URL+"/api/v1/quote"); resp.StatusCode != http.StatusOK {
+		t.Errorf("API route = %d, want it left to API keys", resp.StatusCode)
+	}
+
+	resp, err := client.PostForm(srv.URL+"/login", url.Values{"username": {"alice"}, "password": {"wrong"}, "next": {"/?x=1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong password = %d, want 401", resp.StatusCode)
+	}
+
+	resp, err = client.PostForm(srv.URL+"/login", url.Values{"username": {"Alice"}, "password": {"correct horse"}, "next": {"/?x=1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body2, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.Request.URL.RequestURI() != "/?x=1" || !strings.Contains(string(body2), "Alice Liddell") {
+		t.Fatalf("after login at %s, body has user: %v", resp.Request.URL, strings.Contains(string(body2), "Alice Liddell"))
+	}
+
+	// Changing the password ends the session.
+	localUsers.setPassword("alice", "", "battery staple")
+	if resp, _ := get(t, client, srv.URL+"/"); resp.Request.URL.Path != "/login" {
+		t.Errorf("still logged in after a password change at %s", resp.Request.URL)
+	}
+
+	// Removing the user ends the session.
+	client.PostForm(srv.URL+"/login", url.Values{"username": {"alice"}, "password": {"battery staple"}})
+	if resp, _ := get(t, client, srv.URL+"/"); resp.Request.URL.Path != "/" {
+		t.Fatalf("login with the new password ended at %s", resp.Request.URL)
+	}
+	localUsers.remove("alice")
+	if resp, _ := get(t, client, srv.URL+"/"); resp.Request.URL.Path != "/login" {
+		t.Errorf("removed user still logged in at %s", resp.Request.URL)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	srv, client := loginTestServer(t)
+	withLogin(t, "local", OIDCConfig{})
+	localUsers.setPassword("alice", "", "correct horse")
+	client.PostForm(srv.URL+"/login", url.Values{"username": {"alice"}, "password": {"correct horse"}})
+
+	if resp, _ := get(t, client, srv.URL+"/logout"); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /logout = %d, want 405", resp.StatusCode)
+	}
+	base, _ := url.Parse(srv.URL)
+	saved := client.Jar.Cookies(base)
+	if len(saved) == 0 {
+		t.Fatal("not logged in")
+	}
+	resp, err := client.PostForm(srv.URL+"/logout", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Request.URL.Path != "/login" {
+		t.Errorf("after logout at %s, want /login", resp.Request.URL)
+	}
+
+	// A copy of the old cookie no longer works.
+	client.Jar.SetCookies(base, saved)
+	if resp, _ := get(t, client, srv.URL+"/"); resp.Request.URL.Path != "/login" {
+		t.Errorf("old session cookie still logged in at %s", resp.Request.URL)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	withLogin(t, "local", OIDCConfig{})
+	localUsers.setPassword("alice", "", "correct horse")
+	var got *User
+	h := requireLogin(func(w http.ResponseWriter, r *http.Request) { got = currentUser(r.Context()) })
+
+	r := httptest.NewRequest("GET", "/stock?symbol=AAPL", nil)
+	r.SetBasicAuth("alice", "correct horse")
+	h(httptest.NewRecorder(), r)
+	if got == nil || got.ID != "local:alice" || got.Name != "alice" {
+		t.Errorf("user = %+v", got)
+	}
+
+	// Any case, as on the login form.
+	got = nil
+	r = httptest.NewRequest("GET", "/stock?symbol=AAPL", nil)
+	r.SetBasicAuth(" Alice", "correct horse")
+	h(httptest.NewRecorder(), r)
+	if got == nil || got.ID != "local:alice" {
+		t.Errorf("mixed case user = %+v", got)
+	}
+
+	r = httptest.NewRequest("GET", "/stock?symbol=AAPL", nil)
+	r.SetBasicAuth("alice", "nope")
+	rec := httptest.NewRecorder()
+	h(rec, r)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("wrong password = %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestSessionCookieTampering(t *testing.T) {
+	withLogin(t, "oidc", OIDCConfig{})
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	if err := startSession(rec, r, &User{ID: "oidc:1", Name: "Eve", Provider: "oidc"}); err != nil {
+		t.Fatal(err)
+	}
+	cookie := rec.Result().Cookies()[0]
+	if !cookie.HttpOnly {
+		t.Error("session cookie readable from scripts")
+	}
+
+	r.AddCookie(cookie)
+	if u := sessionUser(r); u == nil || u.Name != "Eve" {
+		t.Fatalf("sessionUser = %+v", u)
+	}
+	body, sig, _ := strings.Cut(cookie.Value, ".")
+	for _, v := range []string{body + "x." + sig, body, "garbage", body + "." + sig + "x"} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.AddCookie(&http.Cookie{Name: sessionCookie, Value: v})
+		if u := sessionUser(r); u != nil {
+			t.Errorf("tampered cookie %q accepted", v)
+		}
+	}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := oidctest.NewProvider()
+	defer idp.Close()
+	idp.Name = "Grace Hopper"
+	srv, client := loginTestServer(t)
+	withLogin(t, "oidc", OIDCConfig{
+		Issuer:       idp.URL,
+		ClientID:     idp.ClientID,
+		ClientSecret: idp.ClientSecret,
+		RedirectURL:  srv.URL + oidcFlowPath + "callback",
+	})
+
+	resp, body := get(t, client, srv.URL+"/")
+	if resp.Request.URL.Path != "/login" || !strings.Contains(body, oidcFlowPath+"login") {
+		t.Fatalf("anonymous visit ended at %s", resp.Request.URL)
+	}
+	// What the SSO button does: through the IdP and back, logged in.
+	resp, body = get(t, client, srv.URL+oidcFlowPath+"login?next=%2F")
+	if resp.Request.URL.Path != "/" || !strings.Contains(body, "Grace Hopper") {
+		t.Fatalf("after OIDC login at %s", resp.Request.URL)
+	}
+
+	u := oidcIdentity(&oidc.Claims{Subject: "../../etc", Email: "a@example.com"})
+	if u.ID != "oidc:../../etc" || u.Name != "a@example.com" || strings.Contains(userDataDir(u), "etc") {
+		t.Errorf("identity = %+v, data in %s", u, userDataDir(u))
+	}
+
+	// A callback without the flow cookie, e.g. replayed from another browser.
+	other := &http.Client{}
+	if resp, _ := get(t, other, srv.URL+oidcFlowPath+"callback?code=x&state=y"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("callback without flow cookie = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestSafeNext(t *testing.T) {
+	for next, want := range map[string]string{
+		"/stock?symbol=AAPL": "/stock?symbol=AAPL",
+		"":                   "/",
+		"https://evil.test":  "/",
+		"//evil.test":        "/",
+		`/\evil.test`:        "/",
+	} {
+		if got := safeNext(next); got != want {
+			t.Errorf("safeNext(%q) = %q, want %q", next, got, want)
+		}
+	}
+}
@@ -2,12 +2,20 @@ package main
 
 import (
 	common "app/internal/common"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"syscall"
+	"time"
 
 	g "maragu.dev/gomponents"
 
@@ -33,17 +41,18 @@ func renderMetricCard(m Metric) g.Node {
 			H3(Class("font-semibold text-lg "+textColor), g.Text(m.Name)),
 			Span(Class("text-2xl font-bold "+textColor), g.Text(m.Value)),
 		),
+		g.If(len(m.Trend) >= 2 || colorChange(m) != nil, Div(Class("flex justify-between items-center "+textColor),
+			sparkline(m.Trend),
+			colorChange(m),
+		)),
 		P(Class("text-sm text-gray-400 mt-2"), g.Raw(m.Reason)),
+		g.If(m.Note != "", P(Class("text-xs text-gray-500 mt-2 italic"), g.Text(m.Note))),
 	)
 }
 
-func stockPage(symbol string) g.Node {
-	result, err := getStockMetrics(symbol)
-	if err != nil {
-		return errorPage("Error Fetching Data", err.Error(), symbol)
-	}
+func stockPage(symbol string, result *Result, snapshots []metricSnapshot, user *User) g.Node {
 
-	metricsList := buildMetricsList(result)
+	metricsList := withTrends(buildMetricsList(result), snapshots, time.Now())
 
 	var metricCards []g.Node
 	for _, m := range metricsList {
@@ -59,7 +68,7 @@ func stockPage(symbol string) g.Node {
 		Head(
 			Meta(Charset("UTF-8")),
 			Meta(Name("viewport"), Content("width=device-width, initial-scale=1.0")),
-			TitleEl(g.Text(fmt.Sprintf("%s - Stock Analysis", symbol))),
+			TitleEl(g.Text(fmt.Sprintf("%s - %s Analysis", symbol, result.instrumentLabel()))),
 			Script(Src("https://unpkg.com/alpinejs@3.x.x/dist/cdn.min.js"), Defer()),
 			Script(Src("https://cdn.tailwindcss.com")),
 			Script(g.Raw(`tailwind.config = { theme: { extend: { colors: { darkbg: '#1a1a1a' } } } }`)),
@@ -67,11 +76,14 @@ func stockPage(symbol string) g.Node {
 		Body(
 			Class("bg-darkbg text-gray-200 min-h-screen"),
 			g.Attr("x-data", "{ isNavigating: false }"), Div(Class("container mx-auto px-4 py-8"),
+				userBadge(user),
 				Div(Class("mb-8"),
 					Div(Class("flex items-center justify-between mb-4"),
 						Div(
 							H1(Class("text-4xl font-bold text-white mb-2"), g.Text(symbol)),
-							P(Class("text-gray-400"), g.Text("Real-time Stock Metrics from Yahoo Finance")),
+							g.If(instrumentName(symbol, result) != symbol, P(Class("text-gray-300"), g.Text(instrumentName(symbol, result)))),
+							g.If(result.isCurrency(), P(Class("text-gray-300"), g.Text(currencyPairText(symbol, result)))),
+							P(Class("text-gray-400"), g.Text(fmt.Sprintf("Real-time %s Metrics from Yahoo Finance", result.instrumentLabel()))),
 						),
 						A(
 							Href("/"),
@@ -86,7 +98,15 @@ func stockPage(symbol string) g.Node {
 							g.Text("← New Search"),
 						),
 					),
-					P(Class("text-xs text-gray-500 mt-2"), g.Text(fmt.Sprintf("Showing %d available metrics", len(metricsList)))),
+					Div(Class("flex items-center justify-between mt-2"),
+						P(Class("text-xs text-gray-500"), g.Text(fmt.Sprintf("Showing %d available metrics", len(metricsList)))),
+						Div(Class("flex gap-2"),
+							currencyPicker(symbol, result.Currencies),
+							g.If(result.hasOptions(), downloadButton("/options?symbol="+url.QueryEscape(symbol), "Options")),
+							downloadButton("/export?symbol="+url.QueryEscape(symbol)+"&format=csv", "Download CSV"),
+							downloadButton("/export?symbol="+url.QueryEscape(symbol)+"&format=xlsx", "Download Excel"),
+						),
+					),
 				),
 
 				Div(Class("mb-6 flex gap-4"),
@@ -102,6 +122,12 @@ func stockPage(symbol string) g.Node {
 					),
 				),
 
+				earningsSection(result, time.Now()),
+				dividendSection(result, time.Now()),
+				analystSection(result),
+				ownershipSection(result, time.Now()),
+				optionsNextSection(symbol, result, time.Now()),
+
 				Div(Class("mt-8 p-4 bg-gray-800 rounded-lg border border-gray-700"),
 					P(Class("text-sm text-gray-400"),
 						g.Text("Note: Data is pulled from Yahoo Finance's internal JSON endpoint. The endpoint is unofficial and may change."),
@@ -133,52 +159,83 @@ func errorPage(title, message, symbol string) g.Node {
 }
 
 func buildMetricsList(result *Result) []Metric {
+	var metricsList []Metric
+	switch {
+	case result.isFund():
+		metricsList = buildFundMetricsList(result)
+	case result.isCrypto():
+		metricsList = buildCryptoMetricsList(result)
+	case result.isCurrency():
+		metricsList = buildCurrencyMetricsList(result)
+	default:
+		metricsList = buildStockMetricsList(result)
+	}
+	return result.Currencies.localize(metricsList)
+}
+
+func buildStockMetricsList(result *Result) []Metric {
 	tempPegRatio := result.SummaryDetail.TrailingPE.Raw / result.FinancialData.EarningsGrowth.Raw
 	tempROIC := CalculateROIC(result.FinancialData, result.DefaultKeyStatistics)
+	priceToBook := result.priceToBook()
+	priceToSales := result.priceToSales()
 	var metricsList []Metric
 	metricConfigs := []struct {
-		name         string
-		value        *float64
-		isPercent    bool
-		needsScaling bool
+		name  string
+		value *float64
+		unit  string
 	}{
-		{"P/E Ratio", &result.SummaryDetail.TrailingPE.Raw, false, false},
-		{"Short Ratio", &result.DefaultKeyStatistics.ShortRatio.Raw, false, false},
-		{"Short Percent of Float", &result.DefaultKeyStatistics.ShortPercentOfFloat.Raw, true, false},
-		{"Forward P/E", &result.SummaryDetail.ForwardPE.Raw, false, false},
-		{"P/B Ratio", &result.DefaultKeyStatistics.PriceToBook.Raw, false, false},
-		{"P/S Ratio", &result.SummaryDetail.PriceToSalesTrailing12Months.Raw, false, false},
-		{"PEG Ratio", &tempPegRatio, false, false},
-		{"Debt/Equity", &result.FinancialData.DebtToEquity.Raw, false, false},
-		{"Current Ratio", &result.FinancialData.CurrentRatio.Raw, false, false},
-		{"Quick Ratio", &result.FinancialData.QuickRatio.Raw, false, false},
-		{"ROE", &result.FinancialData.ReturnOnEquity.Raw, true, true},
-		{"ROA", &result.FinancialData.ReturnOnAssets.Raw, true, true},
-		{"ROIC", &tempROIC, true, false}, // not present in struct; set to 0 or compute elsewhere
-		{"Gross Margin", &result.FinancialData.GrossMargins.Raw, true, true},
-		{"Operating Margin", &result.FinancialData.OperatingMargins.Raw, true, true},
-		{"Net Margin", &result.DefaultKeyStatistics.ProfitMargins.Raw, true, true},
-		{"Revenue Growth", &result.FinancialData.RevenueGrowth.Raw, true, true},
-		{"Earnings Growth", &result.FinancialData.EarningsGrowth.Raw, true, true},
-		{"Free Cash Flow", &result.FinancialData.FreeCashflow.Raw, false, false},
-		{"Beta", &result.SummaryDetail.Beta.Raw, false, false},
-		{"Dividend Yield", &result.SummaryDetail.DividendYield.Raw, true, false},
-		{"Price", &result.FinancialData.CurrentPrice.Raw, false, false},
-		{"Market Cap", &result.SummaryDetail.MarketCap.Raw, false, false},
-		{"Enterprise Value", &result.DefaultKeyStatistics.EnterpriseValue.Raw, false, false},
-		{"Shares Outstanding", &result.DefaultKeyStatistics.SharesOutstanding.Raw, false, false},
-		{"Book Value", &result.DefaultKeyStatistics.BookValue.Raw, false, false},
-		{"Return on Equity", &result.FinancialData.ReturnOnEquity.Raw, true, true},
+		{"P/E Ratio", &result.SummaryDetail.TrailingPE.Raw, unitRatio},
+		{"Short Ratio", &result.DefaultKeyStatistics.ShortRatio.Raw, unitDays},
+		{"Short Percent of Float", &result.DefaultKeyStatistics.ShortPercentOfFloat.Raw, unitPercent},
+		{"Insider Ownership", &result.DefaultKeyStatistics.HeldPercentInsiders.Raw, unitPercent},
+		{"Institutional Ownership", &result.DefaultKeyStatistics.HeldPercentInstitutions.Raw, unitPercent},
+		{"Forward P/E", &result.SummaryDetail.ForwardPE.Raw, unitRatio},
+		{"P/B Ratio", &priceToBook, unitRatio},
+		{"P/S Ratio", &priceToSales, unitRatio},
+		{"PEG Ratio", &tempPegRatio, unitRatio},
+		{"Debt/Equity", &result.FinancialData.DebtToEquity.Raw, unitRatio},
+		{"Current Ratio", &result.FinancialData.CurrentRatio.Raw, unitRatio},
+		{"Quick Ratio", &result.FinancialData.QuickRatio.Raw, unitRatio},
+		{"ROE", &result.FinancialData.ReturnOnEquity.Raw, unitPercent},
+		{"ROA", &result.FinancialData.ReturnOnAssets.Raw, unitPercent},
+		{"ROIC", &tempROIC, unitPercent}, // not present in struct; set to 0 or compute elsewhere
+		{"Gross Margin", &result.FinancialData.GrossMargins.Raw, unitPercent},
+		{"Operating Margin", &result.FinancialData.OperatingMargins.Raw, unitPercent},
+		{"Net Margin", &result.DefaultKeyStatistics.ProfitMargins.Raw, unitPercent},
+		{"Revenue Growth", &result.FinancialData.RevenueGrowth.Raw, unitPercent},
+		{"Earnings Growth", &result.FinancialData.EarningsGrowth.Raw, unitPercent},
+		{"Free Cash Flow", &result.FinancialData.FreeCashflow.Raw, unitCurrency},
+		{"Beta", &result.SummaryDetail.Beta.Raw, unitRatio},
+		{"Price", &result.FinancialData.CurrentPrice.Raw, unitCurrency},
+		{"Market Cap", &result.SummaryDetail.MarketCap.Raw, unitCurrency},
+		{"Enterprise Value", &result.DefaultKeyStatistics.EnterpriseValue.Raw, unitCurrency},
+		{"Shares Outstanding", &result.DefaultKeyStatistics.SharesOutstanding.Raw, unitShares},
+		{"Book Value", &result.DefaultKeyStatistics.BookValue.Raw, unitCurrency},
+		{"Return on Equity", &result.FinancialData.ReturnOnEquity.Raw, unitPercent},
 	}
 
 	for _, cfg := range metricConfigs {
-		if m := buildMetricCardInformation(cfg.name, cfg.value, cfg.isPercent); m != nil {
+		if m := buildMetricCardInformation(cfg.name, cfg.value, cfg.unit); m != nil {
 			metricsList = append(metricsList, *m)
 		}
 	}
+	if m := beatRateMetric(result); m != nil {
+		metricsList = append(metricsList, *m)
+	}
+	metricsList = append(metricsList, ownershipMetrics(result, time.Now())...)
+	metricsList = append(metricsList, dividendMetrics(result, time.Now())...)
 	return metricsList
 }
 
+// Cards shown for information only, without a metricRules entry.
+var infoMetrics = []string{"Price", "Market Cap", "Enterprise Value", "Shares Outstanding", "Book Value", "Return on Equity"}
+
+// isMetricName reports whether buildMetricsList can produce a card named name.
+func isMetricName(name string) bool {
+	_, scored := metricRules[name]
+	return scored || slices.Contains(infoMetrics, name)
+}
+
 func homeHandler(w http.ResponseWriter, r *http.Request) {
 	page := HTML(
 		Head(
@@ -187,33 +244,44 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
 			TitleEl(g.Text("Stock Metrics Analyzer")),
 			Script(Src("https://cdn.tailwindcss.com")),
 			Script(Src("https://unpkg.com/alpinejs@3.x.x/dist/cdn.min.js"), Defer()), // 🔧 Add Alpine.js
+			Script(g.Raw(symbolSearchScript)),
 		),
 		Body(Class("bg-gray-50 min-h-screen flex items-center justify-center"),
 			Div(Class("container mx-auto px-4"),
 				Div(Class("max-w-md mx-auto bg-white rounded-lg shadow-lg p-8"),
+					userBadge(currentUser(r.Context())),
 					H1(Class("text-3xl font-bold text-gray-900 mb-2"), g.Text("Stock Metrics Analyzer")),
 					P(Class("text-gray-600 mb-6 text-sm"), g.Text("Real-time data from Yahoo Finance")),
 
-					// 🔧 Alpine.js loading state
-					Div(g.Attr("x-data", "{ isLoading: false }"),
+					// 🔧 Alpine.js loading state and symbol autocomplete
+					Div(g.Attr("x-data", "symbolSearch()"),
 						FormEl(
 							Action("/stock"),
 							Method("GET"),
+							g.Attr("x-ref", "form"),
 							g.Attr("@submit", "isLoading = true"),
 							Class("space-y-4"),
 
-							Div(
+							Div(Class("relative"),
 								Label(For("symbol"), Class("block text-sm font-medium text-gray-700 mb-2"),
-									g.Text("Enter Stock Symbol"),
+									g.Text("Enter Stock Symbol or Company"),
 								),
 								Input(
 									Type("text"),
 									Name("symbol"),
 									ID("symbol"),
-									Placeholder("e.g., AAPL, MSFT, GOOGL"),
+									Placeholder("e.g., AAPL, Microsoft, GOOGL"),
 									Required(),
+									AutoComplete("off"),
+									g.Attr("x-model", "query"),
+									g.Attr("@input", "search()"),
+									g.Attr("@keydown.down.prevent", "move(1)"),
+									g.Attr("@keydown.up.prevent", "move(-1)"),
+									g.Attr("@keydown.enter", "if (active >= 0) { $event.preventDefault(); choose(results[active]) }"),
+									g.Attr("@keydown.escape", "results = []"),
 									Class("w-full px-4 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-transparent uppercase"),
 								),
+								symbolSuggestions(),
 							),
 
 							Button(
@@ -236,6 +304,36 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
 							)),
 					),
 
+					// Export a list of symbols without visiting each stock page.
+					FormEl(
+						Action("/export"),
+						Method("GET"),
+						Class("mt-6 pt-6 border-t border-gray-200 space-y-2"),
+						Label(For("symbols"), Class("block text-sm font-medium text-gray-700"),
+							g.Text("Export Metrics for a List"),
+						),
+						Input(
+							Type("text"),
+							Name("symbols"),
+							ID("symbols"),
+							Placeholder("e.g., AAPL, MSFT, GOOGL"),
+							Required(),
+							Class("w-full px-4 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-transparent uppercase"),
+						),
+						Div(Class("flex gap-2"),
+							Button(Type("submit"), Name("format"), Value("csv"),
+								Class("flex-1 bg-gray-700 text-white py-2 px-4 rounded-lg hover:bg-gray-800 transition text-sm"),
+								g.Text("Download CSV"),
+							),
+							Button(Type("submit"), Name("format"), Value("xlsx"),
+								Class("flex-1 bg-green-700 text-white py-2 px-4 rounded-lg hover:bg-green-800 transition text-sm"),
+								g.Text("Download Excel"),
+							),
+						),
+					),
+
+					A(Href("/alerts"), Class("block mt-6 text-sm text-blue-600 hover:underline"), g.Text("Manage alerts →")),
+
 					Div(Class("mt-6 p-4 bg-gray-50 rounded-lg"),
 						P(Class("text-xs text-gray-600"),
 							g.Text("This tool uses Yahoo Finance's internal JSON endpoint. Use responsibly."),
@@ -262,6 +360,32 @@ func colorKey(color, label string) g.Node {
 	)
 }
 
+func downloadButton(href, label string) g.Node {
+	return A(Href(href), Class("px-3 py-1 text-xs rounded bg-gray-700 text-gray-300 hover:bg-gray-600 transition"), g.Text(label))
+}
+
+// currencyPicker switches the currency values are shown in. The choice is
+// remembered for later pages.
+func currencyPicker(symbol string, c currencies) g.Node {
+	if c.Trading == "" {
+		return nil
+	}
+	options := []g.Node{Option(Value(""), g.If(c.Display == c.Trading, Selected()), g.Text("Native ("+c.Trading+")"))}
+	for _, code := range displayCurrencies {
+		if code == c.Trading {
+			continue
+		}
+		options = append(options, Option(Value(code), g.If(code == c.Display, Selected()), g.Text(code)))
+	}
+	return Form(Method("get"), Action("/stock"),
+		Input(Type("hidden"), Name("symbol"), Value(symbol)),
+		Select(Name(displayCurrencyParam), g.Attr("aria-label", "Display currency"), g.Attr("onchange", "this.form.submit()"),
+			Class("px-2 py-1 text-xs rounded bg-gray-700 text-gray-300"),
+			g.Group(options),
+		),
+	)
+}
+
 func filterButtons() g.Node {
 	return Div(Class("mb-6"),
 		g.Attr(":disabled", "isNavigating"),
@@ -274,23 +398,63 @@ func filterButtons() g.Node {
 }
 
 func stockHandler(w http.ResponseWriter, r *http.Request) {
-	symbol := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("symbol")))
-	if symbol == "" {
+	raw := strings.TrimSpace(r.URL.Query().Get("symbol"))
+	if raw == "" {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
-	page := stockPage(symbol)
+	ticker, err := common.ParseSymbol(raw)
+	if err != nil {
+		// Usually a company name typed in full, so search for it instead.
+		suggestions, _ := searchSymbols(r.Context(), raw, 5)
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadRequest)
+		symbolNotFoundPage(fmt.Sprintf("%q is not a valid ticker symbol.", raw), suggestions).Render(w)
+		return
+	}
+	symbol := ticker.String()
+	result, err := fetchStockMetrics(r.Context(), ticker)
+	var busy *upstreamBusyError
+	if errors.As(err, &busy) {
+		writeRateLimited(w, r, busy.retryAfter)
+		return
+	}
+	page := errorPage("Error Fetching Data", fmt.Sprint(err), symbol)
+	if errors.Is(err, ErrSymbolNotFound) {
+		// Most likely a typo, so offer what the search finds for it.
+		suggestions, _ := searchSymbols(r.Context(), symbol, 5)
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusNotFound)
+		symbolNotFoundPage(fmt.Sprintf("Yahoo Finance has no data for %q.", symbol), suggestions).Render(w)
+		return
+	}
+	if err == nil {
+		display, _ := displayCurrency(r)
+		rememberDisplayCurrency(w, r, display)
+		result = withCurrencies(r.Context(), result, display)
+	}
+	if err == nil && result.isFund() {
+		page = fundPage(symbol, result, currentUser(r.Context()))
+	} else if err == nil {
+		page = stockPage(symbol, result, tickerSnapshots(r.Context(), ticker), currentUser(r.Context()))
+	}
 	w.Header().Set("Content-Type", "text/html")
 	page.Render(w)
 }
 
+// apiHandler returns the raw Yahoo Result. New clients should use /api/v1/.
 func apiHandler(w http.ResponseWriter, r *http.Request) {
-	symbol := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("symbol")))
-	if symbol == "" {
+	raw := r.URL.Query().Get("symbol")
+	if strings.TrimSpace(raw) == "" {
 		http.Error(w, "symbol parameter required", http.StatusBadRequest)
 		return
 	}
-	metrics, err := getStockMetrics(symbol)
+	symbol, err := common.ParseSymbol(raw)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	metrics, err := fetchStockMetrics(r.Context(), symbol)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -299,59 +463,174 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(metrics)
 }
 
+// handle registers h on the default mux with a request ID, request metrics
+// labelled by pattern, the per-client rate limit, API key auth and the web UI
+// login. The client limit comes first so it also slows down key and password
+// guessing.
+func handle(pattern string, h http.HandlerFunc) {
+	http.Handle(pattern, common.WithRequestIDs(common.InstrumentHandler(pattern, limitClients(requireAPIKey(requireLogin(h))))))
+}
+
 func main() {
-	d := &SystemdDaemon{}
-	EnableBackgroundWatchdog(d)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "config":
+			os.Exit(runConfigCommand(os.Args[2:], os.Stdout, os.Stderr))
+		case "apikey":
+			os.Exit(runAPIKeyCommand(os.Args[2:], os.Stdout, os.Stderr))
+		case "user":
+			os.Exit(runUserCommand(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		}
+	}
 
-	http.HandleFunc("/", homeHandler)
-	http.HandleFunc("/stock", stockHandler)
-	http.HandleFunc("/api/metrics", apiHandler)
+	handle("/", homeHandler)
+	handle("/stock", stockHandler)
+	handle("/options", optionsHandler)
+	handle("/export", exportHandler)
+	handle("/alerts", alertsHandler)
+	handle("/suggest", searchHandler)
+	handle("/api/metrics", apiHandler)
+	for _, route := range apiV1Routes() {
+		handle(route.Path, route.Handler)
+	}
+	handle(apiV1Prefix, apiV1NotFoundHandler)
+	handle("/login", loginHandler)
+	handle("/logout", logoutHandler)
+	handle(oidcFlowPath+"login", oidcLoginHandler)
+	handle(oidcFlowPath+"callback", oidcCallbackHandler)
 
-	port := flag.Int64("port", 8080, "port to listen on")
-	ip := flag.String("ip", "", "ip to listen on")
-	flagDataDir := flag.String("data", "", "directory to store data")
+	flags := addConfigFlags(flag.CommandLine)
 
 	// Parse command-line flags
 	flag.Parse()
 
-	g_dataDir = *flagDataDir
+	cfg, err := loadConfig(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := common.SetupLogging(os.Stdout, cfg.Log.Format, cfg.Log.Level); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	applyConfig(cfg)
+	if err := setupLogin(cfg); err != nil {
+		slog.Error("Could not set up login", "err", err)
+		os.Exit(1)
+	}
 
-	// Run the health check port.
-	healthPort := (*port) + 1
-	err := common.StartHealthServer(VERSION, fmt.Sprintf("%s:%d", *ip, healthPort))
+	tlsConfig, hstsMaxAge, err := setupTLS(cfg)
 	if err != nil {
-		log.Printf("Error starting health server: %v", err)
-		log.Fatal(err)
+		slog.Error("Could not set up TLS", "err", err)
+		os.Exit(1)
 	}
 
-	log.Printf("Server starting on http://%s:%d", *ip, *port)
-	err = http.ListenAndServe(fmt.Sprintf("%s:%d", *ip, *port), nil)
+	if alertInterval > 0 {
+		go runAlerts(upstreamCtx, alertInterval)
+	}
+
+	registerHealthChecks(cfg.scheme(), cfg.listenAddr())
+	d := &SystemdDaemon{}
+	EnableBackgroundWatchdog(d, isAlive)
+
+	// Run the health check port.
+	var healthTLS *tls.Config
+	if cfg.Health.TLS {
+		healthTLS = tlsConfig
+	}
+	err = common.StartHealthServer(VERSION, cfg.healthAddr(), healthTLS)
 	if err != nil {
-		log.Printf("Error starting serving server: %v", err)
-		log.Fatal(err)
+		slog.Error("Error starting health server", "err", err)
+		os.Exit(1)
 	}
 
+	var handler http.Handler = http.DefaultServeMux
+	if hstsMaxAge > 0 {
+		handler = common.WithHSTS(handler, hstsMaxAge)
+	}
+	srv := &http.Server{Addr: cfg.listenAddr(), Handler: handler, TLSConfig: tlsConfig}
+	go func() {
+		slog.Info("Server starting", "url", cfg.scheme()+"://"+cfg.listenAddr())
+		var err error
+		if tlsConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("Error starting serving server", "err", err)
+			os.Exit(1)
+		}
+	}()
+	if cfg.TLS.RedirectPort > 0 {
+		startRedirectServer(cfg)
+	}
+
+	// Wait for systemd (SIGTERM) or Ctrl-C (SIGINT), then drain.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-stop
+	slog.Info("Shutting down", "signal", sig.String())
+	if err := gracefulShutdown(d, srv, shutdownTimeout); err != nil {
+		os.Exit(1)
+	}
 }
 
+// Units a Metric's raw value can be in. Percentages are stored as fractions.
+const (
+	unitRatio    = "ratio"
+	unitPercent  = "percent"
+	unitCurrency = "currency"
+	unitShares   = "shares"
+	unitDays     = "days"
+	unitCoins    = "coins"
+	// An exchange rate, which needs more decimals than a price.
+	unitRate = "rate"
+)
+
 type Metric struct {
-	Name   string
-	Value  string
-	Color  string
+	Name  string
+	Raw   float64
+	Value string
+	Unit  string
+	Color string
+	// Reason is HTML, as rendered on the metric card.
 	Reason string
+	// Nil for informational metrics that are not scored.
+	Threshold *MetricThreshold
+	// ISO code of a currency metric's value, once localized.
+	Currency string
+	// Explains a currency conversion, if one was applied.
+	Note string
+	// Recent daily values, oldest first, and the color a week ago. Only set
+	// on the stock page.
+	Trend        []float64
+	WeekAgoColor string
 }
 
 // Makes the metric presentable for a Metric Card.
-func buildMetricCardInformation(name string, value *float64, isPercent bool) *Metric {
+func buildMetricCardInformation(name string, value *float64, unit string) *Metric {
 	if value == nil {
 		return nil
 	}
 	var valueStr string
-	if isPercent {
+	switch unit {
+	case unitPercent:
 		valueStr = fmt.Sprintf("%.2f%%", *value*100)
-	} else {
+	case unitRate:
+		valueStr = fmt.Sprintf("%.4f", *value)
+	default:
 		// for large numbers (marketcap, ev, fcf) show compact formatting
 		valueStr = common.FormatLargeNumber(*value)
 	}
 	color, reason := getColorAndReasonForMetric(name, *value)
-	return &Metric{Name: name, Value: valueStr, Color: color, Reason: reason}
+	return &Metric{
+		Name:      name,
+		Raw:       *value,
+		Value:     valueStr,
+		Unit:      unit,
+		Color:     color,
+		Reason:    reason,
+		Threshold: metricThreshold(name),
+	}
 }
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// buildOpenAPIDocument generates an OpenAPI 3 document from the route table.
+// Schemas are derived from the response structs' json tags, with the optional
+// `doc` and `enum` struct tags used for descriptions and enumerations.
+func buildOpenAPIDocument(routes []apiRoute) map[string]interface{} {
+	schemas := map[string]interface{}{}
+	errorRef := schemaFor(reflect.TypeOf(APIError{}), schemas)
+
+	paths := map[string]interface{}{}
+	for _, route := range routes {
+		params := queryParams(route.Params)
+		op := map[string]interface{}{
+			"summary": route.Summary,
+			"responses": map[string]interface{}{
+				"200": jsonResponse("OK", schemaFor(route.Response, schemas)),
+				"401": jsonResponse("Missing or invalid API key", errorRef),
+				"429": jsonResponse("Rate limited; see the Retry-After header", errorRef),
+			},
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+			responses := op["responses"].(map[string]interface{})
+			responses["400"] = jsonResponse("Missing or invalid parameter", errorRef)
+			responses["404"] = jsonResponse("Unknown symbol", errorRef)
+			responses["502"] = jsonResponse("Upstream fetch failed", errorRef)
+		}
+		item := map[string]interface{}{"get": op}
+		if route.RequestBody != nil {
+			post := map[string]interface{}{}
+			for k, v := range op {
+				post[k] = v
+			}
+			// Params carried by the body, like batch's symbols, only apply to GET.
+			delete(post, "parameters")
+			if params := queryParams(withoutBodyFields(route.Params, route.RequestBody)); len(params) > 0 {
+				post["parameters"] = params
+			}
+			post["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemaFor(route.RequestBody, schemas)},
+				},
+			}
+			item["post"] = post
+		}
+		if route.DeleteParams != nil {
+			del := map[string]interface{}{}
+			for k, v := range op {
+				del[k] = v
+			}
+			del["parameters"] = queryParams(route.DeleteParams)
+			del["responses"] = map[string]interface{}{
+				"200": jsonResponse("OK", schemaFor(route.Response, schemas)),
+				"400": jsonResponse("Missing or invalid parameter", errorRef),
+				"401": jsonResponse("Missing or invalid API key", errorRef),
+				"404": jsonResponse("Not found", errorRef),
+				"429": jsonResponse("Rate limited; see the Retry-After header", errorRef),
+			}
+			item["delete"] = del
+		}
+		paths[route.Path] = item
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Stock Analysis API",
+			"version": VERSION,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"apiKeyHeader": map[string]interface{}{"type": "apiKey", "in": "header", "name": apiKeyHeader},
+				"apiKeyQuery":  map[string]interface{}{"type": "apiKey", "in": "query", "name": apiKeyQueryParam},
+			},
+		},
+		// Only enforced when the server runs with auth.mode: api_key.
+		"security": []interface{}{
+			map[string]interface{}{"apiKeyHeader": []string{}},
+			map[string]interface{}{"apiKeyQuery": []string{}},
+		},
+	}
+}
+
+func queryParams(list []apiParam) []interface{} {
+	var params []interface{}
+	for _, p := range list {
+		params = append(params, map[string]interface{}{
+			"name":        p.Name,
+			"in":          "query",
+			"required":    p.Required,
+			"description": p.Description,
+			"schema":      map[string]interface{}{"type": "string"},
+		})
+	}
+	return params
+}
+
+func withoutBodyFields(list []apiParam, body reflect.Type) []apiParam {
+	fields := map[string]bool{}
+	for i := 0; i < body.NumField(); i++ {
+		if name, _ := jsonFieldName(body.Field(i)); name != "" {
+			fields[name] = true
+		}
+	}
+	var kept []apiParam
+	for _, p := range list {
+		if !fields[p.Name] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+func jsonResponse(description string, schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+// schemaFor returns the schema for t. Named structs are added to schemas and
+// referenced with $ref.
+func schemaFor(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaFor(t.Elem(), schemas)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := schemas[t.Name()]; ok {
+			return ref
+		}
+		// Reserve the name first so recursive types terminate.
+		schemas[t.Name()] = nil
+		properties := map[string]interface{}{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, omitempty := jsonFieldName(f)
+			if name == "" {
+				continue
+			}
+			prop := schemaFor(f.Type, schemas)
+			if _, isRef := prop["$ref"]; !isRef {
+				if doc := f.Tag.Get("doc"); doc != "" {
+					prop["description"] = doc
+				}
+				if enum := f.Tag.Get("enum"); enum != "" {
+					prop["enum"] = strings.Split(enum, ",")
+				}
+			}
+			properties[name] = prop
+			if !omitempty {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		schemas[t.Name()] = schema
+		return ref
+	}
+	return map[string]interface{}{}
+}
+
+func jsonFieldName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = f.Name
+	}
+	omitempty := false
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitempty = true
+		}
+	}
+	return name, omitempty
+}
+
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	writeAPIJSON(w, http.StatusOK, buildOpenAPIDocument(apiV1Routes()))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	rec := httptest.NewRecorder()
+	openAPIHandler(rec, httptest.NewRequest("GET", "/api/v1/openapi.json", nil))
+
+	var doc struct {
+		OpenAPI string                            `json:"openapi"`
+		Paths   map[string]map[string]interface{} `json:"paths"`
+		Comps   struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]interface{} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+	for _, route := range apiV1Routes() {
+		if _, ok := doc.Paths[route.Path]["get"]; !ok {
+			t.Errorf("path %s missing from document", route.Path)
+		}
+	}
+	post, _ := doc.Paths[apiV1Prefix+"batch"]["post"].(map[string]interface{})
+	params, _ := post["parameters"].([]interface{})
+	if len(params) != 1 || params[0].(map[string]interface{})["name"] != "stream" {
+		t.Errorf("batch POST parameters = %v, want only stream", params)
+	}
+	if _, ok := doc.Paths[apiV1Prefix+"alerts"]["delete"]; !ok {
+		t.Error("alerts has no delete operation")
+	}
+	for _, name := range []string{"APIStock", "APIQuote", "APIFundamentals", "APIMetric", "APIError"} {
+		if _, ok := doc.Comps.Schemas[name]; !ok {
+			t.Errorf("schema %s missing", name)
+		}
+	}
+	color := doc.Comps.Schemas["APIMetric"].Properties["color"]
+	if enum, ok := color["enum"].([]interface{}); !ok || len(enum) != 3 {
+		t.Errorf("APIMetric.color enum = %v", color["enum"])
+	}
+	if ref := doc.Comps.Schemas["APIStock"].Properties["quote"]["$ref"]; ref != "#/components/schemas/APIQuote" {
+		t.Errorf("APIStock.quote $ref = %v", ref)
+	}
+}
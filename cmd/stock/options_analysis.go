@@ -0,0 +1,457 @@
+package main
+
+import (
+	"app/internal/blackscholes"
+	common "app/internal/common"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	g "maragu.dev/gomponents"
+	// Importing this as '.' is intentional for cleaner HTML like code.
+	. "maragu.dev/gomponents/html"
+)
+
+// Used for the Greeks and implied volatility. Set from the config file.
+var optionsRiskFreeRate = 0.04
+
+// Yahoo dates an expiration at midnight UTC; US options stop trading at the
+// 4pm Eastern close that day.
+const expiryCloseOffset = 20 * time.Hour
+
+const expirationDateParam = "date"
+
+var errInvalidExpiration = errors.New("date must be an expiration date as YYYY-MM-DD")
+
+type APIOptionContract struct {
+	ContractSymbol string  `json:"contract_symbol"`
+	Strike         float64 `json:"strike"`
+	LastPrice      float64 `json:"last_price"`
+	Bid            float64 `json:"bid"`
+	Ask            float64 `json:"ask"`
+	Volume         float64 `json:"volume"`
+	OpenInterest   float64 `json:"open_interest"`
+	InTheMoney     bool    `json:"in_the_money"`
+	// Ours, from the bid/ask midpoint. Yahoo's is kept for comparison.
+	ImpliedVolatility      float64 `json:"implied_volatility" doc:"Black-Scholes implied volatility of the bid/ask midpoint, or the last price without a quote. 0 when no volatility fits the price."`
+	YahooImpliedVolatility float64 `json:"yahoo_implied_volatility"`
+	Delta                  float64 `json:"delta"`
+	Gamma                  float64 `json:"gamma"`
+	Theta                  float64 `json:"theta" doc:"Per calendar day."`
+	Vega                   float64 `json:"vega" doc:"Per volatility point."`
+}
+
+type APIOptionsSummary struct {
+	CallVolume       float64 `json:"call_volume"`
+	PutVolume        float64 `json:"put_volume"`
+	CallOpenInterest float64 `json:"call_open_interest"`
+	PutOpenInterest  float64 `json:"put_open_interest"`
+	// 0 when there are no calls to divide by.
+	PutCallVolumeRatio       float64 `json:"put_call_volume_ratio"`
+	PutCallOpenInterestRatio float64 `json:"put_call_open_interest_ratio"`
+	MaxPain                  float64 `json:"max_pain" doc:"The strike at which expiring options would pay holders the least."`
+}
+
+type APIOptions struct {
+	Symbol          string              `json:"symbol"`
+	Currency        string              `json:"currency"`
+	UnderlyingPrice float64             `json:"underlying_price"`
+	Expiration      string              `json:"expiration" doc:"YYYY-MM-DD. Empty when the symbol has no listed options."`
+	Expirations     []string            `json:"expirations" doc:"Every listed expiration, YYYY-MM-DD."`
+	DaysToExpiry    float64             `json:"days_to_expiry"`
+	RiskFreeRate    float64             `json:"risk_free_rate"`
+	DividendYield   float64             `json:"dividend_yield"`
+	Summary         APIOptionsSummary   `json:"summary"`
+	Calls           []APIOptionContract `json:"calls"`
+	Puts            []APIOptionContract `json:"puts"`
+}
+
+// APIOptionsNext summarizes the nearest expiration for the stock page and
+// /api/v1/stock.
+type APIOptionsNext struct {
+	Expiration   string            `json:"expiration" doc:"YYYY-MM-DD."`
+	DaysToExpiry float64           `json:"days_to_expiry"`
+	Summary      APIOptionsSummary `json:"summary"`
+}
+
+func toAPIOptionsNext(result *Result, now time.Time) *APIOptionsNext {
+	if result.Options == nil || len(result.Options.Options) == 0 {
+		return nil
+	}
+	exp := result.Options.Options[0]
+	expiry := time.Unix(exp.ExpirationDate, 0).Add(expiryCloseOffset)
+	return &APIOptionsNext{
+		Expiration:   expirationDate(exp.ExpirationDate),
+		DaysToExpiry: math.Max(expiry.Sub(now).Hours()/24, 0),
+		Summary:      summarizeOptions(exp),
+	}
+}
+
+func expirationDate(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format("2006-01-02")
+}
+
+// parseExpiration turns a date parameter into the unix time Yahoo lists the
+// expiration under, or 0 for the nearest expiration.
+func parseExpiration(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return 0, errInvalidExpiration
+	}
+	return t.Unix(), nil
+}
+
+// loadOptions fetches ticker's chain for an expiration, 0 meaning the
+// nearest, and analyses it.
+func loadOptions(ctx context.Context, ticker common.Symbol, expiration int64) (*APIOptions, error) {
+	chain, err := fetchOptionChain(ctx, ticker, expiration)
+	if err != nil {
+		return nil, err
+	}
+	return analyzeOptions(ticker.String(), chain, time.Now()), nil
+}
+
+func analyzeOptions(symbol string, chain *OptionChain, now time.Time) *APIOptions {
+	o := &APIOptions{
+		Symbol:          symbol,
+		Currency:        chain.Quote.Currency,
+		UnderlyingPrice: chain.Quote.RegularMarketPrice,
+		Expirations:     []string{},
+		RiskFreeRate:    optionsRiskFreeRate,
+		DividendYield:   chain.Quote.TrailingAnnualDividendYield,
+		Calls:           []APIOptionContract{},
+		Puts:            []APIOptionContract{},
+	}
+	for _, e := range chain.ExpirationDates {
+		o.Expirations = append(o.Expirations, expirationDate(e))
+	}
+	if len(chain.Options) == 0 {
+		return o
+	}
+
+	exp := chain.Options[0]
+	o.Expiration = expirationDate(exp.ExpirationDate)
+	expiry := time.Unix(exp.ExpirationDate, 0).Add(expiryCloseOffset)
+	o.DaysToExpiry = math.Max(expiry.Sub(now).Hours()/24, 0)
+	params := blackscholes.Params{
+		Spot:     o.UnderlyingPrice,
+		Time:     o.DaysToExpiry / 365,
+		Rate:     o.RiskFreeRate,
+		Dividend: o.DividendYield,
+	}
+	for _, c := range exp.Calls {
+		o.Calls = append(o.Calls, analyzeContract(blackscholes.Call, params, c))
+	}
+	for _, p := range exp.Puts {
+		o.Puts = append(o.Puts, analyzeContract(blackscholes.Put, params, p))
+	}
+	o.Summary = summarizeOptions(exp)
+	return o
+}
+
+// analyzeContract works out the contract's implied volatility and Greeks.
+// When no volatility fits its price, the Greeks use Yahoo's.
+func analyzeContract(kind blackscholes.Kind, params blackscholes.Params, c OptionContract) APIOptionContract {
+	out := APIOptionContract{
+		ContractSymbol:         c.ContractSymbol,
+		Strike:                 c.Strike,
+		LastPrice:              c.LastPrice,
+		Bid:                    c.Bid,
+		Ask:                    c.Ask,
+		Volume:                 c.Volume,
+		OpenInterest:           c.OpenInterest,
+		InTheMoney:             c.InTheMoney,
+		YahooImpliedVolatility: c.ImpliedVolatility,
+	}
+	params.Strike = c.Strike
+	price := c.LastPrice
+	if c.Bid > 0 && c.Ask > 0 {
+		price = (c.Bid + c.Ask) / 2
+	}
+	if iv, err := blackscholes.ImpliedVolatility(kind, params, price); err == nil {
+		out.ImpliedVolatility = iv
+		params.Volatility = iv
+	} else {
+		params.Volatility = c.ImpliedVolatility
+	}
+	if params.Spot <= 0 || params.Strike <= 0 {
+		return out
+	}
+	greeks := params.Greeks(kind)
+	out.Delta = greeks.Delta
+	out.Gamma = greeks.Gamma
+	out.Theta = greeks.Theta / 365
+	out.Vega = greeks.Vega / 100
+	return out
+}
+
+func summarizeOptions(exp OptionExpiration) APIOptionsSummary {
+	var s APIOptionsSummary
+	for _, c := range exp.Calls {
+		s.CallVolume += c.Volume
+		s.CallOpenInterest += c.OpenInterest
+	}
+	for _, p := range exp.Puts {
+		s.PutVolume += p.Volume
+		s.PutOpenInterest += p.OpenInterest
+	}
+	if s.CallVolume > 0 {
+		s.PutCallVolumeRatio = s.PutVolume / s.CallVolume
+	}
+	if s.CallOpenInterest > 0 {
+		s.PutCallOpenInterestRatio = s.PutOpenInterest / s.CallOpenInterest
+	}
+	s.MaxPain = maxPain(exp)
+	return s
+}
+
+// maxPain is the strike the underlying could expire at for open contracts
+// to pay their holders the least. Only listed strikes are tried, since the
+// payout is linear between them.
+func maxPain(exp OptionExpiration) float64 {
+	strikes := map[float64]bool{}
+	for _, c := range exp.Calls {
+		strikes[c.Strike] = true
+	}
+	for _, p := range exp.Puts {
+		strikes[p.Strike] = true
+	}
+	var sorted []float64
+	for k := range strikes {
+		sorted = append(sorted, k)
+	}
+	sort.Float64s(sorted)
+
+	best, bestPayout := 0.0, math.Inf(1)
+	for _, settle := range sorted {
+		var payout float64
+		for _, c := range exp.Calls {
+			payout += c.OpenInterest * math.Max(settle-c.Strike, 0)
+		}
+		for _, p := range exp.Puts {
+			payout += p.OpenInterest * math.Max(p.Strike-settle, 0)
+		}
+		if payout < bestPayout {
+			best, bestPayout = settle, payout
+		}
+	}
+	return best
+}
+
+// optionsRequest reads the symbol and date parameters. message is empty when
+// they're fine.
+func optionsRequest(r *http.Request) (ticker common.Symbol, expiration int64, code, message string) {
+	raw := r.URL.Query().Get("symbol")
+	if strings.TrimSpace(raw) == "" {
+		return "", 0, errCodeMissingSymbol, "symbol parameter required"
+	}
+	ticker, err := common.ParseSymbol(raw)
+	if err != nil {
+		return "", 0, errCodeInvalidSymbol, err.Error()
+	}
+	if expiration, err = parseExpiration(r.URL.Query().Get(expirationDateParam)); err != nil {
+		return "", 0, errCodeInvalidDate, err.Error()
+	}
+	return ticker, expiration, "", ""
+}
+
+func apiV1OptionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "only GET is supported")
+		return
+	}
+	ticker, expiration, code, message := optionsRequest(r)
+	if message != "" {
+		writeAPIError(w, http.StatusBadRequest, code, message)
+		return
+	}
+	options, err := loadOptions(r.Context(), ticker, expiration)
+	var busy *upstreamBusyError
+	switch {
+	case errors.Is(err, errInvalidExpiration):
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidDate, err.Error())
+	case errors.Is(err, ErrSymbolNotFound):
+		writeAPIError(w, http.StatusNotFound, errCodeSymbolNotFound, err.Error())
+	case errors.As(err, &busy):
+		writeRateLimited(w, r, busy.retryAfter)
+	case err != nil:
+		writeAPIError(w, http.StatusBadGateway, errCodeUpstream, err.Error())
+	default:
+		writeAPIJSON(w, http.StatusOK, options)
+	}
+}
+
+func optionsHandler(w http.ResponseWriter, r *http.Request) {
+	if strings.TrimSpace(r.URL.Query().Get("symbol")) == "" {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	ticker, expiration, _, message := optionsRequest(r)
+	w.Header().Set("Content-Type", "text/html")
+	if message != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		errorPage("Invalid Request", message, r.URL.Query().Get("symbol")).Render(w)
+		return
+	}
+	options, err := loadOptions(r.Context(), ticker, expiration)
+	var busy *upstreamBusyError
+	if errors.As(err, &busy) {
+		writeRateLimited(w, r, busy.retryAfter)
+		return
+	}
+	if err != nil {
+		if errors.Is(err, errInvalidExpiration) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else if errors.Is(err, ErrSymbolNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		errorPage("Error Fetching Options", fmt.Sprint(err), ticker.String()).Render(w)
+		return
+	}
+	optionsPage(options, currentUser(r.Context())).Render(w)
+}
+
+func optionsSummaryBox(label, value string) g.Node {
+	return Div(Class("bg-gray-800 rounded-lg border border-gray-700 p-3"),
+		P(Class("text-xs text-gray-400"), g.Text(label)),
+		P(Class("text-xl font-semibold text-white"), g.Text(value)),
+	)
+}
+
+// optionsNextSection sums up the nearest expiration on the stock page.
+func optionsNextSection(symbol string, result *Result, now time.Time) g.Node {
+	o := toAPIOptionsNext(result, now)
+	if o == nil {
+		return nil
+	}
+	return Div(Class("mt-8"),
+		H2(Class("text-2xl font-bold text-white mb-2"), g.Text("Options")),
+		P(Class("text-gray-400 mb-4"),
+			g.Text(fmt.Sprintf("Nearest expiration %s, %.0f days out. ", o.Expiration, o.DaysToExpiry)),
+			A(Href("/options?symbol="+url.QueryEscape(symbol)), Class("text-blue-400 hover:underline"), g.Text("Full chain →")),
+		),
+		Div(Class("grid grid-cols-2 md:grid-cols-3 gap-4"),
+			optionsSummaryBox("Put/Call Volume", ratioText(o.Summary.PutCallVolumeRatio)),
+			optionsSummaryBox("Put/Call Open Interest", ratioText(o.Summary.PutCallOpenInterestRatio)),
+			optionsSummaryBox("Max Pain", common.FormatCurrency(o.Summary.MaxPain, result.Options.Quote.Currency)),
+		),
+	)
+}
+
+func ratioText(v float64) string {
+	if v == 0 {
+		return "—"
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+func optionsTable(title string, contracts []APIOptionContract, currency string) g.Node {
+	if len(contracts) == 0 {
+		return fundSection(title, P(Class("text-sm text-gray-500"), g.Text("No contracts listed for this expiration.")))
+	}
+	header := Tr(Class("text-gray-400 text-xs"),
+		g.Map([]string{"Strike", "Last", "Bid", "Ask", "Volume", "Open Int.", "IV", "Delta", "Gamma", "Theta/day", "Vega/pt"}, func(h string) g.Node {
+			return Th(Class("py-1 px-2 text-right font-medium"), g.Text(h))
+		}),
+	)
+	var rows []g.Node
+	for _, c := range contracts {
+		rowClass := "border-t border-gray-700"
+		if c.InTheMoney {
+			rowClass += " bg-gray-700/50"
+		}
+		iv := "—"
+		if c.ImpliedVolatility > 0 {
+			iv = percentText(c.ImpliedVolatility)
+		}
+		rows = append(rows, Tr(Class(rowClass),
+			g.Map([]string{
+				common.CurrencySymbol(currency) + fmt.Sprintf("%.2f", c.Strike),
+				fmt.Sprintf("%.2f", c.LastPrice),
+				fmt.Sprintf("%.2f", c.Bid),
+				fmt.Sprintf("%.2f", c.Ask),
+				fmt.Sprintf("%.0f", c.Volume),
+				fmt.Sprintf("%.0f", c.OpenInterest),
+				iv,
+				fmt.Sprintf("%.3f", c.Delta),
+				fmt.Sprintf("%.4f", c.Gamma),
+				fmt.Sprintf("%.3f", c.Theta),
+				fmt.Sprintf("%.3f", c.Vega),
+			}, func(v string) g.Node {
+				return Td(Class("py-1 px-2 text-right tabular-nums"), g.Text(v))
+			}),
+		))
+	}
+	return fundSection(title,
+		Div(Class("overflow-x-auto"),
+			Table(Class("w-full text-sm"), THead(header), TBody(g.Group(rows))),
+		),
+	)
+}
+
+func optionsPage(o *APIOptions, user *User) g.Node {
+	var expirations []g.Node
+	for _, e := range o.Expirations {
+		class := "px-3 py-1 text-xs rounded bg-gray-700 text-gray-300 hover:bg-gray-600"
+		if e == o.Expiration {
+			class = "px-3 py-1 text-xs rounded bg-blue-600 text-white"
+		}
+		href := "/options?symbol=" + url.QueryEscape(o.Symbol) + "&" + expirationDateParam + "=" + e
+		expirations = append(expirations, A(Href(href), Class(class), g.Text(e)))
+	}
+
+	return HTML(
+		Head(
+			Meta(Charset("UTF-8")),
+			Meta(Name("viewport"), Content("width=device-width, initial-scale=1.0")),
+			TitleEl(g.Text(fmt.Sprintf("%s - Options", o.Symbol))),
+			Script(Src("https://cdn.tailwindcss.com")),
+			Script(g.Raw(`tailwind.config = { theme: { extend: { colors: { darkbg: '#1a1a1a' } } } }`)),
+		),
+		Body(Class("bg-darkbg text-gray-200 min-h-screen"),
+			Div(Class("container mx-auto px-4 py-8"),
+				userBadge(user),
+				Div(Class("mb-6 flex items-center justify-between"),
+					Div(
+						H1(Class("text-4xl font-bold text-white mb-2"), g.Text(o.Symbol+" Options")),
+						P(Class("text-gray-400"), g.Text(fmt.Sprintf("Underlying %s · %.0f days to expiry", common.FormatCurrency(o.UnderlyingPrice, o.Currency), o.DaysToExpiry))),
+					),
+					Div(Class("flex flex-col items-end gap-2"),
+						A(Href("/stock?symbol="+url.QueryEscape(o.Symbol)), Class("text-blue-400 hover:underline text-sm"), g.Text("← Back to "+o.Symbol)),
+					),
+				),
+				g.If(len(o.Expirations) == 0,
+					P(Class("text-gray-400"), g.Text("Yahoo Finance lists no options for this symbol.")),
+				),
+				g.If(len(o.Expirations) > 0, g.Group([]g.Node{
+					Div(Class("mb-6 flex flex-wrap gap-2"), g.Group(expirations)),
+					Div(Class("grid grid-cols-2 md:grid-cols-5 gap-4 mb-6"),
+						optionsSummaryBox("Put/Call Volume", ratioText(o.Summary.PutCallVolumeRatio)),
+						optionsSummaryBox("Put/Call Open Interest", ratioText(o.Summary.PutCallOpenInterestRatio)),
+						optionsSummaryBox("Max Pain", common.FormatCurrency(o.Summary.MaxPain, o.Currency)),
+						optionsSummaryBox("Call Volume", fmt.Sprintf("%.0f", o.Summary.CallVolume)),
+						optionsSummaryBox("Put Volume", fmt.Sprintf("%.0f", o.Summary.PutVolume)),
+					),
+					Div(Class("grid grid-cols-1 gap-6"),
+						optionsTable("Calls", o.Calls, o.Currency),
+						optionsTable("Puts", o.Puts, o.Currency),
+					),
+				})),
+				Div(Class("mt-8 p-4 bg-gray-800 rounded-lg border border-gray-700"),
+					P(Class("text-sm text-gray-400"),
+						g.Text(fmt.Sprintf("Implied volatility and Greeks are Black-Scholes values from the bid/ask midpoint, with a %.2f%% risk-free rate and the trailing dividend yield. US equity options are American, so treat them as estimates. Shaded rows are in the money.", o.RiskFreeRate*100)),
+					),
+				),
+			),
+		),
+	)
+}
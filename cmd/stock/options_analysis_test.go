@@ -0,0 +1,193 @@
+package main
+
+import (
+	"app/internal/blackscholes"
+	common "app/internal/common"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A trimmed down options response for AAPL, expiring 2025-11-20.
+const testOptionsResponse = `{"optionChain":{"result":[{
+	"underlyingSymbol":"AAPL","expirationDates":[1763596800,1764201600],"strikes":[180,190,200],
+	"quote":{"regularMarketPrice":190,"currency":"USD","trailingAnnualDividendYield":0.005},
+	"options":[{"expirationDate":1763596800,
+		"calls":[{"contractSymbol":"AAPL251120C00180000","strike":180,"lastPrice":12.5,"bid":12.2,"ask":12.6,"volume":100,"openInterest":1000,"impliedVolatility":0.3,"inTheMoney":true},
+			{"contractSymbol":"AAPL251120C00190000","strike":190,"lastPrice":5.1,"bid":5,"ask":5.2,"volume":300,"openInterest":3000,"impliedVolatility":0.28},
+			{"contractSymbol":"AAPL251120C00200000","strike":200,"lastPrice":1.5,"bid":0,"ask":0,"volume":50,"openInterest":500,"impliedVolatility":0.27}],
+		"puts":[{"contractSymbol":"AAPL251120P00180000","strike":180,"lastPrice":1.2,"bid":1.1,"ask":1.3,"volume":80,"openInterest":2000,"impliedVolatility":0.32},
+			{"contractSymbol":"AAPL251120P00200000","strike":200,"lastPrice":11,"bid":10.8,"ask":11.2,"volume":20,"openInterest":100,"impliedVolatility":0.29,"inTheMoney":true}]
+	}]
+}],"error":null}}`
+
+func testOptionChain(t *testing.T) *OptionChain {
+	chain, err := parseOptionChain("AAPL", []byte(testOptionsResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chain
+}
+
+func TestAnalyzeOptions(t *testing.T) {
+	// 30 days before the 20:00 UTC close on the expiration date.
+	now := time.Date(2025, 11, 20, 20, 0, 0, 0, time.UTC).Add(-30 * 24 * time.Hour)
+	o := analyzeOptions("AAPL", testOptionChain(t), now)
+
+	if o.Expiration != "2025-11-20" || strings.Join(o.Expirations, ",") != "2025-11-20,2025-11-27" || o.DaysToExpiry != 30 {
+		t.Errorf("expiration %s of %v, %v days", o.Expiration, o.Expirations, o.DaysToExpiry)
+	}
+	s := o.Summary
+	if s.CallVolume != 450 || s.PutVolume != 100 || s.PutCallVolumeRatio != 100.0/450 || s.PutCallOpenInterestRatio != 2100.0/4500 {
+		t.Errorf("summary = %+v", s)
+	}
+	// Settling at 180 pays 0 on calls and 100*20 on the 200 put; at 190,
+	// 1000*10 on calls; at 200, 1000*20 + 3000*10 + 2000*0.
+	if s.MaxPain != 180 {
+		t.Errorf("max pain = %v, want 180", s.MaxPain)
+	}
+
+	atm := o.Calls[1]
+	params := blackscholes.Params{Spot: 190, Strike: 190, Time: 30.0 / 365, Rate: optionsRiskFreeRate, Dividend: 0.005, Volatility: atm.ImpliedVolatility}
+	if math.Abs(params.Price(blackscholes.Call)-5.1) > 1e-4 {
+		t.Errorf("IV %v doesn't reprice the 5.10 midpoint", atm.ImpliedVolatility)
+	}
+	if atm.Delta < 0.5 || atm.Delta > 0.6 || atm.Theta >= 0 || atm.Vega <= 0 || atm.Gamma <= 0 {
+		t.Errorf("ATM call Greeks = %+v", atm)
+	}
+	if put := o.Puts[1]; put.Delta >= -0.5 {
+		t.Errorf("ITM put delta = %v", put.Delta)
+	}
+	// No bid or ask, so priced off the last trade.
+	if otm := o.Calls[2]; otm.ImpliedVolatility == 0 || otm.YahooImpliedVolatility != 0.27 {
+		t.Errorf("OTM call = %+v", otm)
+	}
+}
+
+func TestAnalyzeOptionsNoChain(t *testing.T) {
+	o := analyzeOptions("XYZ", &OptionChain{}, time.Now())
+	if o.Expiration != "" || len(o.Expirations) != 0 || o.Calls == nil || o.Puts == nil {
+		t.Errorf("options = %+v", o)
+	}
+}
+
+func TestGetOptionChain(t *testing.T) {
+	var paths []string
+	withFakeYahoo(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path+"?date="+r.URL.Query().Get("date")+"&crumb="+r.URL.Query().Get("crumb"))
+		w.Write([]byte(testOptionsResponse))
+	})
+
+	for _, expiration := range []int64{0, 1763596800, 1764201600, 1764201600} {
+		chain, err := getOptionChain(context.Background(), "AAPL", expiration)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(chain.Options) != 1 || len(chain.Options[0].Calls) != 3 {
+			t.Errorf("chain = %+v", chain)
+		}
+	}
+	// The nearest expiration is the default chain, so it isn't fetched twice.
+	want := "/v7/finance/options/AAPL?date=&crumb=crumb1 /v7/finance/options/AAPL?date=1764201600&crumb=crumb1"
+	if strings.Join(paths, " ") != want {
+		t.Errorf("requests = %v, want one per chain, cached after", paths)
+	}
+
+	if _, err := getOptionChain(context.Background(), "AAPL", 1767225600); !errors.Is(err, errInvalidExpiration) {
+		t.Errorf("unlisted expiration err = %v, want errInvalidExpiration", err)
+	}
+	if len(optionChainCache) != 2 {
+		t.Errorf("cached %d chains, want 2", len(optionChainCache))
+	}
+}
+
+func TestOptionChainCacheIsBounded(t *testing.T) {
+	withFakeYahoo(t, nil)
+	for i := 0; i < maxCachedOptionChains+10; i++ {
+		rememberOptionChain(optionChainKey{common.Symbol(fmt.Sprintf("S%d", i)), 0}, &OptionChain{})
+	}
+	if n := len(optionChainCache); n > maxCachedOptionChains {
+		t.Errorf("cache holds %d chains, want at most %d", n, maxCachedOptionChains)
+	}
+}
+
+func TestGetStockMetricsAddsNearestOptions(t *testing.T) {
+	withFakeYahoo(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v7/finance/options/") {
+			w.Write([]byte(testOptionsResponse))
+			return
+		}
+		w.Write([]byte(testQuoteSummary))
+	})
+
+	result, err := getStockMetrics(context.Background(), "AAPL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := toAPIOptionsNext(result, time.Date(2025, 11, 10, 0, 0, 0, 0, time.UTC))
+	if next == nil || next.Expiration != "2025-11-20" || next.Summary.MaxPain != 180 {
+		t.Errorf("nearest options = %+v", next)
+	}
+	var b strings.Builder
+	optionsNextSection("AAPL", result, time.Now()).Render(&b)
+	if !strings.Contains(b.String(), "Max Pain") || !strings.Contains(b.String(), "/options?symbol=AAPL") {
+		t.Errorf("stock page options section = %s", b.String())
+	}
+}
+
+func TestOptionsHandlers(t *testing.T) {
+	orig := fetchOptionChain
+	fetchOptionChain = func(_ context.Context, ticker common.Symbol, expiration int64) (*OptionChain, error) {
+		if ticker != "AAPL" {
+			return nil, ErrSymbolNotFound
+		}
+		if expiration != 0 && expiration != 1763596800 {
+			return nil, errInvalidExpiration
+		}
+		return parseOptionChain(ticker, []byte(testOptionsResponse))
+	}
+	t.Cleanup(func() { fetchOptionChain = orig })
+
+	rec := httptest.NewRecorder()
+	apiV1OptionsHandler(rec, httptest.NewRequest("GET", "/api/v1/options?symbol=aapl&date=2025-11-20", nil))
+	var got APIOptions
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("options = %d %s", rec.Code, rec.Body.String())
+	}
+	if got.Symbol != "AAPL" || len(got.Calls) != 3 || len(got.Puts) != 2 || got.Summary.MaxPain != 180 {
+		t.Errorf("options = %+v", got)
+	}
+
+	for _, tt := range []struct {
+		query string
+		code  int
+		err   string
+	}{
+		{"", http.StatusBadRequest, errCodeMissingSymbol},
+		{"symbol=AAPL&date=next-friday", http.StatusBadRequest, errCodeInvalidDate},
+		{"symbol=AAPL&date=2030-01-01", http.StatusBadRequest, errCodeInvalidDate},
+		{"symbol=NOPE", http.StatusNotFound, errCodeSymbolNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		apiV1OptionsHandler(rec, httptest.NewRequest("GET", "/api/v1/options?"+tt.query, nil))
+		if rec.Code != tt.code || !strings.Contains(rec.Body.String(), tt.err) {
+			t.Errorf("%q: %d %s", tt.query, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	optionsHandler(rec, httptest.NewRequest("GET", "/options?symbol=AAPL", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"AAPL Options", "Put/Call Volume", "Max Pain", "$180.00", "Calls", "Puts", `href="/options?symbol=AAPL&amp;date=2025-11-27"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("options page lacks %q", want)
+		}
+	}
+}
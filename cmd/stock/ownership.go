@@ -0,0 +1,277 @@
+package main
+
+import (
+	common "app/internal/common"
+	"fmt"
+	"strings"
+	"time"
+
+	g "maragu.dev/gomponents"
+	// Importing this as '.' is intentional for cleaner HTML like code.
+	. "maragu.dev/gomponents/html"
+)
+
+// Who owns a stock and whether its insiders are buying. Percentages and short
+// interest come from defaultKeyStatistics, holders from institutionOwnership
+// and fundOwnership, and transactions from insiderTransactions.
+
+type APIOwnership struct {
+	Insiders                float64              `json:"insiders" doc:"Fraction of shares held by insiders."`
+	Institutions            float64              `json:"institutions" doc:"Fraction of shares held by institutions."`
+	ShortInterest           float64              `json:"short_interest" doc:"Shares sold short at the last report."`
+	ShortInterestPriorMonth float64              `json:"short_interest_prior_month"`
+	ShortInterestChange     float64              `json:"short_interest_change" doc:"Month over month, as a fraction. 0 without a prior month."`
+	InstitutionalHolders    []APIOwnershipHolder `json:"institutional_holders" doc:"Largest first."`
+	FundHolders             []APIOwnershipHolder `json:"fund_holders" doc:"Largest first."`
+	InsiderActivity         []APIInsiderActivity `json:"insider_activity" doc:"Net insider buying over 3, 6 and 12 months."`
+	InsiderTransactions     []APIInsiderTrade    `json:"insider_transactions" doc:"Newest first, at most fifteen."`
+}
+
+type APIOwnershipHolder struct {
+	Name       string  `json:"name"`
+	Shares     float64 `json:"shares"`
+	Value      float64 `json:"value"`
+	Held       float64 `json:"held" doc:"Fraction of shares outstanding."`
+	Change     float64 `json:"change" doc:"Change in position since the previous report, as a fraction."`
+	ReportDate string  `json:"report_date" doc:"YYYY-MM-DD."`
+}
+
+type APIInsiderActivity struct {
+	Period string  `json:"period" enum:"3M,6M,12M"`
+	Bought float64 `json:"bought" doc:"Shares bought on the open market."`
+	Sold   float64 `json:"sold" doc:"Shares sold."`
+	Net    float64 `json:"net" doc:"Bought less sold; negative is net selling."`
+	Trades int     `json:"trades" doc:"Purchases and sales counted."`
+}
+
+type APIInsiderTrade struct {
+	Date     string  `json:"date" doc:"YYYY-MM-DD."`
+	Name     string  `json:"name"`
+	Relation string  `json:"relation" doc:"e.g. Chief Executive Officer."`
+	Kind     string  `json:"kind" enum:"buy,sell,other" doc:"Grants, gifts and option exercises are other."`
+	Shares   float64 `json:"shares"`
+	Value    float64 `json:"value" doc:"0 when Yahoo has no price, as for grants."`
+	Text     string  `json:"text"`
+}
+
+// recentInsiderTrades is how many insider transactions are listed.
+const recentInsiderTrades = 15
+
+var insiderPeriods = []struct {
+	name   string
+	months int
+}{{"3M", 3}, {"6M", 6}, {"12M", 12}}
+
+// insiderTradeKind tells purchases and sales apart from grants, gifts and
+// option exercises, which Yahoo only distinguishes in the description.
+func insiderTradeKind(text string) string {
+	switch {
+	case strings.HasPrefix(text, "Purchase"), strings.HasPrefix(text, "Buy"):
+		return "buy"
+	case strings.HasPrefix(text, "Sale"):
+		return "sell"
+	}
+	return "other"
+}
+
+func toAPIHolders(list []Holder) []APIOwnershipHolder {
+	holders := []APIOwnershipHolder{}
+	for _, h := range list {
+		holders = append(holders, APIOwnershipHolder{
+			Name:       h.Organization,
+			Shares:     h.Position.Raw,
+			Value:      h.Value.Raw,
+			Held:       h.PctHeld.Raw,
+			Change:     h.PctChange.Raw,
+			ReportDate: time.Unix(int64(h.ReportDate.Raw), 0).UTC().Format("2006-01-02"),
+		})
+	}
+	return holders
+}
+
+// toAPIOwnership returns nil for anything but stocks, and for stocks Yahoo
+// has no ownership data for. now bounds the insider activity periods.
+func toAPIOwnership(result *Result, now time.Time) *APIOwnership {
+	if result.quoteType() != quoteTypeEquity {
+		return nil
+	}
+	ks := result.DefaultKeyStatistics
+	institutional, funds := result.InstitutionOwnership.OwnershipList, result.FundOwnership.OwnershipList
+	trades := result.InsiderTransactions.Transactions
+	if ks.HeldPercentInsiders.Raw == 0 && ks.HeldPercentInstitutions.Raw == 0 && ks.SharesShort.Raw == 0 &&
+		len(institutional) == 0 && len(funds) == 0 && len(trades) == 0 {
+		return nil
+	}
+	o := &APIOwnership{
+		Insiders:                ks.HeldPercentInsiders.Raw,
+		Institutions:            ks.HeldPercentInstitutions.Raw,
+		ShortInterest:           ks.SharesShort.Raw,
+		ShortInterestPriorMonth: ks.SharesShortPriorMonth.Raw,
+		InstitutionalHolders:    toAPIHolders(institutional),
+		FundHolders:             toAPIHolders(funds),
+		InsiderActivity:         []APIInsiderActivity{},
+		InsiderTransactions:     []APIInsiderTrade{},
+	}
+	if o.ShortInterestPriorMonth > 0 {
+		o.ShortInterestChange = o.ShortInterest/o.ShortInterestPriorMonth - 1
+	}
+
+	for _, p := range insiderPeriods {
+		activity := APIInsiderActivity{Period: p.name}
+		since := now.AddDate(0, -p.months, 0)
+		for _, t := range trades {
+			if time.Unix(int64(t.StartDate.Raw), 0).Before(since) {
+				continue
+			}
+			switch insiderTradeKind(t.TransactionText) {
+			case "buy":
+				activity.Bought += t.Shares.Raw
+			case "sell":
+				activity.Sold += t.Shares.Raw
+			default:
+				continue
+			}
+			activity.Trades++
+		}
+		activity.Net = activity.Bought - activity.Sold
+		o.InsiderActivity = append(o.InsiderActivity, activity)
+	}
+
+	for _, t := range trades[:min(len(trades), recentInsiderTrades)] {
+		o.InsiderTransactions = append(o.InsiderTransactions, APIInsiderTrade{
+			Date:     time.Unix(int64(t.StartDate.Raw), 0).UTC().Format("2006-01-02"),
+			Name:     t.FilerName,
+			Relation: t.FilerRelation,
+			Kind:     insiderTradeKind(t.TransactionText),
+			Shares:   t.Shares.Raw,
+			Value:    t.Value.Raw,
+			Text:     t.TransactionText,
+		})
+	}
+	return o
+}
+
+// ownershipMetrics are the cards that need more than one field: the short
+// interest change and the last year's net insider buying as a fraction of the
+// shares outstanding.
+func ownershipMetrics(result *Result, now time.Time) []Metric {
+	o := toAPIOwnership(result, now)
+	if o == nil {
+		return nil
+	}
+	var metrics []Metric
+	if o.ShortInterestPriorMonth > 0 {
+		metrics = append(metrics, *buildMetricCardInformation("Short Interest Change", &o.ShortInterestChange, unitPercent))
+	}
+	year := o.InsiderActivity[len(o.InsiderActivity)-1]
+	if shares := result.DefaultKeyStatistics.SharesOutstanding.Raw; year.Trades > 0 && shares > 0 {
+		net := year.Net / shares
+		metrics = append(metrics, *buildMetricCardInformation("Insider Net Buying (12M)", &net, unitPercent))
+	}
+	return metrics
+}
+
+func holdersTable(holders []APIOwnershipHolder, empty string) g.Node {
+	if len(holders) == 0 {
+		return P(Class("text-sm text-gray-500"), g.Text(empty))
+	}
+	var rows []g.Node
+	for _, h := range holders {
+		rows = append(rows, Tr(Class("border-t border-gray-700"),
+			Td(Class("py-1 pr-2 text-gray-300"), g.Text(h.Name)),
+			Td(Class("py-1 pr-2 text-right"), g.Text(common.FormatLargeNumber(h.Shares))),
+			Td(Class("py-1 pr-2 text-right"), g.Text(percentText(h.Held))),
+			Td(Class("py-1 text-right "+returnClass(h.Change)), g.Text(fmt.Sprintf("%+.1f%%", h.Change*100))),
+		))
+	}
+	return Table(Class("w-full text-sm"),
+		THead(Tr(Class("text-gray-400 text-xs"),
+			Th(Class("text-left font-medium"), g.Text("Holder")),
+			Th(Class("text-right font-medium"), g.Text("Shares")),
+			Th(Class("text-right font-medium"), g.Text("Held")),
+			Th(Class("text-right font-medium"), g.Text("Change")),
+		)),
+		TBody(g.Group(rows)),
+	)
+}
+
+func insiderActivityTable(activity []APIInsiderActivity) g.Node {
+	var rows []g.Node
+	for _, a := range activity {
+		rows = append(rows, Tr(Class("border-t border-gray-700"),
+			Td(Class("py-1 pr-2 text-gray-300"), g.Text(a.Period)),
+			Td(Class("py-1 pr-2 text-right"), g.Text(common.FormatLargeNumber(a.Bought))),
+			Td(Class("py-1 pr-2 text-right"), g.Text(common.FormatLargeNumber(a.Sold))),
+			Td(Class("py-1 text-right "+returnClass(a.Net)), g.Text(common.FormatLargeNumber(a.Net))),
+		))
+	}
+	return Table(Class("w-full text-sm"),
+		THead(Tr(Class("text-gray-400 text-xs"),
+			Th(Class("text-left font-medium"), g.Text("Period")),
+			Th(Class("text-right font-medium"), g.Text("Bought")),
+			Th(Class("text-right font-medium"), g.Text("Sold")),
+			Th(Class("text-right font-medium"), g.Text("Net")),
+		)),
+		TBody(g.Group(rows)),
+	)
+}
+
+func insiderTradesTable(trades []APIInsiderTrade, currency string) g.Node {
+	if len(trades) == 0 {
+		return P(Class("text-sm text-gray-500"), g.Text("Yahoo Finance has no insider transactions."))
+	}
+	var rows []g.Node
+	for _, t := range trades {
+		kind, class := "Other", "text-gray-400"
+		switch t.Kind {
+		case "buy":
+			kind, class = "Buy", "text-green-300"
+		case "sell":
+			kind, class = "Sell", "text-red-300"
+		}
+		value := ""
+		if t.Value > 0 {
+			value = common.FormatCurrency(t.Value, currency)
+		}
+		rows = append(rows, Tr(Class("border-t border-gray-700"), TitleAttr(t.Text),
+			Td(Class("py-1 pr-2 text-gray-400"), g.Text(t.Date)),
+			Td(Class("py-1 pr-2 text-gray-300"), g.Text(t.Name), Div(Class("text-xs text-gray-500"), g.Text(t.Relation))),
+			Td(Class("py-1 pr-2 "+class), g.Text(kind)),
+			Td(Class("py-1 pr-2 text-right"), g.Text(common.FormatLargeNumber(t.Shares))),
+			Td(Class("py-1 text-right"), g.Text(value)),
+		))
+	}
+	return Table(Class("w-full text-sm"), TBody(g.Group(rows)))
+}
+
+// ownershipSection is the stock page's ownership panel, or nil without
+// ownership data.
+func ownershipSection(result *Result, now time.Time) g.Node {
+	o := toAPIOwnership(result, now)
+	if o == nil {
+		return nil
+	}
+	summary := fmt.Sprintf("Insiders hold %s and institutions %s.", percentText(o.Insiders), percentText(o.Institutions))
+	if o.ShortInterest > 0 {
+		summary += fmt.Sprintf(" %s shares are sold short", common.FormatLargeNumber(o.ShortInterest))
+		if o.ShortInterestPriorMonth > 0 {
+			summary += fmt.Sprintf(", %+.1f%% on the prior month", o.ShortInterestChange*100)
+		}
+		summary += "."
+	}
+	// Insider trades are in the currency the stock trades in.
+	currency, _ := result.tradingCurrency()
+
+	return Div(Class("mt-8"),
+		H2(Class("text-2xl font-bold text-white mb-2"), g.Text("Ownership")),
+		P(Class("text-gray-400 mb-4"), g.Text(summary)),
+		Div(Class("grid grid-cols-1 lg:grid-cols-2 gap-6"),
+			fundSection("Top Institutional Holders", holdersTable(o.InstitutionalHolders, "Yahoo Finance has no institutional holders.")),
+			fundSection("Top Fund Holders", holdersTable(o.FundHolders, "Yahoo Finance has no fund holders.")),
+			fundSection("Insider Net Buying", insiderActivityTable(o.InsiderActivity),
+				P(Class("text-xs text-gray-500 mt-2"), g.Text("Open market purchases and sales only; grants, gifts and option exercises are left out.")),
+			),
+			fundSection("Recent Insider Transactions", insiderTradesTable(o.InsiderTransactions, currency)),
+		),
+	)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Trimmed down ownership modules. Insider trades are dated 2025-10-01 (a
+// sale and a grant), 2025-07-01, 2025-01-15 and 2024-06-01.
+const testOwnershipModules = `{
+	"institutionOwnership":{"ownershipList":[
+		{"reportDate":{"raw":1751241600},"organization":"Vanguard Group Inc","pctHeld":{"raw":0.0947},"position":{"raw":1400000000},"value":{"raw":280000000000},"pctChange":{"raw":0.012}},
+		{"reportDate":{"raw":1751241600},"organization":"Blackrock Inc.","pctHeld":{"raw":0.0761},"position":{"raw":1130000000},"value":{"raw":226000000000},"pctChange":{"raw":-0.008}}]},
+	"fundOwnership":{"ownershipList":[
+		{"reportDate":{"raw":1751241600},"organization":"Vanguard Total Stock Market Index Fund","pctHeld":{"raw":0.0318},"position":{"raw":470000000},"value":{"raw":94000000000},"pctChange":{"raw":0.004}}]},
+	"insiderTransactions":{"transactions":[
+		{"shares":{"raw":100000},"value":{"raw":23000000},"filerName":"COOK TIMOTHY D","filerRelation":"Chief Executive Officer","transactionText":"Sale at price 230.00 per share.","startDate":{"raw":1759276800},"ownership":"D"},
+		{"shares":{"raw":50000},"value":{"raw":0},"filerName":"COOK TIMOTHY D","filerRelation":"Chief Executive Officer","transactionText":"","startDate":{"raw":1759276800},"ownership":"D"},
+		{"shares":{"raw":20000},"value":{"raw":4000000},"filerName":"LEVINSON ARTHUR D","filerRelation":"Director","transactionText":"Purchase at price 200.00 per share.","startDate":{"raw":1751328000},"ownership":"I"},
+		{"shares":{"raw":30000},"value":{"raw":6000000},"filerName":"WILLIAMS JEFFREY E","filerRelation":"Chief Operating Officer","transactionText":"Sale at price 200.00 per share.","startDate":{"raw":1736899200},"ownership":"D"},
+		{"shares":{"raw":99999},"value":{"raw":0},"filerName":"OLD TRADE","filerRelation":"Director","transactionText":"Sale at price 150.00 per share.","startDate":{"raw":1717200000},"ownership":"D"}]}
+}`
+
+// 2025-10-15, so the trades fall in the 3, 6, 12 month and older windows.
+var testOwnershipNow = time.Date(2025, 10, 15, 0, 0, 0, 0, time.UTC)
+
+func testOwnershipResult(t *testing.T) *Result {
+	r := testResult()
+	if err := json.Unmarshal([]byte(testOwnershipModules), r); err != nil {
+		t.Fatal(err)
+	}
+	ks := &r.DefaultKeyStatistics
+	ks.HeldPercentInsiders = FmtRaw{Raw: 0.02}
+	ks.HeldPercentInstitutions = FmtRaw{Raw: 0.62}
+	ks.SharesShort = FmtRaw{Raw: 105_000_000}
+	ks.SharesShortPriorMonth = FmtRaw{Raw: 100_000_000}
+	ks.SharesOutstanding = FmtRaw{Raw: 15_000_000_000}
+	return r
+}
+
+func TestToAPIOwnership(t *testing.T) {
+	o := toAPIOwnership(testOwnershipResult(t), testOwnershipNow)
+	if o == nil {
+		t.Fatal("no ownership")
+	}
+	if o.Insiders != 0.02 || o.Institutions != 0.62 || math.Abs(o.ShortInterestChange-0.05) > 1e-9 {
+		t.Errorf("ownership = %+v", o)
+	}
+	if len(o.InstitutionalHolders) != 2 || o.InstitutionalHolders[1].Name != "Blackrock Inc." || o.InstitutionalHolders[0].ReportDate != "2025-06-30" || len(o.FundHolders) != 1 {
+		t.Errorf("holders = %+v %+v", o.InstitutionalHolders, o.FundHolders)
+	}
+
+	// The grant is left out; the 2024 sale is outside every window.
+	want := []APIInsiderActivity{
+		{Period: "3M", Sold: 100000, Net: -100000, Trades: 1},
+		{Period: "6M", Bought: 20000, Sold: 100000, Net: -80000, Trades: 2},
+		{Period: "12M", Bought: 20000, Sold: 130000, Net: -110000, Trades: 3},
+	}
+	for i, a := range o.InsiderActivity {
+		if a != want[i] {
+			t.Errorf("activity %d = %+v, want %+v", i, a, want[i])
+		}
+	}
+	if len(o.InsiderTransactions) != 5 || o.InsiderTransactions[0].Kind != "sell" || o.InsiderTransactions[1].Kind != "other" || o.InsiderTransactions[2].Kind != "buy" || o.InsiderTransactions[0].Date != "2025-10-01" {
+		t.Errorf("transactions = %+v", o.InsiderTransactions)
+	}
+
+	etf := testOwnershipResult(t)
+	etf.QuoteType.QuoteType = quoteTypeETF
+	if toAPIOwnership(etf, testOwnershipNow) != nil || toAPIOwnership(&Result{QuoteType: QuoteType{QuoteType: quoteTypeEquity}}, testOwnershipNow) != nil {
+		t.Error("ownership for an ETF or without data")
+	}
+}
+
+func TestOwnershipMetrics(t *testing.T) {
+	metrics := map[string]Metric{}
+	for _, m := range ownershipMetrics(testOwnershipResult(t), testOwnershipNow) {
+		metrics[m.Name] = m
+	}
+	if m := metrics["Short Interest Change"]; m.Value != "5.00%" || m.Color != "yellow" {
+		t.Errorf("short interest change = %+v", m)
+	}
+	// 110,000 net sold of 15 billion shares is well under 0.1%.
+	if m := metrics["Insider Net Buying (12M)"]; m.Color != "yellow" || m.Raw >= 0 {
+		t.Errorf("insider net buying = %+v", m)
+	}
+
+	r := testOwnershipResult(t)
+	r.DefaultKeyStatistics.SharesShortPriorMonth = FmtRaw{}
+	r.InsiderTransactions.Transactions = nil
+	if got := ownershipMetrics(r, testOwnershipNow); len(got) != 0 {
+		t.Errorf("metrics without a prior month or trades = %+v", got)
+	}
+}
+
+func TestOwnershipSection(t *testing.T) {
+	var b strings.Builder
+	ownershipSection(testOwnershipResult(t), testOwnershipNow).Render(&b)
+	html := b.String()
+	for _, want := range []string{
+		"Insiders hold 2.00% and institutions 62.00%. 105.00M shares are sold short, +5.0% on the prior month.",
+		"Vanguard Group Inc", "-0.8%", "Vanguard Total Stock Market Index Fund",
+		"COOK TIMOTHY D", "Chief Executive Officer", "$23.00M", "-110.00K",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("ownership section lacks %q", want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	common "app/internal/common"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long a cached quote is served before fetching again.
+var quoteCacheTTL = time.Hour
+
+var errCacheClosed = errors.New("quote cache is closed")
+
+var (
+	quoteCacheMu     sync.Mutex
+	quoteCacheClosed bool
+	quoteCacheWrites sync.WaitGroup
+)
+
+// Ticker data is cached here, one file per ticker per hour. This is relative
+// to the CWD, or WorkingDirectory=/opt/stock when launched via systemd.
+func stockCacheDir() string {
+	return filepath.Join(g_dataDir, "./stockdata")
+}
+
+// Format current time for cache filename (rounded to hour)
+func quoteCachePath(cacheDir string, ticker common.Symbol, now time.Time) string {
+	hour := now.UTC().Truncate(time.Hour)
+	return filepath.Join(cacheDir, fmt.Sprintf("%s-%s.json", ticker.CacheKey(), hour.Format("2006-01-02-15")))
+}
+
+// writeQuoteCache writes body to path via a temp file and rename, so a reader
+// or a crash mid-write never sees a partial file.
+func writeQuoteCache(path string, body []byte) error {
+	quoteCacheMu.Lock()
+	if quoteCacheClosed {
+		quoteCacheMu.Unlock()
+		return errCacheClosed
+	}
+	quoteCacheWrites.Add(1)
+	quoteCacheMu.Unlock()
+	defer quoteCacheWrites.Done()
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// closeQuoteCache stops new cache writes and waits for pending ones.
+func closeQuoteCache() {
+	quoteCacheMu.Lock()
+	quoteCacheClosed = true
+	quoteCacheMu.Unlock()
+	quoteCacheWrites.Wait()
+}
+
+// quoteCacheFiles returns the cache files for ticker, newest first, with the
+// hour stamped in each name.
+func quoteCacheFiles(cacheDir string, ticker common.Symbol) ([]string, []time.Time) {
+	// Keys never contain glob characters.
+	key := ticker.CacheKey()
+	paths, err := filepath.Glob(filepath.Join(cacheDir, key+"-*.json"))
+	if err != nil {
+		return nil, nil
+	}
+	// The hour stamp in the name sorts chronologically. Names that don't
+	// parse belong to a longer ticker sharing our prefix, e.g. BRK-B for BRK.
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+	var files []string
+	var stamps []time.Time
+	for _, path := range paths {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), key+"-"), ".json")
+		when, err := time.Parse("2006-01-02-15", stamp)
+		if err != nil {
+			continue
+		}
+		files = append(files, path)
+		stamps = append(stamps, when)
+	}
+	return files, stamps
+}
+
+// loadFreshCache returns the newest cached quote for ticker if it was written
+// within quoteCacheTTL.
+func loadFreshCache(cacheDir string, ticker common.Symbol) ([]byte, string, bool) {
+	files, _ := quoteCacheFiles(cacheDir, ticker)
+	if len(files) == 0 {
+		return nil, "", false
+	}
+	info, err := os.Stat(files[0])
+	if err != nil || time.Since(info.ModTime()) >= quoteCacheTTL {
+		return nil, "", false
+	}
+	data, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		return nil, "", false
+	}
+	return data, files[0], true
+}
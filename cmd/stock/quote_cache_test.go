@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQuoteCachePath(t *testing.T) {
+	when := time.Date(2024, 3, 5, 14, 59, 0, 0, time.UTC)
+	if got := quoteCachePath("dir", "AAPL", when); got != filepath.Join("dir", "AAPL-2024-03-05-14.json") {
+		t.Errorf("quoteCachePath = %q", got)
+	}
+	if got := quoteCachePath("dir", "^GSPC", when); got != filepath.Join("dir", "_IGSPC-2024-03-05-14.json") {
+		t.Errorf("quoteCachePath for an index = %q", got)
+	}
+}
+
+func TestWriteQuoteCacheLeavesNoTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "AAPL-2024-03-05-14.json")
+	if err := writeQuoteCache(path, []byte(testQuoteSummary)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil || string(data) != testQuoteSummary {
+		t.Fatalf("read back %q, %v", data, err)
+	}
+	entries, _ := ioutil.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("cache dir has %d entries, want just the cache file", len(entries))
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Anyone who can reach the port could otherwise make us hammer Yahoo, so
+// clients are limited per IP and all upstream requests share one budget.
+var (
+	// Nil means unlimited. Set from the config.
+	clientLimiter   *common.RateLimiter
+	upstreamBudget  *common.RateLimiter
+	upstreamMaxWait = 10 * time.Second
+	trustedProxies  []*net.IPNet
+)
+
+// upstreamBusyError is returned when the Yahoo budget is spent and the queue
+// is longer than upstreamMaxWait.
+type upstreamBusyError struct {
+	retryAfter time.Duration
+}
+
+func (e *upstreamBusyError) Error() string {
+	return fmt.Sprintf("too many requests to Yahoo right now, try again in %ds", common.RetryAfterSeconds(e.retryAfter))
+}
+
+// waitForUpstream takes a token from the upstream budget, queueing for up to
+// upstreamMaxWait. Shutdown or the caller giving up cancels the wait and hands
+// the token back.
+func waitForUpstream(ctx context.Context) error {
+	wait, ok := upstreamBudget.Reserve("yahoo", upstreamMaxWait)
+	if !ok {
+		rateLimited.Inc("upstream")
+		return &upstreamBusyError{retryAfter: wait}
+	}
+	upstreamQueueWait.Observe(wait.Seconds())
+	if wait <= 0 {
+		return nil
+	}
+	slog.DebugContext(ctx, "Queued for upstream budget", "wait", wait)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		upstreamBudget.Release("yahoo")
+		return ctx.Err()
+	case <-upstreamCtx.Done():
+		upstreamBudget.Release("yahoo")
+		return upstreamCtx.Err()
+	}
+}
+
+// limitClients turns away clients that are over their request rate.
+func limitClients(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := clientLimiter.Allow(common.ClientIP(r, trustedProxies)); !ok {
+			rateLimited.Inc("client")
+			writeRateLimited(w, r, wait)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// writeRateLimited answers 429 with Retry-After: JSON for the API and a
+// friendly page for the HTML UI.
+func writeRateLimited(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	seconds := common.RetryAfterSeconds(wait)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeAPIError(w, http.StatusTooManyRequests, errCodeRateLimited,
+			fmt.Sprintf("rate limit exceeded, retry in %ds", seconds))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusTooManyRequests)
+	errorPage("Slow down a little",
+		fmt.Sprintf("We're getting a lot of requests right now. Please wait %d seconds and try again.", seconds), "").Render(w)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func withLimits(t *testing.T, client, upstream *common.RateLimiter, maxWait time.Duration) {
+	origClient, origUpstream, origWait := clientLimiter, upstreamBudget, upstreamMaxWait
+	clientLimiter, upstreamBudget, upstreamMaxWait = client, upstream, maxWait
+	t.Cleanup(func() {
+		clientLimiter, upstreamBudget, upstreamMaxWait = origClient, origUpstream, origWait
+	})
+}
+
+func TestLimitClients(t *testing.T) {
+	withFetcher(t, func(string) (*Result, error) { return testResult(), nil })
+	withLimits(t, common.NewRateLimiter(60, 1), nil, 0)
+
+	get := func(path, remote string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		r.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		limitClients(apiV1QuoteHandler)(rec, r)
+		return rec
+	}
+
+	if rec := get("/api/v1/quote?symbol=AAPL", "192.0.2.1:1"); rec.Code != http.StatusOK {
+		t.Fatalf("first request = %d", rec.Code)
+	}
+	rec := get("/api/v1/quote?symbol=AAPL", "192.0.2.1:2")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("second request = %d, Retry-After %q; want 429 and 1", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	var body APIError
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if body.Error.Code != errCodeRateLimited {
+		t.Errorf("error code = %q", body.Error.Code)
+	}
+	if rec := get("/api/v1/quote?symbol=AAPL", "192.0.2.2:1"); rec.Code != http.StatusOK {
+		t.Errorf("other client = %d, want its own bucket", rec.Code)
+	}
+
+	// The HTML UI gets a page rather than JSON.
+	get("/stock?symbol=AAPL", "192.0.2.3:1")
+	rec = get("/stock?symbol=AAPL", "192.0.2.3:1")
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "<html") {
+		t.Errorf("HTML 429 = %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestUpstreamBudgetReturns429(t *testing.T) {
+	var requests int
+	withFakeYahoo(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(testQuoteSummary))
+	})
+	// One token: the session launch spends it, then the fetch can't queue.
+	withLimits(t, nil, common.NewRateLimiter(1, 1), time.Second)
+
+	before := rateLimited.Value("upstream")
+	rec := httptest.NewRecorder()
+	apiV1QuoteHandler(rec, httptest.NewRequest("GET", "/api/v1/quote?symbol=AAPL", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("missing Retry-After")
+	}
+	if requests != 0 {
+		t.Errorf("made %d upstream requests over budget", requests)
+	}
+	if rateLimited.Value("upstream") != before+1 {
+		t.Error("upstream rate limit not counted")
+	}
+}
+
+func TestUpstreamBudgetQueues(t *testing.T) {
+	withFakeYahoo(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testQuoteSummary))
+	})
+	// 1200/min is a token every 50ms; the fetch queues briefly behind the launch.
+	withLimits(t, nil, common.NewRateLimiter(1200, 1), time.Second)
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	apiV1QuoteHandler(rec, httptest.NewRequest("GET", "/api/v1/quote?symbol=AAPL", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("fetch took %s, expected it to wait for the budget", elapsed)
+	}
+}
+
+func TestWaitForUpstreamStopsWhenRequestGoesAway(t *testing.T) {
+	budget := common.NewRateLimiter(60, 1)
+	withLimits(t, nil, budget, 10*time.Second)
+	budget.Allow("yahoo")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := waitForUpstream(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the request's deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("waited %s after the request went away", elapsed)
+	}
+	// The abandoned token was handed back, so the next caller isn't queued
+	// behind it.
+	if wait, _ := budget.Reserve("yahoo", 10*time.Second); wait > 1500*time.Millisecond {
+		t.Errorf("next wait = %s, want about 1s", wait)
+	}
+}
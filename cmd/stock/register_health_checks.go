@@ -0,0 +1,87 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Readiness fails when the data dir has less than this free, since every
+// uncached fetch writes a new cache file.
+const minFreeDiskBytes = 100 * 1024 * 1024
+
+// The http_listener check GETs this bare route rather than a page, so probes
+// stay out of the request log and metrics and don't spend a rate limit token.
+const listenerProbePath = "/health/listener"
+
+func listenerProbe(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// registerHealthChecks wires our subsystems into the /health/live and
+// /health/ready endpoints. listenAddr is the main server's address and scheme
+// is http or https.
+func registerHealthChecks(scheme, listenAddr string) {
+	http.HandleFunc(listenerProbePath, listenerProbe)
+	common.RegisterLivenessCheck("http_listener", common.HTTPGetCheck(scheme+"://"+loopbackAddr(listenAddr)+listenerProbePath))
+	common.RegisterReadinessCheck("cache_store", cacheStoreCheck)
+	common.RegisterReadinessCheck("upstream_session", upstreamSessionCheck)
+	common.RegisterReadinessCheck("disk_space", func(ctx context.Context) error {
+		dir := g_dataDir
+		if dir == "" {
+			dir = "."
+		}
+		return common.DiskSpaceCheck(dir, minFreeDiskBytes)(ctx)
+	})
+}
+
+// loopbackAddr turns a listen address like ":8080" into one we can dial.
+func loopbackAddr(listenAddr string) string {
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return listenAddr
+	}
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// The cache directory must exist and be writable.
+func cacheStoreCheck(ctx context.Context) error {
+	dir := stockCacheDir()
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("could not create cache dir: %v", err)
+	}
+	probe := filepath.Join(dir, ".health-probe")
+	if err := os.WriteFile(probe, []byte("ok"), 0644); err != nil {
+		return fmt.Errorf("cache dir not writable: %v", err)
+	}
+	return os.Remove(probe)
+}
+
+// Sessions are created lazily, so this only fails once an attempt has failed.
+func upstreamSessionCheck(ctx context.Context) error {
+	if err := yahooSessionError(); err != nil {
+		return fmt.Errorf("last Yahoo session attempt failed: %v", err)
+	}
+	return nil
+}
+
+// isAlive reports whether the liveness checks pass, for gating the watchdog.
+func isAlive() bool {
+	report := common.CheckLiveness()
+	if report.Status != common.StatusOK {
+		slog.Warn("Liveness check failed, withholding watchdog ping", "checks", report.Checks)
+		return false
+	}
+	return true
+}
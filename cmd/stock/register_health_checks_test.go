@@ -0,0 +1,59 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoopbackAddr(t *testing.T) {
+	tests := map[string]string{
+		":8080":          "127.0.0.1:8080",
+		"0.0.0.0:8080":   "127.0.0.1:8080",
+		"[::]:8080":      "[::1]:8080",
+		"10.0.0.5:8082":  "10.0.0.5:8082",
+		"localhost:8080": "localhost:8080",
+	}
+	for in, want := range tests {
+		if got := loopbackAddr(in); got != want {
+			t.Errorf("loopbackAddr(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestListenerProbe(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(listenerProbePath, listenerProbe)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	if err := common.HTTPGetCheck(srv.URL + listenerProbePath)(context.Background()); err != nil {
+		t.Errorf("probe failed: %v", err)
+	}
+}
+
+func TestCacheStoreCheck(t *testing.T) {
+	orig := g_dataDir
+	g_dataDir = t.TempDir()
+	defer func() { g_dataDir = orig }()
+
+	if err := cacheStoreCheck(context.Background()); err != nil {
+		t.Errorf("cacheStoreCheck() = %v", err)
+	}
+}
+
+func TestUpstreamSessionCheck(t *testing.T) {
+	withFakeYahoo(t, nil)
+	if err := upstreamSessionCheck(context.Background()); err != nil {
+		t.Errorf("no attempt yet, got %v", err)
+	}
+
+	newYahooSession = func() (*yahooSession, error) { return nil, errors.New("chrome not installed") }
+	getYahooSession(context.Background())
+	if err := upstreamSessionCheck(context.Background()); err == nil {
+		t.Error("expected failure after a failed session attempt")
+	}
+}
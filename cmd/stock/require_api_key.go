@@ -0,0 +1,168 @@
+package main
+
+import (
+	common "app/internal/common"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	g "maragu.dev/gomponents"
+	. "maragu.dev/gomponents/html"
+)
+
+// Where a request can carry its API key, checked in this order. The cookie is
+// set for browsers after they enter a key on an HTML page.
+const (
+	apiKeyHeader     = "X-API-Key"
+	apiKeyQueryParam = "api_key"
+	apiKeyCookie     = "stock_api_key"
+)
+
+var (
+	// Nil unless auth.mode is api_key. Set from the config.
+	apiKeys *apiKeyStore
+	// Also require a key for the HTML pages, not just /api/.
+	requireAuthForHTML bool
+	// Rate for keys without their own; 0 means unlimited.
+	defaultKeyPerMinute int
+
+	keyLimitersMu sync.Mutex
+	keyLimiters   = map[string]*common.RateLimiter{}
+)
+
+func requestAPIKey(r *http.Request) (key string, fromQuery bool) {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return key, false
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer "), false
+	}
+	if key := r.URL.Query().Get(apiKeyQueryParam); key != "" {
+		return key, true
+	}
+	if c, err := r.Cookie(apiKeyCookie); err == nil {
+		return c.Value, false
+	}
+	return "", false
+}
+
+// keyLimiter returns the limiter for k, or nil when it's unlimited. Limiters
+// are kept per key so each can have its own rate.
+func keyLimiter(k *apiKey) *common.RateLimiter {
+	perMinute := k.RequestsPerMinute
+	if perMinute == 0 {
+		perMinute = defaultKeyPerMinute
+	}
+	keyLimitersMu.Lock()
+	defer keyLimitersMu.Unlock()
+	l, ok := keyLimiters[k.ID]
+	if !ok {
+		l = common.NewRateLimiter(perMinute, 0)
+		keyLimiters[k.ID] = l
+	}
+	return l
+}
+
+// requireAPIKey rejects requests to /api/ without a valid key, and HTML
+// requests too when requireAuthForHTML is set. Each key has its own quota.
+func requireAPIKey(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		isAPI := strings.HasPrefix(r.URL.Path, "/api/")
+		if apiKeys == nil || (!isAPI && !requireAuthForHTML) {
+			h(w, r)
+			return
+		}
+
+		plain, fromQuery := requestAPIKey(r)
+		if plain == "" {
+			apiAuthFailures.Inc("missing")
+			writeUnauthorized(w, r, "API key required")
+			return
+		}
+		k, err := apiKeys.authenticate(plain)
+		if err != nil {
+			reason := "invalid"
+			if errors.Is(err, errAPIKeyRevoked) {
+				reason = "revoked"
+			}
+			apiAuthFailures.Inc(reason)
+			writeUnauthorized(w, r, err.Error())
+			return
+		}
+
+		if ok, wait := keyLimiter(k).Allow(k.ID); !ok {
+			rateLimited.Inc("api_key")
+			writeRateLimited(w, r, wait)
+			return
+		}
+		apiKeyRequests.Inc(k.ID)
+
+		// A browser that typed its key into the form keeps it in a cookie, and
+		// the key comes out of the address bar and history.
+		if !isAPI && fromQuery {
+			http.SetCookie(w, &http.Cookie{
+				Name:     apiKeyCookie,
+				Value:    plain,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+				Secure:   r.TLS != nil,
+			})
+			q := r.URL.Query()
+			q.Del(apiKeyQueryParam)
+			target := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+			http.Redirect(w, r, target.String(), http.StatusSeeOther)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func writeUnauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="stock"`)
+		writeAPIError(w, http.StatusUnauthorized, errCodeUnauthorized,
+			message+": send it in the "+apiKeyHeader+" header or the "+apiKeyQueryParam+" parameter")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusUnauthorized)
+	apiKeyPage(r, message).Render(w)
+}
+
+// apiKeyPage asks for a key and resubmits the current page with it.
+func apiKeyPage(r *http.Request, message string) g.Node {
+	var hidden []g.Node
+	for name, values := range r.URL.Query() {
+		if name == apiKeyQueryParam {
+			continue
+		}
+		for _, v := range values {
+			hidden = append(hidden, Input(Type("hidden"), Name(name), Value(v)))
+		}
+	}
+	return HTML(
+		Head(
+			Meta(Charset("UTF-8")),
+			Meta(Name("viewport"), Content("width=device-width, initial-scale=1.0")),
+			TitleEl(g.Text("API key required")),
+			Script(Src("https://cdn.tailwindcss.com")),
+		),
+		Body(Class("bg-gray-900 text-gray-100 min-h-screen flex items-center justify-center"),
+			Div(Class("container mx-auto px-4"),
+				Div(Class("max-w-md mx-auto bg-gray-800 rounded-lg shadow-lg p-8"),
+					H1(Class("text-2xl font-bold text-yellow-400 mb-4"), g.Text("API key required")),
+					P(Class("text-gray-300 mb-4"), g.Text(message)),
+					Form(Method("get"), Action(r.URL.Path), Class("flex gap-2"),
+						g.Group(hidden),
+						Input(Type("password"), Name(apiKeyQueryParam), Placeholder("sk_..."), Required(),
+							Class("flex-1 px-3 py-2 rounded bg-gray-700 text-gray-100 focus:outline-none")),
+						Button(Type("submit"), Class("px-4 py-2 rounded bg-blue-600 hover:bg-blue-500"), g.Text("Continue")),
+					),
+				),
+			),
+		),
+	)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	common "app/internal/common"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withAPIKeys turns on auth with a store holding one key, which it returns.
+func withAPIKeys(t *testing.T, forHTML bool, perMinute int) string {
+	s := newAPIKeyStore(filepath.Join(t.TempDir(), "apikeys.json"))
+	_, plain, err := s.create("test", perMinute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+	origKeys, origHTML := apiKeys, requireAuthForHTML
+	apiKeys, requireAuthForHTML = s, forHTML
+	keyLimiters = map[string]*common.RateLimiter{}
+	t.Cleanup(func() {
+		apiKeys, requireAuthForHTML = origKeys, origHTML
+		keyLimiters = map[string]*common.RateLimiter{}
+	})
+	return plain
+}
+
+func serveWithAuth(r *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	requireAPIKey(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})(rec, r)
+	return rec
+}
+
+func TestRequireAPIKeyOnAPI(t *testing.T) {
+	plain := withAPIKeys(t, false, 0)
+
+	rec := serveWithAuth(httptest.NewRequest("GET", "/api/v1/quote?symbol=AAPL", nil))
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), errCodeUnauthorized) {
+		t.Errorf("no key = %d %s", rec.Code, rec.Body.String())
+	}
+
+	r := httptest.NewRequest("GET", "/api/v1/quote?symbol=AAPL", nil)
+	r.Header.Set(apiKeyHeader, plain)
+	if rec := serveWithAuth(r); rec.Code != http.StatusOK {
+		t.Errorf("header key = %d", rec.Code)
+	}
+	r = httptest.NewRequest("GET", "/api/v1/quote?symbol=AAPL", nil)
+	r.Header.Set("Authorization", "Bearer "+plain)
+	if rec := serveWithAuth(r); rec.Code != http.StatusOK {
+		t.Errorf("bearer key = %d", rec.Code)
+	}
+	if rec := serveWithAuth(httptest.NewRequest("GET", "/api/v1/quote?symbol=AAPL&api_key="+plain, nil)); rec.Code != http.StatusOK {
+		t.Errorf("query key = %d", rec.Code)
+	}
+
+	// HTML stays open unless configured otherwise.
+	if rec := serveWithAuth(httptest.NewRequest("GET", "/stock?symbol=AAPL", nil)); rec.Code != http.StatusOK {
+		t.Errorf("HTML without key = %d", rec.Code)
+	}
+}
+
+func TestRequireAPIKeyQuota(t *testing.T) {
+	plain := withAPIKeys(t, false, 1)
+	before := apiKeyRequests.Value(strings.Split(plain, "_")[1])
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		r := httptest.NewRequest("GET", "/api/v1/quote", nil)
+		r.Header.Set(apiKeyHeader, plain)
+		if rec := serveWithAuth(r); rec.Code != want {
+			t.Errorf("request %d = %d, want %d", i, rec.Code, want)
+		}
+	}
+	if got := apiKeyRequests.Value(strings.Split(plain, "_")[1]); got != before+1 {
+		t.Errorf("usage counter = %g, want %g", got, before+1)
+	}
+}
+
+func TestRequireAPIKeyOnHTML(t *testing.T) {
+	plain := withAPIKeys(t, true, 0)
+
+	rec := serveWithAuth(httptest.NewRequest("GET", "/stock?symbol=AAPL", nil))
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), `name="api_key"`) || !strings.Contains(rec.Body.String(), `value="AAPL"`) {
+		t.Fatalf("HTML without key = %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Submitting the form sets a cookie and drops the key from the URL.
+	rec = serveWithAuth(httptest.NewRequest("GET", "/stock?symbol=AAPL&api_key="+plain, nil))
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/stock?symbol=AAPL" {
+		t.Fatalf("form submit = %d to %q", rec.Code, rec.Header().Get("Location"))
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("cookies = %+v", cookies)
+	}
+
+	r := httptest.NewRequest("GET", "/stock?symbol=AAPL", nil)
+	r.AddCookie(cookies[0])
+	if rec := serveWithAuth(r); rec.Code != http.StatusOK {
+		t.Errorf("with cookie = %d", rec.Code)
+	}
+}
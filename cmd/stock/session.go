@@ -0,0 +1,218 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const sessionCookie = "stock_session"
+
+var errBadCookie = errors.New("cookie is invalid or expired")
+
+var (
+	// Signs session and login cookies. Loaded from the data dir, so sessions
+	// survive restarts.
+	sessionKey []byte
+	// How long a login lasts.
+	sessionTTL = 7 * 24 * time.Hour
+
+	// Per user ID, bumped on logout to end every session issued before.
+	sessionGenMu   sync.Mutex
+	sessionGens    = map[string]int{}
+	sessionGenFile string
+)
+
+// sessionValue is what the session cookie holds. A session is only valid while
+// Gen matches the user's generation and, for local users, Password matches
+// their current password.
+type sessionValue struct {
+	User
+	Gen      int    `json:"gen"`
+	Password string `json:"pw,omitempty"`
+}
+
+func sessionKeyPath(dataDir string) string {
+	return filepath.Join(dataDir, "session.key")
+}
+
+// loadSessionKey reads the signing key, creating it on first use. Deleting the
+// file logs everyone out.
+func loadSessionKey(path string) ([]byte, error) {
+	key, err := ioutil.ReadFile(path)
+	if err == nil && len(key) >= 32 {
+		return key, nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, writePrivateFile(path, key)
+}
+
+func sessionGenerationsPath(dataDir string) string {
+	return filepath.Join(dataDir, "sessions.json")
+}
+
+// loadSessionGenerations reads the generations file. A missing file means no
+// one has logged out yet.
+func loadSessionGenerations(path string) error {
+	sessionGenMu.Lock()
+	defer sessionGenMu.Unlock()
+	sessionGenFile, sessionGens = path, map[string]int{}
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &sessionGens)
+}
+
+func sessionGeneration(id string) int {
+	sessionGenMu.Lock()
+	defer sessionGenMu.Unlock()
+	return sessionGens[id]
+}
+
+// endSessions logs u out in every browser.
+func endSessions(u *User) error {
+	sessionGenMu.Lock()
+	defer sessionGenMu.Unlock()
+	sessionGens[u.ID]++
+	data, err := json.MarshalIndent(sessionGens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writePrivateFile(sessionGenFile, data)
+}
+
+// passwordFingerprint identifies a password hash without revealing it, so a
+// password change ends the sessions started with the old one.
+func passwordFingerprint(hash string) string {
+	sum := sha256.Sum256([]byte(hash))
+	return hex.EncodeToString(sum[:8])
+}
+
+// signedValue is v as JSON with an HMAC and expiry, for a cookie.
+func signedValue(v interface{}, expires time.Time) (string, error) {
+	payload, err := json.Marshal(struct {
+		V   interface{} `json:"v"`
+		Exp int64       `json:"exp"`
+	}{v, expires.Unix()})
+	if err != nil {
+		return "", err
+	}
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + base64.RawURLEncoding.EncodeToString(signature(body)), nil
+}
+
+// readSignedValue reverses signedValue, rejecting tampered or expired values.
+func readSignedValue(s string, v interface{}) error {
+	body, sig, ok := strings.Cut(s, ".")
+	if !ok {
+		return errBadCookie
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, signature(body)) {
+		return errBadCookie
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return errBadCookie
+	}
+	var wrapper struct {
+		V   json.RawMessage `json:"v"`
+		Exp int64           `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &wrapper); err != nil || time.Now().Unix() > wrapper.Exp {
+		return errBadCookie
+	}
+	if err := json.Unmarshal(wrapper.V, v); err != nil {
+		return errBadCookie
+	}
+	return nil
+}
+
+func signature(body string) []byte {
+	mac := hmac.New(sha256.New, sessionKey)
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
+
+// setSignedCookie stores v in a cookie only we can have written.
+func setSignedCookie(w http.ResponseWriter, r *http.Request, name, path string, v interface{}, ttl time.Duration) error {
+	expires := time.Now().Add(ttl)
+	value, err := signedValue(v, expires)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   r.TLS != nil,
+	})
+	return nil
+}
+
+func clearCookie(w http.ResponseWriter, name, path string) {
+	http.SetCookie(w, &http.Cookie{Name: name, Value: "", Path: path, MaxAge: -1, HttpOnly: true})
+}
+
+func startSession(w http.ResponseWriter, r *http.Request, u *User) error {
+	v := sessionValue{User: *u, Gen: sessionGeneration(u.ID)}
+	if lu := sessionLocalUser(u); lu != nil {
+		v.Password = passwordFingerprint(lu.Hash)
+	}
+	return setSignedCookie(w, r, sessionCookie, "/", v, sessionTTL)
+}
+
+// sessionLocalUser returns the account behind a local user, or nil.
+func sessionLocalUser(u *User) *localUser {
+	if u.Provider != "local" || localUsers == nil {
+		return nil
+	}
+	return localUsers.lookup(strings.TrimPrefix(u.ID, "local:"))
+}
+
+// sessionUser returns the user from the session cookie, or nil.
+func sessionUser(r *http.Request) *User {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil
+	}
+	var v sessionValue
+	if err := readSignedValue(c.Value, &v); err != nil {
+		return nil
+	}
+	if v.Gen != sessionGeneration(v.ID) {
+		return nil
+	}
+	// Removing a local user or changing their password ends their sessions.
+	if v.Provider == "local" {
+		lu := sessionLocalUser(&v.User)
+		if lu == nil || v.Password != passwordFingerprint(lu.Hash) {
+			return nil
+		}
+	}
+	return &v.User
+}
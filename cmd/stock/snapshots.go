@@ -0,0 +1,291 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	g "maragu.dev/gomponents"
+	// Importing this as '.' is intentional for cleaner HTML like code.
+	. "maragu.dev/gomponents/html"
+)
+
+// The scored metrics of each ticker are recorded once a day, the first time
+// it's fetched, so the stock page can show how they've moved. A ticker's
+// first snapshots are backfilled from the hourly quote cache files still on
+// disk.
+
+// How many days of snapshots are kept per ticker.
+const snapshotRetention = 2 * 365
+
+// How many days the sparkline on a metric card covers.
+const sparklineDays = 90
+
+type metricSnapshot struct {
+	Date    string                    `json:"date"`
+	Metrics map[string]snapshotMetric `json:"metrics"`
+}
+
+type snapshotMetric struct {
+	Value float64 `json:"value"`
+	Color string  `json:"color"`
+}
+
+var (
+	snapshotMu sync.Mutex
+	// The day each ticker was last recorded, so recording doesn't read the
+	// file on every fetch.
+	snapshotRecorded = map[common.Symbol]string{}
+)
+
+func snapshotDir() string {
+	return filepath.Join(g_dataDir, "snapshots")
+}
+
+func snapshotPath(ticker common.Symbol) string {
+	return filepath.Join(snapshotDir(), ticker.CacheKey()+".json")
+}
+
+func snapshotDate(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// snapshotOf scores result as the stock page does. Set its currencies with
+// withCurrencies(ctx, result, "") first so ratios like P/S are on the page's
+// basis and amounts are in the currency it trades in.
+func snapshotOf(result *Result, date string) metricSnapshot {
+	s := metricSnapshot{Date: date, Metrics: map[string]snapshotMetric{}}
+	for _, m := range buildMetricsList(result) {
+		// JSON has no NaN, e.g. a PEG ratio without earnings growth.
+		if math.IsNaN(m.Raw) || math.IsInf(m.Raw, 0) {
+			continue
+		}
+		s.Metrics[m.Name] = snapshotMetric{Value: m.Raw, Color: m.Color}
+	}
+	return s
+}
+
+// loadSnapshots returns ticker's snapshots, oldest first. Without a snapshot
+// file they're rebuilt from the quote cache.
+func loadSnapshots(ctx context.Context, ticker common.Symbol) ([]metricSnapshot, error) {
+	data, err := ioutil.ReadFile(snapshotPath(ticker))
+	if errors.Is(err, os.ErrNotExist) {
+		return backfillSnapshots(ctx, ticker), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []metricSnapshot
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		return nil, fmt.Errorf("%s: %v", snapshotPath(ticker), err)
+	}
+	return snapshots, nil
+}
+
+// backfillSnapshots scores the newest cache file of each day, all at today's
+// FX rates.
+func backfillSnapshots(ctx context.Context, ticker common.Symbol) []metricSnapshot {
+	files, stamps := quoteCacheFiles(stockCacheDir(), ticker)
+	var snapshots []metricSnapshot
+	var c *currencies
+	for i, path := range files {
+		date := snapshotDate(stamps[i])
+		// Files are newest first, so the day's first one is its latest.
+		if n := len(snapshots); n > 0 && snapshots[n-1].Date == date {
+			continue
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		result, err := parseQuoteSummary(ticker, data)
+		if err != nil {
+			continue
+		}
+		if c == nil {
+			c = &withCurrencies(ctx, result, "").Currencies
+		}
+		result.Currencies = *c
+		snapshots = append(snapshots, snapshotOf(result, date))
+	}
+	for i, j := 0, len(snapshots)-1; i < j; i, j = i+1, j-1 {
+		snapshots[i], snapshots[j] = snapshots[j], snapshots[i]
+	}
+	return snapshots
+}
+
+// recordSnapshot adds today's snapshot of result unless ticker already has
+// one.
+func recordSnapshot(ctx context.Context, ticker common.Symbol, result *Result, now time.Time) {
+	today := snapshotDate(now)
+	snapshotMu.Lock()
+	recorded := snapshotRecorded[ticker] == today
+	snapshotMu.Unlock()
+	if recorded {
+		return
+	}
+	// Scoring may fetch FX rates, so it's done without the lock.
+	snapshot := snapshotOf(withCurrencies(ctx, result, ""), today)
+
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+	if snapshotRecorded[ticker] == today {
+		return
+	}
+	snapshots, err := loadSnapshots(ctx, ticker)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not load metric snapshots", "ticker", ticker, "err", err)
+		return
+	}
+	if n := len(snapshots); n == 0 || snapshots[n-1].Date != today {
+		snapshots = append(snapshots, snapshot)
+	}
+	snapshots = snapshots[max(0, len(snapshots)-snapshotRetention):]
+	data, err := json.Marshal(snapshots)
+	if err == nil {
+		err = writePrivateFile(snapshotPath(ticker), data)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Could not save metric snapshots", "ticker", ticker, "err", err)
+		return
+	}
+	snapshotRecorded[ticker] = today
+}
+
+// tickerSnapshots is loadSnapshots for pages and the API, which carry on
+// without history if the file can't be read.
+func tickerSnapshots(ctx context.Context, ticker common.Symbol) []metricSnapshot {
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+	snapshots, err := loadSnapshots(ctx, ticker)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not load metric snapshots", "ticker", ticker, "err", err)
+	}
+	return snapshots
+}
+
+type APIMetricHistory struct {
+	Symbol string           `json:"symbol"`
+	Metric string           `json:"metric"`
+	Points []APIMetricPoint `json:"points" doc:"One per day the symbol was looked up, oldest first."`
+}
+
+type APIMetricPoint struct {
+	Date  string  `json:"date" doc:"YYYY-MM-DD."`
+	Value float64 `json:"value" doc:"Raw value in the currency the symbol trades in. Percentages are fractions."`
+	Color string  `json:"color" enum:"green,yellow,red"`
+}
+
+func metricHistory(snapshots []metricSnapshot, name string) []APIMetricPoint {
+	points := []APIMetricPoint{}
+	for _, s := range snapshots {
+		if m, ok := s.Metrics[name]; ok {
+			points = append(points, APIMetricPoint{s.Date, m.Value, m.Color})
+		}
+	}
+	return points
+}
+
+func apiV1HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "only GET is supported")
+		return
+	}
+	raw := r.URL.Query().Get("symbol")
+	if strings.TrimSpace(raw) == "" {
+		writeAPIError(w, http.StatusBadRequest, errCodeMissingSymbol, "symbol parameter required")
+		return
+	}
+	ticker, err := common.ParseSymbol(raw)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidSymbol, err.Error())
+		return
+	}
+	name := strings.TrimSpace(r.URL.Query().Get("metric"))
+	if name == "" {
+		writeAPIError(w, http.StatusBadRequest, errCodeMissingMetric, "metric parameter required, e.g. P/E Ratio")
+		return
+	}
+	if !isMetricName(name) {
+		writeAPIError(w, http.StatusBadRequest, errCodeUnknownMetric, fmt.Sprintf("unknown metric %q; use a name as on the stock page, e.g. P/E Ratio", name))
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, APIMetricHistory{Symbol: ticker.String(), Metric: name, Points: metricHistory(tickerSnapshots(r.Context(), ticker), name)})
+}
+
+// withTrends adds each metric's recent values for its sparkline, and its
+// color a week before now if it has a snapshot that old.
+func withTrends(metrics []Metric, snapshots []metricSnapshot, now time.Time) []Metric {
+	since := snapshotDate(now.AddDate(0, 0, -sparklineDays))
+	weekAgo := snapshotDate(now.AddDate(0, 0, -7))
+	for i := range metrics {
+		m := &metrics[i]
+		m.Trend, m.WeekAgoColor = nil, ""
+		for _, s := range snapshots {
+			v, ok := s.Metrics[m.Name]
+			if !ok {
+				continue
+			}
+			if s.Date >= since {
+				m.Trend = append(m.Trend, v.Value)
+			}
+			if s.Date <= weekAgo {
+				m.WeekAgoColor = v.Color
+			}
+		}
+	}
+	return metrics
+}
+
+// Higher is better.
+var colorRank = map[string]int{"red": 0, "yellow": 1, "green": 2}
+
+// sparkline draws values as a line scaled to their own range.
+func sparkline(values []float64) g.Node {
+	if len(values) < 2 {
+		return nil
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+	const w, h = 100.0, 24.0
+	var points []string
+	for i, v := range values {
+		x := float64(i) / float64(len(values)-1) * w
+		y := h - 2 - (v-lo)/(hi-lo)*(h-4)
+		points = append(points, num(x)+","+num(y))
+	}
+	return SVG(g.Attr("viewBox", fmt.Sprintf("0 0 %g %g", w, h)), Class("w-24 h-6"), g.Attr("preserveAspectRatio", "none"),
+		g.Attr("aria-label", fmt.Sprintf("Last %d days", sparklineDays)),
+		g.El("polyline", g.Attr("points", strings.Join(points, " ")), g.Attr("fill", "none"),
+			g.Attr("stroke", "currentColor"), g.Attr("stroke-width", "1.5"), g.Attr("vector-effect", "non-scaling-stroke")),
+	)
+}
+
+// colorChange says how the metric's color moved since last week, or is nil if
+// it didn't.
+func colorChange(m Metric) g.Node {
+	if m.WeekAgoColor == "" || m.WeekAgoColor == m.Color {
+		return nil
+	}
+	arrow, class := "▲", "text-green-300"
+	if colorRank[m.Color] < colorRank[m.WeekAgoColor] {
+		arrow, class = "▼", "text-red-300"
+	}
+	return Span(Class("text-xs "+class), g.Textf("%s was %s last week", arrow, m.WeekAgoColor))
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withSnapshotDir points the data dir at a temp dir and forgets what was
+// recorded.
+func withSnapshotDir(t *testing.T) {
+	orig := g_dataDir
+	g_dataDir = t.TempDir()
+	snapshotRecorded = map[common.Symbol]string{}
+	t.Cleanup(func() {
+		g_dataDir = orig
+		snapshotRecorded = map[common.Symbol]string{}
+	})
+}
+
+func TestRecordSnapshot(t *testing.T) {
+	withSnapshotDir(t)
+	now := time.Date(2025, 10, 15, 12, 0, 0, 0, time.UTC)
+	// Two cache files on the 13th, one on the 14th, none for MSFT.
+	cacheDir := stockCacheDir()
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, hoursAgo := range []int{47, 40, 20} {
+		path := quoteCachePath(cacheDir, "AAPL", now.Add(-time.Duration(hoursAgo)*time.Hour))
+		if err := os.WriteFile(path, []byte(testQuoteSummary), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := testResult()
+	for i := 0; i < 2; i++ {
+		recordSnapshot(context.Background(), "AAPL", result, now)
+	}
+	snapshots, err := loadSnapshots(context.Background(), "AAPL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dates []string
+	for _, s := range snapshots {
+		dates = append(dates, s.Date)
+	}
+	if strings.Join(dates, " ") != "2025-10-13 2025-10-14 2025-10-15" {
+		t.Errorf("snapshot dates = %v", dates)
+	}
+	if pe := snapshots[2].Metrics["P/E Ratio"]; pe.Value != 30 || pe.Color != "red" {
+		t.Errorf("today's P/E = %+v", pe)
+	}
+
+	// The next day appends to the file rather than backfilling again.
+	recordSnapshot(context.Background(), "AAPL", result, now.AddDate(0, 0, 1))
+	if snapshots, _ = loadSnapshots(context.Background(), "AAPL"); len(snapshots) != 4 {
+		t.Errorf("%d snapshots after a second day, want 4", len(snapshots))
+	}
+	recordSnapshot(context.Background(), "MSFT", result, now)
+	if snapshots, _ = loadSnapshots(context.Background(), "MSFT"); len(snapshots) != 1 {
+		t.Errorf("%d snapshots without a cache, want 1", len(snapshots))
+	}
+	if _, err := os.Stat(filepath.Join(g_dataDir, "snapshots", "MSFT.json")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSnapshotUsesTradingCurrencyRatios(t *testing.T) {
+	withSnapshotDir(t)
+	withFXRates(t, testFXRates)
+	now := time.Date(2025, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	// Scored like the page, not on Yahoo's 0.4 that mixes USD and TWD.
+	recordSnapshot(context.Background(), "TSM", testADRResult(), now)
+	snapshots, err := loadSnapshots(context.Background(), "TSM")
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("snapshots = %v, %v", snapshots, err)
+	}
+	page := metricNamed(t, buildMetricsList(withCurrencies(context.Background(), testADRResult(), "")), "P/S Ratio")
+	if ps := snapshots[0].Metrics["P/S Ratio"]; ps.Value != page.Raw || ps.Color != page.Color {
+		t.Errorf("snapshot P/S = %+v, page = %+v", ps, page)
+	}
+}
+
+func TestSnapshotSkipsNaN(t *testing.T) {
+	result := testResult()
+	// PEG divides by earnings growth.
+	result.FinancialData.EarningsGrowth = FmtRaw{}
+	s := snapshotOf(result, "2025-10-15")
+	if _, ok := s.Metrics["PEG Ratio"]; ok {
+		t.Error("NaN PEG ratio recorded")
+	}
+	if _, err := json.Marshal(s); err != nil {
+		t.Error(err)
+	}
+}
+
+func testSnapshots() []metricSnapshot {
+	return []metricSnapshot{
+		{"2025-05-01", map[string]snapshotMetric{"P/E Ratio": {40, "red"}}},
+		{"2025-10-01", map[string]snapshotMetric{"P/E Ratio": {28, "red"}, "Beta": {1.2, "yellow"}}},
+		{"2025-10-07", map[string]snapshotMetric{"P/E Ratio": {24, "yellow"}}},
+		{"2025-10-14", map[string]snapshotMetric{"P/E Ratio": {14, "green"}}},
+	}
+}
+
+func TestWithTrends(t *testing.T) {
+	now := time.Date(2025, 10, 15, 0, 0, 0, 0, time.UTC)
+	metrics := withTrends([]Metric{{Name: "P/E Ratio", Color: "green"}, {Name: "ROE"}}, testSnapshots(), now)
+	// May is outside the sparkline; the 7th is a week before the 15th, the
+	// 8th wouldn't be.
+	if pe := metrics[0]; len(pe.Trend) != 3 || pe.Trend[0] != 28 || pe.WeekAgoColor != "yellow" {
+		t.Errorf("P/E = %+v", pe)
+	}
+	if roe := metrics[1]; roe.Trend != nil || roe.WeekAgoColor != "" {
+		t.Errorf("ROE without snapshots = %+v", roe)
+	}
+}
+
+func TestMetricCardTrend(t *testing.T) {
+	var b strings.Builder
+	renderMetricCard(Metric{Name: "P/E Ratio", Value: "14.00", Color: "green", Trend: []float64{28, 24, 14}, WeekAgoColor: "yellow"}).Render(&b)
+	if html := b.String(); !strings.Contains(html, "<polyline") || !strings.Contains(html, "▲ was yellow last week") {
+		t.Errorf("card = %s", html)
+	}
+	b.Reset()
+	renderMetricCard(Metric{Name: "P/E Ratio", Value: "30.00", Color: "red", WeekAgoColor: "red"}).Render(&b)
+	if html := b.String(); strings.Contains(html, "<svg") || strings.Contains(html, "last week") {
+		t.Errorf("card without a trend or change = %s", html)
+	}
+}
+
+func TestAPIV1History(t *testing.T) {
+	withSnapshotDir(t)
+	data, _ := json.Marshal(testSnapshots())
+	if err := writePrivateFile(snapshotPath("AAPL"), data); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	apiV1HistoryHandler(rec, httptest.NewRequest("GET", "/api/v1/history?symbol=aapl&metric=P/E+Ratio", nil))
+	var got APIMetricHistory
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("history = %d %s", rec.Code, rec.Body.String())
+	}
+	if got.Symbol != "AAPL" || len(got.Points) != 4 || got.Points[3] != (APIMetricPoint{"2025-10-14", 14, "green"}) {
+		t.Errorf("history = %+v", got)
+	}
+
+	for query, code := range map[string]string{
+		"metric=Beta":         errCodeMissingSymbol,
+		"symbol=AAPL":         errCodeMissingMetric,
+		"symbol=$$&metric=PE": errCodeInvalidSymbol,
+		"symbol=A&metric=PE":  errCodeUnknownMetric,
+	} {
+		rec := httptest.NewRecorder()
+		apiV1HistoryHandler(rec, httptest.NewRequest("GET", "/api/v1/history?"+query, nil))
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), code) {
+			t.Errorf("%q: %d %s", query, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestIsMetricNameCoversCards(t *testing.T) {
+	for _, m := range buildMetricsList(testResult()) {
+		if !isMetricName(m.Name) {
+			t.Errorf("card %q is not a known metric name", m.Name)
+		}
+	}
+}
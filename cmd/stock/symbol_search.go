@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	g "maragu.dev/gomponents"
+	. "maragu.dev/gomponents/html"
+)
+
+// Yahoo's search needs no crumb, so it's cheap next to a quote fetch, but the
+// home page asks on every keystroke. Answers are cached per query, and every
+// symbol seen goes into a local directory that is searched instead when Yahoo
+// can't be reached.
+const (
+	searchCacheTTL     = 24 * time.Hour
+	maxCachedSearches  = 1000
+	maxSearchResults   = 10
+	defaultSearchLimit = 8
+	// Longer queries are cut short; no name or ticker needs more.
+	maxSearchQueryLen = 100
+)
+
+type APISymbol struct {
+	Symbol   string `json:"symbol"`
+	Name     string `json:"name"`
+	Exchange string `json:"exchange" doc:"Exchange display name, e.g. NASDAQ."`
+	Type     string `json:"type" doc:"Yahoo quote type, e.g. EQUITY, ETF, MUTUALFUND, INDEX or CRYPTOCURRENCY."`
+}
+
+type APISearch struct {
+	Query   string      `json:"query"`
+	Results []APISymbol `json:"results"`
+}
+
+type cachedSearch struct {
+	results []APISymbol
+	at      time.Time
+}
+
+var (
+	searchMu    sync.Mutex
+	searchCache = map[string]cachedSearch{}
+	// Every symbol Yahoo has returned, by symbol. Loaded on first use.
+	symbolDirectory map[string]APISymbol
+)
+
+func symbolDirectoryPath() string {
+	return filepath.Join(g_dataDir, "symbols.json")
+}
+
+// searchSymbols finds symbols whose ticker or name matches query.
+func searchSymbols(ctx context.Context, query string, limit int) ([]APISymbol, error) {
+	q := strings.ToLower(strings.TrimSpace(query))
+	if len(q) > maxSearchQueryLen {
+		q = strings.ToValidUTF8(q[:maxSearchQueryLen], "")
+	}
+	if q == "" {
+		return nil, nil
+	}
+	searchMu.Lock()
+	c, ok := searchCache[q]
+	searchMu.Unlock()
+	if ok && time.Since(c.at) < searchCacheTTL {
+		return firstSymbols(c.results, limit), nil
+	}
+
+	results, err := requestYahooSearch(ctx, q)
+	if err != nil {
+		if local := searchDirectory(q, limit); len(local) > 0 {
+			slog.WarnContext(ctx, "Symbol search failed, using the local directory", "query", q, "err", err)
+			return local, nil
+		}
+		return nil, err
+	}
+	rememberSearch(q, results)
+	return firstSymbols(results, limit), nil
+}
+
+func firstSymbols(list []APISymbol, n int) []APISymbol {
+	if len(list) > n {
+		return list[:n]
+	}
+	return list
+}
+
+func requestYahooSearch(ctx context.Context, q string) ([]APISymbol, error) {
+	searchURL := fmt.Sprintf("%s/v1/finance/search?q=%s&quotesCount=%d&newsCount=0&listsCount=0",
+		yahooQueryBaseURL, url.QueryEscape(q), maxSearchResults)
+	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; chromedp)")
+	if err := waitForUpstream(ctx); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer func() {
+		upstreamDuration.Observe(time.Since(start).Seconds(), "search")
+	}()
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		upstreamErrors.Inc("search", upstreamErrNetwork)
+		return nil, fmt.Errorf("search request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		upstreamErrors.Inc("search", upstreamErrStatus)
+		return nil, fmt.Errorf("search returned %s", resp.Status)
+	}
+
+	var body struct {
+		Quotes []struct {
+			Symbol    string `json:"symbol"`
+			ShortName string `json:"shortname"`
+			LongName  string `json:"longname"`
+			Exchange  string `json:"exchange"`
+			ExchDisp  string `json:"exchDisp"`
+			QuoteType string `json:"quoteType"`
+		} `json:"quotes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		upstreamErrors.Inc("search", upstreamErrDecode)
+		return nil, fmt.Errorf("could not parse search response: %v", err)
+	}
+	results := []APISymbol{}
+	for _, item := range body.Quotes {
+		if item.Symbol == "" {
+			continue
+		}
+		s := APISymbol{Symbol: item.Symbol, Name: item.LongName, Exchange: item.ExchDisp, Type: item.QuoteType}
+		if s.Name == "" {
+			s.Name = item.ShortName
+		}
+		if s.Exchange == "" {
+			s.Exchange = item.Exchange
+		}
+		results = append(results, s)
+	}
+	slog.DebugContext(ctx, "Symbol search", "query", q, "results", len(results), "duration", time.Since(start))
+	return results, nil
+}
+
+// rememberSearch caches the answer to q and adds its symbols to the directory.
+func rememberSearch(q string, results []APISymbol) {
+	searchMu.Lock()
+	defer searchMu.Unlock()
+	if len(searchCache) >= maxCachedSearches {
+		for k, c := range searchCache {
+			if time.Since(c.at) >= searchCacheTTL {
+				delete(searchCache, k)
+			}
+		}
+		if len(searchCache) >= maxCachedSearches {
+			searchCache = map[string]cachedSearch{}
+		}
+	}
+	searchCache[q] = cachedSearch{results: results, at: time.Now()}
+
+	loadSymbolDirectory()
+	changed := false
+	for _, s := range results {
+		if symbolDirectory[s.Symbol] != s {
+			symbolDirectory[s.Symbol] = s
+			changed = true
+		}
+	}
+	if changed {
+		if err := saveSymbolDirectory(); err != nil {
+			slog.Error("Could not save symbol directory", "err", err)
+		}
+	}
+}
+
+// loadSymbolDirectory reads the directory the first time it's needed. Callers
+// hold searchMu.
+func loadSymbolDirectory() {
+	if symbolDirectory != nil {
+		return
+	}
+	symbolDirectory = map[string]APISymbol{}
+	data, err := ioutil.ReadFile(symbolDirectoryPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	var list []APISymbol
+	if err == nil {
+		err = json.Unmarshal(data, &list)
+	}
+	if err != nil {
+		slog.Error("Could not load symbol directory, starting empty", "err", err)
+		return
+	}
+	for _, s := range list {
+		symbolDirectory[s.Symbol] = s
+	}
+}
+
+func saveSymbolDirectory() error {
+	list := make([]APISymbol, 0, len(symbolDirectory))
+	for _, s := range symbolDirectory {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Symbol < list[j].Symbol })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writePrivateFile(symbolDirectoryPath(), data)
+}
+
+// searchDirectory ranks known symbols against q: the exact ticker, then
+// tickers starting with q, then names with a word starting with q, then names
+// containing it.
+func searchDirectory(q string, limit int) []APISymbol {
+	searchMu.Lock()
+	defer searchMu.Unlock()
+	loadSymbolDirectory()
+
+	type match struct {
+		s     APISymbol
+		score int
+	}
+	var matches []match
+	for _, s := range symbolDirectory {
+		sym, name := strings.ToLower(s.Symbol), strings.ToLower(s.Name)
+		score := -1
+		switch {
+		case sym == q:
+			score = 0
+		case strings.HasPrefix(sym, q):
+			score = 1
+		case hasWordPrefix(name, q):
+			score = 2
+		case strings.Contains(name, q):
+			score = 3
+		}
+		if score >= 0 {
+			matches = append(matches, match{s, score})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score < b.score
+		}
+		if len(a.s.Symbol) != len(b.s.Symbol) {
+			return len(a.s.Symbol) < len(b.s.Symbol)
+		}
+		return a.s.Symbol < b.s.Symbol
+	})
+	results := []APISymbol{}
+	for _, m := range matches {
+		results = append(results, m.s)
+	}
+	return firstSymbols(results, limit)
+}
+
+func hasWordPrefix(s, prefix string) bool {
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == '-' || r == ',' || r == '.' }) {
+		if strings.HasPrefix(w, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// searchHandler serves /api/v1/search, and /suggest for the home page's
+// autocomplete, which must work without an API key.
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "only GET is supported")
+		return
+	}
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeAPIError(w, http.StatusBadRequest, errCodeMissingQuery, "q parameter required")
+		return
+	}
+	limit := defaultSearchLimit
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = min(n, maxSearchResults)
+	}
+	results, err := searchSymbols(r.Context(), q, limit)
+	var busy *upstreamBusyError
+	if errors.As(err, &busy) {
+		writeRateLimited(w, r, busy.retryAfter)
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, errCodeUpstream, err.Error())
+		return
+	}
+	if results == nil {
+		results = []APISymbol{}
+	}
+	writeAPIJSON(w, http.StatusOK, APISearch{Query: q, Results: results})
+}
+
+// symbolNotFoundPage offers close matches for a ticker Yahoo doesn't know or
+// input that isn't a ticker at all.
+func symbolNotFoundPage(message string, suggestions []APISymbol) g.Node {
+	var items []g.Node
+	for _, s := range suggestions {
+		items = append(items, Li(
+			A(Href("/stock?symbol="+url.QueryEscape(s.Symbol)), Class("text-blue-400 hover:underline font-semibold"), g.Text(s.Symbol)),
+			Span(Class("text-gray-400 text-sm"), g.Text(" "+s.Name+" · "+s.Exchange)),
+		))
+	}
+	return HTML(
+		Head(
+			Meta(Charset("UTF-8")),
+			Meta(Name("viewport"), Content("width=device-width, initial-scale=1.0")),
+			TitleEl(g.Text("Symbol not found")),
+			Script(Src("https://cdn.tailwindcss.com")),
+		),
+		Body(Class("bg-gray-900 text-gray-100 min-h-screen flex items-center justify-center"),
+			Div(Class("container mx-auto px-4"),
+				Div(Class("max-w-md mx-auto bg-gray-800 rounded-lg shadow-lg p-8"),
+					H1(Class("text-2xl font-bold text-red-400 mb-4"), g.Text("Symbol not found")),
+					P(Class("text-gray-300 mb-4"), g.Text(message)),
+					g.If(len(items) > 0, Div(Class("mb-4"),
+						P(Class("text-gray-300 mb-2"), g.Text("Did you mean:")),
+						Ul(Class("space-y-1"), g.Group(items)),
+					)),
+					A(Href("/"), Class("text-blue-400 hover:underline"), g.Text("← Back to Home")),
+				),
+			),
+		),
+	)
+}
+
+// symbolSearchScript drives the home page's autocomplete, asking /suggest as
+// the user types.
+const symbolSearchScript = `
+function symbolSearch() {
+  return {
+    isLoading: false, query: '', results: [], active: -1, timer: null,
+    search() {
+      clearTimeout(this.timer);
+      const q = this.query.trim();
+      if (!q) { this.results = []; return; }
+      this.timer = setTimeout(async () => {
+        try {
+          const resp = await fetch('/suggest?q=' + encodeURIComponent(q));
+          if (!resp.ok) return;
+          const data = await resp.json();
+          // Drop answers for what the user has since typed past.
+          if (q === this.query.trim()) { this.results = data.results; this.active = -1; }
+        } catch (e) {}
+      }, 200);
+    },
+    move(step) {
+      if (this.results.length) this.active = (this.active + step + this.results.length) % this.results.length;
+    },
+    choose(s) {
+      this.query = s.symbol;
+      this.results = [];
+      this.$nextTick(() => this.$refs.form.requestSubmit());
+    },
+  };
+}`
+
+// symbolSuggestions is the autocomplete dropdown under the symbol input.
+func symbolSuggestions() g.Node {
+	return Ul(
+		g.Attr("x-show", "results.length > 0"),
+		g.Attr("@click.outside", "results = []"),
+		Class("absolute z-10 w-full mt-1 bg-white border border-gray-200 rounded-lg shadow-lg max-h-72 overflow-auto"),
+		g.El("template", g.Attr("x-for", "(s, i) in results"), g.Attr(":key", "s.symbol"),
+			Li(
+				g.Attr("@mousedown.prevent", "choose(s)"),
+				g.Attr(":class", "i === active ? 'bg-blue-50' : ''"),
+				Class("px-4 py-2 cursor-pointer hover:bg-blue-50 flex justify-between gap-2"),
+				Span(Class("font-semibold text-gray-900"), g.Attr("x-text", "s.symbol")),
+				Span(Class("text-sm text-gray-500 truncate"), g.Attr("x-text", "s.name + (s.exchange ? ' · ' + s.exchange : '')")),
+			),
+		),
+	)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testSearchResponse = `{"quotes":[
+	{"symbol":"AAPL","shortname":"Apple Inc.","longname":"Apple Inc.","exchange":"NMS","exchDisp":"NASDAQ","quoteType":"EQUITY"},
+	{"symbol":"APLE","shortname":"Apple Hospitality REIT, Inc.","exchange":"NYQ","exchDisp":"NYSE","quoteType":"EQUITY"},
+	{"index":"news-only"}
+]}`
+
+// withFakeSearch serves testSearchResponse from a fake Yahoo with fresh search
+// state, returning how many searches reached it.
+func withFakeSearch(t *testing.T, fail *atomic.Bool) *int32 {
+	var searches int32
+	withFakeYahoo(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/finance/search" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&searches, 1)
+		if fail != nil && fail.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(testSearchResponse))
+	})
+	searchCache, symbolDirectory = map[string]cachedSearch{}, nil
+	t.Cleanup(func() { searchCache, symbolDirectory = map[string]cachedSearch{}, nil })
+	return &searches
+}
+
+func TestSearchSymbolsCachesAndFallsBack(t *testing.T) {
+	var fail atomic.Bool
+	searches := withFakeSearch(t, &fail)
+	ctx := context.Background()
+
+	got, err := searchSymbols(ctx, " APPL ", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != (APISymbol{"AAPL", "Apple Inc.", "NASDAQ", "EQUITY"}) || got[1].Name != "Apple Hospitality REIT, Inc." {
+		t.Errorf("results = %+v", got)
+	}
+	if got, _ := searchSymbols(ctx, "appl", 1); len(got) != 1 || *searches != 1 {
+		t.Errorf("cached search: %d results, %d upstream calls", len(got), *searches)
+	}
+
+	// With Yahoo down, symbols seen before are still found, from disk.
+	fail.Store(true)
+	symbolDirectory = nil
+	got, err = searchSymbols(ctx, "hospitality", 8)
+	if err != nil || len(got) != 1 || got[0].Symbol != "APLE" {
+		t.Errorf("directory fallback = %+v, %v", got, err)
+	}
+	if _, err := searchSymbols(ctx, "zzzz", 8); err == nil {
+		t.Error("no error when Yahoo is down and nothing matches locally")
+	}
+}
+
+func TestSearchDirectoryRanking(t *testing.T) {
+	withFakeSearch(t, nil)
+	symbolDirectory = map[string]APISymbol{}
+	for _, s := range []APISymbol{
+		{Symbol: "MSFT", Name: "Microsoft Corporation"},
+		{Symbol: "MS", Name: "Morgan Stanley"},
+		{Symbol: "MSTR", Name: "MicroStrategy Incorporated"},
+		{Symbol: "XMS", Name: "Example Systems"},
+		{Symbol: "AMS", Name: "Alpha Microsystems"},
+	} {
+		symbolDirectory[s.Symbol] = s
+	}
+	var symbols []string
+	for _, s := range searchDirectory("ms", 10) {
+		symbols = append(symbols, s.Symbol)
+	}
+	// Exact ticker, ticker prefixes (shortest first), then names containing it.
+	if got := strings.Join(symbols, ","); got != "MS,MSFT,MSTR,AMS,XMS" {
+		t.Errorf("ms -> %s", got)
+	}
+	if got := searchDirectory("micro", 10); len(got) != 3 || got[0].Symbol != "AMS" {
+		t.Errorf("micro -> %+v", got)
+	}
+}
+
+func TestSearchHandler(t *testing.T) {
+	withFakeSearch(t, nil)
+	rec := httptest.NewRecorder()
+	searchHandler(rec, httptest.NewRequest("GET", "/api/v1/search?q=apple&limit=1", nil))
+	var body APISearch
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("search = %d %s", rec.Code, rec.Body.String())
+	}
+	if body.Query != "apple" || len(body.Results) != 1 || body.Results[0].Symbol != "AAPL" {
+		t.Errorf("body = %+v", body)
+	}
+
+	rec = httptest.NewRecorder()
+	searchHandler(rec, httptest.NewRequest("GET", "/api/v1/search", nil))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), errCodeMissingQuery) {
+		t.Errorf("no query = %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestStockPageSuggestsOnUnknownSymbol(t *testing.T) {
+	withFakeSearch(t, nil)
+	withFetcher(t, func(string) (*Result, error) { return nil, ErrSymbolNotFound })
+	rec := httptest.NewRecorder()
+	stockHandler(rec, httptest.NewRequest("GET", "/stock?symbol=APPL", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "Did you mean") ||
+		!strings.Contains(rec.Body.String(), `href="/stock?symbol=AAPL"`) {
+		t.Errorf("unknown symbol page = %d %s", rec.Code, rec.Body.String())
+	}
+
+	// A company name isn't a ticker, so it is searched for without a fetch.
+	withFetcher(t, func(symbol string) (*Result, error) {
+		t.Errorf("fetched %q", symbol)
+		return nil, ErrSymbolNotFound
+	})
+	rec = httptest.NewRecorder()
+	stockHandler(rec, httptest.NewRequest("GET", "/stock?symbol=Apple+Inc", nil))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "not a valid ticker") ||
+		!strings.Contains(rec.Body.String(), `href="/stock?symbol=AAPL"`) {
+		t.Errorf("company name page = %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	common "app/internal/common"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The bootstrap certificate is replaced on startup once it has expired.
+const selfSignedValidFor = 365 * 24 * time.Hour
+
+// Serves tls.redirect_port. It only redirects, so shutdown just closes it.
+var redirectServer *http.Server
+
+// Where the bootstrap certificate goes when tls.cert_file isn't set.
+func selfSignedCertPaths(dataDir string) (certFile, keyFile string) {
+	dir := filepath.Join(dataDir, "tls")
+	return filepath.Join(dir, "self-signed.crt"), filepath.Join(dir, "self-signed.key")
+}
+
+// setupTLS returns the server TLS config and the HSTS max-age to send, or a
+// nil config when TLS is off.
+func setupTLS(cfg Config) (*tls.Config, time.Duration, error) {
+	if !cfg.TLS.Enabled {
+		return nil, 0, nil
+	}
+	certFile, keyFile, hsts := cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.HSTSMaxAge
+	if certFile == "" {
+		certFile, keyFile = selfSignedCertPaths(cfg.DataDir)
+		hsts = 0
+		if !usableCert(certFile, keyFile) {
+			slog.Warn("No tls.cert_file configured, generating a self-signed certificate", "cert", certFile)
+			if err := common.GenerateSelfSignedCert(certFile, keyFile, selfSignedHosts(cfg.Listen.IP), selfSignedValidFor); err != nil {
+				return nil, 0, fmt.Errorf("self-signed certificate: %v", err)
+			}
+		}
+	}
+	r, err := common.NewCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, 0, fmt.Errorf("tls: %v", err)
+	}
+	return r.TLSConfig(), hsts, nil
+}
+
+// usableCert reports whether the key pair loads and hasn't expired.
+func usableCert(certFile, keyFile string) bool {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	return err == nil && time.Now().Before(cert.Leaf.NotAfter)
+}
+
+// The names a browser might use to reach this machine.
+func selfSignedHosts(listenIP string) []string {
+	var hosts []string
+	if name, err := os.Hostname(); err == nil && name != "localhost" {
+		hosts = append(hosts, name)
+	}
+	hosts = append(hosts, "localhost", "127.0.0.1", "::1")
+	if listenIP != "" && listenIP != "0.0.0.0" && listenIP != "::" {
+		hosts = append(hosts, listenIP)
+	}
+	return hosts
+}
+
+// startRedirectServer sends plain HTTP on tls.redirect_port to HTTPS.
+func startRedirectServer(cfg Config) {
+	redirectServer = &http.Server{Addr: cfg.redirectAddr(), Handler: common.RedirectToHTTPS(cfg.Listen.Port)}
+	srv := redirectServer
+	go func() {
+		slog.Info("Redirecting to HTTPS", "from", "http://"+cfg.redirectAddr())
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Redirect server failed", "addr", cfg.redirectAddr(), "err", err)
+		}
+	}()
+}
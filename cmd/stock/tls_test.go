@@ -0,0 +1,72 @@
+package main
+
+import (
+	common "app/internal/common"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSetupTLSBootstrapsSelfSigned(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.DataDir = t.TempDir()
+	if tc, _, err := setupTLS(cfg); tc != nil || err != nil {
+		t.Fatalf("TLS off = %v, %v", tc, err)
+	}
+
+	cfg.TLS.Enabled = true
+	tc, hsts, err := setupTLS(cfg)
+	if err != nil || tc == nil {
+		t.Fatalf("setupTLS = %v, %v", tc, err)
+	}
+	if hsts != 0 {
+		t.Errorf("HSTS %s sent with a self-signed certificate", hsts)
+	}
+	certFile, keyFile := selfSignedCertPaths(cfg.DataDir)
+	first, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Later runs keep the same certificate, so browsers' exceptions stick.
+	if _, _, err := setupTLS(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := os.ReadFile(certFile); !bytes.Equal(first, again) {
+		t.Error("self-signed certificate regenerated on restart")
+	}
+
+	// An expired one is replaced.
+	common.GenerateSelfSignedCert(certFile, keyFile, []string{"localhost"}, -time.Minute)
+	if _, _, err := setupTLS(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !usableCert(certFile, keyFile) {
+		t.Error("expired self-signed certificate not replaced")
+	}
+}
+
+func TestSetupTLSWithConfiguredCert(t *testing.T) {
+	dir := t.TempDir()
+	cfg := defaultConfig()
+	cfg.DataDir = dir
+	cfg.TLS.Enabled = true
+	cfg.TLS.CertFile, cfg.TLS.KeyFile = filepath.Join(dir, "fullchain.pem"), filepath.Join(dir, "privkey.pem")
+	if _, _, err := setupTLS(cfg); err == nil {
+		t.Error("missing certificate files were accepted")
+	}
+
+	common.GenerateSelfSignedCert(cfg.TLS.CertFile, cfg.TLS.KeyFile, []string{"stock.example.com"}, time.Hour)
+	_, hsts, err := setupTLS(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hsts != cfg.TLS.HSTSMaxAge {
+		t.Errorf("HSTS = %s, want %s", hsts, cfg.TLS.HSTSMaxAge)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tls")); err == nil {
+		t.Error("bootstrap certificate generated despite tls.cert_file")
+	}
+}
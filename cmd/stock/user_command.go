@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// runUserCommand handles `stock user add|passwd|list|remove` for
+// auth.login: local. Passwords are read from the first line of stdin so they
+// stay out of the shell history and process list.
+func runUserCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	usage := "usage: stock user add USERNAME [-name NAME] | passwd USERNAME | list | remove USERNAME"
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, usage)
+		return 2
+	}
+	fs := flag.NewFlagSet("user "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	f := addConfigFlags(fs)
+	name := fs.String("name", "", "display name (add)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	// Flags may also come after the username, as in `stock user add alice -name Alice`.
+	var username string
+	if fs.NArg() > 0 {
+		username = fs.Arg(0)
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return 2
+		}
+	}
+	cfg, err := loadConfig(f)
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid config:\n%v\n", err)
+		return 1
+	}
+
+	store := newUserStore(userStorePath(cfg.DataDir))
+	if err := store.load(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	switch args[0] {
+	case "add", "passwd":
+		if username == "" {
+			fmt.Fprintln(stderr, usage)
+			return 2
+		}
+		_, exists := store.users[username]
+		if args[0] == "add" && exists {
+			fmt.Fprintf(stderr, "user %q already exists; use passwd to change the password\n", username)
+			return 1
+		}
+		if args[0] == "passwd" && !exists {
+			fmt.Fprintf(stderr, "no user %q\n", username)
+			return 1
+		}
+		fmt.Fprintf(stderr, "Password for %s: ", username)
+		password, err := bufio.NewReader(stdin).ReadString('\n')
+		fmt.Fprintln(stderr)
+		if err != nil && err != io.EOF {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		err = store.setPassword(username, *name, strings.TrimRight(password, "\r\n"))
+		if err == nil {
+			err = store.save()
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if args[0] == "add" {
+			fmt.Fprintf(stdout, "Added user %s\n", username)
+		} else {
+			fmt.Fprintf(stdout, "Changed password for %s\n", username)
+		}
+		return 0
+	case "list":
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "USERNAME\tNAME\tCREATED")
+		for _, u := range store.list() {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", u.Username, u.Name, u.Created.Format(time.DateOnly))
+		}
+		tw.Flush()
+		return 0
+	case "remove":
+		if username == "" {
+			fmt.Fprintln(stderr, usage)
+			return 2
+		}
+		err := store.remove(username)
+		if err == nil {
+			err = store.save()
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "Removed user %s. Their data stays in the data dir.\n", username)
+		return 0
+	}
+	fmt.Fprintln(stderr, usage)
+	return 2
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// How often the server checks the users file for changes made by the CLI.
+const userReloadInterval = 5 * time.Second
+
+// Replaced in tests; the default cost makes each check take ~50ms.
+var bcryptCost = bcrypt.DefaultCost
+
+var errBadCredentials = errors.New("wrong username or password")
+
+// Usernames end up in user IDs and data paths, so keep them plain.
+var validUsername = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// Compared against when the username doesn't exist, so unknown and known
+// users take equally long to reject.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+// localUser is an account for auth.login: local.
+type localUser struct {
+	Username string    `json:"username"`
+	Name     string    `json:"name,omitempty"`
+	Hash     string    `json:"hash"`
+	Created  time.Time `json:"created"`
+}
+
+// userStore is the users file in the data dir, reloaded when it changes on
+// disk. Same shape as apiKeyStore.
+type userStore struct {
+	path string
+
+	mu        sync.Mutex
+	users     map[string]*localUser
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func userStorePath(dataDir string) string {
+	return filepath.Join(dataDir, "users.json")
+}
+
+func newUserStore(path string) *userStore {
+	return &userStore{path: path, users: map[string]*localUser{}}
+}
+
+// load reads the users file. A missing file is an empty store.
+func (s *userStore) load() error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.users = map[string]*localUser{}
+		s.modTime = time.Time{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var list []*localUser
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("%s: %v", s.path, err)
+	}
+	users := make(map[string]*localUser, len(list))
+	for _, u := range list {
+		users[u.Username] = u
+	}
+	s.users, s.modTime = users, info.ModTime()
+	return nil
+}
+
+// save writes the users file. Only the owner can read it.
+func (s *userStore) save() error {
+	data, err := json.MarshalIndent(s.list(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writePrivateFile(s.path, data)
+}
+
+// list returns the users sorted by username.
+func (s *userStore) list() []*localUser {
+	list := make([]*localUser, 0, len(s.users))
+	for _, u := range s.users {
+		list = append(list, u)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Username < list[j].Username })
+	return list
+}
+
+// setPassword creates the user, or changes the password of an existing one.
+func (s *userStore) setPassword(username, name, password string) error {
+	if !validUsername.MatchString(username) {
+		return fmt.Errorf("username %q must be lowercase letters, digits, '.', '_' or '-'", username)
+	}
+	if len(password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+	// bcrypt ignores anything past 72 bytes and newer versions refuse it.
+	if len(password) > 72 {
+		return errors.New("password must be at most 72 bytes")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return err
+	}
+	u, ok := s.users[username]
+	if !ok {
+		u = &localUser{Username: username, Created: time.Now().UTC()}
+		s.users[username] = u
+	}
+	if name != "" {
+		u.Name = name
+	}
+	u.Hash = string(hash)
+	return nil
+}
+
+func (s *userStore) remove(username string) error {
+	if _, ok := s.users[username]; !ok {
+		return fmt.Errorf("no user %q", username)
+	}
+	delete(s.users, username)
+	return nil
+}
+
+// authenticate checks a username and password.
+func (s *userStore) authenticate(username, password string) (*localUser, error) {
+	u := s.lookup(username)
+	hash := dummyPasswordHash
+	if u != nil {
+		hash = []byte(u.Hash)
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || u == nil {
+		return nil, errBadCredentials
+	}
+	return u, nil
+}
+
+// lookup returns the user, or nil once they've been removed. Usernames are
+// stored lowercase, so the login form and Basic auth match any case.
+func (s *userStore) lookup(username string) *localUser {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadIfChanged()
+	return s.users[normalizeUsername(username)]
+}
+
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// Picks up users added or removed by the CLI while the server runs.
+func (s *userStore) reloadIfChanged() {
+	if time.Since(s.lastCheck) < userReloadInterval {
+		return
+	}
+	s.lastCheck = time.Now()
+	info, err := os.Stat(s.path)
+	if err == nil && info.ModTime().Equal(s.modTime) {
+		return
+	}
+	if errors.Is(err, os.ErrNotExist) && s.modTime.IsZero() {
+		return
+	}
+	if err := s.load(); err != nil {
+		// Keep serving with the users we have.
+		slog.Error("Could not reload users", "err", err)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func withCheapBcrypt(t *testing.T) {
+	orig := bcryptCost
+	bcryptCost = bcrypt.MinCost
+	t.Cleanup(func() { bcryptCost = orig })
+}
+
+func TestUserStoreRoundTrip(t *testing.T) {
+	withCheapBcrypt(t)
+	path := filepath.Join(t.TempDir(), "users.json")
+	s := newUserStore(path)
+	if err := s.setPassword("alice", "Alice", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "correct horse") || !strings.Contains(string(data), "$2a$") {
+		t.Errorf("users file should hold a bcrypt hash only: %s", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("users file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	server := newUserStore(path)
+	if err := server.load(); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := server.authenticate("alice", "correct horse"); err != nil || u.Name != "Alice" {
+		t.Fatalf("authenticate = %+v, %v", u, err)
+	}
+	for _, bad := range [][2]string{{"alice", "wrong"}, {"bob", "correct horse"}, {"", ""}} {
+		if _, err := server.authenticate(bad[0], bad[1]); err != errBadCredentials {
+			t.Errorf("authenticate(%q, %q) = %v, want errBadCredentials", bad[0], bad[1], err)
+		}
+	}
+
+	// The CLI removes in another process; the server notices on its next check.
+	if err := s.remove("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+	server.lastCheck = server.lastCheck.Add(-userReloadInterval)
+	server.modTime = server.modTime.Add(-1)
+	if server.lookup("alice") != nil {
+		t.Error("removed user still found")
+	}
+}
+
+func TestUserStoreValidates(t *testing.T) {
+	withCheapBcrypt(t)
+	s := newUserStore(filepath.Join(t.TempDir(), "users.json"))
+	for _, name := range []string{"", "Alice", "../etc", "a b", strings.Repeat("a", 65)} {
+		if err := s.setPassword(name, "", "long enough"); err == nil {
+			t.Errorf("username %q was accepted", name)
+		}
+	}
+	if err := s.setPassword("alice", "", "short"); err == nil {
+		t.Error("short password was accepted")
+	}
+	if err := s.setPassword("alice", "", strings.Repeat("x", 73)); err == nil {
+		t.Error("password over bcrypt's 72 byte limit was accepted")
+	}
+}
+
+func TestUserCommand(t *testing.T) {
+	withCheapBcrypt(t)
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.yaml")
+	os.WriteFile(config, []byte("data_dir: "+dir+"\n"), 0644)
+	run := func(stdin string, args ...string) (int, string) {
+		var out, errOut strings.Builder
+		code := runUserCommand(append(args[:1:1], append([]string{"-config", config}, args[1:]...)...),
+			strings.NewReader(stdin), &out, &errOut)
+		return code, out.String() + errOut.String()
+	}
+
+	if code, out := run("hunter2hunter2\n", "add", "alice", "-name", "Alice A"); code != 0 {
+		t.Fatalf("add = %d %q", code, out)
+	}
+	if code, _ := run("hunter2hunter2\n", "add", "alice"); code != 1 {
+		t.Errorf("adding an existing user = %d, want 1", code)
+	}
+	if code, out := run("", "list"); code != 0 || !strings.Contains(out, "Alice A") {
+		t.Errorf("list = %d %q", code, out)
+	}
+	if code, out := run("new password\n", "passwd", "alice"); code != 0 {
+		t.Fatalf("passwd = %d %q", code, out)
+	}
+	s := newUserStore(userStorePath(dir))
+	s.load()
+	if _, err := s.authenticate("alice", "new password"); err != nil {
+		t.Errorf("new password rejected: %v", err)
+	}
+	if code, _ := run("", "remove", "alice"); code != 0 {
+		t.Errorf("remove = %d", code)
+	}
+	if code, _ := run("", "remove", "alice"); code != 1 {
+		t.Errorf("removing an unknown user = %d, want 1", code)
+	}
+	if code, _ := run("", "add"); code != 2 {
+		t.Errorf("add without a username = %d, want 2", code)
+	}
+}
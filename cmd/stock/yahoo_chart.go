@@ -0,0 +1,113 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Daily closes back volatility for instruments without fundamentals, i.e.
+// crypto and currency pairs. quoteSummary has no price history, so they come
+// from Yahoo's chart endpoint and are kept in memory for quoteCacheTTL.
+const chartRange = "3mo"
+
+type cachedCloses struct {
+	closes []float64
+	at     time.Time
+}
+
+var (
+	chartMu    sync.Mutex
+	chartCache = map[common.Symbol]cachedCloses{}
+)
+
+// getDailyCloses returns the daily closes for ticker over chartRange, oldest
+// first.
+func getDailyCloses(ctx context.Context, ticker common.Symbol) ([]float64, error) {
+	chartMu.Lock()
+	c, ok := chartCache[ticker]
+	chartMu.Unlock()
+	if ok && time.Since(c.at) < quoteCacheTTL {
+		return c.closes, nil
+	}
+
+	closes, err := requestDailyCloses(ctx, ticker)
+	if err != nil {
+		return nil, err
+	}
+	chartMu.Lock()
+	chartCache[ticker] = cachedCloses{closes: closes, at: time.Now()}
+	chartMu.Unlock()
+	return closes, nil
+}
+
+func requestDailyCloses(ctx context.Context, ticker common.Symbol) ([]float64, error) {
+	var body struct {
+		Chart struct {
+			Result []struct {
+				Indicators struct {
+					Quote []struct {
+						// Null on days without trades.
+						Close []*float64 `json:"close"`
+					} `json:"quote"`
+				} `json:"indicators"`
+			} `json:"result"`
+		} `json:"chart"`
+	}
+	start := time.Now()
+	if err := requestChart(ctx, ticker, "range="+chartRange+"&interval=1d", &body); err != nil {
+		return nil, err
+	}
+	if len(body.Chart.Result) == 0 || len(body.Chart.Result[0].Indicators.Quote) == 0 {
+		upstreamErrors.Inc("chart", upstreamErrNotFound)
+		return nil, fmt.Errorf("no chart data for %s", ticker)
+	}
+	var closes []float64
+	for _, c := range body.Chart.Result[0].Indicators.Quote[0].Close {
+		if c != nil {
+			closes = append(closes, *c)
+		}
+	}
+	slog.DebugContext(ctx, "Fetched daily closes", "ticker", ticker, "days", len(closes), "duration", time.Since(start))
+	return closes, nil
+}
+
+// requestChart decodes the chart endpoint's response for ticker and query
+// into out.
+func requestChart(ctx context.Context, ticker common.Symbol, query string, out any) error {
+	chartURL := fmt.Sprintf("%s/v8/finance/chart/%s?%s", yahooQueryBaseURL, ticker.PathEscape(), query)
+	req, err := http.NewRequestWithContext(ctx, "GET", chartURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; chromedp)")
+	if err := waitForUpstream(ctx); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer func() {
+		upstreamDuration.Observe(time.Since(start).Seconds(), "chart")
+	}()
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		upstreamErrors.Inc("chart", upstreamErrNetwork)
+		return fmt.Errorf("chart request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		upstreamErrors.Inc("chart", upstreamErrStatus)
+		return fmt.Errorf("chart returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		upstreamErrors.Inc("chart", upstreamErrDecode)
+		return fmt.Errorf("could not parse chart response: %v", err)
+	}
+	return nil
+}
@@ -14,6 +14,31 @@ type Result struct {
 	DefaultKeyStatistics DefaultKeyStatistics `json:"defaultKeyStatistics"`
 	Earnings             Earnings             `json:"earnings"`
 	FinancialData        FinancialData        `json:"financialData"`
+	QuoteType            QuoteType            `json:"quoteType"`
+	Price                Price                `json:"price"`
+	// Only funds have these.
+	TopHoldings     TopHoldings     `json:"topHoldings"`
+	FundPerformance FundPerformance `json:"fundPerformance"`
+	FundProfile     FundProfile     `json:"fundProfile"`
+	// Only stocks with analyst coverage have these.
+	RecommendationTrend     RecommendationTrend     `json:"recommendationTrend"`
+	UpgradeDowngradeHistory UpgradeDowngradeHistory `json:"upgradeDowngradeHistory"`
+	InstitutionOwnership    Ownership               `json:"institutionOwnership"`
+	FundOwnership           Ownership               `json:"fundOwnership"`
+	InsiderTransactions     InsiderTransactions     `json:"insiderTransactions"`
+	CalendarEvents          CalendarEvents          `json:"calendarEvents"`
+
+	// Daily closes from the chart endpoint, oldest first. Only fetched for
+	// crypto and currency pairs, which have no fundamentals to score.
+	DailyCloses []float64 `json:"-"`
+	// Every dividend paid, oldest first, from the chart endpoint. Only
+	// fetched for stocks that pay one.
+	Dividends []Dividend `json:"-"`
+	// The nearest expiration's option chain. Only fetched for instruments
+	// with listed options.
+	Options *OptionChain `json:"-"`
+	// Set by withCurrencies for pages and API responses.
+	Currencies currencies `json:"-"`
 }
 
 type PriceHint struct {
@@ -57,13 +82,13 @@ type SummaryDetail struct {
 	BidSize                      FmtRaw      `json:"bidSize"`
 	AskSize                      FmtRaw      `json:"askSize"`
 	MarketCap                    FmtRaw      `json:"marketCap"`
-	Yield                        interface{} `json:"yield"`
-	YtdReturn                    interface{} `json:"ytdReturn"`
-	QtdReturn                    interface{} `json:"qtdReturn"`
-	TotalAssets                  interface{} `json:"totalAssets"`
-	ExpireDate                   interface{} `json:"expireDate"`
-	StrikePrice                  interface{} `json:"strikePrice"`
-	OpenInterest                 interface{} `json:"openInterest"`
+	Yield                        FmtRaw      `json:"yield"`
+	YtdReturn                    FmtRaw      `json:"ytdReturn"`
+	QtdReturn                    FmtRaw      `json:"qtdReturn"`
+	TotalAssets                  FmtRaw      `json:"totalAssets"`
+	ExpireDate                   FmtRaw      `json:"expireDate"`
+	StrikePrice                  FmtRaw      `json:"strikePrice"`
+	OpenInterest                 FmtRaw      `json:"openInterest"`
 	FiftyTwoWeekLow              FmtRaw      `json:"fiftyTwoWeekLow"`
 	FiftyTwoWeekHigh             FmtRaw      `json:"fiftyTwoWeekHigh"`
 	AllTimeHigh                  FmtRaw      `json:"allTimeHigh"`
@@ -73,18 +98,18 @@ type SummaryDetail struct {
 	TwoHundredDayAverage         FmtRaw      `json:"twoHundredDayAverage"`
 	TrailingAnnualDividendRate   FmtRaw      `json:"trailingAnnualDividendRate"`
 	TrailingAnnualDividendYield  FmtRaw      `json:"trailingAnnualDividendYield"`
-	NavPrice                     interface{} `json:"navPrice"`
+	NavPrice                     FmtRaw      `json:"navPrice"`
 	Currency                     string      `json:"currency"`
 	FromCurrency                 *string     `json:"fromCurrency"`
 	ToCurrency                   *string     `json:"toCurrency"`
 	LastMarket                   *string     `json:"lastMarket"`
 	CoinMarketCapLink            *string     `json:"coinMarketCapLink"`
-	Volume24Hr                   interface{} `json:"volume24Hr"`
-	VolumeAllCurrencies          interface{} `json:"volumeAllCurrencies"`
-	CirculatingSupply            interface{} `json:"circulatingSupply"`
+	Volume24Hr                   FmtRaw      `json:"volume24Hr"`
+	VolumeAllCurrencies          FmtRaw      `json:"volumeAllCurrencies"`
+	CirculatingSupply            FmtRaw      `json:"circulatingSupply"`
 	Algorithm                    *string     `json:"algorithm"`
-	MaxSupply                    interface{} `json:"maxSupply"`
-	StartDate                    interface{} `json:"startDate"`
+	MaxSupply                    FmtRaw      `json:"maxSupply"`
+	StartDate                    FmtRaw      `json:"startDate"`
 	Tradeable                    bool        `json:"tradeable"`
 }
 
@@ -112,17 +137,17 @@ type DefaultKeyStatistics struct {
 	Category                     *string     `json:"category"`
 	BookValue                    FmtRaw      `json:"bookValue"`
 	PriceToBook                  FmtRaw      `json:"priceToBook"`
-	AnnualReportExpenseRatio     interface{} `json:"annualReportExpenseRatio"`
-	YtdReturn                    interface{} `json:"ytdReturn"`
-	QtdReturn                    interface{} `json:"qtdReturn"`
-	Beta3Year                    interface{} `json:"beta3Year"`
-	TotalAssets                  interface{} `json:"totalAssets"`
-	Yield                        interface{} `json:"yield"`
+	AnnualReportExpenseRatio     FmtRaw      `json:"annualReportExpenseRatio"`
+	YtdReturn                    FmtRaw      `json:"ytdReturn"`
+	QtdReturn                    FmtRaw      `json:"qtdReturn"`
+	Beta3Year                    FmtRaw      `json:"beta3Year"`
+	TotalAssets                  FmtRaw      `json:"totalAssets"`
+	Yield                        FmtRaw      `json:"yield"`
 	FundFamily                   *string     `json:"fundFamily"`
-	FundInceptionDate            interface{} `json:"fundInceptionDate"`
+	FundInceptionDate            FmtRaw      `json:"fundInceptionDate"`
 	LegalType                    *string     `json:"legalType"`
-	ThreeYearAverageReturn       interface{} `json:"threeYearAverageReturn"`
-	FiveYearAverageReturn        interface{} `json:"fiveYearAverageReturn"`
+	ThreeYearAverageReturn       FmtRaw      `json:"threeYearAverageReturn"`
+	FiveYearAverageReturn        FmtRaw      `json:"fiveYearAverageReturn"`
 	PriceToSalesTrailing12Months interface{} `json:"priceToSalesTrailing12Months"`
 	LastFiscalYearEnd            FmtRaw      `json:"lastFiscalYearEnd"`
 	NextFiscalYearEnd            FmtRaw      `json:"nextFiscalYearEnd"`
@@ -143,7 +168,7 @@ type DefaultKeyStatistics struct {
 	LastDividendValue      FmtRaw      `json:"lastDividendValue"`
 	LastDividendDate       FmtRaw      `json:"lastDividendDate"`
 	LastCapGain            interface{} `json:"lastCapGain"`
-	AnnualHoldingsTurnover interface{} `json:"annualHoldingsTurnover"`
+	AnnualHoldingsTurnover FmtRaw      `json:"annualHoldingsTurnover"`
 	LatestFundingDate      interface{} `json:"latestFundingDate"`
 	LatestAmountRaised     interface{} `json:"latestAmountRaised"`
 	LatestImpliedValuation interface{} `json:"latestImpliedValuation"`
@@ -236,4 +261,149 @@ type FinancialData struct {
 	OperatingMargins        FmtRaw `json:"operatingMargins"`
 	ProfitMargins           FmtRaw `json:"profitMargins"`
 	FinancialCurrency       string `json:"financialCurrency"`
-}
\ No newline at end of file
+}
+
+// QuoteType says what kind of instrument a symbol is.
+type QuoteType struct {
+	Exchange  string `json:"exchange"`
+	QuoteType string `json:"quoteType"` // EQUITY, ETF, MUTUALFUND, CRYPTOCURRENCY, CURRENCY, INDEX or FUTURE
+	Symbol    string `json:"symbol"`
+	ShortName string `json:"shortName"`
+	LongName  string `json:"longName"`
+}
+
+// Price has the live quote for every kind of instrument, unlike financialData
+// which only stocks have.
+type Price struct {
+	RegularMarketPrice         FmtRaw `json:"regularMarketPrice"`
+	RegularMarketChangePercent FmtRaw `json:"regularMarketChangePercent"`
+	Currency                   string `json:"currency"`
+	CurrencySymbol             string `json:"currencySymbol"`
+	QuoteType                  string `json:"quoteType"`
+	ShortName                  string `json:"shortName"`
+	LongName                   string `json:"longName"`
+}
+
+type TopHoldings struct {
+	MaxAge        int       `json:"maxAge"`
+	CashPosition  FmtRaw    `json:"cashPosition"`
+	StockPosition FmtRaw    `json:"stockPosition"`
+	BondPosition  FmtRaw    `json:"bondPosition"`
+	OtherPosition FmtRaw    `json:"otherPosition"`
+	Holdings      []Holding `json:"holdings"`
+	// One single-key object per sector, e.g. [{"technology": {...}}, ...].
+	SectorWeightings []map[string]FmtRaw `json:"sectorWeightings"`
+}
+
+type Holding struct {
+	Symbol         string `json:"symbol"`
+	HoldingName    string `json:"holdingName"`
+	HoldingPercent FmtRaw `json:"holdingPercent"`
+}
+
+type FundPerformance struct {
+	MaxAge          int             `json:"maxAge"`
+	TrailingReturns TrailingReturns `json:"trailingReturns"`
+}
+
+// TrailingReturns are fractions; the multi-year ones are annualized.
+type TrailingReturns struct {
+	AsOfDate   FmtRaw `json:"asOfDate"`
+	Ytd        FmtRaw `json:"ytd"`
+	OneMonth   FmtRaw `json:"oneMonth"`
+	ThreeMonth FmtRaw `json:"threeMonth"`
+	OneYear    FmtRaw `json:"oneYear"`
+	ThreeYear  FmtRaw `json:"threeYear"`
+	FiveYear   FmtRaw `json:"fiveYear"`
+	TenYear    FmtRaw `json:"tenYear"`
+}
+
+type FundProfile struct {
+	MaxAge                 int                    `json:"maxAge"`
+	Family                 string                 `json:"family"`
+	CategoryName           string                 `json:"categoryName"`
+	LegalType              string                 `json:"legalType"`
+	FeesExpensesInvestment FeesExpensesInvestment `json:"feesExpensesInvestment"`
+}
+
+// Unlike defaultKeyStatistics, these are in percent: 0.09 means 0.09%.
+type FeesExpensesInvestment struct {
+	AnnualReportExpenseRatio FmtRaw `json:"annualReportExpenseRatio"`
+	NetExpRatio              FmtRaw `json:"netExpRatio"`
+	GrossExpRatio            FmtRaw `json:"grossExpRatio"`
+	AnnualHoldingsTurnover   FmtRaw `json:"annualHoldingsTurnover"`
+	TotalNetAssets           FmtRaw `json:"totalNetAssets"`
+}
+
+// RecommendationTrend counts analyst ratings by month, "0m" for this month
+// and "-1m" for the one before.
+type RecommendationTrend struct {
+	Trend []RecommendationPeriod `json:"trend"`
+}
+
+type RecommendationPeriod struct {
+	Period     string `json:"period"`
+	StrongBuy  int    `json:"strongBuy"`
+	Buy        int    `json:"buy"`
+	Hold       int    `json:"hold"`
+	Sell       int    `json:"sell"`
+	StrongSell int    `json:"strongSell"`
+}
+
+// UpgradeDowngradeHistory is every rating change Yahoo knows of, newest
+// first.
+type UpgradeDowngradeHistory struct {
+	History []GradeChange `json:"history"`
+}
+
+type GradeChange struct {
+	EpochGradeDate int64  `json:"epochGradeDate"`
+	Firm           string `json:"firm"`
+	ToGrade        string `json:"toGrade"`
+	FromGrade      string `json:"fromGrade"`
+	// up, down, init, main or reit.
+	Action             string  `json:"action"`
+	PriceTargetAction  string  `json:"priceTargetAction"`
+	CurrentPriceTarget float64 `json:"currentPriceTarget"`
+	PriorPriceTarget   float64 `json:"priorPriceTarget"`
+}
+
+// Ownership is the largest holders from 13F filings (institutionOwnership)
+// or fund reports (fundOwnership), largest first.
+type Ownership struct {
+	OwnershipList []Holder `json:"ownershipList"`
+}
+
+type Holder struct {
+	ReportDate   FmtRaw `json:"reportDate"`
+	Organization string `json:"organization"`
+	PctHeld      FmtRaw `json:"pctHeld"`
+	Position     FmtRaw `json:"position"`
+	Value        FmtRaw `json:"value"`
+	// Change in position since the previous report, as a fraction.
+	PctChange FmtRaw `json:"pctChange"`
+}
+
+// InsiderTransactions is the Form 4 filings Yahoo knows of, newest first.
+type InsiderTransactions struct {
+	Transactions []InsiderTransaction `json:"transactions"`
+}
+
+type InsiderTransaction struct {
+	Shares        FmtRaw `json:"shares"`
+	Value         FmtRaw `json:"value"`
+	FilerName     string `json:"filerName"`
+	FilerRelation string `json:"filerRelation"`
+	// e.g. "Sale at price 230.00 per share." Empty for grants and gifts.
+	TransactionText string `json:"transactionText"`
+	StartDate       FmtRaw `json:"startDate"`
+	// D for direct, I for indirect.
+	Ownership string `json:"ownership"`
+}
+
+// CalendarEvents has the upcoming dividend dates. exDividendDate is the same
+// as summaryDetail's; dividendDate is when it's paid.
+type CalendarEvents struct {
+	ExDividendDate FmtRaw `json:"exDividendDate"`
+	DividendDate   FmtRaw `json:"dividendDate"`
+}
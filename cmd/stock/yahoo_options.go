@@ -0,0 +1,180 @@
+package main
+
+import (
+	common "app/internal/common"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Option chains come from Yahoo's options endpoint with the same session as
+// quoteSummary. Each expiration is a separate request, so chains are kept in
+// memory for quoteCacheTTL.
+
+// How many chains are kept in memory at most.
+const maxCachedOptionChains = 500
+
+// OptionChain is one expiration's calls and puts. Unlike quoteSummary the
+// options endpoint gives plain numbers rather than {raw, fmt} pairs.
+type OptionChain struct {
+	UnderlyingSymbol string             `json:"underlyingSymbol"`
+	ExpirationDates  []int64            `json:"expirationDates"`
+	Strikes          []float64          `json:"strikes"`
+	Quote            OptionQuote        `json:"quote"`
+	Options          []OptionExpiration `json:"options"`
+}
+
+type OptionQuote struct {
+	RegularMarketPrice          float64 `json:"regularMarketPrice"`
+	Currency                    string  `json:"currency"`
+	TrailingAnnualDividendYield float64 `json:"trailingAnnualDividendYield"`
+}
+
+type OptionExpiration struct {
+	ExpirationDate int64            `json:"expirationDate"`
+	Calls          []OptionContract `json:"calls"`
+	Puts           []OptionContract `json:"puts"`
+}
+
+type OptionContract struct {
+	ContractSymbol    string  `json:"contractSymbol"`
+	Strike            float64 `json:"strike"`
+	Currency          string  `json:"currency"`
+	LastPrice         float64 `json:"lastPrice"`
+	Change            float64 `json:"change"`
+	PercentChange     float64 `json:"percentChange"`
+	Volume            float64 `json:"volume"`
+	OpenInterest      float64 `json:"openInterest"`
+	Bid               float64 `json:"bid"`
+	Ask               float64 `json:"ask"`
+	ContractSize      string  `json:"contractSize"`
+	Expiration        int64   `json:"expiration"`
+	LastTradeDate     int64   `json:"lastTradeDate"`
+	ImpliedVolatility float64 `json:"impliedVolatility"`
+	InTheMoney        bool    `json:"inTheMoney"`
+}
+
+// fetchOptionChain is a test seam.
+var fetchOptionChain = getOptionChain
+
+type optionChainKey struct {
+	ticker     common.Symbol
+	expiration int64
+}
+
+type cachedOptionChain struct {
+	chain *OptionChain
+	at    time.Time
+}
+
+var (
+	optionChainMu    sync.Mutex
+	optionChainCache = map[optionChainKey]cachedOptionChain{}
+)
+
+// getOptionChain returns ticker's chain for the expiration, a unix time as
+// Yahoo lists them, or for the nearest one if expiration is 0. An expiration
+// the nearest chain doesn't list is errInvalidExpiration.
+func getOptionChain(ctx context.Context, ticker common.Symbol, expiration int64) (*OptionChain, error) {
+	if expiration != 0 {
+		nearest, err := getOptionChain(ctx, ticker, 0)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(nearest.ExpirationDates, expiration) {
+			return nil, fmt.Errorf("%w; %s is not listed", errInvalidExpiration, expirationDate(expiration))
+		}
+		if len(nearest.Options) > 0 && nearest.Options[0].ExpirationDate == expiration {
+			return nearest, nil
+		}
+	}
+
+	key := optionChainKey{ticker, expiration}
+	optionChainMu.Lock()
+	c, ok := optionChainCache[key]
+	optionChainMu.Unlock()
+	if ok && time.Since(c.at) < quoteCacheTTL {
+		return c.chain, nil
+	}
+
+	body, status, err := requestWithCrumb(ctx, "options", func(session *yahooSession) ([]byte, int, error) {
+		optionsURL := fmt.Sprintf("%s/v7/finance/options/%s?crumb=%s", yahooQueryBaseURL, ticker.PathEscape(), session.Crumb)
+		if expiration > 0 {
+			optionsURL += fmt.Sprintf("&date=%d", expiration)
+		}
+		return requestYahoo(ctx, "options", ticker, optionsURL, session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	chain, err := parseOptionChain(ticker, body)
+	if errors.Is(err, ErrSymbolNotFound) {
+		upstreamErrors.Inc("options", upstreamErrNotFound)
+		return nil, err
+	}
+	if err != nil {
+		if status != http.StatusOK {
+			upstreamErrors.Inc("options", upstreamErrStatus)
+			return nil, fmt.Errorf("options returned status %d: %v", status, err)
+		}
+		upstreamErrors.Inc("options", upstreamErrDecode)
+		return nil, err
+	}
+
+	rememberOptionChain(key, chain)
+	return chain, nil
+}
+
+// rememberOptionChain caches chain, making room the way rememberSearch does.
+func rememberOptionChain(key optionChainKey, chain *OptionChain) {
+	optionChainMu.Lock()
+	defer optionChainMu.Unlock()
+	if len(optionChainCache) >= maxCachedOptionChains {
+		for k, c := range optionChainCache {
+			if time.Since(c.at) >= quoteCacheTTL {
+				delete(optionChainCache, k)
+			}
+		}
+		if len(optionChainCache) >= maxCachedOptionChains {
+			optionChainCache = map[optionChainKey]cachedOptionChain{}
+		}
+	}
+	optionChainCache[key] = cachedOptionChain{chain: chain, at: time.Now()}
+}
+
+// withOptions adds the nearest expiration's chain to result. Without it the
+// stock page just leaves out its options summary, so errors are only logged.
+func withOptions(ctx context.Context, ticker common.Symbol, result *Result) *Result {
+	if !result.hasOptions() {
+		return result
+	}
+	chain, err := fetchOptionChain(ctx, ticker, 0)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not fetch option chain", "ticker", ticker, "err", err)
+		return result
+	}
+	withChain := *result
+	withChain.Options = chain
+	return &withChain
+}
+
+func parseOptionChain(ticker common.Symbol, body []byte) (*OptionChain, error) {
+	var resp struct {
+		OptionChain struct {
+			Result []OptionChain `json:"result"`
+		} `json:"optionChain"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("error unmarshalling options JSON: %v", err)
+	}
+	if len(resp.OptionChain.Result) == 0 {
+		return nil, fmt.Errorf("no options data for ticker %s: %w", ticker, ErrSymbolNotFound)
+	}
+	return &resp.OptionChain.Result[0], nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// A crumb and its cookies stay valid for a long while, so we share one session
+// across fetches instead of launching Chrome for every ticker.
+const yahooSessionTTL = 30 * time.Minute
+
+type yahooSession struct {
+	Crumb        string
+	CookieHeader string
+	Created      time.Time
+}
+
+var (
+	yahooSessionMu      sync.Mutex
+	currentYahooSession *yahooSession
+	// Set when Yahoo rejected the last session, for the refresh metric.
+	yahooSessionRejected bool
+	// Error from the most recent attempt to create a session, for readiness.
+	lastYahooSessionErr error
+	// newYahooSession is replaced in tests to avoid launching Chrome.
+	newYahooSession = launchChromeForSession
+)
+
+// getYahooSession returns the shared session, creating a new one when there
+// is none or it has expired. Callers block while Chrome runs, so concurrent
+// fetches share a single launch. The wait for the upstream budget happens
+// without the lock, so a caller that gives up doesn't hold up the others.
+func getYahooSession(ctx context.Context) (*yahooSession, error) {
+	if session := freshYahooSession(); session != nil {
+		return session, nil
+	}
+	// A launch loads a Yahoo page, so it spends from the budget too.
+	if err := waitForUpstream(ctx); err != nil {
+		return nil, err
+	}
+
+	yahooSessionMu.Lock()
+	defer yahooSessionMu.Unlock()
+	if yahooSessionFresh() {
+		// Someone else launched while we waited.
+		upstreamBudget.Release("yahoo")
+		return currentYahooSession, nil
+	}
+	reason := "initial"
+	if currentYahooSession != nil {
+		reason = "expired"
+	} else if yahooSessionRejected {
+		reason = "rejected"
+	}
+	slog.InfoContext(ctx, "Creating Yahoo session", "reason", reason)
+	session, err := newYahooSession()
+	lastYahooSessionErr = err
+	if err != nil {
+		upstreamErrors.Inc("session", upstreamErrSession)
+		slog.ErrorContext(ctx, "Could not create Yahoo session", "err", err)
+		return nil, err
+	}
+	crumbRefreshes.Inc(reason)
+	currentYahooSession = session
+	yahooSessionRejected = false
+	return session, nil
+}
+
+// freshYahooSession returns the shared session if it hasn't expired.
+func freshYahooSession() *yahooSession {
+	yahooSessionMu.Lock()
+	defer yahooSessionMu.Unlock()
+	if yahooSessionFresh() {
+		return currentYahooSession
+	}
+	return nil
+}
+
+// The caller holds yahooSessionMu.
+func yahooSessionFresh() bool {
+	return currentYahooSession != nil && time.Since(currentYahooSession.Created) < yahooSessionTTL
+}
+
+// yahooSessionError returns the error from the last attempt to create a
+// session, or nil if it succeeded or none has been made yet.
+func yahooSessionError() error {
+	yahooSessionMu.Lock()
+	defer yahooSessionMu.Unlock()
+	return lastYahooSessionErr
+}
+
+// invalidateYahooSession drops the shared session if it is still s, so the
+// next fetch gets a fresh crumb. Used when Yahoo rejects the crumb.
+func invalidateYahooSession(s *yahooSession) {
+	yahooSessionMu.Lock()
+	defer yahooSessionMu.Unlock()
+	if currentYahooSession == s {
+		currentYahooSession = nil
+		yahooSessionRejected = true
+	}
+}
+
+// launchChromeForSession loads a quote page in headless Chrome to obtain a
+// crumb token and the cookies that go with it.
+func launchChromeForSession() (session *yahooSession, err error) {
+	defer func() {
+		if err != nil {
+			chromeLaunches.Inc("error")
+		} else {
+			chromeLaunches.Inc("ok")
+		}
+	}()
+
+	// Create temp directories for Chrome data.
+	// Required for the chrome/chromium headless request to work.
+	userDataDir := filepath.Join(g_dataDir, "chrome-user-data")
+	if err := os.MkdirAll(userDataDir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create chrome user data dir: %v", err)
+	}
+
+	slog.Info("Running headless Chrome for a new Yahoo session", "user_data_dir", userDataDir)
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", true),
+		chromedp.Flag("disable-gpu", true),
+		chromedp.Flag("user-data-dir", userDataDir),
+		chromedp.UserDataDir(userDataDir),
+		//chromedp.Flag("no-sandbox", true),
+		//chromedp.Flag("disable-setuid-sandbox", true),
+	)
+
+	chromeProcesses.Add(1)
+	defer chromeProcesses.Done()
+
+	// Cancelling upstreamCtx at shutdown kills the Chrome process tree.
+	allocCtx, cancel := chromedp.NewExecAllocator(upstreamCtx, opts...)
+	defer cancel()
+
+	ctx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+
+	if err := chromedp.Run(ctx, network.Enable()); err != nil {
+		return nil, fmt.Errorf("could not start chrome: %v", err)
+	}
+
+	// All this is chrome/chromium fiasco is to get a crumb token so we can
+	// read the ticker data. Any quote page will do.
+	var crumb string
+	if err := chromedp.Run(ctx,
+		chromedp.Navigate("https://finance.yahoo.com/quote/AAPL"),
+		chromedp.Sleep(3*time.Second),
+		chromedp.Evaluate(`window.YAHOO && window.YAHOO.context && window.YAHOO.context.user && window.YAHOO.context.user.crumb || ""`, &crumb),
+	); err != nil {
+		return nil, fmt.Errorf("could not load yahoo quote page: %v", err)
+	}
+
+	// Without a crumb token, we can't read the ticker data
+	if crumb == "" {
+		// Hmm, maybe Yahoo changed the page format?
+		return nil, fmt.Errorf("crumb token not found")
+	}
+
+	// Unfortunately, the crumb is not enough to fetch the data,
+	// we need the cookies to pass as well.
+	//
+	// Fetch cookies properly inside chromedp.Run and ActionFunc
+	var cookies []*network.Cookie
+	if err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		cookies, err = network.GetCookies().WithURLs([]string{"https://finance.yahoo.com"}).Do(ctx)
+		return err
+	})); err != nil {
+		return nil, fmt.Errorf("could not read yahoo cookies: %v", err)
+	}
+
+	var cookiePairs []string
+	for _, c := range cookies {
+		cookiePairs = append(cookiePairs, fmt.Sprintf("%s=%s", c.Name, c.Value))
+	}
+	cookieHeader := strings.Join(cookiePairs, "; ")
+	// The crumb and cookies are credentials; never log them.
+	slog.Info("Yahoo session ready", "cookie_count", len(cookies))
+
+	return &yahooSession{Crumb: crumb, CookieHeader: cookieHeader, Created: time.Now()}, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	common "app/internal/common"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testQuoteSummary = `{"quoteSummary":{"result":[{"financialData":{"currentPrice":{"raw":190}}}],"error":null}}`
+
+// withFakeYahoo points the fetch path at a local server and a fake session
+// source, counting how many sessions get created.
+func withFakeYahoo(t *testing.T, handler http.HandlerFunc) *int32 {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	var sessions int32
+	origURL, origSession, origDir := yahooQueryBaseURL, newYahooSession, g_dataDir
+	yahooQueryBaseURL = server.URL
+	g_dataDir = t.TempDir()
+	newYahooSession = func() (*yahooSession, error) {
+		n := atomic.AddInt32(&sessions, 1)
+		return &yahooSession{Crumb: fmt.Sprintf("crumb%d", n), Created: time.Now()}, nil
+	}
+	currentYahooSession, lastYahooSessionErr = nil, nil
+	optionChainCache = map[optionChainKey]cachedOptionChain{}
+	t.Cleanup(func() {
+		yahooQueryBaseURL, newYahooSession, g_dataDir = origURL, origSession, origDir
+		currentYahooSession, lastYahooSessionErr = nil, nil
+		optionChainCache = map[optionChainKey]cachedOptionChain{}
+	})
+	return &sessions
+}
+
+func TestGetStockMetricsSharesSession(t *testing.T) {
+	var requests int32
+	sessions := withFakeYahoo(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "quoteSummary") {
+			atomic.AddInt32(&requests, 1)
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(testQuoteSummary))
+	})
+
+	var wg sync.WaitGroup
+	for _, ticker := range []common.Symbol{"AAPL", "AAPL", "AAPL", "MSFT", "GOOG"} {
+		wg.Add(1)
+		go func(ticker common.Symbol) {
+			defer wg.Done()
+			result, err := getStockMetrics(context.Background(), ticker)
+			if err != nil || result.FinancialData.CurrentPrice.Raw != 190 {
+				t.Errorf("getStockMetrics(%s) = %+v, %v", ticker, result, err)
+			}
+		}(ticker)
+	}
+	wg.Wait()
+
+	if *sessions != 1 {
+		t.Errorf("created %d sessions, want 1 shared session", *sessions)
+	}
+	if requests != 3 {
+		t.Errorf("made %d upstream requests, want 3 (one per distinct ticker)", requests)
+	}
+
+	// Served from the hourly cache now.
+	if _, err := getStockMetrics(context.Background(), "AAPL"); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 3 {
+		t.Errorf("made %d upstream requests after cache hit, want 3", requests)
+	}
+}
+
+func TestGetStockMetricsEscapesIndexSymbols(t *testing.T) {
+	var path string
+	withFakeYahoo(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "quoteSummary") {
+			path = r.URL.EscapedPath()
+		}
+		w.Write([]byte(testQuoteSummary))
+	})
+	if _, err := getStockMetrics(context.Background(), "^GSPC"); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v10/finance/quoteSummary/%5EGSPC" {
+		t.Errorf("requested %s", path)
+	}
+	if files, _ := quoteCacheFiles(stockCacheDir(), "^GSPC"); len(files) != 1 || !strings.HasPrefix(filepath.Base(files[0]), "_IGSPC-") {
+		t.Errorf("cache files = %v", files)
+	}
+}
+
+func TestGetStockMetricsRefreshesRejectedCrumb(t *testing.T) {
+	sessions := withFakeYahoo(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("crumb") == "crumb1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(testQuoteSummary))
+	})
+
+	if _, err := getStockMetrics(context.Background(), "AAPL"); err != nil {
+		t.Fatal(err)
+	}
+	if *sessions != 2 {
+		t.Errorf("created %d sessions, want 2 after a rejected crumb", *sessions)
+	}
+}
+
+func TestUpstreamLogsCarryRequestIDAndNoSecrets(t *testing.T) {
+	withFakeYahoo(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testQuoteSummary))
+	})
+	saved := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(saved) })
+	var buf bytes.Buffer
+	if err := common.SetupLogging(&buf, "json", "debug"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := common.WithRequestID(context.Background(), "req-1")
+	if _, err := getStockMetrics(ctx, "AAPL"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), `"msg":"quoteSummary response"`) || !strings.Contains(buf.String(), `"request_id":"req-1"`) {
+		t.Errorf("upstream log not tagged with the request ID:\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), "crumb1") {
+		t.Errorf("crumb leaked into the log:\n%s", buf.String())
+	}
+}
@@ -6,6 +6,8 @@ require (
 	github.com/chromedp/cdproto v0.0.0-20250803210736-d308e07a266d
 	github.com/chromedp/chromedp v0.14.2
 	github.com/coreos/go-systemd v0.0.0-20191104093116-d3cd4ed1dbcf
+	golang.org/x/crypto v0.43.0
+	gopkg.in/yaml.v3 v3.0.1
 	maragu.dev/gomponents v1.0.0
 )
 
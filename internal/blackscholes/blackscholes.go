@@ -0,0 +1,160 @@
+// Package blackscholes prices European options and their Greeks with the
+// Black-Scholes-Merton model, and backs implied volatility out of a market
+// price. Rates, dividend yields and volatilities are annual fractions.
+package blackscholes
+
+import (
+	"errors"
+	"math"
+)
+
+type Kind int
+
+const (
+	Call Kind = iota
+	Put
+)
+
+func (k Kind) String() string {
+	if k == Put {
+		return "put"
+	}
+	return "call"
+}
+
+// ErrNoVolatility means no volatility gives the price, because it is outside
+// the bounds any option can trade at.
+var ErrNoVolatility = errors.New("price is outside the option's no-arbitrage bounds")
+
+type Params struct {
+	Spot   float64
+	Strike float64
+	// Years to expiry.
+	Time float64
+	// Continuously compounded risk-free rate and dividend yield.
+	Rate     float64
+	Dividend float64
+	// Ignored by ImpliedVolatility.
+	Volatility float64
+}
+
+type Greeks struct {
+	Delta float64
+	Gamma float64
+	// Change in price per year; divide by 365 for per calendar day.
+	Theta float64
+	// Change in price for a 1.00 change in volatility; divide by 100 for
+	// per volatility point.
+	Vega float64
+	// Change in price for a 1.00 change in the rate.
+	Rho float64
+}
+
+func normCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+func normPDF(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
+
+// expired reports whether there's no time value left to model, in which case
+// the option is worth what it would pay if exercised now.
+func (p Params) expired() bool {
+	return p.Time <= 0 || p.Volatility <= 0
+}
+
+func (p Params) d1d2() (float64, float64) {
+	sqrtT := math.Sqrt(p.Time)
+	d1 := (math.Log(p.Spot/p.Strike) + (p.Rate-p.Dividend+p.Volatility*p.Volatility/2)*p.Time) / (p.Volatility * sqrtT)
+	return d1, d1 - p.Volatility*sqrtT
+}
+
+// Price returns the option's theoretical value.
+func (p Params) Price(kind Kind) float64 {
+	if p.expired() {
+		return p.intrinsic(kind)
+	}
+	d1, d2 := p.d1d2()
+	spot := p.Spot * math.Exp(-p.Dividend*p.Time)
+	strike := p.Strike * math.Exp(-p.Rate*p.Time)
+	if kind == Put {
+		return strike*normCDF(-d2) - spot*normCDF(-d1)
+	}
+	return spot*normCDF(d1) - strike*normCDF(d2)
+}
+
+func (p Params) intrinsic(kind Kind) float64 {
+	if kind == Put {
+		return math.Max(p.Strike-p.Spot, 0)
+	}
+	return math.Max(p.Spot-p.Strike, 0)
+}
+
+// Greeks returns the option's sensitivities. An expired option only has a
+// delta, 1 or -1 in the money and 0 out of it.
+func (p Params) Greeks(kind Kind) Greeks {
+	if p.expired() {
+		var g Greeks
+		if p.intrinsic(kind) > 0 {
+			g.Delta = 1
+			if kind == Put {
+				g.Delta = -1
+			}
+		}
+		return g
+	}
+	d1, d2 := p.d1d2()
+	sqrtT := math.Sqrt(p.Time)
+	divDiscount := math.Exp(-p.Dividend * p.Time)
+	rateDiscount := math.Exp(-p.Rate * p.Time)
+	decay := -p.Spot * divDiscount * normPDF(d1) * p.Volatility / (2 * sqrtT)
+
+	g := Greeks{
+		Gamma: divDiscount * normPDF(d1) / (p.Spot * p.Volatility * sqrtT),
+		Vega:  p.Spot * divDiscount * normPDF(d1) * sqrtT,
+	}
+	if kind == Put {
+		g.Delta = divDiscount * (normCDF(d1) - 1)
+		g.Theta = decay + p.Rate*p.Strike*rateDiscount*normCDF(-d2) - p.Dividend*p.Spot*divDiscount*normCDF(-d1)
+		g.Rho = -p.Strike * p.Time * rateDiscount * normCDF(-d2)
+	} else {
+		g.Delta = divDiscount * normCDF(d1)
+		g.Theta = decay - p.Rate*p.Strike*rateDiscount*normCDF(d2) + p.Dividend*p.Spot*divDiscount*normCDF(d1)
+		g.Rho = p.Strike * p.Time * rateDiscount * normCDF(d2)
+	}
+	return g
+}
+
+// Volatilities ImpliedVolatility searches between.
+const (
+	minVolatility = 1e-6
+	maxVolatility = 10.0
+)
+
+// ImpliedVolatility returns the volatility at which the option is worth
+// price. The price rises with volatility, so it bisects, which always
+// converges where Newton's method can overshoot far from the money.
+func ImpliedVolatility(kind Kind, p Params, price float64) (float64, error) {
+	if p.Time <= 0 || p.Spot <= 0 || p.Strike <= 0 {
+		return 0, ErrNoVolatility
+	}
+	lo, hi := minVolatility, maxVolatility
+	p.Volatility = lo
+	if price <= p.Price(kind) {
+		return 0, ErrNoVolatility
+	}
+	p.Volatility = hi
+	if price >= p.Price(kind) {
+		return 0, ErrNoVolatility
+	}
+	for i := 0; i < 100 && hi-lo > 1e-8; i++ {
+		p.Volatility = (lo + hi) / 2
+		if p.Price(kind) < price {
+			lo = p.Volatility
+		} else {
+			hi = p.Volatility
+		}
+	}
+	return (lo + hi) / 2, nil
+}
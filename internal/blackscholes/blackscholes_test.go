@@ -0,0 +1,105 @@
+package blackscholes
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance
+}
+
+// Examples from Hull, Options, Futures and Other Derivatives.
+func TestPrice(t *testing.T) {
+	p := Params{Spot: 42, Strike: 40, Time: 0.5, Rate: 0.1, Volatility: 0.2}
+	if got := p.Price(Call); !near(got, 4.76, 0.005) {
+		t.Errorf("call = %v, want 4.76", got)
+	}
+	if got := p.Price(Put); !near(got, 0.81, 0.005) {
+		t.Errorf("put = %v, want 0.81", got)
+	}
+
+	// Put-call parity with a dividend: C - P = S e^-qT - K e^-rT.
+	p = Params{Spot: 100, Strike: 95, Time: 0.75, Rate: 0.05, Dividend: 0.03, Volatility: 0.35}
+	parity := p.Spot*math.Exp(-p.Dividend*p.Time) - p.Strike*math.Exp(-p.Rate*p.Time)
+	if got := p.Price(Call) - p.Price(Put); !near(got, parity, 1e-9) {
+		t.Errorf("C - P = %v, want %v", got, parity)
+	}
+
+	// Expired, or no volatility: the intrinsic value.
+	p = Params{Spot: 110, Strike: 100, Volatility: 0.2}
+	if got := p.Price(Call); got != 10 {
+		t.Errorf("expired call = %v, want 10", got)
+	}
+	if got := p.Price(Put); got != 0 {
+		t.Errorf("expired put = %v, want 0", got)
+	}
+}
+
+func TestGreeks(t *testing.T) {
+	// Hull's running example: S=49, K=50, r=5%, sigma=20%, 20 weeks.
+	p := Params{Spot: 49, Strike: 50, Time: 0.3846, Rate: 0.05, Volatility: 0.2}
+	g := p.Greeks(Call)
+	for _, tt := range []struct {
+		name           string
+		got, want, tol float64
+	}{
+		{"delta", g.Delta, 0.522, 0.001},
+		{"gamma", g.Gamma, 0.066, 0.001},
+		{"theta", g.Theta, -4.31, 0.01},
+		{"vega", g.Vega, 12.1, 0.05},
+		{"rho", g.Rho, 8.91, 0.01},
+	} {
+		if !near(tt.got, tt.want, tt.tol) {
+			t.Errorf("call %s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	put := p.Greeks(Put)
+	if !near(put.Delta, g.Delta-1, 1e-9) {
+		t.Errorf("put delta = %v, want call delta - 1", put.Delta)
+	}
+	if put.Gamma != g.Gamma || put.Vega != g.Vega {
+		t.Errorf("put gamma/vega = %v/%v, want the call's", put.Gamma, put.Vega)
+	}
+
+	expired := Params{Spot: 90, Strike: 100}
+	if got := expired.Greeks(Put); got != (Greeks{Delta: -1}) {
+		t.Errorf("expired ITM put = %+v", got)
+	}
+	if got := expired.Greeks(Call); got != (Greeks{}) {
+		t.Errorf("expired OTM call = %+v", got)
+	}
+}
+
+func TestImpliedVolatility(t *testing.T) {
+	for _, kind := range []Kind{Call, Put} {
+		for _, vol := range []float64{0.05, 0.2, 0.8, 2.5} {
+			for _, strike := range []float64{60, 100, 150} {
+				p := Params{Spot: 100, Strike: strike, Time: 0.25, Rate: 0.04, Dividend: 0.01, Volatility: vol}
+				got, err := ImpliedVolatility(kind, p, p.Price(kind))
+				if err != nil || !near(got, vol, 1e-4) {
+					// Far from the money at low vol there's no time value
+					// left, so the price says nothing about volatility.
+					floor := p
+					floor.Volatility = minVolatility
+					if p.Price(kind)-floor.Price(kind) < 1e-6 && errors.Is(err, ErrNoVolatility) {
+						continue
+					}
+					t.Errorf("%s K=%v vol=%v: IV = %v, %v", kind, strike, vol, got, err)
+				}
+			}
+		}
+	}
+
+	p := Params{Spot: 100, Strike: 90, Time: 0.5, Rate: 0.05}
+	for _, price := range []float64{5, 100, -1} {
+		if _, err := ImpliedVolatility(Call, p, price); !errors.Is(err, ErrNoVolatility) {
+			t.Errorf("IV for call at %v: err = %v, want ErrNoVolatility", price, err)
+		}
+	}
+	if _, err := ImpliedVolatility(Call, Params{Spot: 100, Strike: 90}, 12); !errors.Is(err, ErrNoVolatility) {
+		t.Errorf("IV at expiry: err = %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package common
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidCurrency = errors.New("currency must be a three letter ISO 4217 code, e.g. USD")
+
+var currencyRe = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Symbols for the currencies people are most likely to look at. Others are
+// shown by their ISO code.
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"CNY": "CN¥",
+	"INR": "₹",
+	"KRW": "₩",
+	"TWD": "NT$",
+	"HKD": "HK$",
+	"CAD": "CA$",
+	"AUD": "A$",
+	"NZD": "NZ$",
+	"SGD": "S$",
+	"BRL": "R$",
+	"MXN": "MX$",
+	"ILS": "₪",
+	"CHF": "CHF ",
+	"SEK": "SEK ",
+	"NOK": "NOK ",
+	"DKK": "DKK ",
+	"ZAR": "R ",
+}
+
+// Yahoo quotes some exchanges in a minor unit: London in pence (GBp),
+// Johannesburg in cents (ZAc) and Tel Aviv in agorot (ILA).
+var minorUnits = map[string]string{
+	"GBp": "GBP",
+	"GBX": "GBP",
+	"ZAc": "ZAR",
+	"ZAC": "ZAR",
+	"ILA": "ILS",
+}
+
+// ParseCurrency validates an ISO 4217 code, in any case.
+func ParseCurrency(s string) (string, error) {
+	code := strings.ToUpper(strings.TrimSpace(s))
+	if !currencyRe.MatchString(code) {
+		return "", ErrInvalidCurrency
+	}
+	return code, nil
+}
+
+// NormalizeCurrency turns a currency as Yahoo reports it into its ISO code
+// and the factor that converts amounts into it, e.g. GBp is GBP and 0.01.
+func NormalizeCurrency(code string) (string, float64) {
+	if iso, ok := minorUnits[code]; ok {
+		return iso, 0.01
+	}
+	return strings.ToUpper(code), 1
+}
+
+// CurrencySymbol returns the symbol for an ISO code, or the code followed by
+// a space if it has none we know of.
+func CurrencySymbol(code string) string {
+	if s, ok := currencySymbols[code]; ok {
+		return s
+	}
+	if code == "" {
+		return ""
+	}
+	return code + " "
+}
+
+// FormatCurrency is FormatLargeNumber with the currency's symbol in front,
+// e.g. $1.23B or -NT$45.00.
+func FormatCurrency(n float64, code string) string {
+	if n < 0 {
+		return "-" + CurrencySymbol(code) + FormatLargeNumber(-n)
+	}
+	return CurrencySymbol(code) + FormatLargeNumber(n)
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseCurrency(t *testing.T) {
+	for _, tt := range []struct {
+		input, want string
+		err         error
+	}{
+		{"USD", "USD", nil},
+		{" eur ", "EUR", nil},
+		{"", "", ErrInvalidCurrency},
+		{"US", "", ErrInvalidCurrency},
+		{"USDX", "", ErrInvalidCurrency},
+		{"U$D", "", ErrInvalidCurrency},
+	} {
+		got, err := ParseCurrency(tt.input)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("ParseCurrency(%q) = %q, %v; want %q, %v", tt.input, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestNormalizeCurrency(t *testing.T) {
+	for _, tt := range []struct {
+		input, want string
+		scale       float64
+	}{
+		{"USD", "USD", 1},
+		{"twd", "TWD", 1},
+		{"GBp", "GBP", 0.01},
+		{"ZAc", "ZAR", 0.01},
+		{"ILA", "ILS", 0.01},
+	} {
+		got, scale := NormalizeCurrency(tt.input)
+		if got != tt.want || scale != tt.scale {
+			t.Errorf("NormalizeCurrency(%q) = %q, %v; want %q, %v", tt.input, got, scale, tt.want, tt.scale)
+		}
+	}
+}
+
+func TestFormatCurrency(t *testing.T) {
+	for _, tt := range []struct {
+		n        float64
+		code     string
+		expected string
+	}{
+		{1_234_000_000, "USD", "$1.23B"},
+		{45, "TWD", "NT$45.00"},
+		{-1500, "EUR", "-€1.50K"},
+		{2_500_000, "CHF", "CHF 2.50M"},
+		{10, "XYZ", "XYZ 10.00"},
+		{10, "", "10.00"},
+	} {
+		if got := FormatCurrency(tt.n, tt.code); got != tt.expected {
+			t.Errorf("FormatCurrency(%v, %q) = %q; want %q", tt.n, tt.code, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+//go:build linux
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+)
+
+// DiskSpaceCheck fails when the filesystem holding path has less than
+// minFreeBytes available to unprivileged users.
+func DiskSpaceCheck(path string, minFreeBytes uint64) HealthCheck {
+	return func(ctx context.Context) error {
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(path, &st); err != nil {
+			return fmt.Errorf("statfs %s: %v", path, err)
+		}
+		free := st.Bavail * uint64(st.Bsize)
+		if free < minFreeBytes {
+			return fmt.Errorf("only %s bytes free on %s, need %s", FormatLargeNumber(float64(free)), path, FormatLargeNumber(float64(minFreeBytes)))
+		}
+		return nil
+	}
+}
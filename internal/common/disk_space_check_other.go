@@ -0,0 +1,12 @@
+//go:build !linux
+
+package common
+
+import "context"
+
+// DiskSpaceCheck is only implemented on Linux, where the service runs.
+func DiskSpaceCheck(path string, minFreeBytes uint64) HealthCheck {
+	return func(ctx context.Context) error {
+		return nil
+	}
+}
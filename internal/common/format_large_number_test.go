@@ -1,4 +1,4 @@
-package main
+package common
 
 import (
 	"testing"
@@ -28,9 +28,9 @@ func TestFormatLargeNumber(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		got := formatLargeNumber(tt.input)
+		got := FormatLargeNumber(tt.input)
 		if got != tt.expected {
-			t.Errorf("formatLargeNumber(%v) = %v; want %v", tt.input, got, tt.expected)
+			t.Errorf("FormatLargeNumber(%v) = %v; want %v", tt.input, got, tt.expected)
 		}
 	}
 }
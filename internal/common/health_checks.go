@@ -0,0 +1,185 @@
+package common
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Subsystems register probes here. Liveness checks answer "is the process
+// working at all" and gate the systemd watchdog; readiness checks answer "can
+// it serve traffic right now". /health/ready runs both kinds.
+
+// How long a single probe may take before it counts as failed.
+const checkTimeout = 5 * time.Second
+
+// A HealthCheck returns nil when the subsystem is healthy. It should give up
+// when ctx is done.
+type HealthCheck func(ctx context.Context) error
+
+type CheckResult struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+type HealthReport struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+var (
+	checksMu        sync.Mutex
+	livenessChecks  = map[string]HealthCheck{}
+	readinessChecks = map[string]HealthCheck{}
+)
+
+// RegisterLivenessCheck adds a check that must pass for the process to be
+// considered alive. Registering the same name again replaces it.
+func RegisterLivenessCheck(name string, check HealthCheck) {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	livenessChecks[name] = check
+}
+
+// RegisterReadinessCheck adds a check that must pass before the process
+// should receive traffic.
+func RegisterReadinessCheck(name string, check HealthCheck) {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	readinessChecks[name] = check
+}
+
+// CheckLiveness runs the liveness checks.
+func CheckLiveness() HealthReport {
+	checksMu.Lock()
+	checks := copyChecks(livenessChecks)
+	checksMu.Unlock()
+	return runChecks(checks)
+}
+
+// CheckReadiness runs the liveness and readiness checks.
+func CheckReadiness() HealthReport {
+	checksMu.Lock()
+	checks := copyChecks(livenessChecks)
+	for name, check := range readinessChecks {
+		checks[name] = check
+	}
+	checksMu.Unlock()
+	return runChecks(checks)
+}
+
+func copyChecks(m map[string]HealthCheck) map[string]HealthCheck {
+	out := make(map[string]HealthCheck, len(m))
+	for name, check := range m {
+		out[name] = check
+	}
+	return out
+}
+
+// Runs checks concurrently so one slow probe doesn't delay the others.
+func runChecks(checks map[string]HealthCheck) HealthReport {
+	report := HealthReport{Status: StatusOK, Checks: make([]CheckResult, 0, len(checks))}
+	results := make(chan CheckResult, len(checks))
+	for name, check := range checks {
+		go func(name string, check HealthCheck) {
+			results <- runCheck(name, check)
+		}(name, check)
+	}
+	for range checks {
+		r := <-results
+		if r.Status != StatusOK {
+			report.Status = StatusFail
+		}
+		report.Checks = append(report.Checks, r)
+	}
+	sort.Slice(report.Checks, func(i, j int) bool { return report.Checks[i].Name < report.Checks[j].Name })
+	return report
+}
+
+func runCheck(name string, check HealthCheck) CheckResult {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("check panicked: %v", p)
+			}
+		}()
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// A check that ignores ctx is still reported on time.
+		err = fmt.Errorf("timed out after %s", checkTimeout)
+	}
+
+	r := CheckResult{Name: name, Status: StatusOK, DurationMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		r.Status = StatusFail
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// HTTPGetCheck probes our own listeners, whose certificate may be self-signed
+// or issued for the public name rather than loopback, so it isn't verified.
+var healthCheckClient = &http.Client{
+	Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+}
+
+// HTTPGetCheck fails unless a GET of url answers with a status below 500.
+func HTTPGetCheck(url string) HealthCheck {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := healthCheckClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("GET %s returned %s", url, resp.Status)
+		}
+		return nil
+	}
+}
+
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+func liveHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, CheckLiveness())
+}
+
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	if draining.Load() {
+		writeHealthReport(w, HealthReport{Status: StatusFail, Checks: []CheckResult{{Name: "draining", Status: StatusFail, Error: "shutting down"}}})
+		return
+	}
+	writeHealthReport(w, CheckReadiness())
+}
@@ -0,0 +1,106 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// resetChecks clears the registry for the duration of a test.
+func resetChecks(t *testing.T) {
+	checksMu.Lock()
+	savedLive, savedReady := livenessChecks, readinessChecks
+	livenessChecks, readinessChecks = map[string]HealthCheck{}, map[string]HealthCheck{}
+	checksMu.Unlock()
+	t.Cleanup(func() {
+		checksMu.Lock()
+		livenessChecks, readinessChecks = savedLive, savedReady
+		checksMu.Unlock()
+	})
+}
+
+func ok(context.Context) error { return nil }
+
+func TestHealthEndpoints(t *testing.T) {
+	resetChecks(t)
+	RegisterLivenessCheck("listener", ok)
+	RegisterReadinessCheck("upstream", func(context.Context) error { return errors.New("crumb missing") })
+
+	rec := httptest.NewRecorder()
+	liveHandler(rec, httptest.NewRequest("GET", "/health/live", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("/health/live status = %d, want 200", rec.Code)
+	}
+	var live HealthReport
+	json.Unmarshal(rec.Body.Bytes(), &live)
+	if live.Status != StatusOK || len(live.Checks) != 1 || live.Checks[0].Name != "listener" {
+		t.Errorf("live report = %+v", live)
+	}
+
+	rec = httptest.NewRecorder()
+	readyHandler(rec, httptest.NewRequest("GET", "/health/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/health/ready status = %d, want 503", rec.Code)
+	}
+	var ready HealthReport
+	json.Unmarshal(rec.Body.Bytes(), &ready)
+	if ready.Status != StatusFail || len(ready.Checks) != 2 {
+		t.Fatalf("ready report = %+v", ready)
+	}
+	// Sorted by name: listener, upstream.
+	if ready.Checks[1].Name != "upstream" || ready.Checks[1].Status != StatusFail || ready.Checks[1].Error != "crumb missing" {
+		t.Errorf("upstream check = %+v", ready.Checks[1])
+	}
+}
+
+func TestCheckPanicIsReported(t *testing.T) {
+	resetChecks(t)
+	RegisterLivenessCheck("boom", func(context.Context) error { panic("oops") })
+
+	report := CheckLiveness()
+	if report.Status != StatusFail || report.Checks[0].Error == "" {
+		t.Errorf("report = %+v, want failed check", report)
+	}
+}
+
+func TestHTTPGetCheck(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	check := HTTPGetCheck(server.URL)
+	if err := check(context.Background()); err != nil {
+		t.Errorf("healthy server: %v", err)
+	}
+	status = http.StatusInternalServerError
+	if err := check(context.Background()); err == nil {
+		t.Error("expected an error for a 500")
+	}
+	server.Close()
+	if err := check(context.Background()); err == nil {
+		t.Error("expected an error once the server is gone")
+	}
+}
+
+func TestDiskSpaceCheck(t *testing.T) {
+	if err := DiskSpaceCheck(t.TempDir(), 1)(context.Background()); err != nil {
+		t.Errorf("expected at least a byte free: %v", err)
+	}
+}
+
+func TestReadyFailsWhileDraining(t *testing.T) {
+	resetChecks(t)
+	SetDraining(true)
+	defer SetDraining(false)
+
+	rec := httptest.NewRecorder()
+	readyHandler(rec, httptest.NewRequest("GET", "/health/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/health/ready status while draining = %d, want 503", rec.Code)
+	}
+}
@@ -0,0 +1,66 @@
+package common
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	httpRequests = NewCounterVec("http_requests_total",
+		"HTTP requests served, by route and status code.", "route", "code")
+	httpDuration = NewHistogramVec("http_request_duration_seconds",
+		"Time taken to serve HTTP requests, by route.", DefaultBuckets, "route")
+	httpInFlight int64
+)
+
+func init() {
+	NewGaugeFunc("http_requests_in_flight", "HTTP requests currently being served.", func() float64 {
+		return float64(atomic.LoadInt64(&httpInFlight))
+	})
+}
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush lets streaming handlers keep working through the wrapper.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// InstrumentHandler records request counts and latencies for route, and keeps
+// the health server's busyness at the number of requests in flight.
+func InstrumentHandler(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetBusyness(float64(atomic.AddInt64(&httpInFlight, 1)))
+		defer func() {
+			SetBusyness(float64(atomic.AddInt64(&httpInFlight, -1)))
+		}()
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+
+		elapsed := time.Since(start)
+		httpDuration.Observe(elapsed.Seconds(), route)
+		httpRequests.Inc(route, strconv.Itoa(rec.status))
+		// The query is left out because it can carry credentials.
+		slog.InfoContext(r.Context(), "HTTP request", "method", r.Method, "path", r.URL.Path, "status", rec.status, "duration", elapsed)
+	})
+}
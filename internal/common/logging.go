@@ -0,0 +1,153 @@
+package common
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Logs go through log/slog. SetupLogging picks the handler, every record is
+// tagged with the request ID from its context, and attributes that carry
+// secrets are redacted before they're written.
+
+// logLevel can be changed at runtime from /log/level on the health port.
+var logLevel = new(slog.LevelVar)
+
+// Attribute keys whose values are never written to the log.
+var redactedKeys = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"cookies":       true,
+	"cookie_header": true,
+	"set-cookie":    true,
+	"crumb":         true,
+	"password":      true,
+	"api_key":       true,
+	"token":         true,
+	"secret":        true,
+}
+
+const redacted = "[REDACTED]"
+
+// SetupLogging installs the default slog logger. format is "text" or "json"
+// and level is any level slog understands, e.g. "debug" or "warn".
+func SetupLogging(w io.Writer, format, level string) error {
+	if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("log level %q: %v", level, err)
+	}
+	opts := &slog.HandlerOptions{Level: logLevel, ReplaceAttr: redactAttr}
+	var h slog.Handler
+	switch format {
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text", "":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return fmt.Errorf("log format %q is not text or json", format)
+	}
+	slog.SetDefault(slog.New(&requestIDHandler{h}))
+	return nil
+}
+
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if redactedKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+// requestIDHandler adds the request_id attribute to records logged with a
+// request's context.
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h *requestIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *requestIDHandler) WithGroup(name string) slog.Handler {
+	return &requestIDHandler{h.Handler.WithGroup(name)}
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID in ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDHeader is read from incoming requests and echoed on responses.
+const RequestIDHeader = "X-Request-ID"
+
+// WithRequestIDs gives every request an ID, reusing a sane incoming
+// X-Request-ID so IDs can be followed across a proxy.
+func WithRequestIDs(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		h.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
+
+// Anything else could be used to forge log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// logLevelHandler reports the log level on GET and changes it on PUT or POST,
+// e.g. curl -X PUT 'localhost:8081/log/level?level=debug'.
+func logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		level := r.URL.Query().Get("level")
+		if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+			http.Error(w, fmt.Sprintf("invalid level %q: use debug, info, warn or error", level), http.StatusBadRequest)
+			return
+		}
+		slog.Warn("Log level changed", "level", logLevel.Level().String())
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"level": logLevel.Level().String()})
+}
@@ -0,0 +1,95 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// captureLogs installs a JSON logger writing to the returned buffer.
+func captureLogs(t *testing.T, level string) *bytes.Buffer {
+	saved := slog.Default()
+	savedLevel := logLevel.Level()
+	t.Cleanup(func() {
+		slog.SetDefault(saved)
+		logLevel.Set(savedLevel)
+	})
+	var buf bytes.Buffer
+	if err := SetupLogging(&buf, "json", level); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestLogsAreRedacted(t *testing.T) {
+	buf := captureLogs(t, "info")
+	slog.Info("session", "crumb", "abc123", "Cookie", "A=1; B=2", "ticker", "AAPL")
+
+	out := buf.String()
+	if strings.Contains(out, "abc123") || strings.Contains(out, "A=1") {
+		t.Errorf("secret leaked into log: %s", out)
+	}
+	if !strings.Contains(out, `"ticker":"AAPL"`) || !strings.Contains(out, redacted) {
+		t.Errorf("unexpected log line: %s", out)
+	}
+}
+
+func TestRequestIDTagsLogs(t *testing.T) {
+	buf := captureLogs(t, "info")
+	var seen string
+	h := WithRequestIDs(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+		slog.InfoContext(r.Context(), "inside handler")
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "trace-42")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if seen != "trace-42" || rec.Header().Get(RequestIDHeader) != "trace-42" {
+		t.Errorf("request ID = %q, header %q; want the incoming trace-42", seen, rec.Header().Get(RequestIDHeader))
+	}
+	var line map[string]interface{}
+	json.Unmarshal(buf.Bytes(), &line)
+	if line["request_id"] != "trace-42" {
+		t.Errorf("log line = %s, want request_id", buf.String())
+	}
+
+	// A header that could forge log lines is replaced.
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "bad\nid")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if seen == "bad\nid" || len(seen) != 16 {
+		t.Errorf("request ID = %q, want a generated one", seen)
+	}
+
+	if RequestID(context.Background()) != "" {
+		t.Error("background context has a request ID")
+	}
+}
+
+func TestLogLevelHandler(t *testing.T) {
+	buf := captureLogs(t, "info")
+	slog.Debug("hidden")
+
+	rec := httptest.NewRecorder()
+	logLevelHandler(rec, httptest.NewRequest("PUT", "/log/level?level=debug", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"DEBUG"`) {
+		t.Fatalf("PUT = %d %s", rec.Code, rec.Body.String())
+	}
+	slog.Debug("shown")
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+		t.Errorf("level change not applied: %s", buf.String())
+	}
+
+	rec = httptest.NewRecorder()
+	logLevelHandler(rec, httptest.NewRequest("PUT", "/log/level?level=loud", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("bad level status = %d, want 400", rec.Code)
+	}
+}
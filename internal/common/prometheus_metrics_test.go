@@ -0,0 +1,80 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var (
+	testCounter   = NewCounterVec("test_events_total", "Events seen.", "kind")
+	testHistogram = NewHistogramVec("test_duration_seconds", "Durations.", []float64{0.1, 1}, "op")
+)
+
+func TestWritePrometheus(t *testing.T) {
+	testCounter.Inc("a")
+	testCounter.Add(2, `quote"d`)
+	testHistogram.Observe(0.05, "fetch")
+	testHistogram.Observe(0.5, "fetch")
+	testHistogram.Observe(5, "fetch")
+
+	var b strings.Builder
+	WritePrometheus(&b)
+	out := b.String()
+
+	for _, want := range []string{
+		"# TYPE test_events_total counter\n",
+		`test_events_total{kind="a"} 1` + "\n",
+		`test_events_total{kind="quote\"d"} 2` + "\n",
+		"# TYPE test_duration_seconds histogram\n",
+		`test_duration_seconds_bucket{op="fetch",le="0.1"} 1` + "\n",
+		`test_duration_seconds_bucket{op="fetch",le="1"} 2` + "\n",
+		`test_duration_seconds_bucket{op="fetch",le="+Inf"} 3` + "\n",
+		`test_duration_seconds_sum{op="fetch"} 5.55` + "\n",
+		`test_duration_seconds_count{op="fetch"} 3` + "\n",
+		"# TYPE go_goroutines gauge\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestLabelCountMismatchPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for missing label values")
+		}
+	}()
+	testCounter.Inc()
+}
+
+func TestInstrumentHandler(t *testing.T) {
+	var busyDuringRequest float64
+	h := InstrumentHandler("/teapot", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		busyDuringRequest = getBusyness()
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/teapot", nil))
+
+	if busyDuringRequest != 1 {
+		t.Errorf("busyness during request = %v, want 1", busyDuringRequest)
+	}
+	if got := getBusyness(); got != 0 {
+		t.Errorf("busyness after request = %v, want 0", got)
+	}
+	if got := httpRequests.Value("/teapot", "418"); got != 1 {
+		t.Errorf("http_requests_total{route=/teapot,code=418} = %v, want 1", got)
+	}
+	if got := httpDuration.Count("/teapot"); got != 1 {
+		t.Errorf("http_request_duration_seconds count = %v, want 1", got)
+	}
+
+	rec := httptest.NewRecorder()
+	metricsHandler(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), `http_requests_total{route="/teapot",code="418"} 1`) {
+		t.Errorf("/metrics missing request counter:\n%s", rec.Body.String())
+	}
+}
@@ -0,0 +1,182 @@
+package common
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a set of token buckets, one per key. Each bucket holds up to
+// burst tokens and refills at perMinute tokens a minute.
+type RateLimiter struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter, or nil when perMinute is 0. A nil limiter
+// allows everything. burst defaults to perMinute when 0.
+func NewRateLimiter(perMinute, burst int) *RateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = perMinute
+	}
+	return &RateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token for key if one is available. Otherwise it returns how
+// long until one will be.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	wait, ok := l.Reserve(key, 0)
+	return ok, wait
+}
+
+// Reserve takes a token for key, waiting in line for up to maxWait. It returns
+// how long the caller must wait before using the token. When the wait would
+// exceed maxWait nothing is taken, and the wait is returned as a retry hint.
+func (l *RateLimiter) Reserve(key string, maxWait time.Duration) (time.Duration, bool) {
+	if l == nil {
+		return 0, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	var wait time.Duration
+	if b.tokens < 1 {
+		wait = time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	if wait > maxWait {
+		return wait, false
+	}
+	// Tokens can go negative; later callers queue behind this one.
+	b.tokens--
+	return wait, true
+}
+
+// Release gives back a token taken by Reserve that was never used, e.g.
+// because the caller gave up while waiting for it.
+func (l *RateLimiter) Release(key string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = math.Min(l.burst, b.tokens+1)
+	}
+}
+
+// Drops buckets that have refilled completely, since they're the same as new.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RetryAfterSeconds rounds a wait up to whole seconds for a Retry-After header.
+func RetryAfterSeconds(wait time.Duration) int {
+	if s := int(math.Ceil(wait.Seconds())); s > 1 {
+		return s
+	}
+	return 1
+}
+
+// ParseCIDRs parses addresses or CIDR blocks. A bare address is a single host.
+func ParseCIDRs(list []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client that made r. X-Forwarded-For is
+// only believed when the connection comes from a trusted proxy, and then only
+// up to the first hop that isn't one, so clients can't spoof their address.
+func ClientIP(r *http.Request, trusted []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !containsIP(trusted, ip) {
+		return host
+	}
+
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	// The rightmost entries were added by the proxies closest to us.
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		host = hop.String()
+		if !containsIP(trusted, hop) {
+			break
+		}
+	}
+	return host
+}
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurstAndRefill(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := NewRateLimiter(60, 3)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d within burst was refused", i)
+		}
+	}
+	ok, wait := l.Allow("a")
+	if ok || wait != time.Second {
+		t.Errorf("over burst: ok=%v wait=%s, want refused with 1s wait", ok, wait)
+	}
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("another key shares a's bucket")
+	}
+
+	now = now.Add(time.Second)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Error("token not refilled after a second at 60/min")
+	}
+}
+
+func TestRateLimiterReserveQueues(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := NewRateLimiter(60, 1)
+	l.now = func() time.Time { return now }
+
+	for i, want := range []time.Duration{0, time.Second, 2 * time.Second} {
+		wait, ok := l.Reserve("k", 5*time.Second)
+		if !ok || wait != want {
+			t.Errorf("reservation %d: wait=%s ok=%v, want %s", i, wait, ok, want)
+		}
+	}
+	if wait, ok := l.Reserve("k", 2*time.Second); ok || wait != 3*time.Second {
+		t.Errorf("past max wait: wait=%s ok=%v, want refused with 3s", wait, ok)
+	}
+}
+
+func TestRateLimiterRelease(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := NewRateLimiter(60, 1)
+	l.now = func() time.Time { return now }
+
+	l.Reserve("k", 5*time.Second)
+	if wait, _ := l.Reserve("k", 5*time.Second); wait != time.Second {
+		t.Fatalf("second reservation wait = %s, want 1s", wait)
+	}
+	l.Release("k")
+	if wait, _ := l.Reserve("k", 5*time.Second); wait != time.Second {
+		t.Errorf("after release wait = %s, want 1s again", wait)
+	}
+	l.Release("k")
+	l.Release("k")
+	l.Release("k")
+	if ok, _ := l.Allow("k"); !ok {
+		t.Error("released tokens not available")
+	}
+	if ok, _ := l.Allow("k"); ok {
+		t.Error("release went past burst")
+	}
+}
+
+func TestNilRateLimiterAllowsEverything(t *testing.T) {
+	l := NewRateLimiter(0, 10)
+	if l != nil {
+		t.Fatal("0 per minute should mean no limiter")
+	}
+	if ok, _ := l.Allow("x"); !ok {
+		t.Error("nil limiter refused a request")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"127.0.0.1", "10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		remote, xff, want string
+	}{
+		{"203.0.113.5:1234", "", "203.0.113.5"},
+		// Untrusted peers can't pick their own address.
+		{"203.0.113.5:1234", "1.2.3.4", "203.0.113.5"},
+		{"127.0.0.1:1234", "198.51.100.7", "198.51.100.7"},
+		// Spoofed entries to the left of the real client are ignored.
+		{"127.0.0.1:1234", "1.2.3.4, 198.51.100.7, 10.1.2.3", "198.51.100.7"},
+		{"127.0.0.1:1234", "", "127.0.0.1"},
+		{"127.0.0.1:1234", "garbage", "127.0.0.1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.xff != "" {
+			r.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		if got := ClientIP(r, trusted); got != tt.want {
+			t.Errorf("ClientIP(%s, XFF %q) = %s, want %s", tt.remote, tt.xff, got, tt.want)
+		}
+	}
+}
+
+func TestParseCIDRsRejectsGarbage(t *testing.T) {
+	if _, err := ParseCIDRs([]string{"not-an-ip"}); err == nil {
+		t.Error("expected an error")
+	}
+}
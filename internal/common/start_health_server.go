@@ -1,21 +1,36 @@
 package common
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 var (
-	busyness float64
+	// Stored as float64 bits so handlers can update it concurrently.
+	busyness atomic.Uint64
 	version  string
 	uptime   time.Time
+	// Set during shutdown so /health/ready tells load balancers to stop
+	// sending traffic.
+	draining     atomic.Bool
+	healthServer *http.Server
 )
 
-func StartHealthServer(newVersion string, port string) error {
-	log.Printf("Health port starting on http:/%s/health", port)
+// StartHealthServer serves the health, metrics and log level endpoints on
+// port, over HTTPS when tlsConfig is set.
+func StartHealthServer(newVersion string, port string, tlsConfig *tls.Config) error {
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+	slog.Info("Health port starting", "url", scheme+"://"+port+"/health")
 
 	uptime = time.Now()
 	version = newVersion
@@ -27,23 +42,54 @@ func StartHealthServer(newVersion string, port string) error {
 	// Create a new ServeMux for this server
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", healthHandler)
+	mux.HandleFunc("/health/live", liveHandler)
+	mux.HandleFunc("/health/ready", readyHandler)
+	mux.HandleFunc("/metrics", metricsHandler)
+	mux.HandleFunc("/log/level", logLevelHandler)
+
+	healthServer = &http.Server{Addr: port, Handler: mux, TLSConfig: tlsConfig}
+	srv := healthServer
 
 	// Run the server in a goroutine so it doesn't block main
 	go func() {
-		fmt.Printf("Starting server on %s\n", port)
-		if err := http.ListenAndServe(port, mux); err != nil {
-			fmt.Printf("Failed to start server: %v\n", err)
+		var err error
+		if tlsConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("Health server failed", "addr", port, "err", err)
 		}
 	}()
 
 	return nil
 }
 
+// ShutdownHealthServer stops the health server started by StartHealthServer.
+func ShutdownHealthServer(ctx context.Context) error {
+	if healthServer == nil {
+		return nil
+	}
+	return healthServer.Shutdown(ctx)
+}
+
+// SetDraining marks the process as shutting down; readiness fails from then on.
+func SetDraining(d bool) {
+	draining.Store(d)
+}
+
+// SetBusyness sets the load reported by /health. InstrumentHandler keeps it at
+// the number of requests in flight.
 func SetBusyness(newBusyness float64) {
-	busyness = newBusyness
+	busyness.Store(math.Float64bits(newBusyness))
+}
+
+func getBusyness() float64 {
+	return math.Float64frombits(busyness.Load())
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain")
-	fmt.Fprintf(w, "busyness=%.1f\nversion=%s\nuptime=%.1f\n", busyness, version, time.Since(uptime).Seconds())
+	fmt.Fprintf(w, "busyness=%.1f\nversion=%s\nuptime=%.1f\n", getBusyness(), version, time.Since(uptime).Seconds())
 }
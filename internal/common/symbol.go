@@ -0,0 +1,85 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// MaxSymbolLen is the longest symbol accepted. Yahoo's longest, fund and
+// option symbols, are under 20.
+const MaxSymbolLen = 20
+
+// ErrInvalidSymbol is wrapped by every ParseSymbol error.
+var ErrInvalidSymbol = errors.New("invalid symbol")
+
+// Symbol is a validated ticker in Yahoo's canonical form, e.g. AAPL, BRK-B,
+// VOD.L, ^GSPC or EURUSD=X. It can't contain path separators, spaces or glob
+// characters, so it is safe to use in URLs and file names once escaped with
+// PathEscape or CacheKey.
+type Symbol string
+
+// An optional ^ for indices, then letters and digits with - between share
+// classes or pairs (BRK-B, BTC-USD), an optional exchange suffix (VOD.L) and
+// an optional =X or =F for currencies and futures. & shows up in some Indian
+// listings, e.g. M&M.NS.
+var symbolPattern = regexp.MustCompile(`^\^?[A-Z0-9][A-Z0-9&]*(-[A-Z0-9]+)*(\.[A-Z]{1,3})?(=[A-Z])?$`)
+
+// Yahoo's exchange suffixes. A dot followed by anything else is a share class
+// written the way other sites do, BRK.B for BRK-B.
+var exchangeSuffixes = map[string]bool{
+	"AS": true, "AT": true, "AX": true, "BA": true, "BD": true, "BE": true,
+	"BK": true, "BO": true, "BR": true, "CN": true, "CO": true, "DE": true,
+	"DU": true, "F": true, "HA": true, "HE": true, "HK": true, "HM": true,
+	"IC": true, "IR": true, "IS": true, "JK": true, "JO": true, "KL": true,
+	"KQ": true, "KS": true, "L": true, "LS": true, "MC": true, "MI": true,
+	"MU": true, "MX": true, "NE": true, "NS": true, "NZ": true, "OL": true,
+	"PA": true, "PR": true, "QA": true, "SA": true, "SG": true, "SI": true,
+	"SN": true, "SR": true, "SS": true, "ST": true, "SW": true, "SZ": true,
+	"T": true, "TA": true, "TO": true, "TW": true, "TWO": true, "V": true,
+	"VI": true, "WA": true,
+}
+
+// ParseSymbol validates user input and returns its canonical form: trimmed,
+// upper-cased, and with BRK.B or BRK/B share classes written BRK-B.
+func ParseSymbol(s string) (Symbol, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return "", fmt.Errorf("%w: empty", ErrInvalidSymbol)
+	}
+	if len(s) > MaxSymbolLen {
+		return "", fmt.Errorf("%w: longer than %d characters", ErrInvalidSymbol, MaxSymbolLen)
+	}
+	s = strings.ReplaceAll(s, "/", "-")
+	if i := strings.LastIndexByte(s, '.'); i > 0 && !strings.Contains(s, "=") {
+		if class := s[i+1:]; len(class) == 1 && !exchangeSuffixes[class] {
+			s = s[:i] + "-" + class
+		}
+	}
+	if !symbolPattern.MatchString(s) {
+		return "", fmt.Errorf("%w %q", ErrInvalidSymbol, s)
+	}
+	return Symbol(s), nil
+}
+
+func (s Symbol) String() string {
+	return string(s)
+}
+
+// PathEscape returns the symbol for use as a URL path segment, e.g. ^GSPC
+// becomes %5EGSPC.
+func (s Symbol) PathEscape() string {
+	return url.PathEscape(string(s))
+}
+
+// Spells out the characters that are awkward in file names. _ never appears
+// in a symbol, so no two symbols share a key.
+var cacheKeyReplacer = strings.NewReplacer("^", "_I", "=", "_E", "&", "_A")
+
+// CacheKey returns a file name safe form of the symbol: letters, digits, -, .
+// and _, never starting with a dot. Plain tickers are unchanged.
+func (s Symbol) CacheKey() string {
+	return cacheKeyReplacer.Replace(string(s))
+}
@@ -0,0 +1,118 @@
+package common
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParseSymbol(t *testing.T) {
+	valid := map[string]Symbol{
+		"aapl":      "AAPL",
+		" MSFT\n":   "MSFT",
+		"BRK-B":     "BRK-B",
+		"brk.b":     "BRK-B",
+		"BRK/B":     "BRK-B",
+		"VOD.L":     "VOD.L",
+		"shop.to":   "SHOP.TO",
+		"0700.HK":   "0700.HK",
+		"^GSPC":     "^GSPC",
+		"EURUSD=X":  "EURUSD=X",
+		"GC=F":      "GC=F",
+		"BTC-USD":   "BTC-USD",
+		"M&M.NS":    "M&M.NS",
+		"DX-Y.NYB":  "DX-Y.NYB",
+		"RDS.A":     "RDS-A",
+		"2330.TW":   "2330.TW",
+		"^STOXX50E": "^STOXX50E",
+	}
+	for in, want := range valid {
+		if got, err := ParseSymbol(in); got != want || err != nil {
+			t.Errorf("ParseSymbol(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{
+		"", "   ", "../../etc", "A B", "AAPL/../X", "^", "^^GSPC", "-AAPL", "AAPL-",
+		"A..B", ".L", "AAPL.", "BRK-B.", "EURUSD=", "EURUSD=XX", "A=X=F", "A*", "A?",
+		"[A]", "A%2F", "A\x00", "ÄPPL", "A_B", "AAPL.LONDON", strings.Repeat("A", MaxSymbolLen+1),
+	} {
+		if got, err := ParseSymbol(in); !errors.Is(err, ErrInvalidSymbol) {
+			t.Errorf("ParseSymbol(%q) = %q, %v, want ErrInvalidSymbol", in, got, err)
+		}
+	}
+}
+
+func TestSymbolEscaping(t *testing.T) {
+	tests := []struct {
+		sym        Symbol
+		path, file string
+	}{
+		{"AAPL", "AAPL", "AAPL"},
+		{"BRK-B", "BRK-B", "BRK-B"},
+		{"^GSPC", "%5EGSPC", "_IGSPC"},
+		{"EURUSD=X", "EURUSD=X", "EURUSD_EX"},
+		{"M&M.NS", "M&M.NS", "M_AM.NS"},
+	}
+	for _, tt := range tests {
+		if got := tt.sym.PathEscape(); got != tt.path {
+			t.Errorf("%s.PathEscape() = %q, want %q", tt.sym, got, tt.path)
+		}
+		if got := tt.sym.CacheKey(); got != tt.file {
+			t.Errorf("%s.CacheKey() = %q, want %q", tt.sym, got, tt.file)
+		}
+	}
+}
+
+var safeCacheKey = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+func FuzzParseSymbol(f *testing.F) {
+	for _, s := range []string{"AAPL", "brk.b", "BRK/B", "^GSPC", "EURUSD=X", "VOD.L", "M&M.NS", "../../etc", "A B", "A%2F"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		sym, err := ParseSymbol(in)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidSymbol) || sym != "" {
+				t.Fatalf("ParseSymbol(%q) = %q, %v", in, sym, err)
+			}
+			return
+		}
+		if again, err := ParseSymbol(sym.String()); again != sym || err != nil {
+			t.Fatalf("%q is not canonical: reparses to %q, %v", sym, again, err)
+		}
+		if len(sym) > MaxSymbolLen {
+			t.Fatalf("%q is longer than %d", sym, MaxSymbolLen)
+		}
+		key := sym.CacheKey()
+		if !safeCacheKey.MatchString(key) || strings.Contains(key, "..") {
+			t.Fatalf("unsafe cache key %q for %q", key, sym)
+		}
+		path := sym.PathEscape()
+		// Only an index's leading ^ needs escaping.
+		if strings.ContainsAny(strings.TrimPrefix(path, "%5E"), "/?#% ") {
+			t.Fatalf("unsafe path segment %q for %q", path, sym)
+		}
+		if back, err := url.PathUnescape(path); back != sym.String() || err != nil {
+			t.Fatalf("PathEscape(%q) = %q does not round trip", sym, path)
+		}
+	})
+}
+
+func FuzzSymbolCacheKeyUnique(f *testing.F) {
+	f.Add("^GSPC", "_IGSPC")
+	f.Add("EURUSD=X", "EURUSD-X")
+	f.Add("BRK.B", "BRK-B")
+	f.Fuzz(func(t *testing.T, a, b string) {
+		sa, errA := ParseSymbol(a)
+		sb, errB := ParseSymbol(b)
+		if errA != nil || errB != nil || sa == sb {
+			return
+		}
+		if sa.CacheKey() == sb.CacheKey() {
+			t.Fatalf("%q and %q share cache key %q", sa, sb, sa.CacheKey())
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package common
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCertReloaderPicksUpNewFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := GenerateSelfSignedCert(certFile, keyFile, []string{"first.test"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(keyFile); info.Mode().Perm() != 0600 {
+		t.Errorf("key mode = %v, want 0600", info.Mode().Perm())
+	}
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := r.GetCertificate(nil)
+	if cert.Leaf.DNSNames[0] != "first.test" {
+		t.Fatalf("names = %v", cert.Leaf.DNSNames)
+	}
+
+	// A renewal. Move the mtime on so the change shows on coarse filesystems.
+	if err := GenerateSelfSignedCert(certFile, keyFile, []string{"second.test", "127.0.0.1"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	if cert, _ := r.GetCertificate(nil); cert.Leaf.DNSNames[0] != "first.test" {
+		t.Error("files checked again before the interval")
+	}
+	r.lastCheck = r.lastCheck.Add(-certCheckInterval)
+	cert, _ = r.GetCertificate(nil)
+	if cert.Leaf.DNSNames[0] != "second.test" || len(cert.Leaf.IPAddresses) != 1 {
+		t.Errorf("after renewal names = %v %v", cert.Leaf.DNSNames, cert.Leaf.IPAddresses)
+	}
+
+	// A half-written renewal keeps the working certificate.
+	os.WriteFile(certFile, []byte("garbage"), 0644)
+	os.Chtimes(certFile, later.Add(time.Minute), later.Add(time.Minute))
+	r.lastCheck = r.lastCheck.Add(-certCheckInterval)
+	if cert, _ := r.GetCertificate(nil); cert == nil || cert.Leaf.DNSNames[0] != "second.test" {
+		t.Error("broken renewal replaced the certificate")
+	}
+
+	if _, err := NewCertReloader(certFile, keyFile); err == nil {
+		t.Error("NewCertReloader accepted a broken certificate")
+	}
+}
+
+func TestCertReloaderServesTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	GenerateSelfSignedCert(certFile, keyFile, []string{"localhost", "127.0.0.1"}, time.Hour)
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Not httptest's StartTLS, which installs its own certificate.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{
+		Handler:   WithHSTS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), 24*time.Hour),
+		TLSConfig: r.TLSConfig(),
+	}
+	go srv.ServeTLS(ln, "", "")
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get("https://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("Strict-Transport-Security"); got != "max-age=86400" {
+		t.Errorf("HSTS = %q", got)
+	}
+	if resp.TLS.PeerCertificates[0].DNSNames[0] != "localhost" {
+		t.Error("served a different certificate")
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		method, host string
+		port         int
+		want         string
+		status       int
+	}{
+		{"GET", "example.com", 443, "https://example.com/stock?symbol=AAPL", http.StatusMovedPermanently},
+		{"GET", "example.com:80", 8443, "https://example.com:8443/stock?symbol=AAPL", http.StatusMovedPermanently},
+		{"POST", "[::1]:8080", 443, "https://[::1]/stock?symbol=AAPL", http.StatusPermanentRedirect},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "http://"+tt.host+"/stock?symbol=AAPL", nil)
+		rec := httptest.NewRecorder()
+		RedirectToHTTPS(tt.port).ServeHTTP(rec, r)
+		if rec.Code != tt.status || rec.Header().Get("Location") != tt.want {
+			t.Errorf("%s %s -> %d %s, want %d %s", tt.method, tt.host, rec.Code, rec.Header().Get("Location"), tt.status, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"app/internal/oidc"
+	"app/internal/oidc/oidctest"
+)
+
+// login runs the browser's side of the flow: follow the authorize redirect and
+// pick the code and state out of the callback URL.
+func login(t *testing.T, p *oidc.Provider, state, nonce, verifier string) (code, gotState string) {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(p.AuthCodeURL(state, nonce, verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: %s, Location %q", resp.Status, resp.Header.Get("Location"))
+	}
+	return loc.Query().Get("code"), loc.Query().Get("state")
+}
+
+func TestCodeFlow(t *testing.T) {
+	idp := oidctest.NewProvider()
+	defer idp.Close()
+	ctx := context.Background()
+	p, err := oidc.Discover(ctx, idp.Config("http://app.test/auth/oidc/callback"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, nonce := oidc.RandomString(), oidc.RandomString()
+	code, state := login(t, p, "st", nonce, verifier)
+	if state != "st" {
+		t.Errorf("state = %q, want st", state)
+	}
+	claims, err := p.Exchange(ctx, code, verifier, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != idp.Subject || claims.Email != idp.Email || claims.Name != idp.Name {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	// Codes are single use.
+	if _, err := p.Exchange(ctx, code, verifier, nonce); err == nil {
+		t.Error("reused code was accepted")
+	}
+}
+
+func TestExchangeChecksPKCEAndNonce(t *testing.T) {
+	idp := oidctest.NewProvider()
+	defer idp.Close()
+	ctx := context.Background()
+	p, err := oidc.Discover(ctx, idp.Config("http://app.test/cb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := oidc.RandomString()
+	code, _ := login(t, p, "s", "n", verifier)
+	if _, err := p.Exchange(ctx, code, "wrong-verifier", "n"); err == nil {
+		t.Error("wrong PKCE verifier was accepted")
+	}
+	code, _ = login(t, p, "s", "n", verifier)
+	if _, err := p.Exchange(ctx, code, verifier, "other"); err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Errorf("mismatched nonce: err = %v", err)
+	}
+}
+
+func TestVerifyRejectsBadTokens(t *testing.T) {
+	idp := oidctest.NewProvider()
+	defer idp.Close()
+	ctx := context.Background()
+	p, err := oidc.Discover(ctx, idp.Config("http://app.test/cb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := func(change func(map[string]interface{})) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss": idp.URL, "sub": "u", "aud": []string{"other", idp.ClientID},
+			"exp": time.Now().Add(time.Hour).Unix(), "nonce": "n",
+		}
+		if change != nil {
+			change(c)
+		}
+		return c
+	}
+
+	if _, err := p.Verify(ctx, idp.Sign(claims(nil)), "n"); err != nil {
+		t.Errorf("valid token with audience list: %v", err)
+	}
+
+	other := oidctest.NewProvider()
+	defer other.Close()
+	tests := map[string]string{
+		"expired":        idp.Sign(claims(func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() })),
+		"wrong issuer":   idp.Sign(claims(func(c map[string]interface{}) { c["iss"] = "https://evil.test" })),
+		"wrong audience": idp.Sign(claims(func(c map[string]interface{}) { c["aud"] = "someone-else" })),
+		"no subject":     idp.Sign(claims(func(c map[string]interface{}) { delete(c, "sub") })),
+		"other key":      other.Sign(claims(nil)),
+		"not a jwt":      "abc.def",
+	}
+	for name, tok := range tests {
+		if _, err := p.Verify(ctx, tok, "n"); err == nil {
+			t.Errorf("%s: token was accepted", name)
+		}
+	}
+	tampered := idp.Sign(claims(nil))
+	parts := strings.Split(tampered, ".")
+	parts[1] = parts[1][:len(parts[1])-2] + "AA"
+	if _, err := p.Verify(ctx, strings.Join(parts, "."), "n"); err == nil {
+		t.Error("tampered token was accepted")
+	}
+}
+
+func TestDiscoverChecksIssuer(t *testing.T) {
+	idp := oidctest.NewProvider()
+	defer idp.Close()
+	cfg := idp.Config("http://app.test/cb")
+	cfg.Issuer += "/"
+	// The document says the issuer without the slash, which doesn't match.
+	if _, err := oidc.Discover(context.Background(), cfg); err == nil {
+		t.Error("mismatched issuer was accepted")
+	}
+}
@@ -0,0 +1,237 @@
+// Package xlsx writes simple Office Open XML spreadsheets: strings, numbers,
+// bold text and solid cell fills. That's enough for exports without pulling in
+// a full spreadsheet library.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type Cell struct {
+	// Value is written as a number if it is a float64 or int, otherwise as text.
+	Value interface{}
+	// Fill is an RGB hex color such as "C6EFCE", or empty for no fill.
+	Fill string
+	Bold bool
+}
+
+type Sheet struct {
+	Name string
+	Rows [][]Cell
+	// Optional column widths in characters, by column index.
+	ColumnWidths []float64
+}
+
+type style struct {
+	fill string
+	bold bool
+}
+
+// Write writes a workbook containing sheets to w.
+func Write(w io.Writer, sheets ...Sheet) error {
+	if len(sheets) == 0 {
+		return fmt.Errorf("xlsx: at least one sheet is required")
+	}
+
+	// Style 0 is the default. Every other fill/bold combination gets an index.
+	styles := []style{{}}
+	styleIndex := map[style]int{{}: 0}
+	for _, sheet := range sheets {
+		for _, row := range sheet.Rows {
+			for _, c := range row {
+				s := style{strings.ToUpper(c.Fill), c.Bold}
+				if _, ok := styleIndex[s]; !ok {
+					styleIndex[s] = len(styles)
+					styles = append(styles, s)
+				}
+			}
+		}
+	}
+
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name string
+		body string
+	}{
+		{"[Content_Types].xml", contentTypes(len(sheets))},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", workbook(sheets)},
+		{"xl/_rels/workbook.xml.rels", workbookRels(len(sheets))},
+		{"xl/styles.xml", stylesXML(styles)},
+	}
+	for i, sheet := range sheets {
+		files = append(files, struct {
+			name string
+			body string
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), worksheet(sheet, styleIndex)})
+	}
+
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+const rootRels = xmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+func contentTypes(sheetCount int) string {
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= sheetCount; i++ {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	b.WriteString(`</Types>`)
+	return b.String()
+}
+
+func workbook(sheets []Sheet) string {
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, sheet := range sheets {
+		name := sheet.Name
+		if name == "" {
+			name = fmt.Sprintf("Sheet%d", i+1)
+		}
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(sheetName(name)), i+1, i+1)
+	}
+	b.WriteString(`</sheets></workbook>`)
+	return b.String()
+}
+
+func workbookRels(sheetCount int) string {
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheetCount; i++ {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, sheetCount+1)
+	b.WriteString(`</Relationships>`)
+	return b.String()
+}
+
+func stylesXML(styles []style) string {
+	// Fills 0 and 1 are reserved by Excel (none and gray125).
+	fillIndex := map[string]int{}
+	var fills []string
+	for _, s := range styles {
+		if _, ok := fillIndex[s.fill]; s.fill != "" && !ok {
+			fillIndex[s.fill] = len(fills) + 2
+			fills = append(fills, s.fill)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	b.WriteString(`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>`)
+	fmt.Fprintf(&b, `<fills count="%d"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill>`, len(fills)+2)
+	for _, f := range fills {
+		fmt.Fprintf(&b, `<fill><patternFill patternType="solid"><fgColor rgb="FF%s"/><bgColor indexed="64"/></patternFill></fill>`, escape(f))
+	}
+	b.WriteString(`</fills>`)
+	b.WriteString(`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>`)
+	b.WriteString(`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>`)
+	fmt.Fprintf(&b, `<cellXfs count="%d">`, len(styles))
+	for _, s := range styles {
+		fontID, fillID := 0, 0
+		if s.bold {
+			fontID = 1
+		}
+		if s.fill != "" {
+			fillID = fillIndex[s.fill]
+		}
+		fmt.Fprintf(&b, `<xf numFmtId="0" fontId="%d" fillId="%d" borderId="0" xfId="0" applyFont="1" applyFill="1"/>`, fontID, fillID)
+	}
+	b.WriteString(`</cellXfs></styleSheet>`)
+	return b.String()
+}
+
+func worksheet(sheet Sheet, styleIndex map[style]int) string {
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if len(sheet.ColumnWidths) > 0 {
+		b.WriteString(`<cols>`)
+		for i, width := range sheet.ColumnWidths {
+			fmt.Fprintf(&b, `<col min="%d" max="%d" width="%g" customWidth="1"/>`, i+1, i+1, width)
+		}
+		b.WriteString(`</cols>`)
+	}
+	b.WriteString(`<sheetData>`)
+	for r, row := range sheet.Rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, cell := range row {
+			ref := CellRef(c, r)
+			s := styleIndex[style{strings.ToUpper(cell.Fill), cell.Bold}]
+			switch v := cell.Value.(type) {
+			case float64:
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					fmt.Fprintf(&b, `<c r="%s" s="%d"/>`, ref, s)
+					break
+				}
+				fmt.Fprintf(&b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, s, strconv.FormatFloat(v, 'f', -1, 64))
+			case int:
+				fmt.Fprintf(&b, `<c r="%s" s="%d"><v>%d</v></c>`, ref, s, v)
+			case nil:
+				fmt.Fprintf(&b, `<c r="%s" s="%d"/>`, ref, s)
+			default:
+				fmt.Fprintf(&b, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, s, escape(fmt.Sprint(v)))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// CellRef returns the A1-style reference for a zero-based column and row.
+func CellRef(col, row int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return fmt.Sprintf("%s%d", name, row+1)
+}
+
+// Excel limits sheet names to 31 characters and forbids a few symbols.
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if len(name) > 31 {
+		name = name[:31]
+	}
+	return name
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,103 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCellRef(t *testing.T) {
+	tests := []struct {
+		col, row int
+		want     string
+	}{
+		{0, 0, "A1"},
+		{25, 9, "Z10"},
+		{26, 0, "AA1"},
+		{701, 1, "ZZ2"},
+		{702, 2, "AAA3"},
+	}
+	for _, tt := range tests {
+		if got := CellRef(tt.col, tt.row); got != tt.want {
+			t.Errorf("CellRef(%d, %d) = %q, want %q", tt.col, tt.row, got, tt.want)
+		}
+	}
+}
+
+func readZip(t *testing.T, data []byte) map[string]string {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(body)
+	}
+	return files
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, Sheet{
+		Name: "AAPL: metrics",
+		Rows: [][]Cell{
+			{{Value: "Metric", Bold: true}, {Value: "Value", Bold: true}},
+			{{Value: "P/E <trailing> & more"}, {Value: 31.5, Fill: "FFC7CE"}},
+			{{Value: "Market Cap"}, {Value: 3_000_000_000_000.0, Fill: "c6efce"}},
+		},
+		ColumnWidths: []float64{20, 12},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := readZip(t, buf.Bytes())
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		body, ok := files[name]
+		if !ok {
+			t.Fatalf("missing part %s", name)
+		}
+		if err := xml.Unmarshal([]byte(body), new(interface{})); err != nil {
+			t.Errorf("%s is not well-formed XML: %v", name, err)
+		}
+	}
+
+	sheet := files["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`P/E &lt;trailing&gt; &amp; more`,
+		`<v>31.5</v>`,
+		`<v>3000000000000</v>`,
+		`<col min="1" max="1" width="20" customWidth="1"/>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet is missing %q", want)
+		}
+	}
+	if !strings.Contains(files["xl/workbook.xml"], `name="AAPL_ metrics"`) {
+		t.Errorf("sheet name not sanitized: %s", files["xl/workbook.xml"])
+	}
+
+	styles := files["xl/styles.xml"]
+	if !strings.Contains(styles, `rgb="FFFFC7CE"`) || !strings.Contains(styles, `rgb="FFC6EFCE"`) {
+		t.Errorf("fills missing from styles: %s", styles)
+	}
+	// Default, bold, and two fills.
+	if !strings.Contains(styles, `<cellXfs count="4">`) {
+		t.Errorf("unexpected cellXfs: %s", styles)
+	}
+}
+
+func TestWriteRequiresSheet(t *testing.T) {
+	if err := Write(io.Discard); err == nil {
+		t.Error("expected an error for a workbook with no sheets")
+	}
+}